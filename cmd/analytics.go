@@ -0,0 +1,65 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	rediscache "drexel.edu/voter-api/pkg/storage/redis"
+	"github.com/spf13/cobra"
+)
+
+var analyticsTenant string
+
+// analyticsCmd represents the analytics command
+var analyticsCmd = &cobra.Command{
+	Use:   "analytics",
+	Short: "maintains the aggregates behind the /analytics endpoints",
+}
+
+// analyticsRebuildCmd represents the analytics rebuild command
+var analyticsRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "rebuilds the analytics aggregates from the voters",
+	Long: `Deletes every analytics:* key and indexes every voter again. Every
+write keeps the aggregates up to date, rebuild them once for voters
+stored before the aggregates existed, or after editing voters in redis
+by hand.
+
+Voters written while the rebuild runs can be counted twice or not at
+all, run it when the api is idle.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		connectCtx, cancel := context.WithTimeout(cmd.Context(), cfg.Redis.ConnectTimeout)
+		redisCache, err := rediscache.NewWithRetry(connectCtx, cfg.Redis.Address)
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer redisCache.Close()
+
+		if err := encrypt(redisCache); err != nil {
+			return err
+		}
+
+		ctx, err := tenantContext(cmd.Context(), redisCache, analyticsTenant)
+		if err != nil {
+			return err
+		}
+
+		indexed, err := redisCache.RebuildAnalytics(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "indexed %d voters\n", indexed)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(analyticsCmd)
+	analyticsCmd.AddCommand(analyticsRebuildCmd)
+
+	analyticsRebuildCmd.Flags().StringVar(&analyticsTenant, "tenant", "", "Id of the tenant to rebuild, needed when tenancy is on.")
+}
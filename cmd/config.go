@@ -0,0 +1,38 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups the commands that inspect the configuration
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "inspects the configuration",
+}
+
+// configPrintCmd represents the config print command
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "prints the effective configuration",
+	Long: `Prints the configuration voter-api would run with, after merging
+the defaults, the config file, the environment and the flags. Secrets
+are redacted. The output can be used as a config file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), string(out))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configPrintCmd)
+}
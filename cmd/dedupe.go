@@ -0,0 +1,91 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"drexel.edu/voter-api/pkg/dedupe"
+	rediscache "drexel.edu/voter-api/pkg/storage/redis"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dedupeMinScore float64
+	dedupeJSON     bool
+	dedupeTenant   string
+)
+
+// dedupeCmd represents the dedupe command
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "lists voters that look registered twice",
+	Long: `Scores every pair of voters sharing an email or a word of the name
+and lists those scoring at least --min-score, best first. Nothing is
+changed, merge a pair with POST /voters/:id/merge.
+
+A pair with the same email, regardless of case, scores at least 0.9.
+Otherwise the score is 0.9 times the similarity of the names, compared
+in lower case with their words sorted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		connectCtx, cancel := context.WithTimeout(cmd.Context(), cfg.Redis.ConnectTimeout)
+		redisCache, err := rediscache.NewWithRetry(connectCtx, cfg.Redis.Address)
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer redisCache.Close()
+
+		if err := encrypt(redisCache); err != nil {
+			return err
+		}
+
+		ctx, err := tenantContext(cmd.Context(), redisCache, dedupeTenant)
+		if err != nil {
+			return err
+		}
+
+		candidates, err := newDedupeAdapter(redisCache).Duplicates(ctx, dedupeMinScore)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if dedupeJSON {
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(candidates)
+		}
+
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SCORE\tVOTER\tDUPLICATE\tSAME EMAIL")
+		for _, c := range candidates {
+			fmt.Fprintf(w, "%.2f\t%d %s <%s>\t%d %s <%s>\t%t\n", c.Score,
+				c.Voter.Id, c.Voter.Name, c.Voter.Email,
+				c.Duplicate.Id, c.Duplicate.Name, c.Duplicate.Email,
+				c.SameEmail)
+		}
+		return w.Flush()
+	},
+}
+
+// newDedupeAdapter runs merges in a redis transaction
+func newDedupeAdapter(redisCache *rediscache.VoterCache) dedupe.Adapter {
+	return dedupe.New(redisCache, func(ctx context.Context, fn func(dedupe.TxRepository) error) error {
+		return redisCache.RunInTransaction(ctx, func(tx *rediscache.Tx) error {
+			return fn(tx)
+		})
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(dedupeCmd)
+
+	dedupeCmd.Flags().Float64Var(&dedupeMinScore, "min-score", dedupe.DefaultMinScore, "Only list pairs scoring at least this, between 0 and 1.")
+	dedupeCmd.Flags().BoolVar(&dedupeJSON, "json", false, "Print the pairs as JSON.")
+	dedupeCmd.Flags().StringVar(&dedupeTenant, "tenant", "", "Id of the tenant to look at, needed when tenancy is on.")
+}
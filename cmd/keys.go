@@ -0,0 +1,158 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"drexel.edu/voter-api/pkg/pii"
+	rediscache "drexel.edu/voter-api/pkg/storage/redis"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rotateKeysTenant string
+	kmsListen        string
+)
+
+// encrypt turns encryption on when a keyfile or the KMS stand-in is
+// configured
+func encrypt(redisCache *rediscache.VoterCache) error {
+	switch {
+	case cfg.Encryption.KeyFile != "":
+		keys, err := pii.LoadKeyFile(cfg.Encryption.KeyFile)
+		if err != nil {
+			return err
+		}
+		redisCache.EncryptWith(keys)
+	case cfg.Encryption.KMSURL != "":
+		redisCache.EncryptWith(pii.NewKMS(cfg.Encryption.KMSURL, cfg.Encryption.KMSToken))
+	}
+	return nil
+}
+
+// rotateKeysCmd represents the rotate-keys command
+var rotateKeysCmd = &cobra.Command{
+	Use:   "rotate-keys",
+	Short: "seals every voter again with a new data key",
+	Long: `Makes a new data key the one that seals, wraps every data key again
+with the current master key and seals the name and email of every voter
+and tombstone, the payloads of the dead letters and the stored responses
+of idempotent requests again, those stored before encryption was turned
+on included. Run it once after turning encryption on. Events stored in
+clear are deleted once every consumer has handled them, run it again
+if some were kept.
+
+To retire a master key append a new one to the keyfile, restart the api
+and run rotate-keys, afterwards the old one can be removed from the
+file. Retired data keys are removed once nothing is sealed with them,
+the events in the stream keep theirs until they are trimmed.
+
+The api can keep running. It picks the new data key up within 30
+seconds, rotate-keys waits that long before it seals the voters again.
+Without --tenant every tenant is rotated.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cfg.Encryption.Enabled() {
+			return errors.New("encryption is off, set --encryption-keyfile or --kms-url")
+		}
+		connectCtx, cancel := context.WithTimeout(cmd.Context(), cfg.Redis.ConnectTimeout)
+		redisCache, err := rediscache.NewWithRetry(connectCtx, cfg.Redis.Address)
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer redisCache.Close()
+		if err := encrypt(redisCache); err != nil {
+			return err
+		}
+
+		var contexts []context.Context
+		if rotateKeysTenant != "" {
+			ctx, err := tenantContext(cmd.Context(), redisCache, rotateKeysTenant)
+			if err != nil {
+				return err
+			}
+			contexts = []context.Context{ctx}
+		} else {
+			tenants, err := loadTenants(redisCache)
+			if err != nil {
+				return err
+			}
+			contexts = tenantContexts(cmd.Context(), tenants)
+		}
+
+		out := cmd.OutOrStdout()
+		for _, ctx := range contexts {
+			rotation, err := redisCache.RotateKeys(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%sdata key %s: sealed %d voters, %d tombstones, %d dead letters and %d stored responses, wrapped %d keys again\n",
+				tenantLabel(ctx), rotation.DataKey, rotation.Voters, rotation.Tombstones, rotation.DeadLetters, rotation.Responses, rotation.Rewrapped)
+			if rotation.ClearEvents > 0 {
+				fmt.Fprintf(out, "%sdeleted %d events stored in clear\n", tenantLabel(ctx), rotation.ClearEvents)
+			}
+			if rotation.ClearEventsLeft > 0 {
+				fmt.Fprintf(out, "%skept %d events stored in clear that a consumer has not handled yet, run rotate-keys again later\n", tenantLabel(ctx), rotation.ClearEventsLeft)
+			}
+			if len(rotation.Removed) > 0 {
+				fmt.Fprintf(out, "%sremoved data keys %s\n", tenantLabel(ctx), strings.Join(rotation.Removed, ", "))
+			}
+			if len(rotation.Kept) > 0 {
+				fmt.Fprintf(out, "%skept retired data keys %s, events are still sealed with them\n", tenantLabel(ctx), strings.Join(rotation.Kept, ", "))
+			}
+		}
+		return nil
+	},
+}
+
+// kmsCmd represents the kms command
+var kmsCmd = &cobra.Command{
+	Use:   "kms",
+	Short: "serves the master keys of a keyfile as a KMS stand-in",
+	Long: `Serves the KMS stand-in: the api, started with --kms-url, sends it
+the data keys to wrap and unwrap and never sees the master keys, which
+stay in the --encryption-keyfile of this process. Callers must send the
+--kms-token as a bearer token when one is set.
+
+It stands in for a key management service in development and tests, it
+serves plain HTTP and keeps no audit log.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfg.Encryption.KeyFile == "" {
+			return errors.New("the kms needs the master keys, set --encryption-keyfile")
+		}
+		keys, err := pii.LoadKeyFile(cfg.Encryption.KeyFile)
+		if err != nil {
+			return err
+		}
+		if cfg.Encryption.KMSToken == "" {
+			slog.Warn("the kms has no token, anyone who can reach it can unwrap the data keys")
+		}
+
+		server := &http.Server{
+			Addr:              kmsListen,
+			Handler:           pii.KMSHandler(keys, cfg.Encryption.KMSToken),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		slog.Info("serving the kms stand-in", "address", kmsListen)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rotateKeysCmd)
+	rootCmd.AddCommand(kmsCmd)
+
+	rotateKeysCmd.Flags().StringVar(&rotateKeysTenant, "tenant", "", "Id of the tenant to rotate, all of them when empty.")
+	kmsCmd.Flags().StringVar(&kmsListen, "listen", "127.0.0.1:8200", "Address the kms stand-in listens on.")
+}
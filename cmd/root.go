@@ -1,30 +1,53 @@
 /*
 Copyright © 2024 NAME HERE <EMAIL ADDRESS>
-
 */
 package cmd
 
 import (
+	"log/slog"
 	"os"
 
+	"drexel.edu/voter-api/pkg/config"
+	"drexel.edu/voter-api/pkg/logging"
 	"github.com/spf13/cobra"
 )
 
+// cfg holds the effective configuration once the root command has run
+// its PersistentPreRunE, every subcommand reads its settings from it.
+var cfg config.Config
 
+var configLoader *config.Loader
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "voter-api",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
+	Short: "A REST API to keep track of voters and their voting history",
+	Long: `voter-api stores voters and the polls they took part in, in redis.
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+Settings are read, from lowest to highest precedence, from the
+defaults, the YAML file given with --config (or VOTER_API_CONFIG),
+VOTER_API_* environment variables and finally the flags that were
+set explicitly. Run "voter-api config print" to see the result.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+
+	// Every subcommand starts from the validated configuration and
+	// logs through slog, configured from its log section.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		cfg, err = configLoader.Load(os.Getenv)
+		if err != nil {
+			return err
+		}
+
+		logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+		if err != nil {
+			return err
+		}
+		slog.SetDefault(logger)
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -37,15 +60,7 @@ func Execute() {
 }
 
 func init() {
-	// Here you will define your flags and configuration settings.
-	// Cobra supports persistent flags, which, if defined here,
-	// will be global for your application.
-
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.voter-api.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	// Every setting, including --config, is a persistent flag so
+	// that "config print" accepts the same flags as "start".
+	configLoader = config.NewLoader(rootCmd.PersistentFlags())
 }
-
-
@@ -4,71 +4,426 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
+	"drexel.edu/voter-api/pkg/analytics"
+	"drexel.edu/voter-api/pkg/batch"
+	"drexel.edu/voter-api/pkg/certs"
 	"drexel.edu/voter-api/pkg/create"
 	"drexel.edu/voter-api/pkg/delete"
+	"drexel.edu/voter-api/pkg/eligibility"
+	"drexel.edu/voter-api/pkg/events"
+	"drexel.edu/voter-api/pkg/health"
+	"drexel.edu/voter-api/pkg/http/graphql"
 	"drexel.edu/voter-api/pkg/http/rest"
+	"drexel.edu/voter-api/pkg/idempotency"
+	"drexel.edu/voter-api/pkg/metrics"
+	"drexel.edu/voter-api/pkg/outbox"
+	"drexel.edu/voter-api/pkg/precinct"
+	"drexel.edu/voter-api/pkg/ratelimit"
 	"drexel.edu/voter-api/pkg/read"
+	"drexel.edu/voter-api/pkg/rpc"
+	"drexel.edu/voter-api/pkg/search"
+	"drexel.edu/voter-api/pkg/storage"
 	rediscache "drexel.edu/voter-api/pkg/storage/redis"
+	"drexel.edu/voter-api/pkg/tenant"
 	"drexel.edu/voter-api/pkg/update"
+	"drexel.edu/voter-api/pkg/webhooks"
+	"github.com/gofiber/fiber/v2"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-const (
-	defaultPort = 3000
-)
-
-var port int
-var redisLocation string
+// certReloadInterval is how often the tls files are checked for changes
+const certReloadInterval = 5 * time.Second
 
 // startCmd represents the start command
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "starts the server",
 	Long: `Starts the server on port 3000. The user can define
-	a different port using the -p --port flag. 
+	a different port using the -p --port flag, VOTER_API_PORT or
+	server.port in the config file.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("start called")
+		//SIGINT and SIGTERM cancel ctx, which starts the shutdown
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		connectCtx, cancel := context.WithTimeout(ctx, cfg.Redis.ConnectTimeout)
+		redisCache, err := rediscache.NewWithRetry(connectCtx, cfg.Redis.Address)
+		cancel()
+		if err != nil {
+			slog.Error("error initializing repository", "error", err)
+			os.Exit(1)
+		}
 
-		redisCache, err := rediscache.New()
+		redisCache.AddHook(metrics.RedisHook())
+		if err := metrics.RegisterRedisPool(redisCache.PoolStats); err != nil {
+			slog.Error("error registering redis pool metrics", "error", err)
+			os.Exit(1)
+		}
+
+		tenants, err := loadTenants(redisCache)
 		if err != nil {
-			fmt.Sprintln("Error initializing repository: ", err)
-			panic(err)
+			slog.Error("error loading the tenants", "error", err)
+			os.Exit(1)
 		}
 
-		createAdapter := create.New(redisCache)
+		if err := encrypt(redisCache); err != nil {
+			slog.Error("error loading the master keys", "error", err)
+			os.Exit(1)
+		}
 
-		updateAdapter := update.New(redisCache)
+		precinctAdapter := precinct.New(redisCache)
+
+		assigner, err := precinctRules(tenantContexts(ctx, tenants), precinctAdapter)
+		if err != nil {
+			slog.Error("error loading the precinct rules", "error", err)
+			os.Exit(1)
+		}
+
+		eligibilityAdapter, err := newEligibilityAdapter(tenantContexts(ctx, tenants), redisCache)
+		if err != nil {
+			slog.Error("error loading the eligibility rules", "error", err)
+			os.Exit(1)
+		}
+
+		createAdapter := create.New(redisCache, assigner, eligibilityAdapter)
+
+		updateAdapter := update.New(redisCache, assigner, eligibilityAdapter)
 
 		readAdapter := read.New(redisCache)
 
 		deleteAdapter := delete.New(redisCache)
 
-		router := rest.Handler(port, createAdapter, updateAdapter, readAdapter, deleteAdapter)
+		healthAdapter := health.New(redisCache, rediscache.SchemaVersion)
+
+		batchAdapter := batch.New(func(ctx context.Context, fn func(batch.Repository) error) error {
+			return redisCache.RunInTransaction(ctx, func(tx *rediscache.Tx) error {
+				return fn(tx)
+			})
+		}, assigner, eligibilityAdapter)
+
+		searchAdapter := search.New(searchRepository(tenantContexts(ctx, tenants), redisCache))
+
+		dedupeAdapter := newDedupeAdapter(redisCache)
+
+		//config validation already rejected an unknown time zone
+		loc, _ := cfg.Eligibility.Location()
+		analyticsAdapter := analytics.New(redisCache, loc)
+
+		eventsAdapter := events.New(redisCache)
+
+		dispatcher := webhooks.NewDispatcher(redisCache, webhooks.Options(cfg.Webhooks))
+		webhooksAdapter := webhooks.New(redisCache, dispatcher)
+
+		//the relay forwards the events every write stores along with
+		//it to the sinks, webhooks for now
+		relay := outbox.New(redisCache, eventsAdapter)
+		relay.Register("webhooks", dispatcher)
+
+		//the dispatcher is shared, every tenant has a stream and
+		//offsets of its own and so a relay run of its own
+		var background sync.WaitGroup
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			dispatcher.Run(ctx)
+		}()
+		for _, tenantCtx := range tenantContexts(ctx, tenants) {
+			background.Add(1)
+			go func(tenantCtx context.Context) {
+				defer background.Done()
+				relay.Run(tenantCtx)
+			}(tenantCtx)
+		}
+
+		restConfig := rest.Config{
+			ConfirmDeleteToken: cfg.Server.ConfirmDeleteToken,
+			RequestTimeout:     cfg.Server.RequestTimeout,
+			APIKeys:            cfg.Server.APIKeyList(),
+			Idempotency:        idempotency.New(redisCache, cfg.Server.IdempotencyTTL, max(idempotency.DefaultLease, 2*cfg.Server.RequestTimeout)),
+			//open event streams end with the shutdown, otherwise
+			//they would hold it up until the timeout
+			StreamContext: ctx,
+			Tenants:       tenants,
+		}
+		if cfg.RateLimit.Enabled {
+			restConfig.RateLimiter = ratelimit.New(redisCache)
+			restConfig.ReadLimit = ratelimit.Limit(cfg.RateLimit.Read)
+			restConfig.WriteLimit = ratelimit.Limit(cfg.RateLimit.Write)
+		}
+
+		restConfig.GraphQL, err = graphql.Handler(createAdapter, updateAdapter, readAdapter, deleteAdapter)
+		if err != nil {
+			slog.Error("error building the graphql schema", "error", err)
+			os.Exit(1)
+		}
+
+		router := rest.Handler(cfg.Server.Port, createAdapter, updateAdapter, readAdapter, deleteAdapter, healthAdapter, batchAdapter, eventsAdapter, webhooksAdapter, searchAdapter, dedupeAdapter, precinctAdapter, eligibilityAdapter, analyticsAdapter, restConfig)
+
+		reloader, err := loadCertificates(ctx)
+		if err != nil {
+			slog.Error("error loading tls certificates", "error", err)
+			os.Exit(1)
+		}
+
+		var grpcServer *grpc.Server
+		if cfg.Server.GRPCPort != 0 {
+			grpcConfig := rpc.Config{RequestTimeout: cfg.Server.RequestTimeout, Tenants: tenants}
+			if reloader != nil {
+				grpcConfig.Credentials = credentials.NewTLS(reloader.TLSConfig(cfg.Server.TLS.RequireClientCert, "h2"))
+			}
+			grpcServer = rpc.NewServer(ctx, createAdapter, updateAdapter, readAdapter, deleteAdapter, healthAdapter, grpcConfig)
+		}
+
+		scheme := "http"
+		if reloader != nil {
+			scheme = "https"
+		}
+		slog.Info("the server is started", "url", fmt.Sprintf("%s://localhost:%d", scheme, cfg.Server.Port))
+		if grpcServer != nil {
+			slog.Info("the grpc server is started", "address", fmt.Sprintf("localhost:%d", cfg.Server.GRPCPort), "tls", reloader != nil)
+		}
 
-		msg := fmt.Sprintf("the server is started at: http://localhost:%d", port)
+		err = serve(ctx, router, grpcServer, reloader)
 
-		fmt.Println(msg)
+		//serve only returns once ctx is cancelled or the listener
+		//failed, stop the background work in the latter case too. The
+		//relay saves the offsets of what its sinks handled and the
+		//dispatcher parks what it could not deliver, both need redis.
+		stop()
+		background.Wait()
 
-		log.Fatal(router.Listen(string(fmt.Sprintf(":%d", port))))
+		//Only close redis once no request can use it anymore. Any
+		//background work that writes to redis has to be flushed
+		//before this point.
+		if closeErr := redisCache.Close(); closeErr != nil {
+			slog.Warn("error closing redis", "error", closeErr)
+		}
+
+		if err != nil {
+			slog.Error("server stopped", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("the server is stopped")
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(startCmd)
+// precinctRules loads the rules that assign voters to precincts, nil
+// when none are configured. A rule naming a precinct that is not in the
+// reference table of a tenant still assigns to it, the table may be
+// filled in after the start, so it is only worth a warning.
+func precinctRules(contexts []context.Context, precincts precinct.Adapter) (storage.Assigner, error) {
+	if cfg.Precincts.Rules == "" {
+		return nil, nil
+	}
+	rules, err := precinct.LoadRules(cfg.Precincts.Rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, ctx := range contexts {
+		known, err := precincts.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		exists := make(map[string]bool, len(known))
+		for _, p := range known {
+			exists[p.Id] = true
+		}
+		t, _ := tenant.From(ctx)
+		for _, id := range rules.Precincts() {
+			if !exists[id] {
+				slog.Warn("a precinct rule names an unknown precinct", "precinct", id, "tenant", t.Id)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// newEligibilityAdapter checks the voters against the rules and open
+// hours per poll in the configured file, without one only their status
+// is checked and votes are accepted any time. The policy is read in the
+// time zone of every tenant up front, so a poll that does not work out
+// in one of them fails the start rather than a vote.
+func newEligibilityAdapter(contexts []context.Context, redisCache *rediscache.VoterCache) (eligibility.Adapter, error) {
+	if cfg.Eligibility.Rules == "" {
+		return eligibility.New(redisCache, nil), nil
+	}
+	loc, err := cfg.Eligibility.Location()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := eligibility.LoadPolicy(cfg.Eligibility.Rules, loc)
+	if err != nil {
+		return nil, err
+	}
+	for _, ctx := range contexts {
+		if _, err := policy.In(tenant.Location(ctx, loc)); err != nil {
+			return nil, fmt.Errorf("%s%s: %w", tenantLabel(ctx), cfg.Eligibility.Rules, err)
+		}
+	}
+	return eligibility.New(redisCache, policy), nil
+}
+
+// searchRepository answers searches from the RediSearch indexes, which
+// it creates if needed, one per tenant, and falls back to scanning
+// every voter on a redis without the module
+func searchRepository(contexts []context.Context, redisCache *rediscache.VoterCache) search.Repository {
+	loaded, err := redisCache.SearchModuleLoaded(contexts[0])
+	if err == nil && loaded {
+		for _, ctx := range contexts {
+			if err = redisCache.EnsureSearchIndex(ctx); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			return redisCache
+		}
+	}
+	if err != nil {
+		slog.Warn("the search index is unavailable, searches scan every voter", "error", err)
+	} else {
+		slog.Warn("redis has no RediSearch module, searches scan every voter")
+	}
+	return search.NewScan(redisCache)
+}
+
+// serve runs the router, and the gRPC server when there is one, until
+// one of them fails or ctx is cancelled. On cancellation the listeners
+// are closed right away and in-flight requests get up to
+// server.shutdown_timeout to finish, so a deploy does not cut a history
+// write in the middle of its read-modify-write.
+func serve(ctx context.Context, router *fiber.App, grpcServer *grpc.Server, reloader *certs.Reloader) error {
+	ln, err := listen(cfg.Server.Port, reloader)
+	if err != nil {
+		return err
+	}
+
+	listenErr := make(chan error, 3)
+	go func() {
+		listenErr <- router.Listener(ln)
+	}()
+
+	if grpcServer != nil {
+		grpcLn, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.GRPCPort))
+		if err != nil {
+			router.Shutdown()
+			return err
+		}
+		go func() {
+			if err := grpcServer.Serve(grpcLn); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				listenErr <- err
+			}
+		}()
+	}
+
+	var redirect *http.Server
+	if cfg.Server.TLS.RedirectPort != 0 {
+		redirect = httpsRedirect(cfg.Server.TLS.RedirectPort, cfg.Server.Port)
+		go func() {
+			if err := redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				listenErr <- err
+			}
+		}()
+	}
 
-	// Here you will define your flags and configuration settings.
+	select {
+	case err = <-listenErr:
+	case <-ctx.Done():
+		slog.Info("shutting down, draining in-flight requests", "timeout", cfg.Server.ShutdownTimeout)
+	}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// startCmd.PersistentFlags().String("foo", "", "A help for foo")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if redirect != nil {
+		redirect.Shutdown(shutdownCtx)
+	}
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+	return errors.Join(err, router.ShutdownWithContext(shutdownCtx))
+}
+
+// stopGRPC lets the open calls finish, and cuts them once ctx is done
+func stopGRPC(ctx context.Context, grpcServer *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
+	}
+}
+
+// loadCertificates returns the reloader of the tls files, or nil when
+// HTTPS is off. The files are reloaded whenever they change on disk
+// until ctx is done.
+func loadCertificates(ctx context.Context) (*certs.Reloader, error) {
+	tlsCfg := cfg.Server.TLS
+	if !tlsCfg.Enabled() {
+		return nil, nil
+	}
+	reloader, err := certs.NewReloader(tlsCfg.Cert, tlsCfg.Key, tlsCfg.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+	go reloader.Watch(ctx, certReloadInterval)
+	return reloader, nil
+}
+
+// listen opens the listener of the rest api, serving HTTPS when there
+// is a reloader.
+func listen(port int, reloader *certs.Reloader) (net.Listener, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	if reloader == nil {
+		return ln, nil
+	}
+	return tls.NewListener(ln, reloader.TLSConfig(cfg.Server.TLS.RequireClientCert)), nil
+}
+
+// httpsRedirect returns a plain HTTP server that sends every request to
+// the same path on the HTTPS port.
+func httpsRedirect(redirectPort int, httpsPort int) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", redirectPort),
+		ReadHeaderTimeout: 5 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+			if httpsPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(startCmd)
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// startCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	startCmd.Flags().IntVarP(&port, "port", "p", defaultPort, "The port voter-api will use.")
-	//startCmd.Flags().StringVarP(&redisLocation, "redis", "r", defaultRedisLocation, "The redis location to use.")
+	// The flags of start are the settings of the config package, they
+	// are registered on the root command. See cmd/root.go.
 }
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	rediscache "drexel.edu/voter-api/pkg/storage/redis"
+	"drexel.edu/voter-api/pkg/tenant"
+)
+
+// loadTenants returns the resolver of the configured tenants and makes
+// the repository require one on every call, nil when tenancy is off
+func loadTenants(redisCache *rediscache.VoterCache) (*tenant.Resolver, error) {
+	if !cfg.Tenancy.Enabled() {
+		return nil, nil
+	}
+	tenants, err := tenant.Load(cfg.Tenancy.Tenants)
+	if err != nil {
+		return nil, err
+	}
+	redisCache.RequireTenant()
+	return tenant.NewResolver(tenants, tenant.Options{
+		Sources:   cfg.Tenancy.SourceList(),
+		Domain:    cfg.Tenancy.Domain,
+		JWTSecret: []byte(cfg.Tenancy.JWTSecret),
+		JWTClaim:  cfg.Tenancy.JWTClaim,
+	}), nil
+}
+
+// tenantContexts returns a context per tenant for the work that is done
+// for every tenant on its own, such as relaying its events. Without
+// tenancy it is ctx alone.
+func tenantContexts(ctx context.Context, resolver *tenant.Resolver) []context.Context {
+	if resolver == nil {
+		return []context.Context{ctx}
+	}
+	all := resolver.Tenants().All()
+	contexts := make([]context.Context, len(all))
+	for i, t := range all {
+		contexts[i] = tenant.WithTenant(ctx, t)
+	}
+	return contexts
+}
+
+// tenantContext returns ctx with the tenant named by --tenant, which the
+// commands working on the data of one tenant need when tenancy is on
+func tenantContext(ctx context.Context, redisCache *rediscache.VoterCache, id string) (context.Context, error) {
+	resolver, err := loadTenants(redisCache)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resolver == nil && id != "":
+		return nil, fmt.Errorf("--tenant needs tenancy, see --tenants")
+	case resolver == nil:
+		return ctx, nil
+	case id == "":
+		return nil, fmt.Errorf("tenancy is on, name the tenant with --tenant")
+	}
+	t, ok := resolver.Tenants().Get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", tenant.ErrUnknown, id)
+	}
+	return tenant.WithTenant(ctx, t), nil
+}
+
+// tenantLabel starts the lines a command prints about the tenant in
+// ctx, it is empty without tenancy
+func tenantLabel(ctx context.Context) string {
+	if t, ok := tenant.From(ctx); ok {
+		return "tenant " + t.Id + ": "
+	}
+	return ""
+}
@@ -0,0 +1,318 @@
+package analytics
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/tenant"
+)
+
+// The analytics Port reports on turnout and participation. It reads
+// aggregates the repository keeps up to date with every write, never
+// the voters themselves, so a report costs the same however many
+// voters there are. Periods start at midnight, or the full hour, in the
+// time zone of the jurisdiction.
+
+// The lengths of the periods of a series
+const (
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+const (
+	//maxPeriods caps the length of a series
+	maxPeriods = 1000
+
+	//maxPolls caps how many polls participation looks back on
+	maxPolls = 50
+
+	//DefaultLimit and MaxLimit bound the pages of voter ids
+	DefaultLimit = 100
+	MaxLimit     = 1000
+
+	dateLayout = "2006-01-02"
+)
+
+type Adapter interface {
+	//Polls returns how many voters took part in each poll, by poll id
+	Polls(context.Context) ([]PollSummary, error)
+
+	//Turnout is the votes in a poll per period, between the first and
+	//the last vote unless the query says otherwise
+	Turnout(context.Context, int, SeriesQuery) (Turnout, error)
+
+	//NewVoters counts registrations and first votes per period, the
+	//last 30 days unless the query says otherwise
+	NewVoters(context.Context, SeriesQuery) (NewVoters, error)
+
+	//Participation finds the voters that took part in N of the last M
+	//polls, the polls with the highest ids
+	Participation(context.Context, ParticipationQuery) (Participation, error)
+
+	//Inactive finds the voters that have not voted since a time, a
+	//year ago unless the query says otherwise
+	Inactive(context.Context, InactiveQuery) (Inactive, error)
+}
+
+type Repository interface {
+	PollTotals(context.Context) (map[int]int, error)
+	VoteSpan(ctx context.Context, pollId int) (first time.Time, last time.Time, err error)
+
+	//The Count functions count within each pair of consecutive bounds
+	CountVotes(ctx context.Context, pollId int, bounds []time.Time) ([]int, error)
+	CountRegistrations(ctx context.Context, bounds []time.Time) ([]int, error)
+	CountFirstVotes(ctx context.Context, bounds []time.Time) ([]int, error)
+
+	Participants(ctx context.Context, pollIds []int, min int, offset int, limit int) ([]int, int, error)
+	Inactive(ctx context.Context, since time.Time, offset int, limit int) ([]int, int, error)
+}
+
+type adapter struct {
+	r   Repository
+	loc *time.Location
+}
+
+// New builds the analytics Port, loc is the time zone of the
+// jurisdiction. A tenant with a time zone of its own gets its periods
+// in that zone.
+func New(r Repository, loc *time.Location) Adapter {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &adapter{r, loc}
+}
+
+func (a *adapter) Polls(ctx context.Context) ([]PollSummary, error) {
+	totals, err := a.r.PollTotals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	polls := make([]PollSummary, 0, len(totals))
+	for pollId, votes := range totals {
+		polls = append(polls, PollSummary{PollId: pollId, Votes: votes})
+	}
+	sort.Slice(polls, func(i, j int) bool { return polls[i].PollId < polls[j].PollId })
+	return polls, nil
+}
+
+func (a *adapter) Turnout(ctx context.Context, pollId int, q SeriesQuery) (Turnout, error) {
+	if pollId < 1 {
+		return Turnout{}, storage.Invalidf("invalid Poll Id")
+	}
+	first, last, err := a.r.VoteSpan(ctx, pollId)
+	if err != nil {
+		return Turnout{}, err
+	}
+	//one second past the last vote, the end of a series is exclusive
+	bounds, interval, err := a.bounds(q, tenant.Location(ctx, a.loc), first, last.Add(time.Second))
+	if err != nil {
+		return Turnout{}, err
+	}
+
+	turnout := Turnout{PollId: pollId, Interval: interval, Series: []TurnoutPoint{}}
+	if len(bounds) == 0 {
+		return turnout, nil
+	}
+	counts, err := a.r.CountVotes(ctx, pollId, bounds)
+	if err != nil {
+		return Turnout{}, err
+	}
+	for i, count := range counts {
+		turnout.Votes += count
+		turnout.Series = append(turnout.Series, TurnoutPoint{Start: bounds[i], Votes: count, Cumulative: turnout.Votes})
+	}
+
+	registered, err := a.r.CountRegistrations(ctx, []time.Time{{}, bounds[len(bounds)-1]})
+	if err != nil {
+		return Turnout{}, err
+	}
+	turnout.Registered = registered[0]
+	if turnout.Registered > 0 {
+		turnout.Rate = float64(turnout.Votes) / float64(turnout.Registered)
+	}
+	return turnout, nil
+}
+
+func (a *adapter) NewVoters(ctx context.Context, q SeriesQuery) (NewVoters, error) {
+	loc := tenant.Location(ctx, a.loc)
+	now := time.Now().In(loc)
+	bounds, interval, err := a.bounds(q, loc, now.AddDate(0, 0, -30), now)
+	if err != nil {
+		return NewVoters{}, err
+	}
+
+	result := NewVoters{Interval: interval, Series: []NewVotersPoint{}}
+	if len(bounds) == 0 {
+		return result, nil
+	}
+	registered, err := a.r.CountRegistrations(ctx, bounds)
+	if err != nil {
+		return NewVoters{}, err
+	}
+	firstVotes, err := a.r.CountFirstVotes(ctx, bounds)
+	if err != nil {
+		return NewVoters{}, err
+	}
+	for i := range registered {
+		result.Series = append(result.Series, NewVotersPoint{Start: bounds[i], Registered: registered[i], FirstVotes: firstVotes[i]})
+	}
+	return result, nil
+}
+
+func (a *adapter) Participation(ctx context.Context, q ParticipationQuery) (Participation, error) {
+	if q.Polls < 1 || q.Polls > maxPolls {
+		return Participation{}, storage.Invalidf("polls must be between 1 and %d", maxPolls)
+	}
+	if q.Min == 0 {
+		q.Min = q.Polls
+	}
+	if q.Min < 1 || q.Min > q.Polls {
+		return Participation{}, storage.Invalidf("min must be between 1 and polls")
+	}
+	if err := q.Page.check(); err != nil {
+		return Participation{}, err
+	}
+
+	polls, err := a.Polls(ctx)
+	if err != nil {
+		return Participation{}, err
+	}
+	if len(polls) > q.Polls {
+		polls = polls[len(polls)-q.Polls:]
+	}
+	pollIds := make([]int, len(polls))
+	for i, p := range polls {
+		pollIds[i] = p.PollId
+	}
+
+	ids, total, err := a.r.Participants(ctx, pollIds, q.Min, q.Offset, q.limit())
+	if err != nil {
+		return Participation{}, err
+	}
+	return Participation{Polls: pollIds, Min: q.Min, Total: total, VoterIds: ids}, nil
+}
+
+func (a *adapter) Inactive(ctx context.Context, q InactiveQuery) (Inactive, error) {
+	if err := q.Page.check(); err != nil {
+		return Inactive{}, err
+	}
+	loc := tenant.Location(ctx, a.loc)
+	since := time.Now().In(loc).AddDate(-1, 0, 0)
+	if q.Since != "" {
+		var err error
+		if since, err = parse(q.Since, loc); err != nil {
+			return Inactive{}, err
+		}
+	}
+	ids, total, err := a.r.Inactive(ctx, since, q.Offset, q.limit())
+	if err != nil {
+		return Inactive{}, err
+	}
+	return Inactive{Since: since, Total: total, VoterIds: ids}, nil
+}
+
+func (p Page) check() error {
+	if p.Offset < 0 {
+		return storage.Invalidf("offset cannot be negative")
+	}
+	if p.Limit < 0 || p.Limit > MaxLimit {
+		return storage.Invalidf("limit must be between 1 and %d", MaxLimit)
+	}
+	return nil
+}
+
+func (p Page) limit() int {
+	if p.Limit == 0 {
+		return DefaultLimit
+	}
+	return p.Limit
+}
+
+// bounds returns the starts of the periods between from and to in loc
+// and the end of the last one, from and to come from the query if it
+// has them.
+// A series without a from, such as the turnout of a poll nobody voted
+// in, has no periods.
+func (a *adapter) bounds(q SeriesQuery, loc *time.Location, from time.Time, to time.Time) ([]time.Time, string, error) {
+	interval := strings.ToLower(q.Interval)
+	if interval == "" {
+		interval = IntervalDay
+	}
+	switch interval {
+	case IntervalHour, IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		return nil, "", storage.Invalidf("interval must be %s, %s, %s or %s", IntervalHour, IntervalDay, IntervalWeek, IntervalMonth)
+	}
+
+	var err error
+	if q.From != "" {
+		if from, err = parse(q.From, loc); err != nil {
+			return nil, "", err
+		}
+	}
+	if q.To != "" {
+		if to, err = parse(q.To, loc); err != nil {
+			return nil, "", err
+		}
+	}
+	if from.IsZero() {
+		return nil, interval, nil
+	}
+	if !to.After(from) {
+		return nil, "", storage.Invalidf("to must be after from")
+	}
+
+	bounds := []time.Time{truncate(from, interval, loc)}
+	for bounds[len(bounds)-1].Before(to) {
+		if len(bounds) > maxPeriods {
+			return nil, "", storage.Invalidf("a series has at most %d periods, use a longer interval", maxPeriods)
+		}
+		bounds = append(bounds, next(bounds[len(bounds)-1], interval))
+	}
+	return bounds, interval, nil
+}
+
+// parse reads a day, midnight in loc, or a time
+func parse(s string, loc *time.Location) (time.Time, error) {
+	if day, err := time.ParseInLocation(dateLayout, s, loc); err == nil {
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, storage.Invalidf("%q must be a day like %s or a time in RFC 3339", s, dateLayout)
+	}
+	return t.In(loc), nil
+}
+
+// truncate returns the start of the period t is in, in loc
+func truncate(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch interval {
+	case IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case IntervalWeek:
+		//weeks start on monday
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+func next(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
@@ -0,0 +1,88 @@
+package analytics
+
+import "time"
+
+// SeriesQuery picks the periods of a series. From and To are a day,
+// 2006-01-02, or a time in RFC 3339, Interval one of the Interval
+// constants.
+type SeriesQuery struct {
+	From     string `query:"from"`
+	To       string `query:"to"`
+	Interval string `query:"interval"`
+}
+
+// Page picks a page of voter ids
+type Page struct {
+	Offset int `query:"offset"`
+	Limit  int `query:"limit"`
+}
+
+// ParticipationQuery asks for the voters that took part in at least Min
+// of the last Polls polls
+type ParticipationQuery struct {
+	Polls int `query:"polls"`
+	Min   int `query:"min"`
+	Page
+}
+
+// InactiveQuery asks for the voters that have not voted since Since, a
+// day or a time like the bounds of a SeriesQuery
+type InactiveQuery struct {
+	Since string `query:"since"`
+	Page
+}
+
+// PollSummary is how many voters took part in a poll
+type PollSummary struct {
+	PollId int `json:"poll_id"`
+	Votes  int `json:"votes"`
+}
+
+// Turnout is the participation in a poll over time
+type Turnout struct {
+	PollId int `json:"poll_id"`
+	Votes  int `json:"votes"`
+
+	//Registered counts the voters registered before the end of the
+	//series, Rate is Votes out of them
+	Registered int     `json:"registered"`
+	Rate       float64 `json:"rate"`
+
+	Interval string         `json:"interval"`
+	Series   []TurnoutPoint `json:"series"`
+}
+
+type TurnoutPoint struct {
+	Start      time.Time `json:"start"`
+	Votes      int       `json:"votes"`
+	Cumulative int       `json:"cumulative"`
+}
+
+// NewVoters counts the registrations and the first votes per period
+type NewVoters struct {
+	Interval string           `json:"interval"`
+	Series   []NewVotersPoint `json:"series"`
+}
+
+type NewVotersPoint struct {
+	Start      time.Time `json:"start"`
+	Registered int       `json:"registered"`
+	FirstVotes int       `json:"first_votes"`
+}
+
+// Participation is a page of the voters that took part in at least Min
+// of Polls, those in the most polls first
+type Participation struct {
+	Polls    []int `json:"polls"`
+	Min      int   `json:"min"`
+	Total    int   `json:"total"`
+	VoterIds []int `json:"voter_ids"`
+}
+
+// Inactive is a page of the voters that have not voted since Since,
+// those inactive the longest first
+type Inactive struct {
+	Since    time.Time `json:"since"`
+	Total    int       `json:"total"`
+	VoterIds []int     `json:"voter_ids"`
+}
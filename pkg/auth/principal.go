@@ -0,0 +1,41 @@
+package auth
+
+//The auth package describes who is calling the api. The rest Port
+//resolves the caller once per request and stores it in the request
+//context, everything further down only reads it from there.
+
+import "context"
+
+const (
+	// SourceClientCert is used for callers authenticated by a client
+	// certificate over mutual TLS
+	SourceClientCert = "client-cert"
+
+	// SourceAPIKey is used for callers that sent one of the api keys
+	// of the server
+	SourceAPIKey = "api-key"
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	//Name identifies the caller, for a client certificate this is
+	//the subject of the certificate, for an api key a hash of the key
+	Name string
+
+	//Source tells how the caller was authenticated
+	Source string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx. ok is false for
+// anonymous callers.
+func PrincipalFrom(ctx context.Context) (p Principal, ok bool) {
+	p, ok = ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,183 @@
+package batch
+
+//The batch Port runs an ordered list of create, update and delete
+//operations in one go. It does not validate anything itself, every
+//operation goes through the regular create, update and delete adapters.
+//Those adapters are built on top of a transactional repository so all
+//of the writes of a batch are committed together.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"drexel.edu/voter-api/pkg/create"
+	"drexel.edu/voter-api/pkg/delete"
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/update"
+)
+
+// MaxOperations caps the size of a batch, every voter it touches is
+// held in memory and WATCHed until the commit.
+const MaxOperations = 100
+
+type Adapter interface {
+	Execute(context.Context, Request) (Response, error)
+}
+
+// Repository is what the create, update and delete adapters need, plus
+// Savepoint, which returns a function undoing everything written since,
+// so a failed operation leaves no half written state behind.
+type Repository interface {
+	create.Repository
+	update.Repository
+	delete.Repository
+	Savepoint() (rollback func())
+}
+
+// Transact runs fn in a transaction. Writes made through the Repository
+// are committed if fn returns nil and discarded if it returns an error.
+// fn may run more than once when the commit conflicts with another write.
+type Transact func(ctx context.Context, fn func(Repository) error) error
+
+// Polls checks the votes for the create and update adapters, nil skips
+// the checks
+type Polls interface {
+	create.Polls
+	update.Polls
+}
+
+type adapter struct {
+	transact  Transact
+	precincts storage.Assigner
+	polls     Polls
+}
+
+// New builds the batch Port. precincts and polls are handed to the
+// create and update adapters, either may be nil.
+func New(transact Transact, precincts storage.Assigner, polls Polls) Adapter {
+	return &adapter{transact, precincts, polls}
+}
+
+// errAborted discards the writes of an atomic batch with a failure
+var errAborted = errors.New("batch aborted")
+
+func (a *adapter) Execute(ctx context.Context, req Request) (Response, error) {
+	if len(req.Operations) == 0 {
+		return Response{}, storage.Invalidf("a batch needs at least one operation")
+	}
+	if len(req.Operations) > MaxOperations {
+		return Response{}, storage.Invalidf("a batch cannot have more than %d operations", MaxOperations)
+	}
+
+	var results []Result
+	var failed bool
+	err := a.transact(ctx, func(repo Repository) error {
+		//start over on every attempt of the transaction
+		results, failed = a.run(ctx, repo, req)
+		if failed && req.Atomic {
+			return errAborted
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errAborted):
+		for i := range results {
+			if results[i].Status == StatusOK {
+				results[i].Status = StatusRolledBack
+			}
+		}
+		return Response{Committed: false, Results: results}, nil
+	case err != nil:
+		return Response{}, err
+	}
+	return Response{Committed: true, Results: results}, nil
+}
+
+// run applies the operations in order. An atomic batch stops at the
+// first failure, the remaining operations are reported as skipped.
+func (a *adapter) run(ctx context.Context, repo Repository, req Request) ([]Result, bool) {
+	createAdapter := create.New(repo, a.precincts, a.polls)
+	updateAdapter := update.New(repo, a.precincts, a.polls)
+	deleteAdapter := delete.New(repo)
+
+	results := make([]Result, len(req.Operations))
+	failed := false
+	for i, op := range req.Operations {
+		results[i] = Result{Index: i, Op: op.Op, VoterId: op.VoterId, PollId: op.PollId}
+
+		if failed && req.Atomic {
+			results[i].Status = StatusSkipped
+			continue
+		}
+
+		rollback := repo.Savepoint()
+		if err := apply(ctx, createAdapter, updateAdapter, deleteAdapter, op); err != nil {
+			rollback()
+			failed = true
+			results[i].Status = StatusFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Status = StatusOK
+	}
+	return results, failed
+}
+
+func apply(ctx context.Context, createAdapter create.Adapter, updateAdapter update.Adapter, deleteAdapter delete.Adapter, op Operation) error {
+	isHistory := op.PollId != 0
+
+	switch op.Op {
+	case OpCreate:
+		if isHistory {
+			history := create.VoterHistory{}
+			if err := decodeBody(op, &history); err != nil {
+				return err
+			}
+			history.PollId = op.PollId
+			return createAdapter.CreateVoterHistory(ctx, op.VoterId, history)
+		}
+		voter := create.Voter{}
+		if err := decodeBody(op, &voter); err != nil {
+			return err
+		}
+		voter.Id = op.VoterId
+		return createAdapter.CreateVoter(ctx, voter)
+
+	case OpUpdate:
+		if isHistory {
+			history := update.VoterHistory{}
+			if err := decodeBody(op, &history); err != nil {
+				return err
+			}
+			history.PollId = op.PollId
+			return updateAdapter.UpdateVoterHistory(ctx, op.VoterId, history)
+		}
+		voter := update.Voter{}
+		if err := decodeBody(op, &voter); err != nil {
+			return err
+		}
+		voter.Id = op.VoterId
+		return updateAdapter.UpdateVoter(ctx, voter)
+
+	case OpDelete:
+		if isHistory {
+			return deleteAdapter.DeleteVoterHistory(ctx, op.VoterId, op.PollId)
+		}
+		return deleteAdapter.DeleteVoter(ctx, op.VoterId)
+	}
+
+	return fmt.Errorf("unknown op %q, expected %s, %s or %s", op.Op, OpCreate, OpUpdate, OpDelete)
+}
+
+func decodeBody(op Operation, v any) error {
+	if len(op.Body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(op.Body, v); err != nil {
+		return fmt.Errorf("invalid body: %w", err)
+	}
+	return nil
+}
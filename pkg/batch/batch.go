@@ -0,0 +1,51 @@
+package batch
+
+import "encoding/json"
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+const (
+	StatusOK         = "ok"
+	StatusFailed     = "failed"
+	StatusRolledBack = "rolled_back"
+	StatusSkipped    = "skipped"
+)
+
+// Operation is one step of a batch. Without a PollId it works on the
+// voter, with one on the history of the voter for that poll. Body is
+// what the matching REST endpoint takes as its body.
+type Operation struct {
+	Op      string          `json:"op"`
+	VoterId int             `json:"voter_id"`
+	PollId  int             `json:"poll_id,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+// Request is a batch. With Atomic set nothing is written unless every
+// operation succeeds, otherwise the operations that succeeded are
+// written and the others are reported as failed.
+type Request struct {
+	Atomic     bool        `json:"atomic"`
+	Operations []Operation `json:"operations"`
+}
+
+// Result tells what happened to the operation at Index
+type Result struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	VoterId int    `json:"voter_id"`
+	PollId  int    `json:"poll_id,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Response lists one result per operation, in order. Committed is
+// false when an atomic batch was rolled back.
+type Response struct {
+	Committed bool     `json:"committed"`
+	Results   []Result `json:"results"`
+}
@@ -0,0 +1,163 @@
+package certs
+
+//The certs package serves the TLS certificate of the server and the
+//CA used to verify client certificates, and reloads both when the
+//files on disk change so certificates can be rotated without a restart.
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader holds the current certificate and client CA pool.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+// NewReloader loads the key pair and, when caFile is not empty, the
+// client CA bundle. It fails if any of them cannot be loaded.
+func NewReloader(certFile string, keyFile string, caFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a server config that always hands out the current
+// certificate. With a client CA, client certificates are verified
+// against it, and required when requireClientCert is set. nextProtos
+// are the ALPN protocols to offer, gRPC needs "h2".
+func (r *Reloader) TLSConfig(requireClientCert bool, nextProtos ...string) *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+	}
+	if r.caFile == "" {
+		return base
+	}
+
+	clientAuth := tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	//the CA pool is part of the config itself, so hand out a fresh
+	//config per handshake to pick up a reloaded pool
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := base.Clone()
+			cfg.ClientAuth = clientAuth
+			r.mu.RLock()
+			cfg.ClientCAs = r.clientCAs
+			r.mu.RUnlock()
+			return cfg, nil
+		},
+	}
+}
+
+// Watch checks the files every interval and reloads them once one of
+// them changed. A failed reload is logged and the previous certificate
+// stays in use. It returns when ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !r.changed() {
+			continue
+		}
+		if err := r.reload(); err != nil {
+			slog.Error("error reloading tls certificates, keeping the previous ones", "error", err)
+			continue
+		}
+		slog.Info("reloaded tls certificates", "cert", r.certFile, "client_ca", r.caFile)
+	}
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			//a file that is being replaced can be missing for a moment,
+			//try again on the next tick
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading tls key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("reading client ca: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("the client ca file does not contain any PEM certificate")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTimes = modTimes
+	return nil
+}
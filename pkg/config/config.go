@@ -0,0 +1,318 @@
+package config
+
+//The config package builds the settings of voter-api from four
+//sources. Each source overrides the one before it:
+//
+//	1. the defaults in Default()
+//	2. the config file given with --config (or VOTER_API_CONFIG), YAML
+//	3. environment variables, VOTER_API_ followed by the env tag
+//	4. command line flags that were set explicitly
+//
+//Every setting is one field below. The tags tell the loader its name
+//in each source, so adding a setting is a matter of adding a field:
+//
+//	yaml:   key inside its section of the config file
+//	env:    suffix of the VOTER_API_ environment variable
+//	flag:   name of the command line flag (short: one letter alias)
+//
+//	        on a struct field env and flag are prefixes for its fields
+//	usage:  help text of the flag
+//	secret: redacted by `voter-api config print`
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	//the zones are compiled in, the container has no tzdata
+	_ "time/tzdata"
+)
+
+const (
+	// EnvPrefix is put in front of the env tag of every setting
+	EnvPrefix = "VOTER_API_"
+
+	// ConfigEnv may point at the config file instead of --config
+	ConfigEnv = EnvPrefix + "CONFIG"
+
+	// LegacyRedisEnv is still honoured, with a lower precedence than
+	// VOTER_API_REDIS_ADDRESS, so existing containers keep working
+	LegacyRedisEnv = "REDIS_URL"
+)
+
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Webhooks    WebhookConfig     `yaml:"webhooks"`
+	Redis       RedisConfig       `yaml:"redis"`
+	Precincts   PrecinctConfig    `yaml:"precincts"`
+	Eligibility EligibilityConfig `yaml:"eligibility"`
+	Tenancy     TenancyConfig     `yaml:"tenancy"`
+	Encryption  EncryptionConfig  `yaml:"encryption"`
+	Log         LogConfig         `yaml:"log"`
+}
+
+type ServerConfig struct {
+	Port               int           `yaml:"port" env:"PORT" flag:"port" short:"p" usage:"The port voter-api will use."`
+	GRPCPort           int           `yaml:"grpc_port" env:"GRPC_PORT" flag:"grpc-port" usage:"Port of the gRPC api, with TLS when HTTPS is on. 0 disables it."`
+	RequestTimeout     time.Duration `yaml:"request_timeout" env:"REQUEST_TIMEOUT" flag:"request-timeout" usage:"How long a request may wait on redis before failing with 504. 0 disables the deadline."`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"How long in-flight requests get to finish on SIGTERM or SIGINT."`
+	ConfirmDeleteToken string        `yaml:"confirm_delete_token" env:"CONFIRM_DELETE_TOKEN" flag:"confirm-delete-token" secret:"true" usage:"Token clients must send in the X-Confirm-Delete header to bulk delete. Bulk deletes are disabled when empty."`
+	IdempotencyTTL     time.Duration `yaml:"idempotency_ttl" env:"IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"How long responses to requests with an Idempotency-Key are kept for replay."`
+	APIKeys            string        `yaml:"api_keys" env:"API_KEYS" flag:"api-keys" secret:"true" usage:"Comma separated list of the keys clients may send in X-API-Key. A client with one of them is rate limited by it, any other client by its certificate or its IP."`
+	TLS                TLSConfig     `yaml:"tls"`
+}
+
+// APIKeyList returns the api keys, trimmed
+func (s ServerConfig) APIKeyList() []string {
+	var keys []string
+	for _, key := range strings.Split(s.APIKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// TLSConfig turns on HTTPS when both a certificate and a key are given.
+// The files are reloaded when they change on disk.
+type TLSConfig struct {
+	Cert              string `yaml:"cert" env:"TLS_CERT" flag:"tls-cert" usage:"PEM certificate to serve HTTPS with. Requires --tls-key."`
+	Key               string `yaml:"key" env:"TLS_KEY" flag:"tls-key" usage:"PEM private key of --tls-cert."`
+	ClientCA          string `yaml:"client_ca" env:"TLS_CLIENT_CA" flag:"client-ca" usage:"PEM CA bundle to verify client certificates against (mutual TLS)."`
+	RequireClientCert bool   `yaml:"require_client_cert" env:"TLS_REQUIRE_CLIENT_CERT" flag:"require-client-cert" usage:"Reject connections without a valid client certificate. Requires --client-ca."`
+	RedirectPort      int    `yaml:"redirect_port" env:"TLS_REDIRECT_PORT" flag:"http-redirect-port" usage:"Port of a plain HTTP listener that redirects to HTTPS. 0 disables it."`
+}
+
+// Enabled tells whether the server serves HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.Cert != "" || t.Key != ""
+}
+
+// RateLimitConfig limits every client, identified by one of the
+// server.api_keys, its client certificate or else its IP, per route
+// group. Reads are the GET endpoints,
+// writes everything that changes data.
+type RateLimitConfig struct {
+	Enabled bool     `yaml:"enabled" env:"RATE_LIMIT_ENABLED" flag:"rate-limit" usage:"Rate limit clients, with the buckets stored in redis."`
+	Read    RateRule `yaml:"read" env:"RATE_LIMIT_READ_" flag:"rate-limit-read-"`
+	Write   RateRule `yaml:"write" env:"RATE_LIMIT_WRITE_" flag:"rate-limit-write-"`
+}
+
+// RateRule is a token bucket: up to Burst requests at once, refilled
+// at PerMinute requests a minute.
+type RateRule struct {
+	PerMinute int `yaml:"per_minute" env:"PER_MINUTE" flag:"per-minute" usage:"Requests a client may make per minute in this route group."`
+	Burst     int `yaml:"burst" env:"BURST" flag:"burst" usage:"Requests a client may make at once in this route group."`
+}
+
+// WebhookConfig tunes how events are delivered to webhook subscriptions.
+type WebhookConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" flag:"webhook-max-attempts" usage:"How often a webhook delivery is tried before it goes to the dead-letter list."`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"WEBHOOK_INITIAL_BACKOFF" flag:"webhook-initial-backoff" usage:"Wait after the first failed webhook delivery, doubled after every further failure."`
+	Timeout        time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT" flag:"webhook-timeout" usage:"How long a single webhook delivery may take."`
+	Workers        int           `yaml:"workers" env:"WEBHOOK_WORKERS" flag:"webhook-workers" usage:"How many webhook deliveries run at once."`
+	AllowPrivate   bool          `yaml:"allow_private" env:"WEBHOOK_ALLOW_PRIVATE" flag:"webhook-allow-private" usage:"Deliver webhooks to private, loopback and link-local addresses too. Only meant for development."`
+}
+
+type RedisConfig struct {
+	Address        string        `yaml:"address" env:"REDIS_ADDRESS" flag:"redis-address" usage:"The host:port of the redis cache."`
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"REDIS_CONNECT_TIMEOUT" flag:"redis-connect-timeout" usage:"How long to keep retrying the initial connection to redis."`
+}
+
+type PrecinctConfig struct {
+	Rules string `yaml:"rules" env:"PRECINCT_RULES" flag:"precinct-rules" usage:"YAML file of the rules that assign voters to precincts by address. Voters are not assigned when empty."`
+}
+
+type EligibilityConfig struct {
+	Rules    string `yaml:"rules" env:"ELIGIBILITY_RULES" flag:"eligibility-rules" usage:"YAML or JSON file of the rules and open hours per poll, such as a minimum age. Only active voters are checked for when empty."`
+	TimeZone string `yaml:"time_zone" env:"TIME_ZONE" flag:"time-zone" usage:"IANA time zone of the jurisdiction, such as America/New_York. The days and open hours of polls are in this zone, unless a tenant sets a time_zone of its own."`
+}
+
+// Location returns the time zone of the jurisdiction
+func (e EligibilityConfig) Location() (*time.Location, error) {
+	return time.LoadLocation(e.TimeZone)
+}
+
+// TenancyConfig serves several jurisdictions from one deployment. Every
+// tenant listed in the Tenants file has keys of its own in redis, and
+// every request has to name one.
+type TenancyConfig struct {
+	Tenants   string `yaml:"tenants" env:"TENANTS" flag:"tenants" usage:"YAML file of the tenants, the jurisdictions served by this deployment, with their quotas. Tenancy is off when empty."`
+	Sources   string `yaml:"sources" env:"TENANT_SOURCES" flag:"tenant-sources" usage:"Comma separated list of where a request names its tenant: header (X-Tenant-ID), subdomain and jwt. The ones that name a tenant have to agree, with jwt every request needs a valid token. Required when tenancy is on."`
+	Domain    string `yaml:"domain" env:"TENANT_DOMAIN" flag:"tenant-domain" usage:"Domain of the deployment for the subdomain source, montgomery.<domain> is the tenant montgomery."`
+	JWTSecret string `yaml:"jwt_secret" env:"TENANT_JWT_SECRET" flag:"tenant-jwt-secret" secret:"true" usage:"HS256 secret the bearer tokens of the jwt source are signed with."`
+	JWTClaim  string `yaml:"jwt_claim" env:"TENANT_JWT_CLAIM" flag:"tenant-jwt-claim" usage:"Claim of the bearer token holding the tenant id."`
+}
+
+// Enabled tells whether tenancy is on
+func (t TenancyConfig) Enabled() bool {
+	return t.Tenants != ""
+}
+
+// SourceList returns the sources, trimmed and in lower case
+func (t TenancyConfig) SourceList() []string {
+	var sources []string
+	for _, source := range strings.Split(t.Sources, ",") {
+		if source = strings.ToLower(strings.TrimSpace(source)); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// EncryptionConfig seals the names and emails of voters before they are
+// stored. The data keys that seal them are wrapped by a master key held
+// by a local keyfile or by the KMS stand-in, never by redis.
+type EncryptionConfig struct {
+	KeyFile  string `yaml:"keyfile" env:"ENCRYPTION_KEYFILE" flag:"encryption-keyfile" usage:"File of the master keys, an id and 32 bytes in base64 per line, the last one wraps. Encryption is off when neither it nor --kms-url is set."`
+	KMSURL   string `yaml:"kms_url" env:"KMS_URL" flag:"kms-url" usage:"URL of the KMS stand-in (voter-api kms) that wraps the data keys instead of a keyfile."`
+	KMSToken string `yaml:"kms_token" env:"KMS_TOKEN" flag:"kms-token" secret:"true" usage:"Bearer token of the KMS stand-in."`
+}
+
+// Enabled tells whether encryption is on
+func (e EncryptionConfig) Enabled() bool {
+	return e.KeyFile != "" || e.KMSURL != ""
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"Log level: debug, info, warn or error."`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"Log format: json or text."`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:            3000,
+			RequestTimeout:  5 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
+			IdempotencyTTL:  24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Read:  RateRule{PerMinute: 600, Burst: 100},
+			Write: RateRule{PerMinute: 120, Burst: 20},
+		},
+		Webhooks: WebhookConfig{
+			MaxAttempts:    6,
+			InitialBackoff: time.Second,
+			Timeout:        10 * time.Second,
+			Workers:        4,
+		},
+		Redis: RedisConfig{
+			Address:        "0.0.0.0:6379",
+			ConnectTimeout: time.Minute,
+		},
+		Eligibility: EligibilityConfig{
+			TimeZone: "UTC",
+		},
+		Tenancy: TenancyConfig{
+			JWTClaim: "tenant",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Validate checks the effective settings and returns every problem it
+// finds at once, so a broken deployment can be fixed in one go.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Server.GRPCPort != 0 && (c.Server.GRPCPort < 0 || c.Server.GRPCPort > 65535 || c.Server.GRPCPort == c.Server.Port || c.Server.GRPCPort == c.Server.TLS.RedirectPort) {
+		errs = append(errs, fmt.Errorf("server.grpc_port must be between 1 and 65535 and differ from the other ports, got %d", c.Server.GRPCPort))
+	}
+	if c.Server.RequestTimeout < 0 {
+		errs = append(errs, errors.New("server.request_timeout cannot be negative"))
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout cannot be negative"))
+	}
+	if c.Server.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("server.idempotency_ttl must be positive"))
+	}
+	if tls := c.Server.TLS; tls.Enabled() {
+		if tls.Cert == "" || tls.Key == "" {
+			errs = append(errs, errors.New("server.tls.cert and server.tls.key must be set together"))
+		}
+		if tls.RedirectPort != 0 && (tls.RedirectPort < 0 || tls.RedirectPort > 65535 || tls.RedirectPort == c.Server.Port) {
+			errs = append(errs, fmt.Errorf("server.tls.redirect_port must be between 1 and 65535 and differ from server.port, got %d", tls.RedirectPort))
+		}
+	} else if tls.ClientCA != "" || tls.RequireClientCert || tls.RedirectPort != 0 {
+		errs = append(errs, errors.New("server.tls.client_ca, require_client_cert and redirect_port need server.tls.cert and server.tls.key"))
+	}
+	if c.Server.TLS.RequireClientCert && c.Server.TLS.ClientCA == "" {
+		errs = append(errs, errors.New("server.tls.require_client_cert needs server.tls.client_ca"))
+	}
+	if c.RateLimit.Enabled {
+		if r := c.RateLimit.Read; r.PerMinute < 1 || r.Burst < 1 {
+			errs = append(errs, errors.New("rate_limit.read.per_minute and burst must be at least 1"))
+		}
+		if w := c.RateLimit.Write; w.PerMinute < 1 || w.Burst < 1 {
+			errs = append(errs, errors.New("rate_limit.write.per_minute and burst must be at least 1"))
+		}
+	}
+	if c.Webhooks.MaxAttempts < 1 || c.Webhooks.Workers < 1 {
+		errs = append(errs, errors.New("webhooks.max_attempts and workers must be at least 1"))
+	}
+	if c.Webhooks.InitialBackoff <= 0 || c.Webhooks.Timeout <= 0 {
+		errs = append(errs, errors.New("webhooks.initial_backoff and timeout must be positive"))
+	}
+	if len(strings.TrimSpace(c.Redis.Address)) == 0 {
+		errs = append(errs, errors.New("redis.address cannot be blank"))
+	}
+	if c.Redis.ConnectTimeout < 0 {
+		errs = append(errs, errors.New("redis.connect_timeout cannot be negative"))
+	}
+	if _, err := c.Eligibility.Location(); err != nil || c.Eligibility.TimeZone == "" {
+		errs = append(errs, fmt.Errorf("eligibility.time_zone must be an IANA time zone such as America/New_York, got %q", c.Eligibility.TimeZone))
+	}
+	if t := c.Tenancy; t.Enabled() {
+		sources := t.SourceList()
+		if len(sources) == 0 {
+			errs = append(errs, errors.New("tenancy.sources cannot be empty when tenancy is on, list header, subdomain or jwt"))
+		}
+		for _, source := range sources {
+			switch source {
+			case "header":
+			case "subdomain":
+				if strings.Trim(t.Domain, ".") == "" {
+					errs = append(errs, errors.New("tenancy.domain is needed by the subdomain source"))
+				}
+			case "jwt":
+				if t.JWTSecret == "" || t.JWTClaim == "" {
+					errs = append(errs, errors.New("tenancy.jwt_secret and jwt_claim are needed by the jwt source"))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("tenancy.sources must be header, subdomain or jwt, got %q", source))
+			}
+		}
+	}
+	if e := c.Encryption; e.KMSURL != "" {
+		if e.KeyFile != "" {
+			errs = append(errs, errors.New("encryption.keyfile and kms_url cannot both be set, the app reads the master key from one of them"))
+		}
+		if u, err := url.Parse(e.KMSURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("encryption.kms_url must be an http or https URL, got %q", e.KMSURL))
+		}
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", c.Log.Format))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "********"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting is one leaf field of Config together with where it lives
+// and its env and flag names. A struct field may carry env and flag
+// tags too, they are prefixed to the names of its fields so the same
+// struct can be used more than once (see RateRule).
+type setting struct {
+	field reflect.StructField
+	index []int
+	env   string
+	flag  string
+}
+
+// settings lists every leaf field of Config in declaration order
+func settings() []setting {
+	var out []setting
+	var walk func(t reflect.Type, index []int, envPrefix string, flagPrefix string)
+	walk = func(t reflect.Type, index []int, envPrefix string, flagPrefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			idx := append(append([]int{}, index...), i)
+			env := envPrefix + f.Tag.Get("env")
+			flag := flagPrefix + f.Tag.Get("flag")
+			if f.Type.Kind() == reflect.Struct && f.Type != durationType {
+				walk(f.Type, idx, env, flag)
+				continue
+			}
+			s := setting{field: f, index: idx}
+			if f.Tag.Get("env") != "" {
+				s.env = env
+			}
+			if f.Tag.Get("flag") != "" {
+				s.flag = flag
+			}
+			out = append(out, s)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), nil, "", "")
+	return out
+}
+
+// Loader registers the config flags on a flag set and later merges
+// them with the defaults, the config file and the environment.
+type Loader struct {
+	flags *pflag.FlagSet
+
+	//values holds what the flags parsed into. Only the flags that
+	//were set explicitly are copied over, the rest keep the value of
+	//the sources with a lower precedence.
+	values     Config
+	configFile string
+}
+
+// NewLoader registers --config and one flag per setting on flags.
+func NewLoader(flags *pflag.FlagSet) *Loader {
+	l := &Loader{flags: flags, values: Default()}
+
+	flags.StringVar(&l.configFile, "config", "", "Config file (YAML). Also read from "+ConfigEnv+". Flags override environment variables, which override the file.")
+
+	v := reflect.ValueOf(&l.values).Elem()
+	for _, s := range settings() {
+		name := s.flag
+		if name == "" {
+			continue
+		}
+		short := s.field.Tag.Get("short")
+		usage := s.field.Tag.Get("usage")
+		ptr := v.FieldByIndex(s.index).Addr().Interface()
+
+		switch p := ptr.(type) {
+		case *time.Duration:
+			flags.DurationVarP(p, name, short, *p, usage)
+		case *string:
+			flags.StringVarP(p, name, short, *p, usage)
+		case *int:
+			flags.IntVarP(p, name, short, *p, usage)
+		case *bool:
+			flags.BoolVarP(p, name, short, *p, usage)
+		default:
+			panic(fmt.Sprintf("config: unsupported type %T for flag %s", ptr, name))
+		}
+	}
+	return l
+}
+
+// Load returns the effective configuration. getenv is usually os.Getenv.
+func (l *Loader) Load(getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	path := l.configFile
+	if !l.flags.Changed("config") {
+		path = getenv(ConfigEnv)
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if legacy := getenv(LegacyRedisEnv); legacy != "" {
+		cfg.Redis.Address = legacy
+	}
+
+	v := reflect.ValueOf(&cfg).Elem()
+	flagValues := reflect.ValueOf(&l.values).Elem()
+	for _, s := range settings() {
+		if env := s.env; env != "" {
+			if raw, ok := lookup(getenv, EnvPrefix+env); ok {
+				if err := setFromString(v.FieldByIndex(s.index), raw); err != nil {
+					return Config{}, fmt.Errorf("%s%s: %w", EnvPrefix, env, err)
+				}
+			}
+		}
+		if name := s.flag; name != "" && l.flags.Changed(name) {
+			v.FieldByIndex(s.index).Set(flagValues.FieldByIndex(s.index))
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// An empty environment variable counts as unset
+func lookup(getenv func(string) string, key string) (string, bool) {
+	v := getenv(key)
+	return v, v != ""
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	//unknown keys are most likely typos, fail rather than ignore them
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func setFromString(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Redacted returns a copy of c with every secret setting masked, for
+// printing or logging.
+func (c Config) Redacted() Config {
+	v := reflect.ValueOf(&c).Elem()
+	for _, s := range settings() {
+		if s.field.Tag.Get("secret") != "true" {
+			continue
+		}
+		f := v.FieldByIndex(s.index)
+		if f.Kind() == reflect.String && f.String() != "" {
+			f.SetString(redacted)
+		}
+	}
+	return c
+}
+
+// YAML renders c in the format of the config file.
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -1,9 +1,12 @@
 package create
 
 import (
-	"errors"
+	"context"
 	"strings"
+	"time"
 
+	"drexel.edu/voter-api/pkg/logging"
+	"drexel.edu/voter-api/pkg/metrics"
 	"drexel.edu/voter-api/pkg/storage"
 )
 
@@ -80,8 +83,11 @@ we want to create them. So lets create an interface for that.
 type Adapter interface {
 	//Make sure you capitalize these to make them public or you
 	//won't be able to use them!
-	CreateVoter(Voter) error
-	CreateVoterHistory(int, VoterHistory) error
+	//
+	//The context carries the request id (and later the deadline)
+	//of the caller all the way down to the repository.
+	CreateVoter(context.Context, Voter) error
+	CreateVoterHistory(context.Context, int, VoterHistory) error
 }
 
 /**
@@ -104,11 +110,11 @@ type Repository interface {
 	//remeber that on the storage Port, voter and
 	//voter history are one item, so we only need
 	//one addItem for both.
-	AddItem(*storage.Voter) error
+	AddItem(context.Context, *storage.Voter) error
 
 	//We will need a get function to check if the
 	//voter exists for the history we are about to add.
-	GetItem(int) (*storage.Voter, error)
+	GetItem(context.Context, int) (*storage.Voter, error)
 
 	//We will need the update function to add new Voter
 	//History. remember that there is only one Voter storage
@@ -116,12 +122,24 @@ type Repository interface {
 	//object. so when we add new history we are actually fetching
 	//an existing voter, editing the voterHistory map, and saving
 	//voter which counts as an update.
-	UpdateItem(*storage.Voter) error
+	UpdateItem(context.Context, *storage.Voter) error
+}
+
+// Polls knows the rules and the open hours of the polls, see
+// eligibility.Adapter. Check returns an error listing the rules the
+// voter does not meet, the built-in rule that the voter is active
+// included, VoteDate the date of a vote in UTC or an error if the poll
+// was not open at the time.
+type Polls interface {
+	Check(context.Context, *storage.Voter, int) error
+	VoteDate(context.Context, int, time.Time) (time.Time, error)
 }
 
 // Now we create a struct to implement the Adapter interface
 type adapter struct {
-	r Repository
+	r         Repository
+	precincts storage.Assigner
+	polls     Polls
 }
 
 //Our struct will need a New function so that things
@@ -143,8 +161,8 @@ the correctness of the program. In other words, if a piece of code
 works with a particular type, it should also work correctly when
 the code is replaced with a subtype of that type.
 **/
-func New(r Repository) Adapter {
-	return &adapter{r}
+func New(r Repository, precincts storage.Assigner, polls Polls) Adapter {
+	return &adapter{r, precincts, polls}
 }
 
 /**
@@ -168,12 +186,16 @@ and createVoterHistory functions.
 // do this the compiler wouldn't make the association and throw
 // the same error we talked about above. Make sure you remeber this
 // as it will pop up in all of your adapter! its very important!!!!
-func (a *adapter) CreateVoter(voter Voter) error {
+func (a *adapter) CreateVoter(ctx context.Context, voter Voter) (err error) {
+	//every mutation is logged with its latency once we return
+	defer logging.Mutation(ctx, "create voter", time.Now(), &err, voter.Id, 0)
+	defer metrics.Operation("create voter", &err)
+
 	//before we do anything, lets handle some validation
 	//lets make sure that the id exists (i.e., != 0) and
 	//that the Voter's name and email isn't blank.
 	if voter.Id == 0 {
-		return errors.New("invalid Voter Id")
+		return storage.Invalidf("invalid Voter Id")
 	}
 
 	//Note: there are two functions being used in tandem.
@@ -187,7 +209,7 @@ func (a *adapter) CreateVoter(voter Voter) error {
 	//Unicode. If the string is empty or only has whitespace,
 	//we will get a 0 as a result and end up throwing an error
 	if len(strings.TrimSpace(voter.Name)) == 0 {
-		return errors.New("voter name cannot be blank")
+		return storage.Invalidf("voter name cannot be blank")
 	}
 
 	//Do the same for Email... Extra Credit. find a way to
@@ -197,7 +219,7 @@ func (a *adapter) CreateVoter(voter Voter) error {
 	//
 	//hint: google "Regex"
 	if len(strings.TrimSpace(voter.Email)) == 0 {
-		return errors.New("voter email cannot be blank")
+		return storage.Invalidf("voter email cannot be blank")
 	}
 
 	//Now that we have done some basic data validation and
@@ -205,10 +227,46 @@ func (a *adapter) CreateVoter(voter Voter) error {
 	//are in the clear to add it to a repository. So let's
 	//convert it into a storage object
 
+	if voter.Address != nil {
+		if err := storage.CheckAddress(storage.Address(*voter.Address)); err != nil {
+			return err
+		}
+	}
+
+	//A voter starts out active or, if the registration still has to
+	//be verified, pending. Every other status is reached through a
+	//transition of the update Port.
+	switch voter.Status {
+	case "":
+		voter.Status = storage.StatusActive
+	case storage.StatusActive, storage.StatusPending:
+	default:
+		return storage.Invalidf("a new voter must be %s or %s", storage.StatusActive, storage.StatusPending)
+	}
+
+	now := time.Now().UTC()
+	registeredAt := now
+	if voter.RegisteredAt != nil {
+		if voter.RegisteredAt.After(now) {
+			return storage.Invalidf("registration date cannot be in the future")
+		}
+		registeredAt = voter.RegisteredAt.UTC()
+	}
 	storageObject := storage.Voter{
-		Id:    voter.Id,
-		Name:  voter.Name,
-		Email: voter.Email,
+		Id:              voter.Id,
+		Name:            voter.Name,
+		Email:           voter.Email,
+		Status:          voter.Status,
+		StatusChangedAt: &now,
+		RegisteredAt:    &registeredAt,
+	}
+	if voter.BirthDate != "" {
+		if storageObject.BirthDate, err = storage.ParseBirthDate(voter.BirthDate, now); err != nil {
+			return err
+		}
+	}
+	if voter.Address != nil {
+		storage.SetAddress(&storageObject, storage.Address(*voter.Address), a.precincts)
 	}
 
 	//that now. Notice that we did this in the method signature
@@ -217,7 +275,7 @@ func (a *adapter) CreateVoter(voter Voter) error {
 	//but it is also so this function, as a member of adapter can
 	//access its private methods and variables... In this case
 	//we want to use a to access r, the repsoitory. lets try it out.
-	err := a.r.AddItem(&storageObject)
+	err = a.r.AddItem(ctx, &storageObject)
 	if err != nil {
 		return err
 	}
@@ -235,22 +293,51 @@ func (a *adapter) CreateVoter(voter Voter) error {
 // Note: Please make sure you understand createVoter (above) before
 // you read this. this function is going to be a tad lighter on
 // explanations
-func (a *adapter) CreateVoterHistory(voterId int, voterHistory VoterHistory) error {
+func (a *adapter) CreateVoterHistory(ctx context.Context, voterId int, voterHistory VoterHistory) (err error) {
+	defer logging.Mutation(ctx, "create voter history", time.Now(), &err, voterId, voterHistory.PollId)
+	defer metrics.Operation("create voter history", &err)
 
 	//first off, lets check if the Voter exists. If not, no need to
 	//proceed with validation. we can use the repository to retrieve
 	//the voter.
-	targetVoter, err := a.r.GetItem(voterId)
+	targetVoter, err := a.r.GetItem(ctx, voterId)
 	if err != nil {
 		return err
 	}
 
+	//The rules of the poll come first, the status of the voter among
+	//them: only active voters may vote. The rejection lists every rule
+	//the voter does not meet, the same checks the eligibility
+	//endpoint shows. The status holds with or without polls configured.
+	if a.polls != nil {
+		if err := a.polls.Check(ctx, targetVoter, voterHistory.PollId); err != nil {
+			return err
+		}
+	}
+	if status := storage.StatusOf(targetVoter); status != storage.StatusActive {
+		return storage.Conflictf("voter %d is %s and may not vote", voterId, status)
+	}
+
 	//Now that we have our voter, we need to check if the pollId
 	//already exists. If it does, since this is a create Port, we
 	//throw an error. If it was an update port, we would just update
 	//it.
+
 	if _, exists := targetVoter.VoterHistory[voterHistory.PollId]; exists {
-		return errors.New("the specified pollId allready exists inside the voter")
+		metrics.HistoryConflict()
+		return storage.AlreadyExistsf("the specified pollId allready exists inside the voter")
+	}
+
+	//A vote without a date happens now. Dates are stored in UTC and
+	//have to fall within the open hours of the poll.
+	voteDate := voterHistory.VoteDate
+	if a.polls == nil {
+		if voteDate.IsZero() {
+			voteDate = time.Now()
+		}
+		voteDate = voteDate.UTC()
+	} else if voteDate, err = a.polls.VoteDate(ctx, voterHistory.PollId, voteDate); err != nil {
+		return err
 	}
 
 	//Now that we know the pollId doesn't already exist within voter,
@@ -260,7 +347,7 @@ func (a *adapter) CreateVoterHistory(voterId int, voterHistory VoterHistory) err
 	storageObject := storage.VoterHistory{
 		PollId:   voterHistory.PollId,
 		VoteId:   voterHistory.VoteId,
-		VoteDate: voterHistory.VoteDate,
+		VoteDate: voteDate,
 	}
 
 	//We need to make sure the map is initialized. If not initialize it.
@@ -272,7 +359,9 @@ func (a *adapter) CreateVoterHistory(voterId int, voterHistory VoterHistory) err
 
 	//now we just need to add it back into redis
 
-	a.r.UpdateItem(targetVoter)
+	if err = a.r.UpdateItem(ctx, targetVoter); err != nil {
+		return err
+	}
 
 	//Now that we have defined our adapter on the create port,
 	//we have to define an adapter on the redis port. lets jump
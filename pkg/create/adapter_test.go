@@ -0,0 +1,56 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// memRepository keeps the voters in memory
+type memRepository map[int]storage.Voter
+
+func (m memRepository) AddItem(ctx context.Context, voter *storage.Voter) error {
+	m[voter.Id] = *voter
+	return nil
+}
+
+func (m memRepository) GetItem(ctx context.Context, id int) (*storage.Voter, error) {
+	voter, ok := m[id]
+	if !ok {
+		return nil, storage.NotFoundf("voter %d not found", id)
+	}
+	return &voter, nil
+}
+
+func (m memRepository) UpdateItem(ctx context.Context, voter *storage.Voter) error {
+	m[voter.Id] = *voter
+	return nil
+}
+
+func TestOnlyActiveVotersMayVoteWithoutPolls(t *testing.T) {
+	tests := []struct {
+		status    string
+		wantError error
+	}{
+		{"", nil},
+		{storage.StatusActive, nil},
+		{storage.StatusPending, storage.ErrConflict},
+		{storage.StatusInactive, storage.ErrConflict},
+		{storage.StatusSuspended, storage.ErrConflict},
+		{storage.StatusPurged, storage.ErrConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.status, func(t *testing.T) {
+			m := memRepository{1: {Id: 1, Name: "Peter Patel", Status: test.status}}
+			err := New(m, nil, nil).CreateVoterHistory(context.Background(), 1, VoterHistory{PollId: 1, VoteId: 1})
+			if !errors.Is(err, test.wantError) {
+				t.Fatalf("got %v, want %v", err, test.wantError)
+			}
+			if voted := len(m[1].VoterHistory) == 1; voted != (test.wantError == nil) {
+				t.Errorf("the vote was recorded: %v", voted)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package create
 
+import "time"
+
 //This is part of the create Port!!!
 //
 //The Port is "create", Voter is a part of that port. The Ports
@@ -15,4 +17,27 @@ type Voter struct {
 	Name         string `json:"name"`
 	Email        string `json:"email"`
 	VoterHistory HistoryMap
+
+	//Address assigns the voter to a precinct
+	Address *Address `json:"address"`
+
+	//BirthDate is a day, 2006-01-02
+	BirthDate string `json:"birth_date"`
+
+	//RegisteredAt defaults to now, it is given when importing an
+	//existing roll
+	RegisteredAt *time.Time `json:"registered_at"`
+
+	//Status is StatusPending for a registration that still has to be
+	//verified, empty registers the voter as active
+	Status string `json:"status"`
+}
+
+type Address struct {
+	Number int    `json:"number"`
+	Street string `json:"street"`
+	Unit   string `json:"unit"`
+	City   string `json:"city"`
+	State  string `json:"state"`
+	Zip    string `json:"zip"`
 }
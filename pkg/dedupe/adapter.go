@@ -0,0 +1,238 @@
+package dedupe
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// The dedupe Port finds voters that were registered twice and merges
+// them. A merge folds the history of the duplicate into the surviving
+// voter, deletes the duplicate and leaves a tombstone in its place, all
+// in one transaction.
+
+// maxBlockSize skips a word shared by more voters than this when
+// looking for pairs. Every voter in a block is compared with every
+// other, a very common first name would make that quadratic in the
+// number of voters, and such pairs usually share a rarer word too.
+const maxBlockSize = 500
+
+type Adapter interface {
+	//Duplicates returns the pairs scoring at least minScore, best
+	//first
+	Duplicates(ctx context.Context, minScore float64) ([]Candidate, error)
+
+	//Merge folds the voter req.DuplicateId into the voter survivorId
+	Merge(ctx context.Context, survivorId int, req MergeRequest) (MergeResult, error)
+}
+
+type Repository interface {
+	GetAllItems(context.Context) ([]storage.Voter, error)
+}
+
+// TxRepository is what a merge needs inside its transaction
+type TxRepository interface {
+	GetItem(context.Context, int) (*storage.Voter, error)
+	UpdateItem(context.Context, *storage.Voter) error
+	DeleteItem(context.Context, int) error
+	AddTombstone(context.Context, storage.Tombstone) error
+}
+
+// Transact runs fn in a transaction, see batch.Transact
+type Transact func(ctx context.Context, fn func(TxRepository) error) error
+
+type adapter struct {
+	r        Repository
+	transact Transact
+}
+
+func New(r Repository, transact Transact) Adapter {
+	return &adapter{r, transact}
+}
+
+type normalized struct {
+	voter storage.Voter
+	name  []string
+	email string
+}
+
+func (a *adapter) Duplicates(ctx context.Context, minScore float64) ([]Candidate, error) {
+	if minScore <= 0 || minScore > 1 {
+		return nil, storage.Invalidf("the minimum score must be above 0 and at most 1")
+	}
+
+	voters, err := a.r.GetAllItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(voters, func(i, j int) bool { return voters[i].Id < voters[j].Id })
+
+	//only voters sharing an email or a word of the name are compared
+	all := make([]normalized, len(voters))
+	blocks := make(map[string][]int)
+	for i, voter := range voters {
+		all[i] = normalized{voter: voter, name: normalizeName(voter.Name), email: normalizeEmail(voter.Email)}
+		if all[i].email != "" {
+			blocks["email:"+all[i].email] = append(blocks["email:"+all[i].email], i)
+		}
+		for _, word := range all[i].name {
+			if len([]rune(word)) > 1 {
+				blocks["name:"+word] = append(blocks["name:"+word], i)
+			}
+		}
+	}
+
+	type pair struct{ i, j int }
+	seen := make(map[pair]bool)
+	candidates := []Candidate{}
+	for _, block := range blocks {
+		if len(block) > maxBlockSize {
+			continue
+		}
+		for x := 0; x < len(block); x++ {
+			for y := x + 1; y < len(block); y++ {
+				p := pair{block[x], block[y]}
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+
+				first, second := all[p.i], all[p.j]
+				sameEmail := first.email != "" && first.email == second.email
+				similarity := nameSimilarity(first.name, second.name)
+				s := score(similarity, sameEmail)
+				if s < minScore {
+					continue
+				}
+				candidates = append(candidates, Candidate{
+					Voter:          fromStorage(first.voter),
+					Duplicate:      fromStorage(second.voter),
+					Score:          s,
+					NameSimilarity: similarity,
+					SameEmail:      sameEmail,
+				})
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].Voter.Id != candidates[j].Voter.Id {
+			return candidates[i].Voter.Id < candidates[j].Voter.Id
+		}
+		return candidates[i].Duplicate.Id < candidates[j].Duplicate.Id
+	})
+	return candidates, nil
+}
+
+func (a *adapter) Merge(ctx context.Context, survivorId int, req MergeRequest) (MergeResult, error) {
+	if survivorId < 1 || req.DuplicateId < 1 {
+		return MergeResult{}, storage.Invalidf("invalid Voter Id")
+	}
+	if survivorId == req.DuplicateId {
+		return MergeResult{}, storage.Invalidf("a voter cannot be merged into itself")
+	}
+	if req.Strategy == "" {
+		req.Strategy = KeepEarliest
+	}
+	switch req.Strategy {
+	case KeepEarliest, KeepLatest, KeepSurvivor, KeepDuplicate:
+	default:
+		return MergeResult{}, storage.Invalidf("unknown strategy %q, use %s, %s, %s or %s", req.Strategy, KeepEarliest, KeepLatest, KeepSurvivor, KeepDuplicate)
+	}
+
+	var result MergeResult
+	err := a.transact(ctx, func(tx TxRepository) error {
+		//start over on every attempt of the transaction
+		result = MergeResult{Moved: []int{}, Conflicts: []Conflict{}}
+
+		survivor, err := tx.GetItem(ctx, survivorId)
+		if err != nil {
+			return err
+		}
+		duplicate, err := tx.GetItem(ctx, req.DuplicateId)
+		if err != nil {
+			return err
+		}
+		//a purged voter is only kept for the record and a suspended
+		//one is under review, neither may gain or lose votes
+		for _, voter := range []*storage.Voter{survivor, duplicate} {
+			if status := storage.StatusOf(voter); status == storage.StatusPurged || status == storage.StatusSuspended {
+				return storage.Conflictf("voter %d is %s and cannot be merged", voter.Id, status)
+			}
+		}
+		if survivor.VoterHistory == nil {
+			survivor.VoterHistory = make(storage.HistoryMap)
+		}
+
+		pollIds := make([]int, 0, len(duplicate.VoterHistory))
+		for pollId := range duplicate.VoterHistory {
+			pollIds = append(pollIds, pollId)
+		}
+		sort.Ints(pollIds)
+
+		for _, pollId := range pollIds {
+			theirs := duplicate.VoterHistory[pollId]
+			ours, conflict := survivor.VoterHistory[pollId]
+			if !conflict {
+				survivor.VoterHistory[pollId] = theirs
+				result.Moved = append(result.Moved, pollId)
+				continue
+			}
+			kept, dropped := resolve(req.Strategy, ours, theirs)
+			survivor.VoterHistory[pollId] = kept
+			result.Conflicts = append(result.Conflicts, Conflict{
+				PollId:  pollId,
+				Kept:    historyFromStorage(kept),
+				Dropped: historyFromStorage(dropped),
+			})
+		}
+
+		tombstone := storage.Tombstone{
+			Id:         duplicate.Id,
+			Name:       duplicate.Name,
+			Email:      duplicate.Email,
+			MergedInto: survivor.Id,
+			MergedAt:   time.Now().UTC(),
+		}
+		if err := tx.UpdateItem(ctx, survivor); err != nil {
+			return err
+		}
+		if err := tx.DeleteItem(ctx, duplicate.Id); err != nil {
+			return err
+		}
+		if err := tx.AddTombstone(ctx, tombstone); err != nil {
+			return err
+		}
+
+		result.Voter = fromStorage(*survivor)
+		result.Tombstone = Tombstone(tombstone)
+		return nil
+	})
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return result, nil
+}
+
+// resolve picks the history to keep of a poll both voters took part in.
+// A vote without a date never counts as the earlier or later one.
+func resolve(strategy string, ours, theirs storage.VoterHistory) (kept, dropped storage.VoterHistory) {
+	takeTheirs := false
+	switch strategy {
+	case KeepEarliest:
+		takeTheirs = !theirs.VoteDate.IsZero() && (ours.VoteDate.IsZero() || theirs.VoteDate.Before(ours.VoteDate))
+	case KeepLatest:
+		takeTheirs = !theirs.VoteDate.IsZero() && (ours.VoteDate.IsZero() || theirs.VoteDate.After(ours.VoteDate))
+	case KeepDuplicate:
+		takeTheirs = true
+	}
+	if takeTheirs {
+		return theirs, ours
+	}
+	return ours, theirs
+}
@@ -0,0 +1,284 @@
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// memRepository keeps the voters and tombstones in memory. A
+// transaction runs straight against it.
+type memRepository struct {
+	voters     map[int]storage.Voter
+	tombstones map[int]storage.Tombstone
+}
+
+func newMemRepository(voters ...storage.Voter) *memRepository {
+	m := &memRepository{voters: make(map[int]storage.Voter), tombstones: make(map[int]storage.Tombstone)}
+	for _, voter := range voters {
+		m.voters[voter.Id] = voter
+	}
+	return m
+}
+
+func (m *memRepository) GetAllItems(ctx context.Context) ([]storage.Voter, error) {
+	all := make([]storage.Voter, 0, len(m.voters))
+	for _, voter := range m.voters {
+		all = append(all, voter)
+	}
+	return all, nil
+}
+
+func (m *memRepository) GetItem(ctx context.Context, id int) (*storage.Voter, error) {
+	voter, ok := m.voters[id]
+	if !ok {
+		return nil, storage.NotFoundf("voter %d not found", id)
+	}
+	history := make(storage.HistoryMap, len(voter.VoterHistory))
+	for pollId, h := range voter.VoterHistory {
+		history[pollId] = h
+	}
+	voter.VoterHistory = history
+	return &voter, nil
+}
+
+func (m *memRepository) UpdateItem(ctx context.Context, voter *storage.Voter) error {
+	m.voters[voter.Id] = *voter
+	return nil
+}
+
+func (m *memRepository) DeleteItem(ctx context.Context, id int) error {
+	delete(m.voters, id)
+	return nil
+}
+
+func (m *memRepository) AddTombstone(ctx context.Context, tombstone storage.Tombstone) error {
+	m.tombstones[tombstone.Id] = tombstone
+	return nil
+}
+
+func (m *memRepository) transact(ctx context.Context, fn func(TxRepository) error) error {
+	return fn(m)
+}
+
+func newAdapter(m *memRepository) Adapter {
+	return New(m, m.transact)
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Peter Patel", "peter patel", 1},
+		{"Patel, Peter", "peter patel", 1},
+		{"  Peter   O'Patel ", "o'patel, PETER", 1},
+		{"Peter Patel", "Petr Patel", 1 - 1.0/11},
+		{"Ann Smith", "Anne Smyth", 1 - 2.0/10},
+		{"Ann Smith", "Bob Jones", 1 - 8.0/9},
+		{"", "", 0},
+	}
+	for _, test := range tests {
+		got := nameSimilarity(normalizeName(test.a), normalizeName(test.b))
+		if diff := got - test.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("nameSimilarity(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestDuplicatesFindsNearDuplicates(t *testing.T) {
+	m := newMemRepository(
+		storage.Voter{Id: 1, Name: "Peter Patel", Email: "peter@example.org"},
+		storage.Voter{Id: 2, Name: "Patel, Peter", Email: "pp@example.org"},
+		storage.Voter{Id: 3, Name: "Petr Patel", Email: " PETER@Example.org"},
+		storage.Voter{Id: 4, Name: "Ann Smith", Email: "ann@example.org"},
+		storage.Voter{Id: 5, Name: "Anne Smyth", Email: "ANN@example.org"},
+		storage.Voter{Id: 6, Name: "Bob Jones", Email: "bob@example.org"},
+		//same email, nothing else in common
+		storage.Voter{Id: 7, Name: "Carla Ruiz", Email: "family@example.org"},
+		storage.Voter{Id: 8, Name: "Dan Ruiz", Email: "family@example.org"},
+	)
+
+	candidates, err := newAdapter(m).Duplicates(context.Background(), DefaultMinScore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range candidates {
+		got = append(got, fmt.Sprintf("%d-%d", c.Voter.Id, c.Duplicate.Id))
+	}
+	//best first: the same email and one typo, the same email and two,
+	//the same email alone, the same name with another email, and one
+	//typo with another email
+	want := "[1-3 4-5 7-8 1-2 2-3]"
+	if fmt.Sprint(got) != want {
+		for _, c := range candidates {
+			t.Logf("%d-%d score %.3f", c.Voter.Id, c.Duplicate.Id, c.Score)
+		}
+		t.Fatalf("got the pairs %v, want %s", got, want)
+	}
+	for i, c := range candidates {
+		if c.SameEmail != (i < 3) {
+			t.Errorf("SameEmail of %d-%d is %v", c.Voter.Id, c.Duplicate.Id, c.SameEmail)
+		}
+	}
+	if candidates[3].Score != emailWeight {
+		t.Errorf("the same name with another email scores %v, want %v", candidates[3].Score, emailWeight)
+	}
+
+	if _, err := newAdapter(m).Duplicates(context.Background(), 0); !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("a minimum score of 0 gave %v, want ErrInvalid", err)
+	}
+}
+
+func TestDuplicatesSkipsBlocksOverMaxBlockSize(t *testing.T) {
+	//voters named "maria w0001", "maria w0002" and so on only share the
+	//word maria, and would pair up at a score of 0.5
+	crowd := func(n int) *memRepository {
+		m := newMemRepository()
+		for i := 1; i <= n; i++ {
+			m.voters[i] = storage.Voter{Id: i, Name: fmt.Sprintf("Maria W%04d", i), Email: fmt.Sprintf("m%d@example.org", i)}
+		}
+		return m
+	}
+
+	m := crowd(maxBlockSize)
+	candidates, err := newAdapter(m).Duplicates(context.Background(), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := maxBlockSize * (maxBlockSize - 1) / 2; len(candidates) != want {
+		t.Errorf("at the cap got %d pairs, want every one of the %d", len(candidates), want)
+	}
+
+	m = crowd(maxBlockSize + 1)
+	//a pair that also shares a rare word is still found
+	m.voters[1000] = storage.Voter{Id: 1000, Name: "Maria Quintanilla", Email: "mq@example.org"}
+	m.voters[1001] = storage.Voter{Id: 1001, Name: "Marya Quintanilla", Email: "mq2@example.org"}
+	candidates, err = newAdapter(m).Duplicates(context.Background(), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].Voter.Id != 1000 || candidates[0].Duplicate.Id != 1001 {
+		for _, c := range candidates[:min(len(candidates), 5)] {
+			t.Logf("%d-%d score %.3f", c.Voter.Id, c.Duplicate.Id, c.Score)
+		}
+		t.Fatalf("got %d pairs, want only 1000-1001 from the block of quintanilla", len(candidates))
+	}
+}
+
+func TestMergeLeavesATombstone(t *testing.T) {
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := march.AddDate(0, 1, 0)
+	m := newMemRepository(
+		storage.Voter{Id: 1, Name: "Peter Patel", Email: "peter@example.org", VoterHistory: storage.HistoryMap{
+			1: {PollId: 1, VoteId: 10, VoteDate: march},
+			2: {PollId: 2, VoteId: 20, VoteDate: april},
+		}},
+		storage.Voter{Id: 2, Name: "Petr Patel", Email: "PETER@example.org", VoterHistory: storage.HistoryMap{
+			2: {PollId: 2, VoteId: 21, VoteDate: march},
+			3: {PollId: 3, VoteId: 31, VoteDate: april},
+		}},
+	)
+
+	result, err := newAdapter(m).Merge(context.Background(), 1, MergeRequest{DuplicateId: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(result.Moved) != "[3]" {
+		t.Errorf("moved the polls %v, want [3]", result.Moved)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Kept.VoteId != 21 || result.Conflicts[0].Dropped.VoteId != 20 {
+		t.Errorf("got the conflicts %+v, want poll 2 settled for the earlier vote 21", result.Conflicts)
+	}
+
+	if _, ok := m.voters[2]; ok {
+		t.Error("the duplicate was not deleted")
+	}
+	survivor := m.voters[1]
+	if len(survivor.VoterHistory) != 3 || survivor.VoterHistory[2].VoteId != 21 || survivor.VoterHistory[3].VoteId != 31 {
+		t.Errorf("the survivor has the history %+v", survivor.VoterHistory)
+	}
+
+	tombstone, ok := m.tombstones[2]
+	if !ok {
+		t.Fatal("no tombstone was left for the duplicate")
+	}
+	if tombstone.MergedInto != 1 || tombstone.Name != "Petr Patel" || tombstone.Email != "PETER@example.org" || tombstone.MergedAt.IsZero() {
+		t.Errorf("got the tombstone %+v", tombstone)
+	}
+	if result.Tombstone != Tombstone(tombstone) {
+		t.Errorf("the result has the tombstone %+v, %+v was stored", result.Tombstone, tombstone)
+	}
+}
+
+func TestMergeRefusesBadRequests(t *testing.T) {
+	m := newMemRepository(
+		storage.Voter{Id: 1, Name: "Peter Patel"},
+		storage.Voter{Id: 2, Name: "Petr Patel"},
+		storage.Voter{Id: 3, Name: "Peter Patel", Status: storage.StatusPurged},
+		storage.Voter{Id: 4, Name: "Peter Patel", Status: storage.StatusSuspended},
+	)
+	tests := []struct {
+		name      string
+		survivor  int
+		request   MergeRequest
+		wantError error
+	}{
+		{"into itself", 1, MergeRequest{DuplicateId: 1}, storage.ErrInvalid},
+		{"no duplicate", 1, MergeRequest{}, storage.ErrInvalid},
+		{"unknown strategy", 1, MergeRequest{DuplicateId: 2, Strategy: "newest"}, storage.ErrInvalid},
+		{"missing duplicate", 1, MergeRequest{DuplicateId: 9}, storage.ErrNotFound},
+		{"missing survivor", 9, MergeRequest{DuplicateId: 2}, storage.ErrNotFound},
+		{"purged duplicate", 1, MergeRequest{DuplicateId: 3}, storage.ErrConflict},
+		{"purged survivor", 3, MergeRequest{DuplicateId: 1}, storage.ErrConflict},
+		{"suspended duplicate", 1, MergeRequest{DuplicateId: 4}, storage.ErrConflict},
+		{"suspended survivor", 4, MergeRequest{DuplicateId: 2}, storage.ErrConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newAdapter(m).Merge(context.Background(), test.survivor, test.request)
+			if !errors.Is(err, test.wantError) {
+				t.Errorf("got %v, want %v", err, test.wantError)
+			}
+			if len(m.voters) != 4 || len(m.tombstones) != 0 {
+				t.Errorf("a refused merge changed the voters %v or tombstones %v", m.voters, m.tombstones)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := march.AddDate(0, 1, 0)
+	ours := storage.VoterHistory{PollId: 1, VoteId: 1, VoteDate: april}
+	theirs := storage.VoterHistory{PollId: 1, VoteId: 2, VoteDate: march}
+	undated := storage.VoterHistory{PollId: 1, VoteId: 3}
+
+	tests := []struct {
+		strategy     string
+		ours, theirs storage.VoterHistory
+		kept         int
+	}{
+		{KeepEarliest, ours, theirs, 2},
+		{KeepLatest, ours, theirs, 1},
+		{KeepSurvivor, ours, theirs, 1},
+		{KeepDuplicate, ours, theirs, 2},
+		{KeepEarliest, ours, undated, 1},
+		{KeepEarliest, undated, theirs, 2},
+		{KeepLatest, undated, theirs, 2},
+	}
+	for _, test := range tests {
+		kept, dropped := resolve(test.strategy, test.ours, test.theirs)
+		if kept.VoteId != test.kept {
+			t.Errorf("%s of %d and %d kept %d, want %d", test.strategy, test.ours.VoteId, test.theirs.VoteId, kept.VoteId, test.kept)
+		}
+		if dropped.VoteId == kept.VoteId {
+			t.Errorf("%s dropped the vote it kept", test.strategy)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package dedupe
+
+import (
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// The ways to settle a poll both voters of a merge have a history in
+const (
+	//KeepEarliest keeps the vote with the earlier date, the later
+	//one would have been a second vote in the same poll
+	KeepEarliest  = "earliest"
+	KeepLatest    = "latest"
+	KeepSurvivor  = "survivor"
+	KeepDuplicate = "duplicate"
+)
+
+// Candidate is a pair of voters that look like the same person
+type Candidate struct {
+	Voter          Voter   `json:"voter"`
+	Duplicate      Voter   `json:"duplicate"`
+	Score          float64 `json:"score"`
+	NameSimilarity float64 `json:"name_similarity"`
+	SameEmail      bool    `json:"same_email"`
+}
+
+// MergeRequest folds the voter with DuplicateId into the voter the
+// request is sent to
+type MergeRequest struct {
+	DuplicateId int `json:"duplicate_id"`
+
+	//Strategy settles the polls both voters have a history in, one
+	//of the Keep constants, KeepEarliest if empty
+	Strategy string `json:"strategy"`
+}
+
+// MergeResult is the surviving voter and what happened on the way
+type MergeResult struct {
+	Voter     Voter      `json:"voter"`
+	Tombstone Tombstone  `json:"tombstone"`
+	Moved     []int      `json:"moved"`
+	Conflicts []Conflict `json:"conflicts"`
+}
+
+// Conflict is a poll both voters had a history in
+type Conflict struct {
+	PollId  int          `json:"poll_id"`
+	Kept    VoterHistory `json:"kept"`
+	Dropped VoterHistory `json:"dropped"`
+}
+
+type Tombstone struct {
+	Id         int       `json:"id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	MergedInto int       `json:"merged_into"`
+	MergedAt   time.Time `json:"merged_at"`
+}
+
+type HistoryMap map[int]VoterHistory
+
+type Voter struct {
+	Id           int        `json:"id"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	VoterHistory HistoryMap `json:"history"`
+}
+
+type VoterHistory struct {
+	PollId   int       `json:"poll_id"`
+	VoteId   int       `json:"vote_id"`
+	VoteDate time.Time `json:"vote_date"`
+}
+
+func fromStorage(voter storage.Voter) Voter {
+	history := make(HistoryMap, len(voter.VoterHistory))
+	for pollId, h := range voter.VoterHistory {
+		history[pollId] = historyFromStorage(h)
+	}
+	return Voter{
+		Id:           voter.Id,
+		Name:         voter.Name,
+		Email:        voter.Email,
+		VoterHistory: history,
+	}
+}
+
+func historyFromStorage(h storage.VoterHistory) VoterHistory {
+	return VoterHistory{
+		PollId:   h.PollId,
+		VoteId:   h.VoteId,
+		VoteDate: h.VoteDate,
+	}
+}
@@ -0,0 +1,77 @@
+package dedupe
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scoring. Names are compared after normalizing them, lower case, only
+// letters and digits, words sorted so "Patel, Peter" and "peter patel"
+// are the same name. The similarity of two normalized names is one minus
+// their edit distance relative to the longer one.
+//
+// A shared email is the stronger signal, registration drives mostly
+// produce the same address in a different case:
+//
+//	same email:      score = 0.9 + 0.1 * name similarity
+//	different email: score = 0.9 * name similarity
+
+const (
+	// DefaultMinScore reports identical names with different emails and
+	// names one typo apart with the same email
+	DefaultMinScore = 0.8
+
+	emailWeight = 0.9
+)
+
+// normalizeName returns the words of name, lower case and sorted
+func normalizeName(name string) []string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return words
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// nameSimilarity is between 0 for nothing in common and 1 for the same
+// normalized name
+func nameSimilarity(a, b []string) float64 {
+	ra, rb := []rune(strings.Join(a, " ")), []rune(strings.Join(b, " "))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func score(nameSim float64, sameEmail bool) float64 {
+	if sameEmail {
+		return emailWeight + (1-emailWeight)*nameSim
+	}
+	return emailWeight * nameSim
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
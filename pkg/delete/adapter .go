@@ -1,25 +1,28 @@
 package delete
 
 import (
-	"errors"
+	"context"
+	"time"
 
+	"drexel.edu/voter-api/pkg/logging"
+	"drexel.edu/voter-api/pkg/metrics"
 	"drexel.edu/voter-api/pkg/storage"
 )
 
 type Adapter interface {
-	DeleteVoter(int) error
-	DeleteVoterHistory(int, int) error
-	//DeleteAllVoterHistory(int) error
-	DeleteAllVoters() error
+	DeleteVoter(context.Context, int) error
+	DeleteVoterHistory(context.Context, int, int) error
+	DeleteAllVoterHistory(context.Context, int) (int, error)
+	DeleteAllVoters(context.Context) (int, error)
 }
 
 type Repository interface {
-	GetItem(int) (*storage.Voter, error)
-	UpdateItem(item *storage.Voter) error
-	DeleteItem(int) error
-	DeleteVoterHistory(int, int) error
-	//DeleteAllVoterHistory(int) error
-	DeleteAllVoters() error
+	GetItem(context.Context, int) (*storage.Voter, error)
+	UpdateItem(context.Context, *storage.Voter) error
+	DeleteItem(context.Context, int) error
+	DeleteVoterHistory(context.Context, int, int) error
+	DeleteAllVoterHistory(context.Context, int) (int, error)
+	DeleteAllVoters(context.Context) (int, error)
 }
 
 // Now we create a struct to implement the Adapter interface
@@ -32,34 +35,39 @@ func New(r Repository) Adapter {
 	return &adapter{r}
 }
 
-func (a *adapter) DeleteVoter(voterId int) error {
+func (a *adapter) DeleteVoter(ctx context.Context, voterId int) (err error) {
+	defer logging.Mutation(ctx, "delete voter", time.Now(), &err, voterId, 0)
+	defer metrics.Operation("delete voter", &err)
+
 	if voterId < 1 {
-		return errors.New("invalid Voter Id")
+		return storage.Invalidf("invalid Voter Id")
 	}
-	return a.r.DeleteItem(voterId)
+	return a.r.DeleteItem(ctx, voterId)
 }
 
 // Delete voter history
 
-func (a *adapter) DeleteVoterHistory(voterId int, pollId int) error {
+func (a *adapter) DeleteVoterHistory(ctx context.Context, voterId int, pollId int) (err error) {
+	defer logging.Mutation(ctx, "delete voter history", time.Now(), &err, voterId, pollId)
+	defer metrics.Operation("delete voter history", &err)
 
 	if voterId < 1 || pollId < 1 {
 
-		return errors.New("invalid Voter Id or Poll Id")
+		return storage.Invalidf("invalid Voter Id or Poll Id")
 	}
 
-	targetVoter, err := a.r.GetItem(voterId)
+	targetVoter, err := a.r.GetItem(ctx, voterId)
 	if err != nil {
 		return err
 	}
 
 	if _, exists := targetVoter.VoterHistory[pollId]; !exists {
-		return errors.New("the specified pollId does not exist in the voter's history")
+		return storage.NotFoundf("the specified pollId does not exist in the voter's history")
 	}
 
 	delete(targetVoter.VoterHistory, pollId)
 
-	err = a.r.UpdateItem(targetVoter)
+	err = a.r.UpdateItem(ctx, targetVoter)
 
 	if err != nil {
 		return err
@@ -68,9 +76,23 @@ func (a *adapter) DeleteVoterHistory(voterId int, pollId int) error {
 	return nil
 }
 
+// Delete all voter history for a voter, the voter itself is kept
+
+func (a *adapter) DeleteAllVoterHistory(ctx context.Context, voterId int) (numDeleted int, err error) {
+	defer logging.Mutation(ctx, "delete all voter history", time.Now(), &err, voterId, 0)
+	defer metrics.Operation("delete all voter history", &err)
+
+	if voterId < 1 {
+		return 0, storage.Invalidf("invalid Voter Id")
+	}
+	return a.r.DeleteAllVoterHistory(ctx, voterId)
+}
+
 // Delete All
 
-func (a *adapter) DeleteAllVoters() error {
+func (a *adapter) DeleteAllVoters(ctx context.Context) (numDeleted int, err error) {
+	defer logging.Mutation(ctx, "delete all voters", time.Now(), &err, 0, 0)
+	defer metrics.Operation("delete all voters", &err)
 
-	return a.r.DeleteAllVoters()
+	return a.r.DeleteAllVoters(ctx)
 }
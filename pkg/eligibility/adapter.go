@@ -0,0 +1,203 @@
+package eligibility
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/tenant"
+)
+
+// The eligibility Port decides whether a voter may take part in a poll.
+// The create Port asks it before recording a vote, GET
+// /voters/:id/eligibility previews the answer.
+
+type Adapter interface {
+	//Evaluate runs every rule of the poll for the voter
+	Evaluate(ctx context.Context, voterId int, pollId int) (Decision, error)
+
+	//Check evaluates the rules for a voter that was already read, it
+	//returns a *Rejection when the voter is not eligible
+	Check(ctx context.Context, voter *storage.Voter, pollId int) error
+
+	//VoteDate returns the date of a vote in the poll in UTC, now if
+	//it is zero. It fails if the date is in the future or the poll is
+	//not open at the time.
+	VoteDate(ctx context.Context, pollId int, date time.Time) (time.Time, error)
+}
+
+type Repository interface {
+	GetItem(context.Context, int) (*storage.Voter, error)
+	GetPrecinct(context.Context, string) (*storage.Precinct, error)
+}
+
+// Decision is the outcome of every rule of a poll for a voter
+type Decision struct {
+	VoterId  int     `json:"voter_id"`
+	PollId   int     `json:"poll_id"`
+	Eligible bool    `json:"eligible"`
+	Checks   []Check `json:"checks"`
+
+	//Window is when votes in the poll are accepted, nil if always
+	Window *Window `json:"window,omitempty"`
+}
+
+// Window is when a poll is open, in the time zone of the jurisdiction
+type Window struct {
+	Opens  time.Time `json:"opens"`
+	Closes time.Time `json:"closes"`
+}
+
+// Check is the outcome of a single rule
+type Check struct {
+	Rule   string `json:"rule"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Failed returns the checks that did not pass
+func (d Decision) Failed() []Check {
+	var failed []Check
+	for _, c := range d.Checks {
+		if !c.Passed {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Rejection is the error of a vote refused by the rules. It is a
+// storage.ErrConflict, the voter would have to change to be allowed.
+type Rejection struct {
+	Decision Decision
+}
+
+func (r *Rejection) Error() string {
+	var rules []string
+	for _, c := range r.Decision.Failed() {
+		rules = append(rules, c.Rule)
+	}
+	return fmt.Sprintf("voter %d is not eligible for poll %d, failed %s", r.Decision.VoterId, r.Decision.PollId, strings.Join(rules, ", "))
+}
+
+func (r *Rejection) Unwrap() error { return storage.ErrConflict }
+
+type adapter struct {
+	r      Repository
+	policy *Policy
+}
+
+// New builds the eligibility Port, a nil policy only checks the status
+// of voters
+func New(r Repository, policy *Policy) Adapter {
+	if policy == nil {
+		policy = &Policy{loc: time.UTC}
+	}
+	return &adapter{r, policy}
+}
+
+func (a *adapter) Evaluate(ctx context.Context, voterId int, pollId int) (Decision, error) {
+	if voterId < 1 {
+		return Decision{}, storage.Invalidf("invalid Voter Id")
+	}
+	if pollId < 1 {
+		return Decision{}, storage.Invalidf("invalid Poll Id")
+	}
+	voter, err := a.r.GetItem(ctx, voterId)
+	if err != nil {
+		return Decision{}, err
+	}
+	return a.decide(ctx, voter, pollId)
+}
+
+func (a *adapter) Check(ctx context.Context, voter *storage.Voter, pollId int) error {
+	decision, err := a.decide(ctx, voter, pollId)
+	if err != nil {
+		return err
+	}
+	if !decision.Eligible {
+		return &Rejection{decision}
+	}
+	return nil
+}
+
+// policyIn returns the policy in the time zone of the tenant in ctx
+func (a *adapter) policyIn(ctx context.Context) (*Policy, error) {
+	return a.policy.In(tenant.Location(ctx, a.policy.loc))
+}
+
+func (a *adapter) decide(ctx context.Context, voter *storage.Voter, pollId int) (Decision, error) {
+	policy, err := a.policyIn(ctx)
+	if err != nil {
+		return Decision{}, err
+	}
+	poll := policy.polls[pollId]
+	f := facts{voter: voter, pollDate: poll.date, loc: policy.loc}
+
+	rules := append([]rule{active{}}, poll.rules...)
+	for _, r := range poll.rules {
+		if _, ok := r.(district); ok {
+			var err error
+			if f.district, err = a.district(ctx, voter); err != nil {
+				return Decision{}, err
+			}
+			break
+		}
+	}
+
+	decision := Decision{VoterId: voter.Id, PollId: pollId, Eligible: true, Checks: make([]Check, 0, len(rules))}
+	if !poll.opens.IsZero() {
+		decision.Window = &Window{Opens: poll.opens, Closes: poll.closes}
+	}
+	for _, r := range rules {
+		passed, reason := r.evaluate(f)
+		decision.Checks = append(decision.Checks, Check{Rule: r.name(), Passed: passed, Reason: reason})
+		decision.Eligible = decision.Eligible && passed
+	}
+	return decision, nil
+}
+
+// maxClockSkew lets a client whose clock is a little ahead still record
+// a vote as it happens
+const maxClockSkew = time.Minute
+
+func (a *adapter) VoteDate(ctx context.Context, pollId int, date time.Time) (time.Time, error) {
+	policy, err := a.policyIn(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now().UTC()
+	if date.IsZero() {
+		date = now
+	}
+	date = date.UTC()
+	if date.After(now.Add(maxClockSkew)) {
+		return time.Time{}, storage.Invalidf("vote date %s is in the future", date.Format(time.RFC3339))
+	}
+
+	poll := policy.polls[pollId]
+	if !poll.opens.IsZero() && (date.Before(poll.opens) || !date.Before(poll.closes)) {
+		return time.Time{}, storage.Invalidf("poll %d is open from %s until %s, the vote date %s is outside of it",
+			pollId, poll.opens.Format(time.RFC3339), poll.closes.Format(time.RFC3339), date.In(policy.loc).Format(time.RFC3339))
+	}
+	return date, nil
+}
+
+// district looks up the district of the precinct of voter, empty if the
+// precinct is not in the reference table
+func (a *adapter) district(ctx context.Context, voter *storage.Voter) (string, error) {
+	if voter.PrecinctId == "" {
+		return "", nil
+	}
+	p, err := a.r.GetPrecinct(ctx, voter.PrecinctId)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return p.District, nil
+}
@@ -0,0 +1,249 @@
+package eligibility
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// memRepository keeps the voters and the precincts in memory
+type memRepository struct {
+	voters    map[int]storage.Voter
+	precincts map[string]storage.Precinct
+}
+
+func (m memRepository) GetItem(ctx context.Context, id int) (*storage.Voter, error) {
+	voter, ok := m.voters[id]
+	if !ok {
+		return nil, storage.NotFoundf("voter %d not found", id)
+	}
+	return &voter, nil
+}
+
+func (m memRepository) GetPrecinct(ctx context.Context, id string) (*storage.Precinct, error) {
+	p, ok := m.precincts[id]
+	if !ok {
+		return nil, storage.NotFoundf("precinct %s not found", id)
+	}
+	return &p, nil
+}
+
+// eastern is a jurisdiction five hours behind UTC
+var eastern = time.FixedZone("EST", -5*60*60)
+
+func day(s string) *time.Time {
+	d, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return &d
+}
+
+func at(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+// evaluate runs the rules of poll 1, held on 2024-11-05 in eastern
+func evaluate(t *testing.T, rules []RuleSpec, voter storage.Voter) Decision {
+	t.Helper()
+	policy, err := NewPolicy([]PollSpec{{Poll: 1, Date: "2024-11-05", Rules: rules}}, eastern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	voter.Id = 1
+	m := memRepository{
+		voters: map[int]storage.Voter{1: voter},
+		precincts: map[string]storage.Precinct{
+			"27-04": {Id: "27-04", District: "3"},
+			"27-05": {Id: "27-05", District: "4"},
+		},
+	}
+	decision, err := New(m, policy).Evaluate(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decision
+}
+
+func TestRules(t *testing.T) {
+	minAge := []RuleSpec{{Rule: RuleMinAge, Age: 18}}
+	cutoff := []RuleSpec{{Rule: RuleRegistrationCutoff, Days: 15}}
+	districts := []RuleSpec{{Rule: RuleDistrict, Districts: []string{"3", "5"}}}
+
+	tests := []struct {
+		name     string
+		rules    []RuleSpec
+		voter    storage.Voter
+		eligible bool
+	}{
+		{"18 on the day of the poll", minAge, storage.Voter{BirthDate: day("2006-11-05")}, true},
+		{"18 the day after the poll", minAge, storage.Voter{BirthDate: day("2006-11-06")}, false},
+		{"no birth date", minAge, storage.Voter{}, false},
+
+		//the cutoff is 2024-10-21, the day of registering is the day in
+		//eastern
+		{"registered on the cutoff", cutoff, storage.Voter{RegisteredAt: at("2024-10-21T00:00:00-05:00")}, true},
+		{"registered late on the cutoff, the next day in UTC", cutoff, storage.Voter{RegisteredAt: at("2024-10-22T04:30:00Z")}, true},
+		{"registered the day after the cutoff", cutoff, storage.Voter{RegisteredAt: at("2024-10-22T05:00:00Z")}, false},
+		{"no registration date", cutoff, storage.Voter{}, false},
+
+		{"in the district", districts, storage.Voter{PrecinctId: "27-04"}, true},
+		{"in another district", districts, storage.Voter{PrecinctId: "27-05"}, false},
+		{"in a precinct not in the table", districts, storage.Voter{PrecinctId: "99-01"}, false},
+		{"without a precinct", districts, storage.Voter{}, false},
+
+		{"no rules", nil, storage.Voter{}, true},
+		{"no rules but suspended", nil, storage.Voter{Status: storage.StatusSuspended}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := evaluate(t, test.rules, test.voter)
+			if decision.Eligible != test.eligible {
+				t.Fatalf("eligible is %v, want %v: %+v", decision.Eligible, test.eligible, decision.Checks)
+			}
+			if len(decision.Checks) != len(test.rules)+1 || decision.Checks[0].Rule != RuleStatus {
+				t.Errorf("got the checks %+v, want the status and every rule", decision.Checks)
+			}
+			for _, c := range decision.Failed() {
+				if c.Reason == "" {
+					t.Errorf("%s failed without a reason", c.Rule)
+				}
+			}
+		})
+	}
+}
+
+func TestAgeOn(t *testing.T) {
+	tests := []struct {
+		birth, day string
+		age        int
+	}{
+		{"2006-11-05", "2024-11-05", 18},
+		{"2006-11-05", "2024-11-04", 17},
+		{"2006-12-01", "2024-11-05", 17},
+		{"2004-02-29", "2022-02-28", 17},
+		{"2004-02-29", "2022-03-01", 18},
+		{"2004-02-29", "2024-02-29", 20},
+	}
+	for _, test := range tests {
+		if got := ageOn(*day(test.birth), *day(test.day)); got != test.age {
+			t.Errorf("born %s is %d on %s, want %d", test.birth, got, test.day, test.age)
+		}
+	}
+}
+
+func TestCheckRejects(t *testing.T) {
+	policy, err := NewPolicy([]PollSpec{{Poll: 1, Date: "2024-11-05", Rules: []RuleSpec{{Rule: RuleMinAge, Age: 18}}}}, eastern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = New(memRepository{}, policy).Check(context.Background(), &storage.Voter{Id: 1, BirthDate: day("2010-01-01")}, 1)
+	var rejection *Rejection
+	if !errors.As(err, &rejection) || !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("got %v, want a Rejection", err)
+	}
+	if failed := rejection.Decision.Failed(); len(failed) != 1 || failed[0].Rule != RuleMinAge {
+		t.Errorf("failed %+v, want only %s", failed, RuleMinAge)
+	}
+}
+
+func TestVoteDateWindow(t *testing.T) {
+	policy, err := NewPolicy([]PollSpec{
+		//all of the day in eastern, 05:00 UTC to 05:00 UTC
+		{Poll: 1, Date: "2024-11-05"},
+		//07:00 to 20:00 in eastern
+		{Poll: 2, Date: "2024-11-05", Opens: "2024-11-05T07:00", Closes: "2024-11-05T20:00"},
+	}, eastern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(memRepository{}, policy)
+
+	tests := []struct {
+		poll int
+		date string
+		ok   bool
+	}{
+		{1, "2024-11-05T04:59:59Z", false},
+		{1, "2024-11-05T05:00:00Z", true},
+		{1, "2024-11-05T23:30:00-05:00", true},
+		{1, "2024-11-06T04:59:59Z", true},
+		{1, "2024-11-06T05:00:00Z", false},
+		{2, "2024-11-05T11:59:59Z", false},
+		{2, "2024-11-05T12:00:00Z", true},
+		{2, "2024-11-06T00:59:59Z", true},
+		{2, "2024-11-06T01:00:00Z", false},
+		//a poll without a window is always open
+		{3, "2020-01-01T00:00:00Z", true},
+	}
+	for _, test := range tests {
+		date, err := a.VoteDate(context.Background(), test.poll, *at(test.date))
+		if (err == nil) != test.ok {
+			t.Errorf("a vote in poll %d at %s gave %v, want accepted %v", test.poll, test.date, err, test.ok)
+			continue
+		}
+		if err == nil && (date.Location() != time.UTC || !date.Equal(*at(test.date))) {
+			t.Errorf("a vote at %s was dated %s, want the same time in UTC", test.date, date)
+		}
+		if err != nil && !errors.Is(err, storage.ErrInvalid) {
+			t.Errorf("got %v, want ErrInvalid", err)
+		}
+	}
+}
+
+func TestPolicyIn(t *testing.T) {
+	policy, err := NewPolicy([]PollSpec{{Poll: 1, Date: "2024-11-05", Opens: "2024-11-05T07:00", Closes: "2024-11-05T20:00"}}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pacific := time.FixedZone("PST", -8*60*60)
+	zoned, err := policy.In(pacific)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := policy.In(pacific); again != zoned {
+		t.Error("the policy in a zone was built twice")
+	}
+	if same, _ := policy.In(time.UTC); same != policy {
+		t.Error("the policy in its own zone is another one")
+	}
+	poll := zoned.polls[1]
+	if !poll.opens.Equal(*at("2024-11-05T15:00:00Z")) || !poll.closes.Equal(*at("2024-11-06T04:00:00Z")) {
+		t.Errorf("the poll is open from %s until %s in pacific", poll.opens, poll.closes)
+	}
+}
+
+func TestVoteDateClockSkew(t *testing.T) {
+	a := New(memRepository{}, nil)
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		date time.Time
+		ok   bool
+	}{
+		{"now", now, true},
+		{"a little ahead", now.Add(maxClockSkew / 2), true},
+		{"too far ahead", now.Add(2 * maxClockSkew), false},
+		{"in the past", now.AddDate(0, 0, -1), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := a.VoteDate(context.Background(), 1, test.date); (err == nil) != test.ok {
+				t.Errorf("got %v, want accepted %v", err, test.ok)
+			}
+		})
+	}
+
+	date, err := a.VoteDate(context.Background(), 1, time.Time{})
+	if err != nil || date.Location() != time.UTC || time.Since(date) > time.Minute {
+		t.Errorf("a vote without a date was dated %s, %v, want now in UTC", date, err)
+	}
+}
@@ -0,0 +1,240 @@
+package eligibility
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Policy holds the rules and the open hours of the polls. It is
+// loaded from a YAML file, JSON works too:
+//
+//	polls:
+//	  - poll: 1
+//	    date: 2024-11-05
+//	    opens: 2024-11-05T07:00
+//	    closes: 2024-11-05T20:00
+//	    rules:
+//	      - rule: min_age
+//	        age: 18
+//	      - rule: district
+//	        districts: ["3", "5"]
+//	      - rule: registration_cutoff
+//	        days: 15
+//
+// A poll without rules is open to every active voter. Days and times
+// are in the time zone of the jurisdiction, so election day ends at
+// midnight where the votes are cast rather than in UTC. A tenant with a
+// time zone of its own reads them in its zone, see In.
+type Policy struct {
+	specs []PollSpec
+	polls map[int]pollRules
+	loc   *time.Location
+
+	//zones holds the policy in the other time zones asked for, by name
+	mu    sync.Mutex
+	zones map[string]*Policy
+}
+
+// PollSpec is the entry of one poll in the file. Date is the day of the
+// poll, ages and the registration cutoff are counted up to it. Votes
+// are accepted from Opens until Closes, all of Date if both are left
+// out, any time if there is no Date either.
+type PollSpec struct {
+	Poll   int        `yaml:"poll"`
+	Date   string     `yaml:"date"`
+	Opens  string     `yaml:"opens"`
+	Closes string     `yaml:"closes"`
+	Rules  []RuleSpec `yaml:"rules"`
+}
+
+// RuleSpec is one rule, Rule is its kind and decides which of the other
+// fields it reads
+type RuleSpec struct {
+	Rule string `yaml:"rule"`
+
+	//Age is the minimum age of RuleMinAge on the day of the poll
+	Age int `yaml:"age"`
+
+	//Districts are where a voter has to live for RuleDistrict
+	Districts []string `yaml:"districts"`
+
+	//Days is how long before the poll a voter has to have registered
+	//for RuleRegistrationCutoff
+	Days int `yaml:"days"`
+}
+
+// The kinds of rules
+const (
+	RuleMinAge             = "min_age"
+	RuleDistrict           = "district"
+	RuleRegistrationCutoff = "registration_cutoff"
+
+	//RuleStatus is not configured, every poll wants active voters
+	RuleStatus = "status"
+)
+
+type pollRules struct {
+	date  time.Time
+	rules []rule
+
+	//opens and closes are zero for a poll that is always open
+	opens, closes time.Time
+}
+
+// timeLayouts are how opens and closes may be written, without an
+// offset they are in the time zone of the jurisdiction
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"}
+
+// LoadPolicy reads the policy in path, loc is the time zone of the
+// jurisdiction or the default one of the tenants
+func LoadPolicy(path string, loc *time.Location) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Polls []PollSpec `yaml:"polls"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	policy, err := NewPolicy(file.Polls, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// NewPolicy checks the specs and builds their rules, loc is the time
+// zone of the jurisdiction
+func NewPolicy(specs []PollSpec, loc *time.Location) (*Policy, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	p := &Policy{specs: specs, polls: make(map[int]pollRules, len(specs)), loc: loc}
+	for _, spec := range specs {
+		if spec.Poll < 1 {
+			return nil, fmt.Errorf("invalid poll id %d", spec.Poll)
+		}
+		if _, dup := p.polls[spec.Poll]; dup {
+			return nil, fmt.Errorf("poll %d is listed twice", spec.Poll)
+		}
+
+		poll := pollRules{}
+		if spec.Date != "" {
+			date, err := time.Parse(dateLayout, strings.TrimSpace(spec.Date))
+			if err != nil {
+				return nil, fmt.Errorf("poll %d: date must look like %s", spec.Poll, dateLayout)
+			}
+			poll.date = date
+		}
+		if err := p.window(&poll, spec); err != nil {
+			return nil, fmt.Errorf("poll %d: %w", spec.Poll, err)
+		}
+
+		for i, r := range spec.Rules {
+			compiled, err := compile(r, !poll.date.IsZero())
+			if err != nil {
+				return nil, fmt.Errorf("poll %d, rule %d: %w", spec.Poll, i+1, err)
+			}
+			poll.rules = append(poll.rules, compiled)
+		}
+		p.polls[spec.Poll] = poll
+	}
+	return p, nil
+}
+
+func compile(spec RuleSpec, dated bool) (rule, error) {
+	switch spec.Rule {
+	case RuleMinAge:
+		if spec.Age < 1 {
+			return nil, fmt.Errorf("%s needs an age of at least 1", RuleMinAge)
+		}
+		if !dated {
+			return nil, fmt.Errorf("%s needs the date of the poll", RuleMinAge)
+		}
+		return minAge{spec.Age}, nil
+	case RuleDistrict:
+		districts := make(map[string]bool, len(spec.Districts))
+		for _, d := range spec.Districts {
+			if d = strings.TrimSpace(d); d != "" {
+				districts[d] = true
+			}
+		}
+		if len(districts) == 0 {
+			return nil, fmt.Errorf("%s needs at least one district", RuleDistrict)
+		}
+		return district{districts}, nil
+	case RuleRegistrationCutoff:
+		if spec.Days < 0 {
+			return nil, fmt.Errorf("%s cannot have negative days", RuleRegistrationCutoff)
+		}
+		if !dated {
+			return nil, fmt.Errorf("%s needs the date of the poll", RuleRegistrationCutoff)
+		}
+		return registrationCutoff{spec.Days}, nil
+	}
+	return nil, fmt.Errorf("unknown rule %q, use %s, %s or %s", spec.Rule, RuleMinAge, RuleDistrict, RuleRegistrationCutoff)
+}
+
+// In returns the policy with the days and open hours of the polls read
+// in loc, the time zone of a tenant. It is built once per time zone.
+func (p *Policy) In(loc *time.Location) (*Policy, error) {
+	if loc == nil || loc.String() == p.loc.String() {
+		return p, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if zoned, ok := p.zones[loc.String()]; ok {
+		return zoned, nil
+	}
+	zoned, err := NewPolicy(p.specs, loc)
+	if err != nil {
+		return nil, fmt.Errorf("in %s: %w", loc, err)
+	}
+	if p.zones == nil {
+		p.zones = make(map[string]*Policy)
+	}
+	p.zones[loc.String()] = zoned
+	return zoned, nil
+}
+
+// window sets when the poll is open, the day of the poll by default
+func (p *Policy) window(poll *pollRules, spec PollSpec) error {
+	if spec.Opens == "" && spec.Closes == "" {
+		if !poll.date.IsZero() {
+			poll.opens = time.Date(poll.date.Year(), poll.date.Month(), poll.date.Day(), 0, 0, 0, 0, p.loc)
+			poll.closes = poll.opens.AddDate(0, 0, 1)
+		}
+		return nil
+	}
+	if spec.Opens == "" || spec.Closes == "" {
+		return fmt.Errorf("opens and closes must be given together")
+	}
+	var err error
+	if poll.opens, err = p.parseTime(spec.Opens); err != nil {
+		return fmt.Errorf("opens: %w", err)
+	}
+	if poll.closes, err = p.parseTime(spec.Closes); err != nil {
+		return fmt.Errorf("closes: %w", err)
+	}
+	if !poll.closes.After(poll.opens) {
+		return fmt.Errorf("closes must be after opens")
+	}
+	return nil
+}
+
+func (p *Policy) parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, p.loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q must look like 2006-01-02T15:04, optionally with an offset", s)
+}
@@ -0,0 +1,117 @@
+package eligibility
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// dateLayout is how the days of polls are written, and shown in reasons
+const dateLayout = "2006-01-02"
+
+// facts is what the rules look at
+type facts struct {
+	voter *storage.Voter
+
+	//district is that of the precinct of the voter, empty if the
+	//voter has no precinct or it is not in the reference table
+	district string
+
+	//pollDate is the day of the poll, zero if it has none
+	pollDate time.Time
+
+	//loc is the time zone of the jurisdiction, the day a voter
+	//registered on is the day there
+	loc *time.Location
+}
+
+// A rule passes or fails a voter, the reason says why it failed
+type rule interface {
+	name() string
+	evaluate(facts) (passed bool, reason string)
+}
+
+// minAge wants the voter to be at least age on the day of the poll
+type minAge struct {
+	age int
+}
+
+func (r minAge) name() string { return RuleMinAge }
+
+func (r minAge) evaluate(f facts) (bool, string) {
+	if f.voter.BirthDate == nil {
+		return false, "the birth date of the voter is not on record"
+	}
+	if age := ageOn(*f.voter.BirthDate, f.pollDate); age < r.age {
+		return false, fmt.Sprintf("the voter is %d on %s, the minimum age is %d", age, f.pollDate.Format(dateLayout), r.age)
+	}
+	return true, ""
+}
+
+// ageOn is the age in full years of someone born on birth on day
+func ageOn(birth time.Time, day time.Time) int {
+	age := day.Year() - birth.Year()
+	if day.Month() < birth.Month() || (day.Month() == birth.Month() && day.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
+
+// district wants the voter to live in one of districts
+type district struct {
+	districts map[string]bool
+}
+
+func (r district) name() string { return RuleDistrict }
+
+func (r district) evaluate(f facts) (bool, string) {
+	if f.district == "" {
+		return false, "the voter is not assigned to a precinct with a district"
+	}
+	if !r.districts[f.district] {
+		allowed := make([]string, 0, len(r.districts))
+		for d := range r.districts {
+			allowed = append(allowed, d)
+		}
+		sort.Strings(allowed)
+		return false, fmt.Sprintf("the voter lives in district %s, the poll is for %s", f.district, strings.Join(allowed, ", "))
+	}
+	return true, ""
+}
+
+// registrationCutoff wants the voter to have registered at least days
+// before the day of the poll
+type registrationCutoff struct {
+	days int
+}
+
+func (r registrationCutoff) name() string { return RuleRegistrationCutoff }
+
+func (r registrationCutoff) evaluate(f facts) (bool, string) {
+	if f.voter.RegisteredAt == nil {
+		return false, "the registration date of the voter is not on record"
+	}
+	cutoff := f.pollDate.AddDate(0, 0, -r.days)
+	registered := f.voter.RegisteredAt.In(f.loc)
+	day := time.Date(registered.Year(), registered.Month(), registered.Day(), 0, 0, 0, 0, time.UTC)
+	if day.After(cutoff) {
+		return false, fmt.Sprintf("the voter registered on %s, after the cutoff of %s", day.Format(dateLayout), cutoff.Format(dateLayout))
+	}
+	return true, ""
+}
+
+// active wants the voter to be active. It is part of every poll and
+// is not configured.
+type active struct{}
+
+func (active) name() string { return RuleStatus }
+
+func (active) evaluate(f facts) (bool, string) {
+	if status := storage.StatusOf(f.voter); status != storage.StatusActive {
+		return false, fmt.Sprintf("the voter is %s", status)
+	}
+	return true, ""
+}
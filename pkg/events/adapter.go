@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// The events Port follows the changes to voters. The repository
+// publishes an event for every mutation, this Port reads them back in
+// order, from a given event on, so a client that reconnects picks up
+// where it stopped.
+
+// pollBatch is the most events read from the repository at once
+const pollBatch = 100
+
+type Adapter interface {
+	//Start returns the id to follow from when the client did not
+	//give one, the newest event, so only new changes are sent
+	Start(context.Context) (string, error)
+
+	//Poll waits up to wait for events after the event with id
+	//after and returns those matching filter. last is the id to
+	//poll after next time, it moves past events that were filtered
+	//out too.
+	Poll(ctx context.Context, after string, filter Filter, wait time.Duration) (events []Event, last string, err error)
+}
+
+type Repository interface {
+	//LastEventID returns the id of the newest event
+	LastEventID(context.Context) (string, error)
+
+	//ReadEvents returns up to count events after the event with id
+	//after, waiting up to block for the first one
+	ReadEvents(ctx context.Context, after string, count int, block time.Duration) ([]storage.Event, error)
+}
+
+type adapter struct {
+	r Repository
+}
+
+func New(r Repository) Adapter {
+	return &adapter{r}
+}
+
+func (a *adapter) Start(ctx context.Context) (string, error) {
+	return a.r.LastEventID(ctx)
+}
+
+func (a *adapter) Poll(ctx context.Context, after string, filter Filter, wait time.Duration) ([]Event, string, error) {
+	stored, err := a.r.ReadEvents(ctx, after, pollBatch, wait)
+	if err != nil {
+		return nil, after, err
+	}
+
+	var events []Event
+	last := after
+	for _, s := range stored {
+		last = s.ID
+		if !filter.Matches(s) {
+			continue
+		}
+		events = append(events, fromStorage(s))
+	}
+	return events, last, nil
+}
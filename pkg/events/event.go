@@ -0,0 +1,81 @@
+package events
+
+import (
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// Event is one change to a voter as it is sent to clients. The
+// voter and history are the state after the change, or before it for
+// deletes.
+type Event struct {
+	ID      string        `json:"id"`
+	Type    string        `json:"type"`
+	VoterId int           `json:"voter_id"`
+	PollId  int           `json:"poll_id,omitempty"`
+	Time    time.Time     `json:"time"`
+	Voter   *Voter        `json:"voter,omitempty"`
+	History *VoterHistory `json:"history,omitempty"`
+}
+
+type HistoryMap map[int]VoterHistory
+
+type Voter struct {
+	Id           int        `json:"id"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	VoterHistory HistoryMap `json:"history"`
+}
+
+type VoterHistory struct {
+	PollId   int       `json:"poll_id"`
+	VoteId   int       `json:"vote_id"`
+	VoteDate time.Time `json:"vote_date"`
+}
+
+// Filter narrows the events to one voter and/or one poll. A zero
+// field matches everything.
+type Filter struct {
+	VoterId int
+	PollId  int
+}
+
+// Matches tells whether e passes the filter. A poll filter only
+// matches history events of that poll.
+func (f Filter) Matches(e storage.Event) bool {
+	if f.VoterId != 0 && e.VoterId != f.VoterId {
+		return false
+	}
+	if f.PollId != 0 && e.PollId != f.PollId {
+		return false
+	}
+	return true
+}
+
+func fromStorage(s storage.Event) Event {
+	e := Event{
+		ID:      s.ID,
+		Type:    s.Type,
+		VoterId: s.VoterId,
+		PollId:  s.PollId,
+		Time:    s.Time,
+	}
+	if s.Voter != nil {
+		history := make(HistoryMap)
+		for _, item := range s.Voter.VoterHistory {
+			history[item.PollId] = VoterHistory(item)
+		}
+		e.Voter = &Voter{
+			Id:           s.Voter.Id,
+			Name:         s.Voter.Name,
+			Email:        s.Voter.Email,
+			VoterHistory: history,
+		}
+	}
+	if s.History != nil {
+		h := VoterHistory(*s.History)
+		e.History = &h
+	}
+	return e
+}
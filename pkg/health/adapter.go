@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"fmt"
+)
+
+// The health Port answers whether the app can serve traffic. Liveness
+// only says the process is up, readiness checks that the repository is
+// reachable and looks the way this version of the app expects it to.
+
+type Adapter interface {
+	//Live reports that the process is running. It never touches
+	//the repository so a slow redis does not get us restarted.
+	Live() Report
+
+	//Ready runs every readiness check against the repository.
+	Ready(context.Context) Report
+}
+
+type Repository interface {
+	//Ping checks that the repository answers at all
+	Ping(context.Context) error
+
+	//JSONModuleLoaded checks that the RedisJSON module is available,
+	//every voter is stored as a JSON document
+	JSONModuleLoaded(context.Context) (bool, error)
+
+	//StoredSchemaVersion returns the schema version recorded in the
+	//repository
+	StoredSchemaVersion(context.Context) (int, error)
+}
+
+type adapter struct {
+	r             Repository
+	schemaVersion int
+}
+
+// New returns a health Adapter. schemaVersion is the version of the
+// storage format this build of the app reads and writes.
+func New(r Repository, schemaVersion int) Adapter {
+	return &adapter{r, schemaVersion}
+}
+
+func (a *adapter) Live() Report {
+	return Report{Status: StatusOK}
+}
+
+func (a *adapter) Ready(ctx context.Context) Report {
+	report := Report{Status: StatusOK}
+
+	//without a connection the other checks cannot run
+	if err := a.r.Ping(ctx); err != nil {
+		report.add(Check{Name: "redis", Status: StatusFail, Error: err.Error()})
+		return report
+	}
+	report.add(Check{Name: "redis", Status: StatusOK})
+
+	loaded, err := a.r.JSONModuleLoaded(ctx)
+	switch {
+	case err != nil:
+		report.add(Check{Name: "redisjson", Status: StatusFail, Error: err.Error()})
+	case !loaded:
+		report.add(Check{Name: "redisjson", Status: StatusFail, Error: "the RedisJSON module is not loaded"})
+	default:
+		report.add(Check{Name: "redisjson", Status: StatusOK})
+	}
+
+	stored, err := a.r.StoredSchemaVersion(ctx)
+	switch {
+	case err != nil:
+		report.add(Check{Name: "schema_version", Status: StatusFail, Error: err.Error()})
+	case stored != a.schemaVersion:
+		report.add(Check{Name: "schema_version", Status: StatusFail,
+			Error: fmt.Sprintf("stored schema version %d, expected %d", stored, a.schemaVersion)})
+	default:
+		report.add(Check{Name: "schema_version", Status: StatusOK})
+	}
+
+	return report
+}
@@ -0,0 +1,32 @@
+package health
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check is the outcome of a single readiness check
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is what the health endpoints return. Status is "fail" as soon
+// as one of the checks fails.
+type Report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks,omitempty"`
+}
+
+// OK tells whether every check passed
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+func (r *Report) add(c Check) {
+	if c.Status != StatusOK {
+		r.Status = StatusFail
+	}
+	r.Checks = append(r.Checks, c)
+}
@@ -0,0 +1,231 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"drexel.edu/voter-api/pkg/read"
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeReader serves the voters 1 to n and records every ReadVoters
+type fakeReader struct {
+	read.Adapter
+	n   int
+	err error
+
+	mu    sync.Mutex
+	calls [][]int
+}
+
+func (f *fakeReader) voter(id int) *read.Voter {
+	return &read.Voter{
+		Id:           id,
+		Name:         fmt.Sprint("Voter ", id),
+		Email:        fmt.Sprintf("voter%d@example.org", id),
+		VoterHistory: read.HistoryMap{1: {PollId: 1, VoteId: id}},
+	}
+}
+
+func (f *fakeReader) ReadVoters(ctx context.Context, ids []int) ([]*read.Voter, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, append([]int(nil), ids...))
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	voters := make([]*read.Voter, len(ids))
+	for i, id := range ids {
+		if id <= f.n {
+			voters[i] = f.voter(id)
+		}
+	}
+	return voters, nil
+}
+
+func (f *fakeReader) ReadAllVoter(ctx context.Context) ([]*read.Voter, error) {
+	voters := make([]*read.Voter, f.n)
+	for i := range voters {
+		voters[i] = f.voter(f.n - i)
+	}
+	return voters, nil
+}
+
+// response is the body of an answer of /graphql
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// do POSTs query to a /graphql over reader and returns the status and
+// the body
+func do(t *testing.T, reader read.Adapter, query string, variables map[string]interface{}) (int, response) {
+	t.Helper()
+	handler, err := Handler(nil, nil, reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Post("/graphql", handler)
+
+	body, _ := json.Marshal(Request{Query: query, Variables: variables})
+	req := httptest.NewRequest(fiber.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	result := response{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, result
+}
+
+func TestVotersAreLoadedInOneBatch(t *testing.T) {
+	reader := &fakeReader{n: 3}
+	_, result := do(t, reader, `{
+		votersById(ids: [1, 2, 3, 2, 9]) { id name }
+		voter(id: 2) { email }
+	}`, nil)
+	if len(result.Errors) > 0 {
+		t.Fatalf("got the errors %+v", result.Errors)
+	}
+	if len(reader.calls) != 1 {
+		t.Fatalf("ReadVoters was called %d times: %v", len(reader.calls), reader.calls)
+	}
+	if got := fmt.Sprint(reader.calls[0]); got != "[1 2 3 9]" {
+		t.Errorf("read the ids %s, want every id once", got)
+	}
+	voters := result.Data["votersById"].([]interface{})
+	if len(voters) != 5 || voters[4] != nil {
+		t.Errorf("got the voters %v, want 5 with null for 9", voters)
+	}
+}
+
+func TestLoaderCachesVotersAndErrors(t *testing.T) {
+	ctx := context.Background()
+	reader := &fakeReader{n: 5}
+	loader := newVoterLoader(reader)
+
+	first, second := loader.load(ctx, 1), loader.load(ctx, 2)
+	if voter, err := first(); err != nil || voter.(*read.Voter).Id != 1 {
+		t.Fatalf("got %v, %v", voter, err)
+	}
+	if voter, err := second(); err != nil || voter.(*read.Voter).Id != 2 {
+		t.Fatalf("got %v, %v", voter, err)
+	}
+	if voter, err := loader.load(ctx, 1)(); err != nil || voter.(*read.Voter).Id != 1 {
+		t.Fatalf("got %v, %v", voter, err)
+	}
+	if len(reader.calls) != 1 {
+		t.Errorf("ReadVoters was called %d times: %v", len(reader.calls), reader.calls)
+	}
+
+	failing := newVoterLoader(&fakeReader{n: 5, err: errors.New("connection refused")})
+	_, err := failing.load(ctx, 3)()
+	coded, ok := err.(codedError)
+	if !ok || coded.code != "INTERNAL" {
+		t.Errorf("got %v, want an INTERNAL error", err)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		nodes string
+		next  bool
+		code  string
+	}{
+		{"first page", `{ voters(first: 2) { nodes { id } hasNextPage endCursor totalCount } }`, "[1 2]", true, ""},
+		{"after a cursor", `{ voters(first: 2, after: 2) { nodes { id } hasNextPage endCursor totalCount } }`, "[3 4]", true, ""},
+		{"last page", `{ voters(first: 2, after: 4) { nodes { id } hasNextPage endCursor totalCount } }`, "[5]", false, ""},
+		{"at the cap", fmt.Sprintf(`{ voters(first: %d) { nodes { id } hasNextPage endCursor totalCount } }`, maxPageSize), "[1 2 3 4 5]", false, ""},
+		{"over the cap", fmt.Sprintf(`{ voters(first: %d) { totalCount } }`, maxPageSize+1), "", false, "BAD_USER_INPUT"},
+		{"nothing", `{ voters(first: 0) { totalCount } }`, "", false, "BAD_USER_INPUT"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, result := do(t, &fakeReader{n: 5}, test.query, nil)
+			if test.code != "" {
+				if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != test.code {
+					t.Fatalf("got the errors %+v, want %s", result.Errors, test.code)
+				}
+				return
+			}
+			if len(result.Errors) > 0 {
+				t.Fatalf("got the errors %+v", result.Errors)
+			}
+			page := result.Data["voters"].(map[string]interface{})
+			var ids []int
+			for _, node := range page["nodes"].([]interface{}) {
+				ids = append(ids, int(node.(map[string]interface{})["id"].(float64)))
+			}
+			if fmt.Sprint(ids) != test.nodes {
+				t.Errorf("got the voters %v, want %s", ids, test.nodes)
+			}
+			if page["hasNextPage"] != test.next {
+				t.Errorf("hasNextPage is %v", page["hasNextPage"])
+			}
+			if page["totalCount"] != 5.0 {
+				t.Errorf("totalCount is %v", page["totalCount"])
+			}
+		})
+	}
+}
+
+func TestQueryLimits(t *testing.T) {
+	//nest voter { history { ... } } n times
+	nested := func(n int) string {
+		query := "id"
+		for i := 0; i < n; i++ {
+			query = "history { voter { " + query + " } }"
+		}
+		return "{ voter(id: 1) { " + query + " } }"
+	}
+	ids := make([]string, maxComplexity)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i + 1)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		refused   bool
+	}{
+		{"shallow", nested(3), nil, false},
+		{"too deep", nested(4), nil, true},
+		{"a page of nested fields", `{ voters(first: 100) { nodes { id name email history { pollId voter { id name } } } } }`, nil, false},
+		{"too many ids", "{ votersById(ids: [" + strings.Join(ids, ",") + "]) { id name } }", nil, true},
+		{"a page too large through a variable", `query($n: Int) { voters(first: $n) { nodes { history { voter { id name email status } } } } }`,
+			map[string]interface{}{"n": 100}, false},
+		{"too deep through a fragment", `fragment deep on Voter { history { voter { history { voter { history { voter { history { voter { id } } } } } } } } }
+			{ voter(id: 1) { ...deep } }`, nil, true},
+		{"a fragment that spreads itself", `fragment loop on Voter { history { voter { ...loop } } }
+			{ voter(id: 1) { ...loop } }`, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, result := do(t, &fakeReader{n: 5}, test.query, test.variables)
+			refused := len(result.Errors) == 1 && result.Errors[0].Extensions["code"] == "QUERY_TOO_COMPLEX"
+			if refused != test.refused {
+				t.Fatalf("got the errors %+v, refused should be %v", result.Errors, test.refused)
+			}
+			if refused && status != fiber.StatusBadRequest {
+				t.Errorf("got the status %d, want 400", status)
+			}
+		})
+	}
+}
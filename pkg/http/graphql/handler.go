@@ -0,0 +1,110 @@
+package graphql
+
+import (
+	"encoding/json"
+
+	"drexel.edu/voter-api/pkg/create"
+	"drexel.edu/voter-api/pkg/delete"
+	"drexel.edu/voter-api/pkg/read"
+	"drexel.edu/voter-api/pkg/update"
+	"github.com/gofiber/fiber/v2"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// The GraphQL Port. It answers POST /graphql with a JSON body and GET
+// /graphql with query parameters, both carrying the usual query,
+// operationName and variables. Only POST runs mutations, a GET may be
+// cached or prefetched. Queries nesting too deep or resolving too many
+// fields are refused with 400 before they run, see checkLimits.
+
+// Request is the body of a GraphQL request
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler returns the fiber handler of /graphql. It fails if the
+// schema does not build, which is a programming error.
+func Handler(createAdapter create.Adapter, updateAdapter update.Adapter, readAdapter read.Adapter, deleteAdapter delete.Adapter) (fiber.Handler, error) {
+	schema, err := newSchema(&resolver{
+		createAdapter: createAdapter,
+		updateAdapter: updateAdapter,
+		readAdapter:   readAdapter,
+		deleteAdapter: deleteAdapter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *fiber.Ctx) error {
+		req := Request{}
+		switch c.Method() {
+		case fiber.MethodPost:
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		case fiber.MethodGet:
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if variables := c.Query("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, "invalid variables: "+err.Error())
+				}
+			}
+		default:
+			return fiber.ErrMethodNotAllowed
+		}
+		if req.Query == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "missing query")
+		}
+
+		ctx := withLoader(c.UserContext(), newVoterLoader(readAdapter))
+		params := gql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			OperationName:  req.OperationName,
+			VariableValues: req.Variables,
+			Context:        ctx,
+		}
+
+		if c.Method() == fiber.MethodGet && isMutation(params) {
+			return fiber.NewError(fiber.StatusMethodNotAllowed, "mutations must be sent with POST")
+		}
+
+		if err := checkLimits(params); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&gql.Result{Errors: []gqlerrors.FormattedError{{
+				Message:    err.Error(),
+				Extensions: map[string]interface{}{"code": "QUERY_TOO_COMPLEX"},
+			}}})
+		}
+
+		return c.JSON(gql.Do(params))
+	}, nil
+}
+
+// isMutation reports whether the operation params would run is a
+// mutation. A query that does not parse is left to gql.Do, which
+// answers with the syntax error.
+func isMutation(params gql.Params) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: params.RequestString})
+	if err != nil {
+		return false
+	}
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if params.OperationName != "" && (operation.Name == nil || operation.Name.Value != params.OperationName) {
+			continue
+		}
+		if operation.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+	return false
+}
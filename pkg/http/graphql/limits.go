@@ -0,0 +1,161 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+const (
+	//maxDepth bounds how deeply selections nest. voter { history {
+	//voter { ... } } } could go on forever otherwise.
+	maxDepth = 8
+
+	//maxComplexity bounds the fields a query may resolve. Every field
+	//costs 1, the fields below a page or a list of ids cost as often
+	//as the page or the list may be long.
+	maxComplexity = 5000
+)
+
+// checkLimits refuses the operation params would run when it nests
+// deeper than maxDepth or costs more than maxComplexity. It runs before
+// the query is executed, a query that does not parse is left to gql.Do.
+func checkLimits(params gql.Params) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: params.RequestString})
+	if err != nil {
+		return nil
+	}
+	m := measure{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: params.VariableValues,
+		visiting:  make(map[string]bool),
+	}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			m.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if params.OperationName != "" && (operation.Name == nil || operation.Name.Value != params.OperationName) {
+			continue
+		}
+		cost, err := m.selections(operation.SelectionSet, 1)
+		if err != nil {
+			return err
+		}
+		if cost > maxComplexity {
+			return fmt.Errorf("the query costs %d, more than the limit of %d; ask for smaller pages or fewer fields", cost, maxComplexity)
+		}
+	}
+	return nil
+}
+
+// measure walks the selections of an operation
+type measure struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+
+	//visiting has the fragments being walked, a fragment that spreads
+	//itself is left to the validation of gql.Do
+	visiting map[string]bool
+}
+
+// selections returns the cost of a selection set at depth
+func (m measure) selections(set *ast.SelectionSet, depth int) (int, error) {
+	if set == nil {
+		return 0, nil
+	}
+	if depth > maxDepth {
+		return 0, fmt.Errorf("the query nests deeper than the limit of %d", maxDepth)
+	}
+	cost := 0
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			below, err := m.selections(s.SelectionSet, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			cost += 1 + m.multiplier(s)*below
+		case *ast.InlineFragment:
+			below, err := m.selections(s.SelectionSet, depth)
+			if err != nil {
+				return 0, err
+			}
+			cost += below
+		case *ast.FragmentSpread:
+			fragment, ok := m.fragments[s.Name.Value]
+			if !ok || m.visiting[s.Name.Value] {
+				continue
+			}
+			m.visiting[s.Name.Value] = true
+			below, err := m.selections(fragment.SelectionSet, depth)
+			delete(m.visiting, s.Name.Value)
+			if err != nil {
+				return 0, err
+			}
+			cost += below
+		}
+		if cost > maxComplexity {
+			return cost, nil
+		}
+	}
+	return cost, nil
+}
+
+// multiplier is how often the selections below a field may be resolved:
+// the size of the page it asks for, or the number of ids
+func (m measure) multiplier(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		switch argument.Name.Value {
+		case "first":
+			if first, ok := m.intValue(argument.Value); ok {
+				return min(max(first, 1), maxPageSize)
+			}
+		case "ids":
+			if n, ok := m.listLength(argument.Value); ok {
+				return max(n, 1)
+			}
+		}
+	}
+	if field.Name.Value == "voters" || field.Name.Value == "votes" {
+		return defaultPageSize
+	}
+	return 1
+}
+
+func (m measure) intValue(value ast.Value) (int, bool) {
+	switch v := value.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(v.Value)
+		return n, err == nil
+	case *ast.Variable:
+		//variables decoded from JSON are float64
+		switch n := m.variables[v.Name.Value].(type) {
+		case float64:
+			return int(n), true
+		case int:
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func (m measure) listLength(value ast.Value) (int, bool) {
+	switch v := value.(type) {
+	case *ast.ListValue:
+		return len(v.Values), true
+	case *ast.Variable:
+		if list, ok := m.variables[v.Name.Value].([]interface{}); ok {
+			return len(list), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,99 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"drexel.edu/voter-api/pkg/read"
+)
+
+// voterLoader batches the voter lookups of one query. A resolver that
+// needs a voter asks the loader for a thunk, and the executor only runs
+// the thunks once every field on the same level was resolved. The first
+// thunk then fetches all the ids asked for so far with one ReadVoters,
+// so a page of histories that each resolve their voter costs a single
+// JSON.MGET instead of one JSON.GET per history.
+//
+// The loader lives as long as the request, voters are cached by id so
+// the same voter is never fetched twice in one query.
+type voterLoader struct {
+	readAdapter read.Adapter
+
+	mu      sync.Mutex
+	pending []int
+	voters  map[int]*read.Voter
+	errs    map[int]error
+}
+
+type loaderKey struct{}
+
+func newVoterLoader(readAdapter read.Adapter) *voterLoader {
+	return &voterLoader{
+		readAdapter: readAdapter,
+		voters:      make(map[int]*read.Voter),
+		errs:        make(map[int]error),
+	}
+}
+
+func withLoader(ctx context.Context, loader *voterLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+func loaderFrom(ctx context.Context) *voterLoader {
+	return ctx.Value(loaderKey{}).(*voterLoader)
+}
+
+// prime caches voters that were read some other way, such as the full
+// scan behind voters and polls
+func (l *voterLoader) prime(voters []*read.Voter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, voter := range voters {
+		l.voters[voter.Id] = voter
+	}
+}
+
+// load returns a thunk resolving to the voter with id, null if there is
+// none
+func (l *voterLoader) load(ctx context.Context, id int) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, cached := l.voters[id]; !cached {
+		l.voters[id] = nil
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.flush(ctx)
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if err := l.errs[id]; err != nil {
+			return nil, withCode(err)
+		}
+		if voter := l.voters[id]; voter != nil {
+			return voter, nil
+		}
+		return nil, nil
+	}
+}
+
+// flush fetches every pending id in one go
+func (l *voterLoader) flush(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.pending) == 0 {
+		return
+	}
+	ids := l.pending
+	l.pending = nil
+
+	found, err := l.readAdapter.ReadVoters(ctx, ids)
+	for i, id := range ids {
+		if err != nil {
+			l.errs[id] = err
+			continue
+		}
+		l.voters[id] = found[i]
+	}
+}
@@ -0,0 +1,390 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"drexel.edu/voter-api/pkg/create"
+	"drexel.edu/voter-api/pkg/delete"
+	"drexel.edu/voter-api/pkg/read"
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/update"
+	gql "github.com/graphql-go/graphql"
+)
+
+// resolver answers the fields of the schema through the adapters, like
+// the rest and gRPC Ports it does not talk to the repository itself.
+// Mutations answer with the stored result, read back through the read
+// adapter.
+type resolver struct {
+	createAdapter create.Adapter
+	updateAdapter update.Adapter
+	readAdapter   read.Adapter
+	deleteAdapter delete.Adapter
+}
+
+// history is a VoterHistory that knows its voter, the HistoryMap of
+// the read Port only has the poll id
+type history struct {
+	VoterId  int
+	PollId   int
+	VoteId   int
+	VoteDate time.Time
+}
+
+type poll struct {
+	Id        int
+	VoteCount int
+	votes     []*history
+}
+
+type page struct {
+	Nodes       interface{}
+	TotalCount  int
+	HasNextPage bool
+	EndCursor   *int
+}
+
+// codedError puts the kind of a domain error in the "extensions" of
+// the GraphQL error, so clients can tell a missing voter from a broken
+// server without parsing messages
+type codedError struct {
+	error
+	code string
+}
+
+func (e codedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+func withCode(err error) error {
+	code := "INTERNAL"
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		code = "NOT_FOUND"
+	case errors.Is(err, storage.ErrAlreadyExists):
+		code = "ALREADY_EXISTS"
+	case errors.Is(err, storage.ErrInvalid):
+		code = "BAD_USER_INPUT"
+	case errors.Is(err, storage.ErrConflict):
+		code = "CONFLICT"
+	case errors.Is(err, context.DeadlineExceeded):
+		code = "TIMEOUT"
+	case errors.Is(err, context.Canceled):
+		code = "CANCELLED"
+	}
+	return codedError{err, code}
+}
+
+func histories(voter *read.Voter) []*history {
+	list := make([]*history, 0, len(voter.VoterHistory))
+	for _, h := range voter.VoterHistory {
+		list = append(list, &history{
+			VoterId:  voter.Id,
+			PollId:   h.PollId,
+			VoteId:   h.VoteId,
+			VoteDate: h.VoteDate,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].PollId < list[j].PollId })
+	return list
+}
+
+// paginate returns the page of the first items after the cursor,
+// items must be sorted by key
+func paginate[T any](items []T, key func(T) int, args map[string]interface{}) (*page, error) {
+	first, _ := args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, storage.Invalidf("first must be between 1 and %d", maxPageSize)
+	}
+
+	start := 0
+	if after, ok := args["after"].(int); ok {
+		start = sort.Search(len(items), func(i int) bool { return key(items[i]) > after })
+	}
+	end := min(start+first, len(items))
+
+	p := &page{
+		Nodes:       items[start:end],
+		TotalCount:  len(items),
+		HasNextPage: end < len(items),
+	}
+	if end > start {
+		cursor := key(items[end-1])
+		p.EndCursor = &cursor
+	}
+	return p, nil
+}
+
+// allVoters reads every voter, sorted by id, and hands them to the
+// loader so nested fields do not fetch them again
+func (r *resolver) allVoters(ctx context.Context) ([]*read.Voter, error) {
+	voters, err := r.readAdapter.ReadAllVoter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(voters, func(i, j int) bool { return voters[i].Id < voters[j].Id })
+	loaderFrom(ctx).prime(voters)
+	return voters, nil
+}
+
+// allPolls groups the histories of every voter by poll
+func (r *resolver) allPolls(ctx context.Context) ([]*poll, error) {
+	voters, err := r.allVoters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[int]*poll)
+	for _, voter := range voters {
+		for _, h := range histories(voter) {
+			p, ok := byId[h.PollId]
+			if !ok {
+				p = &poll{Id: h.PollId}
+				byId[h.PollId] = p
+			}
+			//voters come sorted by id, so do the votes
+			p.votes = append(p.votes, h)
+			p.VoteCount++
+		}
+	}
+
+	polls := make([]*poll, 0, len(byId))
+	for _, p := range byId {
+		polls = append(polls, p)
+	}
+	sort.Slice(polls, func(i, j int) bool { return polls[i].Id < polls[j].Id })
+	return polls, nil
+}
+
+// Query
+
+func (r *resolver) voter(p gql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(int)
+	if id < 1 {
+		return nil, withCode(storage.Invalidf("invalid Voter Id"))
+	}
+	return loaderFrom(p.Context).load(p.Context, id), nil
+}
+
+func (r *resolver) votersById(p gql.ResolveParams) (interface{}, error) {
+	loader := loaderFrom(p.Context)
+	var thunks []interface{}
+	for _, id := range p.Args["ids"].([]interface{}) {
+		if id.(int) < 1 {
+			return nil, withCode(storage.Invalidf("invalid Voter Id"))
+		}
+		thunks = append(thunks, loader.load(p.Context, id.(int)))
+	}
+
+	//resolve the thunks after all of them were handed out, so the ids
+	//are fetched together
+	return func() (interface{}, error) {
+		voters := make([]interface{}, len(thunks))
+		for i, thunk := range thunks {
+			voter, err := thunk.(func() (interface{}, error))()
+			if err != nil {
+				return nil, err
+			}
+			voters[i] = voter
+		}
+		return voters, nil
+	}, nil
+}
+
+func (r *resolver) voters(p gql.ResolveParams) (interface{}, error) {
+	voters, err := r.allVoters(p.Context)
+	if err != nil {
+		return nil, withCode(err)
+	}
+
+	if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+		name, _ := filter["name"].(string)
+		email, _ := filter["email"].(string)
+		pollId, hasPoll := filter["pollId"].(int)
+
+		matching := voters[:0:0]
+		for _, voter := range voters {
+			if name != "" && !strings.Contains(strings.ToLower(voter.Name), strings.ToLower(name)) {
+				continue
+			}
+			if email != "" && !strings.Contains(strings.ToLower(voter.Email), strings.ToLower(email)) {
+				continue
+			}
+			if _, voted := voter.VoterHistory[pollId]; hasPoll && !voted {
+				continue
+			}
+			matching = append(matching, voter)
+		}
+		voters = matching
+	}
+
+	result, err := paginate(voters, func(v *read.Voter) int { return v.Id }, p.Args)
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return result, nil
+}
+
+func (r *resolver) poll(p gql.ResolveParams) (interface{}, error) {
+	polls, err := r.allPolls(p.Context)
+	if err != nil {
+		return nil, withCode(err)
+	}
+	id := p.Args["id"].(int)
+	for _, found := range polls {
+		if found.Id == id {
+			return found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *resolver) polls(p gql.ResolveParams) (interface{}, error) {
+	polls, err := r.allPolls(p.Context)
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return polls, nil
+}
+
+// Voter
+
+func (r *resolver) voterHistory(p gql.ResolveParams) (interface{}, error) {
+	list := histories(p.Source.(*read.Voter))
+	if pollId, ok := p.Args["pollId"].(int); ok {
+		filtered := list[:0]
+		for _, h := range list {
+			if h.PollId == pollId {
+				filtered = append(filtered, h)
+			}
+		}
+		list = filtered
+	}
+	return list, nil
+}
+
+// VoterHistory
+
+func (r *resolver) voteDate(p gql.ResolveParams) (interface{}, error) {
+	if date := p.Source.(*history).VoteDate; !date.IsZero() {
+		return date, nil
+	}
+	return nil, nil
+}
+
+func (r *resolver) statusReason(p gql.ResolveParams) (interface{}, error) {
+	if reason := p.Source.(*read.Voter).StatusReason; reason != "" {
+		return reason, nil
+	}
+	return nil, nil
+}
+
+func (r *resolver) historyVoter(p gql.ResolveParams) (interface{}, error) {
+	return loaderFrom(p.Context).load(p.Context, p.Source.(*history).VoterId), nil
+}
+
+// Poll
+
+func (r *resolver) pollVotes(p gql.ResolveParams) (interface{}, error) {
+	votes := p.Source.(*poll).votes
+	result, err := paginate(votes, func(h *history) int { return h.VoterId }, p.Args)
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return result, nil
+}
+
+// Mutation
+
+func (r *resolver) readVoter(ctx context.Context, id int) (interface{}, error) {
+	voter, err := r.readAdapter.ReadVoter(ctx, id)
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return &voter, nil
+}
+
+func (r *resolver) readHistory(ctx context.Context, voterId, pollId int) (interface{}, error) {
+	h, err := r.readAdapter.ReadVoterHistory(ctx, voterId, pollId)
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return &history{VoterId: voterId, PollId: h.PollId, VoteId: h.VoteId, VoteDate: h.VoteDate}, nil
+}
+
+func (r *resolver) createVoter(p gql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(int)
+	err := r.createAdapter.CreateVoter(p.Context, create.Voter{
+		Id:    id,
+		Name:  p.Args["name"].(string),
+		Email: p.Args["email"].(string),
+	})
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return r.readVoter(p.Context, id)
+}
+
+func (r *resolver) updateVoter(p gql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(int)
+	err := r.updateAdapter.UpdateVoter(p.Context, update.Voter{
+		Id:    id,
+		Name:  p.Args["name"].(string),
+		Email: p.Args["email"].(string),
+	})
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return r.readVoter(p.Context, id)
+}
+
+func (r *resolver) deleteVoter(p gql.ResolveParams) (interface{}, error) {
+	if err := r.deleteAdapter.DeleteVoter(p.Context, p.Args["id"].(int)); err != nil {
+		return nil, withCode(err)
+	}
+	return true, nil
+}
+
+// voteDateArg maps a missing voteDate to the zero time, like a JSON body
+// without vote_date
+func voteDateArg(args map[string]interface{}) time.Time {
+	date, _ := args["voteDate"].(time.Time)
+	return date
+}
+
+func (r *resolver) createVoterHistory(p gql.ResolveParams) (interface{}, error) {
+	voterId, pollId := p.Args["voterId"].(int), p.Args["pollId"].(int)
+	err := r.createAdapter.CreateVoterHistory(p.Context, voterId, create.VoterHistory{
+		PollId:   pollId,
+		VoteId:   p.Args["voteId"].(int),
+		VoteDate: voteDateArg(p.Args),
+	})
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return r.readHistory(p.Context, voterId, pollId)
+}
+
+func (r *resolver) updateVoterHistory(p gql.ResolveParams) (interface{}, error) {
+	voterId, pollId := p.Args["voterId"].(int), p.Args["pollId"].(int)
+	err := r.updateAdapter.UpdateVoterHistory(p.Context, voterId, update.VoterHistory{
+		PollId:   pollId,
+		VoteId:   p.Args["voteId"].(int),
+		VoteDate: voteDateArg(p.Args),
+	})
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return r.readHistory(p.Context, voterId, pollId)
+}
+
+func (r *resolver) deleteVoterHistory(p gql.ResolveParams) (interface{}, error) {
+	err := r.deleteAdapter.DeleteVoterHistory(p.Context, p.Args["voterId"].(int), p.Args["pollId"].(int))
+	if err != nil {
+		return nil, withCode(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,213 @@
+package graphql
+
+import (
+	gql "github.com/graphql-go/graphql"
+)
+
+// The schema of /graphql. It is built in code rather than from SDL, the
+// SDL equivalent is:
+//
+//	type Voter {
+//	  id: Int!
+//	  name: String!
+//	  email: String!
+//	  status: String!
+//	  statusReason: String
+//	  history(pollId: Int): [VoterHistory!]!
+//	}
+//
+//	type VoterHistory {
+//	  voterId: Int!
+//	  pollId: Int!
+//	  voteId: Int!
+//	  voteDate: DateTime
+//	  voter: Voter
+//	}
+//
+//	type Poll {
+//	  id: Int!
+//	  voteCount: Int!
+//	  votes(first: Int = 20, after: Int): VoterHistoryPage!
+//	}
+//
+//	type VoterPage {
+//	  nodes: [Voter!]!
+//	  totalCount: Int!
+//	  hasNextPage: Boolean!
+//	  endCursor: Int
+//	}
+//
+//	type VoterHistoryPage { ...same as VoterPage with VoterHistory nodes }
+//
+//	input VoterFilter {
+//	  name: String
+//	  email: String
+//	  pollId: Int
+//	}
+//
+//	type Query {
+//	  voter(id: Int!): Voter
+//	  votersById(ids: [Int!]!): [Voter]!
+//	  voters(filter: VoterFilter, first: Int = 20, after: Int): VoterPage!
+//	  poll(id: Int!): Poll
+//	  polls: [Poll!]!
+//	}
+//
+//	type Mutation {
+//	  createVoter(id: Int!, name: String!, email: String!): Voter
+//	  updateVoter(id: Int!, name: String!, email: String!): Voter
+//	  deleteVoter(id: Int!): Boolean
+//	  createVoterHistory(voterId: Int!, pollId: Int!, voteId: Int!, voteDate: DateTime): VoterHistory
+//	  updateVoterHistory(voterId: Int!, pollId: Int!, voteId: Int!, voteDate: DateTime): VoterHistory
+//	  deleteVoterHistory(voterId: Int!, pollId: Int!): Boolean
+//	}
+//
+// Pages are ordered by voter id, after is the endCursor of the previous
+// page.
+
+const (
+	//defaultPageSize and maxPageSize bound the first argument
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func pageArgs() gql.FieldConfigArgument {
+	return gql.FieldConfigArgument{
+		"first": &gql.ArgumentConfig{Type: gql.Int, DefaultValue: defaultPageSize},
+		"after": &gql.ArgumentConfig{Type: gql.Int},
+	}
+}
+
+func pageType(name string, node gql.Type) *gql.Object {
+	return gql.NewObject(gql.ObjectConfig{
+		Name: name,
+		Fields: gql.Fields{
+			"nodes":       &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(node)))},
+			"totalCount":  &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"hasNextPage": &gql.Field{Type: gql.NewNonNull(gql.Boolean)},
+			"endCursor":   &gql.Field{Type: gql.Int},
+		},
+	})
+}
+
+func newSchema(r *resolver) (gql.Schema, error) {
+	voterType := gql.NewObject(gql.ObjectConfig{
+		Name: "Voter",
+		Fields: gql.Fields{
+			"id":    &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"name":  &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"email": &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"status": &gql.Field{
+				Type:        gql.NewNonNull(gql.String),
+				Description: "pending, active, inactive, suspended or purged",
+			},
+			"statusReason": &gql.Field{Type: gql.String, Resolve: r.statusReason},
+		},
+	})
+
+	historyType := gql.NewObject(gql.ObjectConfig{
+		Name: "VoterHistory",
+		Fields: gql.Fields{
+			"voterId":  &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"pollId":   &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"voteId":   &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"voteDate": &gql.Field{Type: gql.DateTime, Resolve: r.voteDate},
+			"voter":    &gql.Field{Type: voterType, Resolve: r.historyVoter},
+		},
+	})
+
+	//the two types refer to each other, so one of them gets the field
+	//after both exist
+	voterType.AddFieldConfig("history", &gql.Field{
+		Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(historyType))),
+		Args: gql.FieldConfigArgument{
+			"pollId": &gql.ArgumentConfig{Type: gql.Int},
+		},
+		Resolve: r.voterHistory,
+	})
+
+	pollType := gql.NewObject(gql.ObjectConfig{
+		Name: "Poll",
+		Fields: gql.Fields{
+			"id":        &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"voteCount": &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"votes": &gql.Field{
+				Type:    gql.NewNonNull(pageType("VoterHistoryPage", historyType)),
+				Args:    pageArgs(),
+				Resolve: r.pollVotes,
+			},
+		},
+	})
+
+	filterType := gql.NewInputObject(gql.InputObjectConfig{
+		Name: "VoterFilter",
+		Fields: gql.InputObjectConfigFieldMap{
+			"name":   &gql.InputObjectFieldConfig{Type: gql.String, Description: "part of the name, case insensitive"},
+			"email":  &gql.InputObjectFieldConfig{Type: gql.String, Description: "part of the email, case insensitive"},
+			"pollId": &gql.InputObjectFieldConfig{Type: gql.Int, Description: "only voters with a history in this poll"},
+		},
+	})
+
+	votersArgs := pageArgs()
+	votersArgs["filter"] = &gql.ArgumentConfig{Type: filterType}
+
+	query := gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"voter": &gql.Field{
+				Type:    voterType,
+				Args:    gql.FieldConfigArgument{"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)}},
+				Resolve: r.voter,
+			},
+			"votersById": &gql.Field{
+				Type:    gql.NewNonNull(gql.NewList(voterType)),
+				Args:    gql.FieldConfigArgument{"ids": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(gql.Int)))}},
+				Resolve: r.votersById,
+			},
+			"voters": &gql.Field{
+				Type:    gql.NewNonNull(pageType("VoterPage", voterType)),
+				Args:    votersArgs,
+				Resolve: r.voters,
+			},
+			"poll": &gql.Field{
+				Type:    pollType,
+				Args:    gql.FieldConfigArgument{"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)}},
+				Resolve: r.poll,
+			},
+			"polls": &gql.Field{
+				Type:    gql.NewNonNull(gql.NewList(gql.NewNonNull(pollType))),
+				Resolve: r.polls,
+			},
+		},
+	})
+
+	voterArgs := gql.FieldConfigArgument{
+		"id":    &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+		"name":  &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+		"email": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+	}
+	historyArgs := gql.FieldConfigArgument{
+		"voterId":  &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+		"pollId":   &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+		"voteId":   &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+		"voteDate": &gql.ArgumentConfig{Type: gql.DateTime},
+	}
+	historyKeyArgs := gql.FieldConfigArgument{
+		"voterId": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+		"pollId":  &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+	}
+
+	mutation := gql.NewObject(gql.ObjectConfig{
+		Name: "Mutation",
+		Fields: gql.Fields{
+			"createVoter":        &gql.Field{Type: voterType, Args: voterArgs, Resolve: r.createVoter},
+			"updateVoter":        &gql.Field{Type: voterType, Args: voterArgs, Resolve: r.updateVoter},
+			"deleteVoter":        &gql.Field{Type: gql.Boolean, Args: gql.FieldConfigArgument{"id": voterArgs["id"]}, Resolve: r.deleteVoter},
+			"createVoterHistory": &gql.Field{Type: historyType, Args: historyArgs, Resolve: r.createVoterHistory},
+			"updateVoterHistory": &gql.Field{Type: historyType, Args: historyArgs, Resolve: r.updateVoterHistory},
+			"deleteVoterHistory": &gql.Field{Type: gql.Boolean, Args: historyKeyArgs, Resolve: r.deleteVoterHistory},
+		},
+	})
+
+	return gql.NewSchema(gql.SchemaConfig{Query: query, Mutation: mutation})
+}
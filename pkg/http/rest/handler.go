@@ -1,19 +1,185 @@
 package rest
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
 	"strconv"
+	"time"
 
+	"drexel.edu/voter-api/pkg/analytics"
+	"drexel.edu/voter-api/pkg/batch"
 	"drexel.edu/voter-api/pkg/create"
+	"drexel.edu/voter-api/pkg/dedupe"
 	"drexel.edu/voter-api/pkg/delete"
+	"drexel.edu/voter-api/pkg/eligibility"
+	"drexel.edu/voter-api/pkg/events"
+	"drexel.edu/voter-api/pkg/health"
+	"drexel.edu/voter-api/pkg/idempotency"
+	"drexel.edu/voter-api/pkg/metrics"
+	"drexel.edu/voter-api/pkg/precinct"
+	"drexel.edu/voter-api/pkg/ratelimit"
 	"drexel.edu/voter-api/pkg/read"
+	"drexel.edu/voter-api/pkg/search"
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/tenant"
 	"drexel.edu/voter-api/pkg/update"
+	"drexel.edu/voter-api/pkg/webhooks"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapter, readAdapter read.Adapter, deleteAdapter delete.Adapter) *fiber.App {
+// Config holds the settings of the rest Port that are not adapters.
+type Config struct {
+	//ConfirmDeleteToken must be sent in the X-Confirm-Delete header
+	//to use the bulk delete endpoints. Empty disables them.
+	ConfirmDeleteToken string
+
+	//RequestTimeout bounds how long a request may spend in the
+	//adapters and redis before the client gets a 504. Zero means no
+	//deadline.
+	RequestTimeout time.Duration
+
+	//APIKeys are the keys a client may send in X-API-Key to be
+	//identified by, any other key is ignored.
+	APIKeys []string
+
+	//RateLimiter limits clients per route group with ReadLimit and
+	//WriteLimit. Nil disables rate limiting.
+	RateLimiter *ratelimit.Limiter
+	ReadLimit   ratelimit.Limit
+	WriteLimit  ratelimit.Limit
+
+	//Idempotency keeps the responses of POST and PUT requests sent
+	//with an Idempotency-Key. Nil ignores the header.
+	Idempotency *idempotency.Keeper
+
+	//StreamContext is cancelled when the server shuts down, it ends
+	//long-lived responses such as GET /events. Nil never ends them.
+	StreamContext context.Context
+
+	//GraphQL answers GET and POST /graphql. Nil leaves the route out.
+	GraphQL fiber.Handler
+
+	//Tenants resolves the tenant of every request, see resolveTenant.
+	//Nil turns tenancy off.
+	Tenants *tenant.Resolver
+}
+
+// ConfirmDeleteHeader is the header a client has to send on the bulk
+// delete endpoints. Its value has to match the token the server was
+// started with, otherwise nothing is removed.
+const ConfirmDeleteHeader = "X-Confirm-Delete"
+
+// confirmDelete guards the endpoints that remove more than one record.
+// An empty token disables bulk deletes entirely.
+func confirmDelete(c *fiber.Ctx, token string) error {
+	if token == "" {
+		return fiber.NewError(fiber.StatusForbidden, "bulk deletes are disabled on this server")
+	}
+	provided := c.Get(ConfirmDeleteHeader)
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		return fiber.NewError(fiber.StatusForbidden, "missing or invalid "+ConfirmDeleteHeader+" header")
+	}
+	return nil
+}
+
+// domainError maps the kinds of storage errors to a status, anything
+// else stays a 500
+func domainError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrAlreadyExists), errors.Is(err, storage.ErrConflict):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, storage.ErrInvalid):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return err
+}
+
+// webhookError maps the errors of the webhooks Port to a status
+func webhookError(err error) error {
+	switch {
+	case errors.Is(err, webhooks.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, webhooks.ErrInvalid):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, webhooks.ErrBusy):
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	return err
+}
+
+func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapter, readAdapter read.Adapter, deleteAdapter delete.Adapter, healthAdapter health.Adapter, batchAdapter batch.Adapter, eventsAdapter events.Adapter, webhooksAdapter webhooks.Adapter, searchAdapter search.Adapter, dedupeAdapter dedupe.Adapter, precinctAdapter precinct.Adapter, eligibilityAdapter eligibility.Adapter, analyticsAdapter analytics.Adapter, config Config) *fiber.App {
 
 	router := fiber.New()
 
+	if config.StreamContext == nil {
+		config.StreamContext = context.Background()
+	}
+
+	//every request gets an X-Request-ID that follows it through the
+	//adapters and the repository, and is logged once it completes.
+	//The request context carries the deadline down to redis.
+	router.Use(requestID(), clientCertificate(), apiKey(config.APIKeys), requestMetrics(), accessLog(), timeout(config.RequestTimeout))
+	if config.Tenants != nil {
+		router.Use(resolveTenant(config.Tenants))
+	}
+	if config.RateLimiter != nil {
+		router.Use(rateLimit(config.RateLimiter, config.ReadLimit, config.WriteLimit))
+	}
+	if config.Idempotency != nil {
+		router.Use(idempotentReplay(config.Idempotency))
+	}
+
+	// Liveness, the process is up
+	router.Get("/healthz", func(c *fiber.Ctx) error {
+		return c.JSON(healthAdapter.Live())
+	})
+
+	// Readiness, redis answers with the RedisJSON module and the
+	// schema version we expect. 503 tells the orchestrator to keep
+	// traffic away until redis is back.
+	router.Get("/readyz", func(c *fiber.Ctx) error {
+		report := healthAdapter.Ready(c.UserContext())
+		if !report.OK() {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+		return c.JSON(report)
+	})
+
+	// Prometheus metrics for http, the adapters and redis
+	router.Get("/metrics", adaptor.HTTPHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+
+	// Registration status transitions, POST /voters/:id:activate and
+	// the other update.Actions with a body of {"reason": "..."}. They
+	// come before POST /voters/:id, which would take "5:activate" for
+	// an id.
+	for action, status := range update.Actions {
+		status := status
+		router.Post("/voters/:id\\:"+action, func(c *fiber.Ctx) error {
+			voterId, err := strconv.Atoi(c.Params("id"))
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid voter id")
+			}
+			transition := update.Transition{}
+			if err := c.BodyParser(&transition); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			transition.Status = status
+			if err := updateAdapter.Transition(c.UserContext(), voterId, transition); err != nil {
+				return domainError(err)
+			}
+			voter, err := readAdapter.ReadVoter(c.UserContext(), voterId)
+			if err != nil {
+				return domainError(err)
+			}
+			return c.JSON(voter)
+		})
+	}
+
 	router.Post("/voters/:id", func(c *fiber.Ctx) error {
 
 		voterId, err := strconv.Atoi(c.Params("id"))
@@ -32,7 +198,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			return err
 		}
 
-		if err = createAdapter.CreateVoter(newVoter); err != nil {
+		if err = createAdapter.CreateVoter(c.UserContext(), newVoter); err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
 		}
@@ -66,7 +232,13 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			return err
 		}
 
-		err = createAdapter.CreateVoterHistory(voterId, newHistory)
+		err = createAdapter.CreateVoterHistory(c.UserContext(), voterId, newHistory)
+		//a vote refused by the eligibility rules lists the rules
+		//that failed
+		var rejection *eligibility.Rejection
+		if errors.As(err, &rejection) {
+			return c.Status(fiber.StatusConflict).JSON(rejection.Decision)
+		}
 		if err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
@@ -97,7 +269,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			return err
 		}
 
-		if err = updateAdapter.UpdateVoter(newVoter); err != nil {
+		if err = updateAdapter.UpdateVoter(c.UserContext(), newVoter); err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
 		}
@@ -130,7 +302,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			return err
 		}
 
-		err = updateAdapter.UpdateVoterHistory(voterId, newHistory)
+		err = updateAdapter.UpdateVoterHistory(c.UserContext(), voterId, newHistory)
 		if err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
@@ -146,7 +318,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 	// GET voter by ID
 
 	router.Get("/voters", func(c *fiber.Ctx) error {
-		voters, err := readAdapter.ReadAllVoter()
+		voters, err := readAdapter.ReadAllVoter(c.UserContext())
 		if err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
@@ -155,6 +327,76 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 		return c.JSON(voters)
 	})
 
+	// Search voters by name, email and polls, see search.Query for the
+	// parameters. It has to come before /voters/:id.
+
+	router.Get("/voters/search", func(c *fiber.Ctx) error {
+		query := search.Query{}
+		if err := c.QueryParser(&query); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		result, err := searchAdapter.Search(c.UserContext(), query)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(result)
+	})
+
+	// Pairs of voters that look like the same person, best first.
+	// ?min_score= between 0 and 1 defaults to dedupe.DefaultMinScore.
+
+	router.Get("/voters/duplicates", func(c *fiber.Ctx) error {
+		minScore := dedupe.DefaultMinScore
+		if s := c.Query("min_score"); s != "" {
+			parsed, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid min_score")
+			}
+			minScore = parsed
+		}
+		candidates, err := dedupeAdapter.Duplicates(c.UserContext(), minScore)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(candidates)
+	})
+
+	// Fold the voter duplicate_id into :id and tombstone it, see
+	// dedupe.MergeRequest for the body
+	router.Post("/voters/:id/merge", func(c *fiber.Ctx) error {
+		voterId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid voter id")
+		}
+		req := dedupe.MergeRequest{}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		result, err := dedupeAdapter.Merge(c.UserContext(), voterId, req)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(result)
+	})
+
+	// Whether the voter may take part in ?poll=, with the outcome of
+	// every rule. The vote itself is checked the same way.
+	router.Get("/voters/:id/eligibility", func(c *fiber.Ctx) error {
+		voterId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid voter id")
+		}
+		pollId, err := strconv.Atoi(c.Query("poll"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "?poll= must be a poll id")
+		}
+		decision, err := eligibilityAdapter.Evaluate(c.UserContext(), voterId, pollId)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(decision)
+	})
+
 	// GET voter by : ID
 
 	router.Get("/voters/:id", func(c *fiber.Ctx) error {
@@ -163,7 +405,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			c.Status(fiber.StatusBadRequest)
 			return err
 		}
-		voter, err := readAdapter.ReadVoter(voterId)
+		voter, err := readAdapter.ReadVoter(c.UserContext(), voterId)
 		if err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
@@ -186,7 +428,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			c.Status(fiber.StatusBadRequest)
 			return err
 		}
-		voterHistory, err := readAdapter.ReadVoterHistory(voterId, pollId)
+		voterHistory, err := readAdapter.ReadVoterHistory(c.UserContext(), voterId, pollId)
 		if err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
@@ -197,7 +439,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 	// GET all voters
 
 	router.Get("/voters", func(c *fiber.Ctx) error {
-		voters, err := readAdapter.ReadAllVoter()
+		voters, err := readAdapter.ReadAllVoter(c.UserContext())
 		if err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
@@ -213,7 +455,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			c.Status(fiber.StatusBadRequest)
 			return err
 		}
-		voterHistories, err := readAdapter.ReadAllVoterHistory(voterId)
+		voterHistories, err := readAdapter.ReadAllVoterHistory(c.UserContext(), voterId)
 		if err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
@@ -227,7 +469,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			c.Status(fiber.StatusBadRequest)
 			return err
 		}
-		if err := deleteAdapter.DeleteVoter(voterId); err != nil {
+		if err := deleteAdapter.DeleteVoter(c.UserContext(), voterId); err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
 		}
@@ -249,7 +491,7 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 			c.Status(fiber.StatusBadRequest)
 			return err
 		}
-		if err := deleteAdapter.DeleteVoterHistory(voterId, pollId); err != nil {
+		if err := deleteAdapter.DeleteVoterHistory(c.UserContext(), voterId, pollId); err != nil {
 			c.Status(fiber.StatusInternalServerError)
 			return err
 		}
@@ -258,6 +500,275 @@ func Handler(port int, createAdapter create.Adapter, updateAdapter update.Adapte
 		return c.SendString("Voter History got deleted")
 	})
 
+	// Run a list of create/update/delete operations in one transaction.
+	// ?atomic=true, or "atomic": true in the body, writes nothing
+	// unless every operation succeeds.
+	router.Post("/batch", func(c *fiber.Ctx) error {
+		req := batch.Request{}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		req.Atomic = req.Atomic || c.QueryBool("atomic")
+
+		resp, err := batchAdapter.Execute(c.UserContext(), req)
+		if err != nil {
+			return domainError(err)
+		}
+
+		//an atomic batch that was rolled back did not change anything
+		if !resp.Committed {
+			c.Status(fiber.StatusUnprocessableEntity)
+		} else {
+			c.Status(fiber.StatusOK)
+		}
+		return c.JSON(resp)
+	})
+
+	// The GraphQL Port, see pkg/http/graphql for the schema
+	if config.GraphQL != nil {
+		router.Get("/graphql", config.GraphQL)
+		router.Post("/graphql", config.GraphQL)
+	}
+
+	// Follow the changes to voters as Server-Sent Events, see
+	// streamEvents for the resume and filter parameters
+	router.Get("/events", func(c *fiber.Ctx) error {
+		return streamEvents(c, eventsAdapter, config.StreamContext)
+	})
+
+	// Webhook subscriptions. The dead-letter routes come first, fiber
+	// would otherwise take "dead-letters" for an :id.
+
+	router.Get("/webhooks/dead-letters", func(c *fiber.Ctx) error {
+		letters, err := webhooksAdapter.DeadLetters(c.UserContext())
+		if err != nil {
+			return webhookError(err)
+		}
+		return c.JSON(letters)
+	})
+
+	router.Post("/webhooks/dead-letters/:id/replay", func(c *fiber.Ctx) error {
+		if err := webhooksAdapter.Replay(c.UserContext(), c.Params("id")); err != nil {
+			return webhookError(err)
+		}
+		c.Status(fiber.StatusAccepted)
+		return c.SendString("Delivery queued for replay")
+	})
+
+	router.Post("/webhooks", func(c *fiber.Ctx) error {
+		subscription := webhooks.Subscription{}
+		if err := c.BodyParser(&subscription); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		created, err := webhooksAdapter.Create(c.UserContext(), subscription)
+		if err != nil {
+			return webhookError(err)
+		}
+		c.Status(fiber.StatusCreated)
+		return c.JSON(created)
+	})
+
+	router.Get("/webhooks", func(c *fiber.Ctx) error {
+		subscriptions, err := webhooksAdapter.List(c.UserContext())
+		if err != nil {
+			return webhookError(err)
+		}
+		return c.JSON(subscriptions)
+	})
+
+	router.Get("/webhooks/:id", func(c *fiber.Ctx) error {
+		subscription, err := webhooksAdapter.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return webhookError(err)
+		}
+		return c.JSON(subscription)
+	})
+
+	router.Put("/webhooks/:id", func(c *fiber.Ctx) error {
+		subscription := webhooks.Subscription{}
+		if err := c.BodyParser(&subscription); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		updated, err := webhooksAdapter.Update(c.UserContext(), c.Params("id"), subscription)
+		if err != nil {
+			return webhookError(err)
+		}
+		return c.JSON(updated)
+	})
+
+	router.Delete("/webhooks/:id", func(c *fiber.Ctx) error {
+		if err := webhooksAdapter.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return webhookError(err)
+		}
+		return c.SendString("Webhook got deleted")
+	})
+
+	// Precincts, the reference table voters are assigned to
+
+	router.Post("/precincts", func(c *fiber.Ctx) error {
+		p := precinct.Precinct{}
+		if err := c.BodyParser(&p); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if err := precinctAdapter.Create(c.UserContext(), p); err != nil {
+			return domainError(err)
+		}
+		c.Status(fiber.StatusCreated)
+		return c.JSON(p)
+	})
+
+	router.Get("/precincts", func(c *fiber.Ctx) error {
+		precincts, err := precinctAdapter.List(c.UserContext())
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(precincts)
+	})
+
+	router.Get("/precincts/:id", func(c *fiber.Ctx) error {
+		p, err := precinctAdapter.Get(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(p)
+	})
+
+	router.Put("/precincts/:id", func(c *fiber.Ctx) error {
+		p := precinct.Precinct{}
+		if err := c.BodyParser(&p); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		p.Id = c.Params("id")
+		if err := precinctAdapter.Update(c.UserContext(), p); err != nil {
+			return domainError(err)
+		}
+		return c.JSON(p)
+	})
+
+	router.Delete("/precincts/:id", func(c *fiber.Ctx) error {
+		if err := precinctAdapter.Delete(c.UserContext(), c.Params("id")); err != nil {
+			return domainError(err)
+		}
+		return c.SendString("Precinct got deleted")
+	})
+
+	// The voters assigned to a precinct in id order, ?after= is the
+	// next_after of the previous page and ?limit= its size
+	router.Get("/precincts/:id/voters", func(c *fiber.Ctx) error {
+		q := struct {
+			After int `query:"after"`
+			Limit int `query:"limit"`
+		}{}
+		if err := c.QueryParser(&q); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		page, err := precinctAdapter.Voters(c.UserContext(), c.Params("id"), q.After, q.Limit)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(page)
+	})
+
+	// Analytics, read from aggregates kept along with every write
+
+	router.Get("/analytics/polls", func(c *fiber.Ctx) error {
+		polls, err := analyticsAdapter.Polls(c.UserContext())
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(polls)
+	})
+
+	// The votes in a poll per ?interval= between ?from= and ?to=
+	router.Get("/analytics/polls/:id/turnout", func(c *fiber.Ctx) error {
+		pollId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		q := analytics.SeriesQuery{}
+		if err := c.QueryParser(&q); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		turnout, err := analyticsAdapter.Turnout(c.UserContext(), pollId, q)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(turnout)
+	})
+
+	router.Get("/analytics/new-voters", func(c *fiber.Ctx) error {
+		q := analytics.SeriesQuery{}
+		if err := c.QueryParser(&q); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		newVoters, err := analyticsAdapter.NewVoters(c.UserContext(), q)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(newVoters)
+	})
+
+	// The voters in at least ?min= of the last ?polls= polls
+	router.Get("/analytics/participation", func(c *fiber.Ctx) error {
+		q := analytics.ParticipationQuery{}
+		if err := c.QueryParser(&q); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		participation, err := analyticsAdapter.Participation(c.UserContext(), q)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(participation)
+	})
+
+	// The voters that have not voted since ?since=
+	router.Get("/analytics/inactive", func(c *fiber.Ctx) error {
+		q := analytics.InactiveQuery{}
+		if err := c.QueryParser(&q); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		inactive, err := analyticsAdapter.Inactive(c.UserContext(), q)
+		if err != nil {
+			return domainError(err)
+		}
+		return c.JSON(inactive)
+	})
+
+	// Delete all voters, requires the X-Confirm-Delete header
+	router.Delete("/voters", func(c *fiber.Ctx) error {
+		if err := confirmDelete(c, config.ConfirmDeleteToken); err != nil {
+			return err
+		}
+		numDeleted, err := deleteAdapter.DeleteAllVoters(c.UserContext())
+		if err != nil {
+			c.Status(fiber.StatusInternalServerError)
+			return err
+		}
+		c.Status(fiber.StatusOK)
+
+		return c.JSON(fiber.Map{"deleted": numDeleted})
+	})
+
+	// Delete all voter history for a voter, requires the X-Confirm-Delete header
+	router.Delete("/voters/:voterId/polls", func(c *fiber.Ctx) error {
+		voterId, err := strconv.Atoi(c.Params("voterId"))
+		if err != nil {
+			c.Status(fiber.StatusBadRequest)
+			return err
+		}
+		if err := confirmDelete(c, config.ConfirmDeleteToken); err != nil {
+			return err
+		}
+		numDeleted, err := deleteAdapter.DeleteAllVoterHistory(c.UserContext(), voterId)
+		if err != nil {
+			c.Status(fiber.StatusInternalServerError)
+			return err
+		}
+		c.Status(fiber.StatusOK)
+
+		return c.JSON(fiber.Map{"deleted": numDeleted})
+	})
+
 	return router
 
 }
@@ -0,0 +1,340 @@
+package rest
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"drexel.edu/voter-api/pkg/auth"
+	"drexel.edu/voter-api/pkg/idempotency"
+	"drexel.edu/voter-api/pkg/logging"
+	"drexel.edu/voter-api/pkg/metrics"
+	"drexel.edu/voter-api/pkg/ratelimit"
+	"drexel.edu/voter-api/pkg/tenant"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// RequestIDHeader is used both to accept a request id from an upstream
+// proxy and to return the id that was used to the client.
+const RequestIDHeader = "X-Request-ID"
+
+// requestID propagates the X-Request-ID header, or generates one when the
+// client did not send it, and stores it in the user context so the
+// adapters and the repository can log it.
+func requestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(RequestIDHeader)
+		if id == "" {
+			id = utils.UUIDv4()
+		}
+		c.Set(RequestIDHeader, id)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+// accessLog writes one line per request once the handler has returned.
+// Errors are passed to the error handler first so the logged status is
+// the one the client actually receives.
+func accessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		if err != nil {
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				c.Status(fiber.StatusInternalServerError)
+			}
+		}
+
+		ctx := c.UserContext()
+		level := slog.LevelInfo
+		if c.Response().StatusCode() >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []any{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.Int("status", c.Response().StatusCode()),
+			slog.Duration("latency", time.Since(start)),
+		}
+		if principal, ok := auth.PrincipalFrom(ctx); ok {
+			attrs = append(attrs, slog.String("principal", principal.Name))
+		}
+		if t, ok := tenant.From(ctx); ok {
+			attrs = append(attrs, slog.String("tenant", t.Id))
+		}
+		logging.FromContext(ctx).Log(ctx, level, "request", attrs...)
+		return nil
+	}
+}
+
+// timeout puts a deadline on the user context of every request. The
+// adapters and the repository pass that context to redis, so a slow
+// redis makes the call fail instead of hanging, and the client gets a
+// 504 rather than a generic 500. The deadline is also cancelled with the
+// context of the request itself, which fasthttp closes when the server
+// shuts down.
+func timeout(d time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if d <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), d)
+		defer cancel()
+		stop := context.AfterFunc(c.Context(), cancel)
+		defer stop()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if err == nil {
+			//the handler answered, even if the deadline passed right
+			//after it did
+			return nil
+		}
+
+		//go-redis either returns the context error or a network
+		//timeout once the deadline passes, so check the context too
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fiber.NewError(fiber.StatusGatewayTimeout, "request timed out waiting for the repository")
+		}
+		return err
+	}
+}
+
+// requestMetrics records the count and latency of every request by
+// route pattern. It runs outside of accessLog, which has already turned
+// any error into a response, so the recorded status is the final one.
+func requestMetrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		//fiber reuses the memory behind its strings once the request is
+		//done, so the label values have to be copied before prometheus
+		//holds on to them
+		route := utils.CopyString(c.Route().Path)
+		method := utils.CopyString(c.Method())
+		metrics.ObserveRequest(route, method, c.Response().StatusCode(), time.Since(start))
+		return err
+	}
+}
+
+// clientCertificate maps a verified client certificate to the principal
+// of the request. The TLS handshake has already checked the certificate
+// against the client CA, so only verified chains are trusted here.
+func clientCertificate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		state := c.Context().TLSConnectionState()
+		if state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+			leaf := state.VerifiedChains[0][0]
+			c.SetUserContext(auth.WithPrincipal(c.UserContext(), auth.Principal{
+				Name:   leaf.Subject.String(),
+				Source: auth.SourceClientCert,
+			}))
+		}
+		return c.Next()
+	}
+}
+
+// APIKeyHeader identifies a client by one of the api keys of the
+// server. Clients without a valid key are identified by their
+// certificate subject or else their IP.
+const APIKeyHeader = "X-API-Key"
+
+// apiKey makes a caller that sent one of keys the principal of the
+// request, unless a client certificate already did. Any other key is
+// ignored, a client cannot get a fresh rate limit by sending a new one.
+func apiKey(keys []string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provided := c.Get(APIKeyHeader)
+		if provided == "" {
+			return c.Next()
+		}
+		if _, ok := auth.PrincipalFrom(c.UserContext()); ok {
+			return c.Next()
+		}
+		for _, key := range keys {
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+				//keys are hashed so they are never logged or stored
+				//in redis in clear text
+				sum := sha256.Sum256([]byte(key))
+				c.SetUserContext(auth.WithPrincipal(c.UserContext(), auth.Principal{
+					Name:   hex.EncodeToString(sum[:16]),
+					Source: auth.SourceAPIKey,
+				}))
+				break
+			}
+		}
+		return c.Next()
+	}
+}
+
+// resolveTenant stores the tenant of the request in the user context,
+// the repository keeps to the keys of that tenant. A request without a
+// known tenant is refused before it reaches an adapter. The probes and
+// /metrics belong to the server, not to a tenant.
+func resolveTenant(resolver *tenant.Resolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/healthz", "/readyz", "/metrics":
+			return c.Next()
+		}
+
+		t, err := resolver.Resolve(tenant.Request{
+			Header:        c.Get(tenant.Header),
+			Host:          c.Hostname(),
+			Authorization: c.Get(fiber.HeaderAuthorization),
+		})
+		switch {
+		case errors.Is(err, tenant.ErrUnknown):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case errors.Is(err, tenant.ErrInvalidToken):
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		case err != nil:
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		c.SetUserContext(tenant.WithTenant(c.UserContext(), t))
+		return c.Next()
+	}
+}
+
+// rateLimit applies the token bucket of the route group to the client.
+// GET and HEAD count against read, everything else against write. A
+// tenant may have limits of its own, which replace those of the server. The
+// probes and /metrics are never limited. When the bucket store fails
+// the request is let through, an outage of the limiter should not be an
+// outage of the api.
+func rateLimit(limiter *ratelimit.Limiter, read ratelimit.Limit, write ratelimit.Limit) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/healthz", "/readyz", "/metrics":
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		t, _ := tenant.From(ctx)
+
+		group, limit := "write", write
+		if t.RateLimit.Write != nil {
+			limit = ratelimit.Limit(*t.RateLimit.Write)
+		}
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			group, limit = "read", read
+			if t.RateLimit.Read != nil {
+				limit = ratelimit.Limit(*t.RateLimit.Read)
+			}
+		}
+
+		result, err := limiter.Allow(ctx, group+":"+clientKey(c), limit)
+		if err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "rate limiter unavailable, letting the request through", "error", err)
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			return fiber.NewError(fiber.StatusTooManyRequests, "rate limit exceeded for "+group+" requests")
+		}
+		return c.Next()
+	}
+}
+
+// clientKey names the bucket owner, the authenticated principal or
+// else the IP. Nothing the client merely claims goes into it.
+func clientKey(c *fiber.Ctx) string {
+	if principal, ok := auth.PrincipalFrom(c.UserContext()); ok {
+		switch principal.Source {
+		case auth.SourceAPIKey:
+			return "key:" + principal.Name
+		case auth.SourceClientCert:
+			return "cert:" + principal.Name
+		}
+	}
+	return "ip:" + c.IP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// IdempotencyKeyHeader lets a client retry a POST or PUT safely. The
+// first response is stored and replayed for retries with the same key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyStoreTimeout bounds storing the response, or freeing the
+// key, once the request is done
+const idempotencyStoreTimeout = 2 * time.Second
+
+// idempotentReplay keeps and replays the responses of POST and PUT
+// requests that carry an Idempotency-Key. Keys are scoped to the client
+// like rate limits are, by its principal or else its IP, so a client
+// cannot reach the responses of another by claiming its api key. Server
+// errors and 429s are not kept so that the retry actually runs again.
+// When the store fails the request runs without the guarantee rather
+// than failing.
+func idempotentReplay(keeper *idempotency.Keeper) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" || (c.Method() != fiber.MethodPost && c.Method() != fiber.MethodPut) {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return fiber.NewError(fiber.StatusBadRequest, IdempotencyKeyHeader+" cannot be longer than 255 characters")
+		}
+
+		ctx := c.UserContext()
+		key = clientKey(c) + ":" + key
+		fingerprint := idempotency.Fingerprint(c.Method(), c.OriginalURL(), c.Body())
+
+		replay, err := keeper.Begin(ctx, key, fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrMismatch):
+			return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+		case errors.Is(err, idempotency.ErrInProgress):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		case err != nil:
+			logging.FromContext(ctx).WarnContext(ctx, "idempotency store unavailable, running the request anyway", "error", err)
+			return c.Next()
+		case replay != nil:
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, replay.ContentType)
+			return c.Status(replay.Status).Send(replay.Body)
+		}
+
+		//turn an error into its response now, the response is what
+		//gets stored
+		if err := c.Next(); err != nil {
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				c.Status(fiber.StatusInternalServerError)
+			}
+		}
+
+		//the deadline of the request may have passed already, which is
+		//when freeing the key for the retry matters most
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyStoreTimeout)
+		defer cancel()
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError || status == fiber.StatusTooManyRequests {
+			err = keeper.Abort(storeCtx, key)
+		} else {
+			err = keeper.Finish(storeCtx, key, fingerprint, idempotency.Response{
+				Status:      status,
+				ContentType: string(c.Response().Header.ContentType()),
+				Body:        append([]byte(nil), c.Response().Body()...),
+			})
+		}
+		if err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "error storing the idempotent response", "error", err)
+		}
+		return nil
+	}
+}
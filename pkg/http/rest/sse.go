@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"drexel.edu/voter-api/pkg/events"
+	"drexel.edu/voter-api/pkg/logging"
+	"drexel.edu/voter-api/pkg/tenant"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// LastEventIDHeader is sent by an EventSource when it reconnects, with
+// the id of the last event it received.
+const LastEventIDHeader = "Last-Event-ID"
+
+// eventPollWait is how long one poll of the stream waits for events.
+// A client gets a keep-alive comment when nothing happened, which is
+// also how a closed connection is noticed.
+const eventPollWait = 10 * time.Second
+
+// eventIDPattern matches the ids of redis stream entries
+var eventIDPattern = regexp.MustCompile(`^\d+(-\d+)?$`)
+
+// streamEvents answers GET /events with a Server-Sent Events stream of
+// the changes to voters. The stream resumes after the Last-Event-ID
+// header (or ?last_event_id=) and otherwise starts with new events.
+// ?voter_id= and ?poll_id= filter the events.
+//
+// The body is written after the handler returned, so it cannot use the
+// request context, which the timeout middleware cancels by then. It
+// runs on base instead, which is cancelled when the server shuts down,
+// with the request id and the tenant of the request.
+func streamEvents(c *fiber.Ctx, eventsAdapter events.Adapter, base context.Context) error {
+	filter := events.Filter{
+		VoterId: c.QueryInt("voter_id"),
+		PollId:  c.QueryInt("poll_id"),
+	}
+
+	after := c.Get(LastEventIDHeader, c.Query("last_event_id"))
+	if after != "" && !eventIDPattern.MatchString(after) {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid "+LastEventIDHeader)
+	}
+	if after == "" {
+		start, err := eventsAdapter.Start(c.UserContext())
+		if err != nil {
+			c.Status(fiber.StatusInternalServerError)
+			return err
+		}
+		after = start
+	}
+	after = utils.CopyString(after)
+
+	ctx := logging.WithRequestID(base, logging.RequestID(c.UserContext()))
+	if t, ok := tenant.From(c.UserContext()); ok {
+		ctx = tenant.WithTenant(ctx, t)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	//proxies such as nginx would otherwise hold the events back
+	c.Set("X-Accel-Buffering", "no")
+	c.Status(fiber.StatusOK)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		//tell the client right away that the stream is open
+		fmt.Fprintf(w, ": following events after %s\n\n", after)
+		if w.Flush() != nil {
+			return
+		}
+
+		for ctx.Err() == nil {
+			found, last, err := eventsAdapter.Poll(ctx, after, filter, eventPollWait)
+			if err != nil {
+				if ctx.Err() == nil {
+					logging.FromContext(ctx).ErrorContext(ctx, "reading events failed", slog.String("error", err.Error()))
+				}
+				return
+			}
+			after = last
+
+			if len(found) == 0 {
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			for _, event := range found {
+				data, err := json.Marshal(event)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			}
+			//a failed flush means the client went away
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,121 @@
+package idempotency
+
+//The idempotency package remembers the response to a request sent
+//with an Idempotency-Key header so a client that retries, for example
+//after a timeout, gets the same response again instead of running the
+//request twice. A key is bound to the request it was first used with,
+//reusing it for a different request is an error.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInProgress is returned while the first request with a key has
+	// not finished yet
+	ErrInProgress = errors.New("a request with this Idempotency-Key is still being processed")
+
+	// ErrMismatch is returned when a key is reused for another request
+	ErrMismatch = errors.New("the Idempotency-Key was already used for a different request")
+)
+
+// Store keeps the records. Reserve stores value under key only if the
+// key is free, otherwise it returns what is stored there.
+type Store interface {
+	Reserve(ctx context.Context, key string, value []byte, ttl time.Duration) (existing []byte, reserved bool, err error)
+	Save(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+// Response is what gets replayed
+type Response struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+type record struct {
+	Fingerprint string    `json:"fingerprint"`
+	InProgress  bool      `json:"in_progress"`
+	Response    *Response `json:"response,omitempty"`
+}
+
+// DefaultLease is how long the claim of a request that is still being
+// processed holds its key at least. A claim outlives its request only
+// when the request could not Finish or Abort it, then the lease frees
+// the key for the retry.
+const DefaultLease = time.Minute
+
+type Keeper struct {
+	store Store
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// New returns a Keeper that remembers responses for ttl. A request in
+// progress holds its key for lease, which has to be longer than any
+// request may take.
+func New(store Store, ttl time.Duration, lease time.Duration) *Keeper {
+	return &Keeper{store, ttl, lease}
+}
+
+// Fingerprint identifies a request so a reused key can be told apart
+// from a retry.
+func Fingerprint(method string, uri string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(uri))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claims key for the request with fingerprint. When the key was
+// used before it returns the stored response to replay, ErrInProgress
+// or ErrMismatch. A nil response and nil error mean the caller owns the
+// key and has to call Finish or Abort once it is done.
+func (k *Keeper) Begin(ctx context.Context, key string, fingerprint string) (*Response, error) {
+	claim, err := json.Marshal(record{Fingerprint: fingerprint, InProgress: true})
+	if err != nil {
+		return nil, err
+	}
+
+	existing, reserved, err := k.store.Reserve(ctx, key, claim, k.lease)
+	if err != nil || reserved {
+		return nil, err
+	}
+
+	var rec record
+	if err := json.Unmarshal(existing, &rec); err != nil {
+		return nil, err
+	}
+	switch {
+	case rec.Fingerprint != fingerprint:
+		return nil, ErrMismatch
+	case rec.InProgress || rec.Response == nil:
+		return nil, ErrInProgress
+	}
+	return rec.Response, nil
+}
+
+// Finish stores the response of a request started with Begin, for the
+// full ttl.
+func (k *Keeper) Finish(ctx context.Context, key string, fingerprint string, resp Response) error {
+	value, err := json.Marshal(record{Fingerprint: fingerprint, Response: &resp})
+	if err != nil {
+		return err
+	}
+	return k.store.Save(ctx, key, value, k.ttl)
+}
+
+// Abort frees key so the request can be retried, used when the request
+// failed in a way that is worth retrying.
+func (k *Keeper) Abort(ctx context.Context, key string) error {
+	return k.store.Release(ctx, key)
+}
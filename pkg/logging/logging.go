@@ -0,0 +1,88 @@
+package logging
+
+//The logging package wraps log/slog so the rest of the app has one
+//place to build the logger and one place to look up the request id
+//that travels with a request through rest, the adapters and redis.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type requestIDKey struct{}
+
+// New builds a slog.Logger writing to w. level is one of debug, info,
+// warn or error and format is either json or text.
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected %s or %s", format, FormatJSON, FormatText)
+	}
+}
+
+// WithRequestID returns a copy of ctx carrying the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id stored in ctx, or an empty string
+// when there is none (for example for calls made from the cli).
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger, tagged with the request id
+// of ctx when there is one.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	return logger
+}
+
+// Mutation logs the outcome of a write. It is meant to be deferred at
+// the top of an adapter method with a pointer to its named error result:
+//
+//	defer logging.Mutation(ctx, "create voter", time.Now(), &err, voterId, 0)
+//
+// A voterId or pollId of 0 means the mutation is not about a single
+// voter or poll and the attribute is left out.
+func Mutation(ctx context.Context, op string, start time.Time, errp *error, voterId int, pollId int) {
+	attrs := []any{slog.String("op", op)}
+	if voterId != 0 {
+		attrs = append(attrs, slog.Int("voter_id", voterId))
+	}
+	if pollId != 0 {
+		attrs = append(attrs, slog.Int("poll_id", pollId))
+	}
+	attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+
+	logger := FromContext(ctx)
+	if errp != nil && *errp != nil {
+		logger.ErrorContext(ctx, "mutation failed", append(attrs, slog.String("error", (*errp).Error()))...)
+		return
+	}
+	logger.InfoContext(ctx, "mutation", attrs...)
+}
@@ -0,0 +1,126 @@
+package metrics
+
+//The metrics package owns the prometheus registry of the app. The rest
+//Port, the adapters and the redis repository report into it, and the
+//rest Port exposes it on /metrics.
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "voter_api"
+
+// Registry holds every metric of the app. We use our own registry
+// instead of the global default so only what we register is exported.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "Number of gRPC calls by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of gRPC calls by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	adapterOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "adapter_operations_total",
+		Help:      "Number of adapter operations by operation and result.",
+	}, []string{"operation", "result"})
+
+	historyConflicts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "history_conflicts_total",
+		Help:      "Number of voter history writes rejected because the poll was already recorded.",
+	})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of webhook delivery attempts by result: delivered, failed or dead_lettered.",
+	}, []string{"result"})
+
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Latency of redis commands by command and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		grpcRequests,
+		grpcDuration,
+		adapterOperations,
+		historyConflicts,
+		webhookDeliveries,
+		redisDuration,
+	)
+}
+
+// ObserveRequest records a finished HTTP request. route is the route
+// pattern (/voters/:id) rather than the path so the label stays bounded.
+func ObserveRequest(route string, method string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpDuration.WithLabelValues(route, method, code).Observe(d.Seconds())
+}
+
+// ObserveRPC records a finished gRPC call. method is the full method
+// name, code the name of the status code (OK, NotFound, ...).
+func ObserveRPC(method string, code string, d time.Duration) {
+	grpcRequests.WithLabelValues(method, code).Inc()
+	grpcDuration.WithLabelValues(method, code).Observe(d.Seconds())
+}
+
+// Operation counts an adapter operation. Like logging.Mutation it is
+// meant to be deferred with a pointer to the named error result:
+//
+//	defer metrics.Operation("create voter", &err)
+func Operation(op string, errp *error) {
+	result := "success"
+	if errp != nil && *errp != nil {
+		result = "error"
+	}
+	adapterOperations.WithLabelValues(op, result).Inc()
+}
+
+// HistoryConflict counts a voter history create that was rejected
+// because the voter already has history for the poll.
+func HistoryConflict() {
+	historyConflicts.Inc()
+}
+
+// WebhookDelivery counts an attempt to deliver a webhook. result is
+// delivered, failed (it will be retried or parked) or dead_lettered.
+func WebhookDelivery(result string) {
+	webhookDeliveries.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisHook is a go-redis hook that times every command and pipeline.
+type redisHook struct{}
+
+// RedisHook returns the hook that feeds redis_command_duration_seconds.
+// It is added to the client with AddHook.
+func RedisHook() redis.Hook {
+	return redisHook{}
+}
+
+func (redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		redisDuration.WithLabelValues(cmd.Name(), redisResult(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func (redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		redisDuration.WithLabelValues("pipeline", redisResult(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// A missing key is a normal answer from redis and not a failure
+func redisResult(err error) string {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return "success"
+	}
+	return "error"
+}
+
+// poolCollector exports the connection pool statistics of a redis client.
+type poolCollector struct {
+	stats func() *redis.PoolStats
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+// RegisterRedisPool exports the pool statistics returned by stats,
+// typically the PoolStats method of the repository.
+func RegisterRedisPool(stats func() *redis.PoolStats) error {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", name), help, nil, nil)
+	}
+	return Registry.Register(&poolCollector{
+		stats:      stats,
+		hits:       desc("hits_total", "Number of times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times a wait for a connection timed out."),
+		totalConns: desc("connections", "Number of connections in the pool."),
+		idleConns:  desc("idle_connections", "Number of idle connections in the pool."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	})
+}
+
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.hits
+	ch <- p.misses
+	ch <- p.timeouts
+	ch <- p.totalConns
+	ch <- p.idleConns
+	ch <- p.staleConns
+}
+
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := p.stats()
+	ch <- prometheus.MustNewConstMetric(p.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(p.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(p.timeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(p.totalConns, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(p.idleConns, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(p.staleConns, prometheus.CounterValue, float64(s.StaleConns))
+}
@@ -0,0 +1,241 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"drexel.edu/voter-api/pkg/events"
+)
+
+// The repository writes every change to a voter and its events in one
+// transaction, the event stream is the outbox. The Relay forwards the
+// events from there to the sinks, each of which has its own offset in
+// the stream. An offset only moves once the sink handled the events
+// before it, so a sink gets every event at least once, also across
+// restarts, and must cope with getting one twice. When several
+// instances run a relay, the one holding the lock of a sink forwards
+// to it and the others stand by.
+
+const (
+	//pollWait is how long one poll of the stream waits for events
+	pollWait = 10 * time.Second
+
+	//initialBackoff and maxBackoff bound the wait after a sink or
+	//the repository failed
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+
+	//saveTimeout bounds saving an offset after the relay was stopped
+	saveTimeout = 5 * time.Second
+
+	//lockTTL is how long the lock of a sink outlives an instance
+	//that stopped renewing it. It is renewed every lockTTL/3, the
+	//instances standing by try to take it as often.
+	lockTTL = 30 * time.Second
+)
+
+// Sink receives the events in stream order. Returning an error makes
+// the relay retry the same events later.
+type Sink interface {
+	Handle(context.Context, []events.Event) error
+}
+
+type Repository interface {
+	//ConsumerOffset returns the id of the last event handled by the
+	//consumer, "" if it never handled one
+	ConsumerOffset(ctx context.Context, consumer string) (string, error)
+	SaveConsumerOffset(ctx context.Context, consumer string, offset string) error
+
+	//LockConsumer takes the lock of the consumer for owner, or renews
+	//it when owner holds it already, until ttl passes. It returns
+	//false when another owner holds the lock.
+	LockConsumer(ctx context.Context, consumer string, owner string, ttl time.Duration) (bool, error)
+
+	//UnlockConsumer frees the lock of the consumer if owner holds it
+	UnlockConsumer(ctx context.Context, consumer string, owner string) error
+}
+
+// Relay forwards the event stream to the registered sinks.
+type Relay struct {
+	r     Repository
+	feed  events.Adapter
+	sinks map[string]Sink
+
+	//owner tells the locks of this relay from those of the relays of
+	//other instances
+	owner string
+}
+
+func New(r Repository, feed events.Adapter) *Relay {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+	return &Relay{r: r, feed: feed, sinks: make(map[string]Sink), owner: owner}
+}
+
+// Register adds a sink. name identifies its offset, so it must stay the
+// same across releases. A sink registered for the first time starts
+// with the events published after that.
+func (r *Relay) Register(name string, sink Sink) {
+	r.sinks[name] = sink
+}
+
+// Run forwards events until ctx is cancelled. It returns once every
+// sink finished the events it was handling and their offsets were
+// saved, so the repository must stay open until then.
+func (r *Relay) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for name, sink := range r.sinks {
+		wg.Add(1)
+		go func(name string, sink Sink) {
+			defer wg.Done()
+			r.forward(ctx, name, sink)
+		}(name, sink)
+	}
+	wg.Wait()
+}
+
+// forward takes the lock of the sink and relays to it while it holds
+// the lock. It waits for the lock while another instance holds it.
+func (r *Relay) forward(ctx context.Context, name string, sink Sink) {
+	log := slog.With("consumer", name)
+	backoff := newBackoff()
+
+	for ctx.Err() == nil {
+		held, err := r.r.LockConsumer(ctx, name, r.owner, lockTTL)
+		if err != nil {
+			backoff.wait(ctx, log, "taking the consumer lock failed", err)
+			continue
+		}
+		if !held {
+			select {
+			case <-ctx.Done():
+			case <-time.After(lockTTL / 3):
+			}
+			continue
+		}
+		backoff.reset()
+
+		lockCtx, cancel := context.WithCancel(ctx)
+		renewed := make(chan struct{})
+		go func() {
+			defer close(renewed)
+			r.renew(lockCtx, cancel, log, name)
+		}()
+		r.relay(lockCtx, log, name, sink)
+		cancel()
+		<-renewed
+
+		unlockCtx, cancelUnlock := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+		if err := r.r.UnlockConsumer(unlockCtx, name, r.owner); err != nil {
+			log.Warn("freeing the consumer lock failed, it expires on its own", "error", err)
+		}
+		cancelUnlock()
+	}
+}
+
+// renew keeps the lock of the sink until ctx ends. When the lock was
+// lost, or could not be renewed in time, it cancels the relaying, the
+// instance that takes the lock over carries on from the saved offset.
+func (r *Relay) renew(ctx context.Context, cancel context.CancelFunc, log *slog.Logger, name string) {
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		held, err := r.r.LockConsumer(ctx, name, r.owner, lockTTL)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil || !held {
+			log.Warn("lost the consumer lock, standing by", "error", err)
+			cancel()
+			return
+		}
+	}
+}
+
+// relay forwards the events to the sink until ctx ends
+func (r *Relay) relay(ctx context.Context, log *slog.Logger, name string, sink Sink) {
+	backoff := newBackoff()
+
+	offset := ""
+	for offset == "" && ctx.Err() == nil {
+		saved, err := r.r.ConsumerOffset(ctx, name)
+		if err == nil && saved == "" {
+			saved, err = r.feed.Start(ctx)
+			if err == nil {
+				err = r.r.SaveConsumerOffset(ctx, name, saved)
+			}
+		}
+		if err != nil {
+			backoff.wait(ctx, log, "loading the consumer offset failed", err)
+			continue
+		}
+		offset = saved
+	}
+	if offset != "" {
+		log.Info("relaying events", "after", offset)
+	}
+
+	for ctx.Err() == nil {
+		found, last, err := r.feed.Poll(ctx, offset, events.Filter{}, pollWait)
+		if err != nil {
+			backoff.wait(ctx, log, "reading the event stream failed", err)
+			continue
+		}
+		if last == offset {
+			continue
+		}
+
+		if len(found) > 0 {
+			if err := sink.Handle(ctx, found); err != nil {
+				//the offset stays put, the same events come again
+				backoff.wait(ctx, log, "the sink failed to handle events", err)
+				continue
+			}
+		}
+		backoff.reset()
+
+		//the events were handled, record that even when ctx was
+		//cancelled meanwhile so they are not handled again
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+		err = r.r.SaveConsumerOffset(saveCtx, name, last)
+		cancel()
+		if err != nil {
+			backoff.wait(ctx, log, "saving the consumer offset failed", err)
+		}
+		offset = last
+	}
+}
+
+type backoff struct {
+	next time.Duration
+}
+
+func newBackoff() *backoff {
+	return &backoff{next: initialBackoff}
+}
+
+func (b *backoff) reset() {
+	b.next = initialBackoff
+}
+
+// wait logs err and sleeps, twice as long as last time, until ctx ends
+func (b *backoff) wait(ctx context.Context, log *slog.Logger, msg string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	log.Error(msg, "error", err, "retry_in", b.next)
+	select {
+	case <-ctx.Done():
+	case <-time.After(b.next):
+	}
+	b.next = min(b.next*2, maxBackoff)
+}
@@ -0,0 +1,142 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"drexel.edu/voter-api/pkg/events"
+)
+
+// memRepository keeps the offsets and locks in memory
+type memRepository struct {
+	mu      sync.Mutex
+	offsets map[string]string
+	locks   map[string]string
+}
+
+func (m *memRepository) ConsumerOffset(ctx context.Context, consumer string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offsets[consumer], nil
+}
+
+func (m *memRepository) SaveConsumerOffset(ctx context.Context, consumer string, offset string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.offsets[consumer] = offset
+	return nil
+}
+
+func (m *memRepository) LockConsumer(ctx context.Context, consumer string, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if holder, ok := m.locks[consumer]; ok && holder != owner {
+		return false, nil
+	}
+	m.locks[consumer] = owner
+	return true, nil
+}
+
+func (m *memRepository) UnlockConsumer(ctx context.Context, consumer string, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks[consumer] == owner {
+		delete(m.locks, consumer)
+	}
+	return nil
+}
+
+// feed serves the events 1-0 to n-0
+type feed struct {
+	n int
+}
+
+func (f feed) Start(ctx context.Context) (string, error) {
+	return "0", nil
+}
+
+func (f feed) Poll(ctx context.Context, after string, filter events.Filter, wait time.Duration) ([]events.Event, string, error) {
+	first, _ := strconv.Atoi(strings.TrimSuffix(after, "-0"))
+	var found []events.Event
+	for i := first + 1; i <= f.n; i++ {
+		found = append(found, events.Event{ID: fmt.Sprintf("%d-0", i)})
+	}
+	if len(found) == 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Millisecond):
+		}
+		return nil, after, nil
+	}
+	return found, found[len(found)-1].ID, nil
+}
+
+// countingSink counts the events it handled
+type countingSink struct {
+	mu      sync.Mutex
+	handled int
+}
+
+func (s *countingSink) Handle(ctx context.Context, found []events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handled += len(found)
+	return nil
+}
+
+func (s *countingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handled
+}
+
+func TestOnlyTheLockHolderForwards(t *testing.T) {
+	repo := &memRepository{offsets: make(map[string]string), locks: make(map[string]string)}
+	events := feed{n: 5}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	sinks := make([]*countingSink, 3)
+	for i := range sinks {
+		sinks[i] = &countingSink{}
+		relay := New(repo, events)
+		relay.owner = fmt.Sprint("instance", i)
+		relay.Register("webhooks", sinks[i])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relay.Run(ctx)
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	total := func() int {
+		sum := 0
+		for _, sink := range sinks {
+			sum += sink.count()
+		}
+		return sum
+	}
+	for total() < events.n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	//give the others the time to deliver twice if they were to
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	if got := total(); got != events.n {
+		t.Errorf("the sinks handled %d events together, want each of the %d once", got, events.n)
+	}
+	if got := repo.offsets["webhooks"]; got != "5-0" {
+		t.Errorf("the offset is %q, want 5-0", got)
+	}
+	if len(repo.locks) != 0 {
+		t.Errorf("the locks %v were not freed on shutdown", repo.locks)
+	}
+}
@@ -0,0 +1,110 @@
+package pii
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// validMasterKeyId is what the id of a master key may look like
+var validMasterKeyId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// A KeyFile is a MasterKey read from a local file. Every line holds the
+// id of a master key and the key, 32 bytes in base64, blank lines and
+// lines starting with # are skipped:
+//
+//	# generated with: echo "2024-06 $(head -c 32 /dev/urandom | base64)"
+//	2024-01 3q2+7w...
+//	2024-06 yv66vg...
+//
+// The last key wraps, the ones above it only unwrap the data keys they
+// wrapped before. To rotate the master key append a new one, run
+// rotate-keys, and drop the old ones afterwards.
+type KeyFile struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// LoadKeyFile reads the master keys in the file at path
+func LoadKeyFile(path string) (*KeyFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	k := &KeyFile{aeads: make(map[string]cipher.AEAD)}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a key id and a key", path, n)
+		}
+		id := fields[0]
+		if !validMasterKeyId.MatchString(id) {
+			return nil, fmt.Errorf("%s:%d: key id %q may only hold letters, digits, '.', '_' and '-'", path, n, id)
+		}
+		if _, exists := k.aeads[id]; exists {
+			return nil, fmt.Errorf("%s:%d: key id %q is used twice", path, n, id)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("%s:%d: the key of %q must be %d bytes in base64", path, n, id, KeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[id] = aead
+		k.current = id
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if k.current == "" {
+		return nil, fmt.Errorf("%s holds no master key", path)
+	}
+	return k, nil
+}
+
+// Wrap implements MasterKey with the current master key.
+func (k *KeyFile) Wrap(ctx context.Context, key []byte) (string, []byte, error) {
+	aead := k.aeads[k.current]
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return "", nil, err
+	}
+	return k.current, aead.Seal(nonce, nonce, key, []byte(k.current)), nil
+}
+
+// Unwrap implements MasterKey with any of the master keys.
+func (k *KeyFile) Unwrap(ctx context.Context, masterKeyId string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.aeads[masterKeyId]
+	if !ok {
+		return nil, fmt.Errorf("%w: master key %s is not in the keyfile", ErrUnknownKey, masterKeyId)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("a wrapped key is malformed")
+	}
+	nonce, ciphertext := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, ciphertext, []byte(masterKeyId))
+	if err != nil {
+		return nil, fmt.Errorf("a key does not unwrap with master key %s: %w", masterKeyId, err)
+	}
+	return key, nil
+}
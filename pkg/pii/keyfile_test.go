@@ -0,0 +1,96 @@
+package pii
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeyFile writes a keyfile holding a new key for each id, the last
+// one current
+func writeKeyFile(t *testing.T, path string, ids ...string) {
+	t.Helper()
+	var lines []string
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("%s %s", id, base64.StdEncoding.EncodeToString(testKey(t))))
+	}
+	if err := os.WriteFile(path, []byte("# master keys\n\n"+strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyFileRotation(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys")
+	writeKeyFile(t, path, "2024-01")
+	before, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey, key, err := NewDataKey(ctx, before, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataKey.MasterKeyId != "2024-01" || dataKey.Id == "" {
+		t.Fatalf("got the data key %+v", dataKey)
+	}
+
+	//append a new master key, keeping the old one
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	fmt.Fprintf(f, "2024-06 %s\n", base64.StdEncoding.EncodeToString(testKey(t)))
+	f.Close()
+	after, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := dataKey.Unwrap(ctx, after)
+	if err != nil || string(unwrapped) != string(key) {
+		t.Fatalf("the old master key no longer unwraps: %v", err)
+	}
+	if err := dataKey.Wrap(ctx, after, unwrapped); err != nil || dataKey.MasterKeyId != "2024-06" {
+		t.Fatalf("rewrapped with %q, %v, want the new master key", dataKey.MasterKeyId, err)
+	}
+
+	//a keyfile without the new master key cannot unwrap it
+	if _, err := dataKey.Unwrap(ctx, before); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v, want ErrUnknownKey", err)
+	}
+
+	//the id of the master key is bound to what it wrapped
+	dataKey.MasterKeyId = "2024-01"
+	if _, err := dataKey.Unwrap(ctx, after); err == nil {
+		t.Error("a key unwrapped under another master key id")
+	}
+}
+
+func TestLoadKeyFileRejects(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(testKey(t))
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"no key", "# nothing yet\n"},
+		{"no id", key + "\n"},
+		{"a bad id", "2024/01 " + key + "\n"},
+		{"an id used twice", "a " + key + "\na " + key + "\n"},
+		{"a short key", "a " + base64.StdEncoding.EncodeToString([]byte("short")) + "\n"},
+		{"not base64", "a not-base64!\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys")
+			if err := os.WriteFile(path, []byte(test.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadKeyFile(path); err == nil {
+				t.Error("the keyfile loaded")
+			}
+		})
+	}
+}
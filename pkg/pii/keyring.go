@@ -0,0 +1,213 @@
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sealedPrefix starts every sealed value. The id of the data key
+// follows, then a colon and the nonce and ciphertext in base64.
+const sealedPrefix = "pii:v1:"
+
+// indexSize is how many bytes of the HMAC a blind index keeps
+const indexSize = 16
+
+// A Keyring holds the unwrapped data keys of one keyspace and its index
+// key. It seals with the active data key and opens with any of them.
+type Keyring struct {
+	active string
+	aeads  map[string]cipher.AEAD
+	index  []byte
+}
+
+// NewKeyring returns the keyring of the data keys in keys, by id, that
+// seals with the one called active
+func NewKeyring(active string, keys map[string][]byte, indexKey []byte) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: the active data key %s", ErrUnknownKey, active)
+	}
+	if len(indexKey) != KeySize {
+		return nil, errors.New("the index key has the wrong size")
+	}
+	k := &Keyring{active: active, aeads: make(map[string]cipher.AEAD, len(keys)), index: indexKey}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// Active returns the id of the data key that seals
+func (k *Keyring) Active() string {
+	return k.active
+}
+
+// Has tells whether the keyring holds the data key with the id
+func (k *Keyring) Has(id string) bool {
+	_, ok := k.aeads[id]
+	return ok
+}
+
+// Seal encrypts value with the active data key. context is bound to
+// the result, Open only succeeds with the same context, so a sealed
+// value cannot be copied to another voter or field. An empty value
+// stays empty.
+func (k *Keyring) Seal(value string, context string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	aead := k.aeads[k.active]
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(context))
+	return sealedPrefix + k.active + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed with the same context. A value that is
+// not sealed, stored before encryption was turned on, is returned as is.
+func (k *Keyring) Open(value string, context string) (string, error) {
+	id, data, ok := split(value)
+	if !ok {
+		return value, nil
+	}
+	aead, known := k.aeads[id]
+	if !known {
+		return "", fmt.Errorf("%w: data key %s", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("a sealed value is malformed")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(context))
+	if err != nil {
+		return "", fmt.Errorf("a sealed value does not open with data key %s: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// Sealed tells whether value was sealed
+func Sealed(value string) bool {
+	_, _, ok := split(value)
+	return ok
+}
+
+// KeyId returns the id of the data key value was sealed with, false
+// when it was not sealed
+func KeyId(value string) (string, bool) {
+	id, _, ok := split(value)
+	return id, ok
+}
+
+func split(value string) (id string, data string, ok bool) {
+	rest, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+//------------------------------------------------------------
+// BLIND INDEXES
+//------------------------------------------------------------
+
+//A blind index is an HMAC of a value under the index key, equal values
+//give equal hashes so they can be looked up without being stored. The
+//hashes are lower case hex, a RediSearch TAG needs no escaping for them.
+//They tell which voters share a value, and the prefixes of a name tell
+//how long its words are, but nothing more without the index key.
+
+// Email returns the blind index of an email, which ignores case
+func (k *Keyring) Email(email string) string {
+	return k.hash("email", strings.ToLower(strings.TrimSpace(email)))
+}
+
+// Name returns the blind indexes of a name. prefixes has one for every
+// prefix of two letters or more of every word, fuzzy one for every word
+// and for every word with one letter left out.
+func (k *Keyring) Name(name string) (prefixes []string, fuzzy []string) {
+	seen := make(map[string]bool)
+	add := func(list []string, hash string) []string {
+		if seen[hash] {
+			return list
+		}
+		seen[hash] = true
+		return append(list, hash)
+	}
+	for _, word := range Words(name) {
+		runes := []rune(word)
+		for n := 2; n <= len(runes); n++ {
+			prefixes = add(prefixes, k.hash("prefix", string(runes[:n])))
+		}
+		for _, variant := range deletions(word) {
+			fuzzy = add(fuzzy, k.hash("fuzzy", variant))
+		}
+	}
+	return prefixes, fuzzy
+}
+
+// Term returns the blind indexes to look a search term up by, the one
+// of the term as a prefix and the fuzzy ones. Two words share a fuzzy
+// index when they are one insertion, deletion or substitution apart,
+// or when moving a single letter turns one into the other.
+func (k *Keyring) Term(term string) (prefix string, fuzzy []string) {
+	term = strings.ToLower(term)
+	for _, variant := range deletions(term) {
+		fuzzy = append(fuzzy, k.hash("fuzzy", variant))
+	}
+	return k.hash("prefix", term), fuzzy
+}
+
+// hash is the blind index of value, kind keeps the indexes of emails,
+// prefixes and fuzzy variants apart
+func (k *Keyring) hash(kind string, value string) string {
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:indexSize])
+}
+
+// Words splits a name into lower case words the way the search Port
+// does, on everything that is not a letter or a digit
+func Words(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// deletions returns word and every word with one letter of it left out
+func deletions(word string) []string {
+	variants := []string{word}
+	if utf8.RuneCountInString(word) < 2 {
+		return variants
+	}
+	runes := []rune(word)
+	seen := map[string]bool{word: true}
+	for i := range runes {
+		variant := string(runes[:i]) + string(runes[i+1:])
+		if !seen[variant] {
+			seen[variant] = true
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
@@ -0,0 +1,164 @@
+package pii
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := randomBytes(KeySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testKeyring(t *testing.T, active string, keys map[string][]byte, index []byte) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(active, keys, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestSealOpen(t *testing.T) {
+	k := testKeyring(t, "a", map[string][]byte{"a": testKey(t)}, testKey(t))
+
+	for _, value := range []string{"Ann Smith", "ann@example.org", "Zoë Ærø", strings.Repeat("x", 10000)} {
+		sealed, err := k.Seal(value, "voter:1:name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Sealed(sealed) || strings.Contains(sealed, value) {
+			t.Fatalf("%q was sealed as %q", value, sealed)
+		}
+		if id, ok := KeyId(sealed); !ok || id != "a" {
+			t.Errorf("sealed with the data key %q, %v, want a", id, ok)
+		}
+		again, _ := k.Seal(value, "voter:1:name")
+		if again == sealed {
+			t.Error("sealing twice gave the same value, the nonce is reused")
+		}
+		opened, err := k.Open(sealed, "voter:1:name")
+		if err != nil || opened != value {
+			t.Errorf("opened %q, %v, want %q", opened, err, value)
+		}
+	}
+
+	if sealed, err := k.Seal("", "voter:1:name"); err != nil || sealed != "" {
+		t.Errorf("an empty value was sealed as %q, %v", sealed, err)
+	}
+	if opened, err := k.Open("Ann Smith", "voter:1:name"); err != nil || opened != "Ann Smith" {
+		t.Errorf("a value in clear opened as %q, %v", opened, err)
+	}
+	if _, err := k.Open(sealedPrefix+"a:not base64!", "voter:1:name"); err == nil {
+		t.Error("a malformed value opened")
+	}
+}
+
+func TestSealIsBoundToTheContext(t *testing.T) {
+	k := testKeyring(t, "a", map[string][]byte{"a": testKey(t)}, testKey(t))
+	sealed, err := k.Seal("Ann Smith", "voter:1:name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, context := range []string{"voter:2:name", "voter:1:email", ""} {
+		if opened, err := k.Open(sealed, context); err == nil {
+			t.Errorf("opened as %q in the context %q", opened, context)
+		}
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	a, b, index := testKey(t), testKey(t), testKey(t)
+	before := testKeyring(t, "a", map[string][]byte{"a": a}, index)
+	old, err := before.Seal("Ann Smith", "voter:1:name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := testKeyring(t, "b", map[string][]byte{"a": a, "b": b}, index)
+	if opened, err := after.Open(old, "voter:1:name"); err != nil || opened != "Ann Smith" {
+		t.Errorf("the retired data key no longer opens, got %q, %v", opened, err)
+	}
+	resealed, err := after.Seal("Ann Smith", "voter:1:name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := KeyId(resealed); id != "b" || after.Active() != "b" {
+		t.Errorf("sealed with %s, want the new data key b", id)
+	}
+	if !after.Has("a") || before.Has("b") {
+		t.Error("Has does not match the keys of the keyrings")
+	}
+
+	//once the retired key is removed
+	pruned := testKeyring(t, "b", map[string][]byte{"b": b}, index)
+	if _, err := pruned.Open(old, "voter:1:name"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v, want ErrUnknownKey", err)
+	}
+	if _, err := NewKeyring("c", map[string][]byte{"b": b}, index); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("a keyring without its active key got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestBlindIndexesAreStable(t *testing.T) {
+	index := testKey(t)
+	before := testKeyring(t, "a", map[string][]byte{"a": testKey(t)}, index)
+	after := testKeyring(t, "b", map[string][]byte{"b": testKey(t)}, index)
+
+	//a new data key does not change the indexes, the index key decides
+	if before.Email("ann@example.org") != after.Email(" ANN@Example.org ") {
+		t.Error("the email index changed with the data key or the case")
+	}
+	beforePrefixes, beforeFuzzy := before.Name("Ann Smith")
+	afterPrefixes, afterFuzzy := after.Name("smith, ANN")
+	if !sameSet(beforePrefixes, afterPrefixes) {
+		t.Error("the name prefixes changed with the data key")
+	}
+	if !sameSet(beforeFuzzy, afterFuzzy) {
+		t.Error("the fuzzy name indexes changed with the data key")
+	}
+
+	other := testKeyring(t, "a", map[string][]byte{"a": testKey(t)}, testKey(t))
+	if other.Email("ann@example.org") == before.Email("ann@example.org") {
+		t.Error("another index key gave the same index")
+	}
+
+	//a search term finds the name by a prefix and by a typo
+	if prefix, _ := after.Term("Smi"); !contains(beforePrefixes, prefix) {
+		t.Error("the prefix smi does not find smith")
+	}
+	_, fuzzy := after.Term("smiht")
+	found := false
+	for _, hash := range fuzzy {
+		found = found || contains(beforeFuzzy, hash)
+	}
+	if !found {
+		t.Error("the typo smiht does not find smith")
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, s := range a {
+		if !contains(b, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//The KMS stand-in keeps the master keys out of the app, the way a real
+//key management service would: the app sends data keys to be wrapped
+//and unwrapped and never sees a master key. It speaks JSON over HTTP,
+//byte strings are base64:
+//
+//	POST /v1/wrap    {"plaintext": ...}                   {"key_id": ..., "ciphertext": ...}
+//	POST /v1/unwrap  {"key_id": ..., "ciphertext": ...}   {"plaintext": ...}
+//
+//Both need "Authorization: Bearer <token>" when the stand-in has a
+//token. KMSHandler serves it from a KeyFile, `voter-api kms` runs it.
+
+// kmsTimeout bounds a call to the KMS
+const kmsTimeout = 5 * time.Second
+
+// maxKMSBody caps the requests and responses, they only carry a key
+const maxKMSBody = 4096
+
+type kmsRequest struct {
+	KeyId      string `json:"key_id,omitempty"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+type kmsResponse struct {
+	KeyId      string `json:"key_id,omitempty"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// A KMS is a MasterKey that has the KMS stand-in at url wrap and unwrap
+// the data keys.
+type KMS struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewKMS returns the MasterKey of the KMS stand-in at url
+func NewKMS(url string, token string) *KMS {
+	return &KMS{
+		url:    strings.TrimSuffix(url, "/"),
+		token:  token,
+		client: &http.Client{Timeout: kmsTimeout},
+	}
+}
+
+// Wrap implements MasterKey.
+func (k *KMS) Wrap(ctx context.Context, key []byte) (string, []byte, error) {
+	resp, err := k.call(ctx, "/v1/wrap", kmsRequest{Plaintext: key})
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.KeyId, resp.Ciphertext, nil
+}
+
+// Unwrap implements MasterKey.
+func (k *KMS) Unwrap(ctx context.Context, masterKeyId string, wrapped []byte) ([]byte, error) {
+	resp, err := k.call(ctx, "/v1/unwrap", kmsRequest{KeyId: masterKeyId, Ciphertext: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+func (k *KMS) call(ctx context.Context, path string, body kmsRequest) (kmsResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return kmsResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.url+path, bytes.NewReader(data))
+	if err != nil {
+		return kmsResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+
+	res, err := k.client.Do(req)
+	if err != nil {
+		return kmsResponse{}, fmt.Errorf("calling the KMS: %w", err)
+	}
+	defer res.Body.Close()
+
+	resp := kmsResponse{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxKMSBody)).Decode(&resp); err != nil {
+		return kmsResponse{}, fmt.Errorf("the KMS answered %s with an unreadable body: %w", res.Status, err)
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return kmsResponse{}, fmt.Errorf("%w: %s", ErrUnknownKey, resp.Error)
+	case res.StatusCode != http.StatusOK:
+		return kmsResponse{}, fmt.Errorf("the KMS answered %s: %s", res.Status, resp.Error)
+	}
+	return resp, nil
+}
+
+// KMSHandler serves the KMS stand-in with the master keys of keys. An
+// empty token lets every caller in.
+func KMSHandler(keys MasterKey, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/wrap", kmsEndpoint(token, func(ctx context.Context, req kmsRequest) (kmsResponse, error) {
+		if len(req.Plaintext) != KeySize {
+			return kmsResponse{}, errBadKMSRequest
+		}
+		keyId, ciphertext, err := keys.Wrap(ctx, req.Plaintext)
+		return kmsResponse{KeyId: keyId, Ciphertext: ciphertext}, err
+	}))
+	mux.HandleFunc("/v1/unwrap", kmsEndpoint(token, func(ctx context.Context, req kmsRequest) (kmsResponse, error) {
+		if req.KeyId == "" || len(req.Ciphertext) == 0 {
+			return kmsResponse{}, errBadKMSRequest
+		}
+		plaintext, err := keys.Unwrap(ctx, req.KeyId, req.Ciphertext)
+		return kmsResponse{Plaintext: plaintext}, err
+	}))
+	return mux
+}
+
+var errBadKMSRequest = errors.New("the request is missing a field or has the wrong key size")
+
+func kmsEndpoint(token string, fn func(context.Context, kmsRequest) (kmsResponse, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeKMS(w, http.StatusMethodNotAllowed, kmsResponse{Error: "use POST"})
+			return
+		}
+		if token != "" {
+			given, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				writeKMS(w, http.StatusUnauthorized, kmsResponse{Error: "missing or wrong bearer token"})
+				return
+			}
+		}
+
+		req := kmsRequest{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxKMSBody)).Decode(&req); err != nil {
+			writeKMS(w, http.StatusBadRequest, kmsResponse{Error: "the body is not a valid request"})
+			return
+		}
+		resp, err := fn(r.Context(), req)
+		switch {
+		case errors.Is(err, errBadKMSRequest):
+			writeKMS(w, http.StatusBadRequest, kmsResponse{Error: err.Error()})
+		case errors.Is(err, ErrUnknownKey):
+			writeKMS(w, http.StatusNotFound, kmsResponse{Error: err.Error()})
+		case err != nil:
+			//a key that does not unwrap, the details stay in the log
+			slog.WarnContext(r.Context(), "kms request failed", "path", r.URL.Path, "error", err)
+			writeKMS(w, http.StatusUnprocessableEntity, kmsResponse{Error: "the key does not unwrap"})
+		default:
+			writeKMS(w, http.StatusOK, resp)
+		}
+	}
+}
+
+func writeKMS(w http.ResponseWriter, status int, resp kmsResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
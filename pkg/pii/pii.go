@@ -0,0 +1,97 @@
+package pii
+
+//The pii package encrypts the personal data of voters, their names and
+//emails, before it is stored. It uses envelope encryption:
+//
+//	master key   held by a keyfile or a KMS, never stored with the data
+//	data keys    random AES-256 keys that seal the fields, stored next
+//	             to the data wrapped (encrypted) by the master key
+//	index key    a random HMAC key for the blind indexes, wrapped the
+//	             same way
+//
+//Rotating a data key means sealing every field again with a new one,
+//rotating the master key only means wrapping the data keys again. The
+//storage Port decides where the wrapped keys live, this package only
+//does the cryptography.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// KeySize is the size of every key, AES-256 and HMAC-SHA256
+const KeySize = 32
+
+// ErrUnknownKey is returned when data was sealed, or a key wrapped, by
+// a key that is not known (anymore).
+var ErrUnknownKey = errors.New("the key the data was encrypted with is unknown")
+
+// A MasterKey wraps and unwraps data keys. Wrap uses the current master
+// key and tells which one that was, Unwrap has to accept every master
+// key that wrapped a data key still in use.
+type MasterKey interface {
+	Wrap(ctx context.Context, key []byte) (masterKeyId string, wrapped []byte, err error)
+	Unwrap(ctx context.Context, masterKeyId string, wrapped []byte) ([]byte, error)
+}
+
+// A DataKey is a data key as it is stored, wrapped by a master key.
+// RetiredAt is set once another data key seals new data.
+type DataKey struct {
+	Id          string     `json:"id"`
+	MasterKeyId string     `json:"master_key_id"`
+	Wrapped     []byte     `json:"wrapped"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+// NewDataKey generates a data key and returns it wrapped by master
+// along with the key itself
+func NewDataKey(ctx context.Context, master MasterKey, now time.Time) (DataKey, []byte, error) {
+	key, err := randomBytes(KeySize)
+	if err != nil {
+		return DataKey{}, nil, err
+	}
+	id, err := randomBytes(8)
+	if err != nil {
+		return DataKey{}, nil, err
+	}
+	dataKey := DataKey{Id: hex.EncodeToString(id), CreatedAt: now}
+	if err := dataKey.Wrap(ctx, master, key); err != nil {
+		return DataKey{}, nil, err
+	}
+	return dataKey, key, nil
+}
+
+// Wrap stores key in d, wrapped by the current master key
+func (d *DataKey) Wrap(ctx context.Context, master MasterKey, key []byte) error {
+	masterKeyId, wrapped, err := master.Wrap(ctx, key)
+	if err != nil {
+		return err
+	}
+	d.MasterKeyId = masterKeyId
+	d.Wrapped = wrapped
+	return nil
+}
+
+// Unwrap returns the key wrapped in d
+func (d DataKey) Unwrap(ctx context.Context, master MasterKey) ([]byte, error) {
+	key, err := master.Unwrap(ctx, d.MasterKeyId, d.Wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, errors.New("the unwrapped key has the wrong size")
+	}
+	return key, nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
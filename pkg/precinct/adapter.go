@@ -0,0 +1,135 @@
+package precinct
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// The precinct Port keeps the reference table of precincts and lists
+// the voters assigned to each. Voters are assigned by the create and
+// update Ports, from their address and the Rules.
+
+const (
+	//DefaultLimit and MaxLimit bound the page size of Voters
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// validId keeps precinct ids usable in a url and a redis key
+var validId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type Adapter interface {
+	Create(context.Context, Precinct) error
+	Get(context.Context, string) (Precinct, error)
+	List(context.Context) ([]Precinct, error)
+	Update(context.Context, Precinct) error
+
+	//Delete refuses to remove a precinct voters are assigned to
+	Delete(context.Context, string) error
+
+	//Voters returns up to limit voters of the precinct with an id
+	//above after, DefaultLimit if limit is 0
+	Voters(ctx context.Context, id string, after int, limit int) (Page, error)
+}
+
+type Repository interface {
+	AddPrecinct(context.Context, storage.Precinct) error
+	UpdatePrecinct(context.Context, storage.Precinct) error
+	GetPrecinct(context.Context, string) (*storage.Precinct, error)
+	ListPrecincts(context.Context) ([]storage.Precinct, error)
+	DeletePrecinct(context.Context, string) error
+	PrecinctVoters(ctx context.Context, id string, after int, limit int) ([]storage.Voter, int, error)
+}
+
+type adapter struct {
+	r Repository
+}
+
+func New(r Repository) Adapter {
+	return &adapter{r}
+}
+
+func validate(p Precinct) error {
+	if !validId.MatchString(p.Id) {
+		return storage.Invalidf("a precinct id is 1 to 64 letters, digits, dots, dashes or underscores")
+	}
+	if len(strings.TrimSpace(p.Name)) == 0 {
+		return storage.Invalidf("precinct name cannot be blank")
+	}
+	if len(strings.TrimSpace(p.District)) == 0 {
+		return storage.Invalidf("precinct district cannot be blank")
+	}
+	return nil
+}
+
+func (a *adapter) Create(ctx context.Context, p Precinct) error {
+	if err := validate(p); err != nil {
+		return err
+	}
+	return a.r.AddPrecinct(ctx, storage.Precinct(p))
+}
+
+func (a *adapter) Get(ctx context.Context, id string) (Precinct, error) {
+	p, err := a.r.GetPrecinct(ctx, id)
+	if err != nil {
+		return Precinct{}, err
+	}
+	return Precinct(*p), nil
+}
+
+func (a *adapter) List(ctx context.Context) ([]Precinct, error) {
+	all, err := a.r.ListPrecincts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	precincts := make([]Precinct, len(all))
+	for i, p := range all {
+		precincts[i] = Precinct(p)
+	}
+	return precincts, nil
+}
+
+func (a *adapter) Update(ctx context.Context, p Precinct) error {
+	if err := validate(p); err != nil {
+		return err
+	}
+	return a.r.UpdatePrecinct(ctx, storage.Precinct(p))
+}
+
+func (a *adapter) Delete(ctx context.Context, id string) error {
+	return a.r.DeletePrecinct(ctx, id)
+}
+
+func (a *adapter) Voters(ctx context.Context, id string, after int, limit int) (Page, error) {
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if limit < 1 || limit > MaxLimit {
+		return Page{}, storage.Invalidf("limit must be between 1 and %d", MaxLimit)
+	}
+	if after < 0 {
+		return Page{}, storage.Invalidf("after cannot be negative")
+	}
+
+	//an unknown precinct is a 404 rather than an empty page
+	if _, err := a.r.GetPrecinct(ctx, id); err != nil {
+		return Page{}, err
+	}
+
+	voters, total, err := a.r.PrecinctVoters(ctx, id, after, limit)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Total: total, Voters: make([]Voter, len(voters))}
+	for i, voter := range voters {
+		page.Voters[i] = fromStorage(voter)
+	}
+	if len(voters) == limit {
+		page.NextAfter = voters[len(voters)-1].Id
+	}
+	return page, nil
+}
@@ -0,0 +1,48 @@
+package precinct
+
+import "drexel.edu/voter-api/pkg/storage"
+
+type Precinct struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	District string `json:"district"`
+}
+
+// Page is a page of the voters assigned to a precinct, in id order.
+// Pass NextAfter as ?after= to get the next page, it is 0 on the last.
+type Page struct {
+	Total     int     `json:"total"`
+	Voters    []Voter `json:"voters"`
+	NextAfter int     `json:"next_after,omitempty"`
+}
+
+type Voter struct {
+	Id      int      `json:"id"`
+	Name    string   `json:"name"`
+	Email   string   `json:"email"`
+	Status  string   `json:"status"`
+	Address *Address `json:"address,omitempty"`
+}
+
+type Address struct {
+	Number int    `json:"number,omitempty"`
+	Street string `json:"street"`
+	Unit   string `json:"unit,omitempty"`
+	City   string `json:"city"`
+	State  string `json:"state"`
+	Zip    string `json:"zip"`
+}
+
+func fromStorage(voter storage.Voter) Voter {
+	v := Voter{
+		Id:     voter.Id,
+		Name:   voter.Name,
+		Email:  voter.Email,
+		Status: storage.StatusOf(&voter),
+	}
+	if voter.Address != nil {
+		address := Address(*voter.Address)
+		v.Address = &address
+	}
+	return v
+}
@@ -0,0 +1,197 @@
+package precinct
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules assign an address to a precinct. They are loaded from a YAML
+// file, the precincts they name are expected to exist:
+//
+//	rules:
+//	  # the even side of the 3200 block of Chestnut St
+//	  - precinct: "27-04"
+//	    zip: "19104"
+//	    street: Chestnut Street
+//	    from: 3200
+//	    to: 3298
+//	    side: even
+//	  # everything else in the ZIP code
+//	  - precinct: "27-01"
+//	    zip: "19104"
+//
+// A rule with a street is more specific than one without and wins over
+// it, otherwise the first matching rule in the file wins. Streets are
+// compared in lower case with the usual suffixes abbreviated, so
+// "Chestnut Street" and "chestnut st" are the same street.
+type Rules struct {
+	streets []Rule
+	zips    []Rule
+}
+
+// Rule matches the addresses in Zip. Street narrows it down to one
+// street, From and To, both inclusive, to a range of house numbers on
+// it and Side to its odd or even numbers.
+type Rule struct {
+	Precinct string `yaml:"precinct"`
+	Zip      string `yaml:"zip"`
+	Street   string `yaml:"street"`
+	From     int    `yaml:"from"`
+	To       int    `yaml:"to"`
+	Side     string `yaml:"side"`
+}
+
+// The sides of a street
+const (
+	SideBoth = "both"
+	SideOdd  = "odd"
+	SideEven = "even"
+)
+
+// LoadRules reads the rules in path
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	rules, err := NewRules(file.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// NewRules checks rules and orders them for Assign
+func NewRules(rules []Rule) (*Rules, error) {
+	r := &Rules{}
+	for i, rule := range rules {
+		rule.Precinct = strings.TrimSpace(rule.Precinct)
+		rule.Zip = normalizeZip(rule.Zip)
+		rule.Street = normalizeStreet(rule.Street)
+		rule.Side = strings.ToLower(strings.TrimSpace(rule.Side))
+		if rule.Side == "" {
+			rule.Side = SideBoth
+		}
+
+		switch {
+		case rule.Precinct == "":
+			return nil, fmt.Errorf("rule %d has no precinct", i+1)
+		case rule.Zip == "":
+			return nil, fmt.Errorf("rule %d has no zip", i+1)
+		case rule.Side != SideBoth && rule.Side != SideOdd && rule.Side != SideEven:
+			return nil, fmt.Errorf("rule %d: side must be %s, %s or %s", i+1, SideBoth, SideOdd, SideEven)
+		case rule.From < 0 || rule.To < 0 || (rule.To != 0 && rule.To < rule.From):
+			return nil, fmt.Errorf("rule %d: from and to must be a range of house numbers", i+1)
+		case rule.Street == "" && (rule.From != 0 || rule.To != 0 || rule.Side != SideBoth):
+			return nil, fmt.Errorf("rule %d: a range of house numbers needs a street", i+1)
+		}
+
+		if rule.Street != "" {
+			r.streets = append(r.streets, rule)
+		} else {
+			r.zips = append(r.zips, rule)
+		}
+	}
+	return r, nil
+}
+
+// Precincts returns the ids of the precincts the rules assign to
+func (r *Rules) Precincts() []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, rule := range append(append([]Rule{}, r.streets...), r.zips...) {
+		if !seen[rule.Precinct] {
+			seen[rule.Precinct] = true
+			ids = append(ids, rule.Precinct)
+		}
+	}
+	return ids
+}
+
+// Assign returns the precinct of address, empty when no rule matches
+func (r *Rules) Assign(address storage.Address) string {
+	zip := normalizeZip(address.Zip)
+	street := normalizeStreet(address.Street)
+	for _, rule := range r.streets {
+		if rule.Zip == zip && rule.Street == street && rule.covers(address.Number) {
+			return rule.Precinct
+		}
+	}
+	for _, rule := range r.zips {
+		if rule.Zip == zip {
+			return rule.Precinct
+		}
+	}
+	return ""
+}
+
+// covers tells whether the house number is in the range of the rule. An
+// address without a number only matches a rule without a range.
+func (rule Rule) covers(number int) bool {
+	if rule.From == 0 && rule.To == 0 && rule.Side == SideBoth {
+		return true
+	}
+	if number < 1 || number < rule.From || (rule.To != 0 && number > rule.To) {
+		return false
+	}
+	switch rule.Side {
+	case SideOdd:
+		return number%2 == 1
+	case SideEven:
+		return number%2 == 0
+	}
+	return true
+}
+
+// normalizeZip keeps the five digit ZIP code of a ZIP+4
+func normalizeZip(zip string) string {
+	zip = strings.TrimSpace(zip)
+	if len(zip) > 5 && zip[5] == '-' {
+		zip = zip[:5]
+	}
+	return zip
+}
+
+// streetSuffixes abbreviates the common street types the way the postal
+// service does
+var streetSuffixes = map[string]string{
+	"street":    "st",
+	"avenue":    "ave",
+	"av":        "ave",
+	"road":      "rd",
+	"boulevard": "blvd",
+	"drive":     "dr",
+	"lane":      "ln",
+	"place":     "pl",
+	"court":     "ct",
+	"terrace":   "ter",
+	"parkway":   "pkwy",
+	"highway":   "hwy",
+	"north":     "n",
+	"south":     "s",
+	"east":      "e",
+	"west":      "w",
+}
+
+func normalizeStreet(street string) string {
+	words := strings.FieldsFunc(strings.ToLower(street), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		if short, ok := streetSuffixes[word]; ok {
+			words[i] = short
+		}
+	}
+	return strings.Join(words, " ")
+}
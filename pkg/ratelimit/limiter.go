@@ -0,0 +1,79 @@
+package ratelimit
+
+//The ratelimit package decides whether a client may make another
+//request. It uses a token bucket per client and route group: the
+//bucket holds up to Burst tokens, refills at PerMinute tokens a minute
+//and every request takes one token. The buckets live in a Store shared
+//by every replica, so a limit holds no matter which replica is hit.
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit describes one token bucket
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// Store keeps the buckets. TakeToken refills the bucket under key,
+// takes a token if there is one and returns whether it did together
+// with the tokens left. It has to be atomic across replicas.
+type Store interface {
+	TakeToken(ctx context.Context, key string, ratePerSecond float64, burst int) (allowed bool, tokens float64, err error)
+}
+
+// Result is the outcome of Allow, with everything the RateLimit-*
+// and Retry-After headers need.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	//RetryAfter is how long until the next token, zero if allowed
+	RetryAfter time.Duration
+
+	//Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+type Limiter struct {
+	store Store
+}
+
+func New(store Store) *Limiter {
+	return &Limiter{store}
+}
+
+// Allow takes a token from the bucket of key.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	rate := limit.perSecond()
+	allowed, tokens, err := l.store.TakeToken(ctx, key, rate, limit.Burst)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return result, nil
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
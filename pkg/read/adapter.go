@@ -1,7 +1,8 @@
 package read
 
 import (
-	"errors"
+	"context"
+	"sort"
 
 	"drexel.edu/voter-api/pkg/storage"
 )
@@ -77,18 +78,33 @@ we want to create them. So lets create an interface for that.
 */
 
 type Adapter interface {
-	ReadVoter(int) (Voter, error)
-	ReadVoterHistory(int, int) (VoterHistory, error)
-	ReadAllVoter() ([]*Voter, error)
-	ReadAllVoterHistory(int) ([]*VoterHistory, error)
+	ReadVoter(context.Context, int) (Voter, error)
+	ReadVoterHistory(context.Context, int, int) (VoterHistory, error)
+	ReadAllVoter(context.Context) ([]*Voter, error)
+	ReadAllVoterHistory(context.Context, int) ([]*VoterHistory, error)
+
+	//ReadVoters reads many voters at once, in the order of the ids.
+	//An id without a voter gives nil.
+	ReadVoters(context.Context, []int) ([]*Voter, error)
+
+	//ReadVoterIds returns the ids of every voter in ascending order,
+	//so the voters can be read a page at a time with ReadVoters
+	ReadVoterIds(context.Context) ([]int, error)
 }
 
 type Repository interface {
-	GetItem(int) (*storage.Voter, error)
+	GetItem(context.Context, int) (*storage.Voter, error)
 
-	UpdateItem(*storage.Voter) error
+	UpdateItem(context.Context, *storage.Voter) error
 
-	GetAllItems() ([]storage.Voter, error)
+	GetAllItems(context.Context) ([]storage.Voter, error)
+
+	//GetItems returns the voters with the given ids in one go, nil
+	//for an id without a voter
+	GetItems(context.Context, []int) ([]*storage.Voter, error)
+
+	//GetItemIds returns the ids of every voter without reading them
+	GetItemIds(context.Context) ([]int, error)
 }
 
 // Now we create a struct to implement the Adapter interface
@@ -103,14 +119,14 @@ func New(r Repository) Adapter {
 
 // Get Voter
 
-func (a *adapter) ReadVoter(voterId int) (Voter, error) {
+func (a *adapter) ReadVoter(ctx context.Context, voterId int) (Voter, error) {
 
 	if voterId < 1 {
 
-		return Voter{}, errors.New("invalid Voter Id")
+		return Voter{}, storage.Invalidf("invalid Voter Id")
 	}
 
-	voter, err := a.r.GetItem(voterId)
+	voter, err := a.r.GetItem(ctx, voterId)
 
 	if err != nil {
 
@@ -133,6 +149,14 @@ func (a *adapter) ReadVoter(voterId int) (Voter, error) {
 		Name:         voter.Name,
 		Email:        voter.Email,
 		VoterHistory: voterHistory,
+		Address:      addressFromStorage(voter.Address),
+		PrecinctId:   voter.PrecinctId,
+		BirthDate:    birthDateFromStorage(voter.BirthDate),
+		RegisteredAt: voter.RegisteredAt,
+
+		Status:          storage.StatusOf(voter),
+		StatusReason:    voter.StatusReason,
+		StatusChangedAt: voter.StatusChangedAt,
 	}
 
 	return returnVoter, nil
@@ -140,9 +164,9 @@ func (a *adapter) ReadVoter(voterId int) (Voter, error) {
 
 // Get Voter History
 
-func (a *adapter) ReadVoterHistory(voterId int, pollId int) (VoterHistory, error) {
+func (a *adapter) ReadVoterHistory(ctx context.Context, voterId int, pollId int) (VoterHistory, error) {
 
-	targetVoter, err := a.r.GetItem(voterId)
+	targetVoter, err := a.r.GetItem(ctx, voterId)
 	if err != nil {
 		return VoterHistory{}, err
 	}
@@ -151,7 +175,7 @@ func (a *adapter) ReadVoterHistory(voterId int, pollId int) (VoterHistory, error
 
 	if !exists {
 
-		return VoterHistory{}, errors.New("the specified pollId does not exists inside the voter")
+		return VoterHistory{}, storage.NotFoundf("the specified pollId does not exists inside the voter")
 
 	}
 
@@ -167,9 +191,9 @@ func (a *adapter) ReadVoterHistory(voterId int, pollId int) (VoterHistory, error
 
 // Get all Voters
 
-func (a *adapter) ReadAllVoter() ([]*Voter, error) {
+func (a *adapter) ReadAllVoter(ctx context.Context) ([]*Voter, error) {
 
-	allVoters, err := a.r.GetAllItems()
+	allVoters, err := a.r.GetAllItems(ctx)
 
 	if err != nil {
 		return nil, err
@@ -191,6 +215,14 @@ func (a *adapter) ReadAllVoter() ([]*Voter, error) {
 			Name:         voter.Name,
 			Email:        voter.Email,
 			VoterHistory: voterHistory,
+			Address:      addressFromStorage(voter.Address),
+			PrecinctId:   voter.PrecinctId,
+			BirthDate:    birthDateFromStorage(voter.BirthDate),
+			RegisteredAt: voter.RegisteredAt,
+
+			Status:          storage.StatusOf(&voter),
+			StatusReason:    voter.StatusReason,
+			StatusChangedAt: voter.StatusChangedAt,
 		}
 		voters = append(voters, voterObj)
 	}
@@ -200,11 +232,11 @@ func (a *adapter) ReadAllVoter() ([]*Voter, error) {
 
 // Get all voter history
 
-func (a *adapter) ReadAllVoterHistory(voterId int) ([]*VoterHistory, error) {
+func (a *adapter) ReadAllVoterHistory(ctx context.Context, voterId int) ([]*VoterHistory, error) {
 
 	// Assuming GetItem is used to fetch a single voter by ID
 
-	voter, err := a.r.GetItem(voterId)
+	voter, err := a.r.GetItem(ctx, voterId)
 	if err != nil {
 		return nil, err
 	}
@@ -221,3 +253,61 @@ func (a *adapter) ReadAllVoterHistory(voterId int) ([]*VoterHistory, error) {
 
 	return voterHistories, nil
 }
+
+// Get many voters by ID
+
+func (a *adapter) ReadVoters(ctx context.Context, voterIds []int) ([]*Voter, error) {
+
+	for _, voterId := range voterIds {
+		if voterId < 1 {
+			return nil, storage.Invalidf("invalid Voter Id")
+		}
+	}
+
+	found, err := a.r.GetItems(ctx, voterIds)
+	if err != nil {
+		return nil, err
+	}
+
+	voters := make([]*Voter, len(found))
+	for i, voter := range found {
+		if voter == nil {
+			continue
+		}
+		voterHistory := make(HistoryMap)
+		for _, item := range voter.VoterHistory {
+			voterHistory[item.PollId] = VoterHistory{
+				PollId:   item.PollId,
+				VoteId:   item.VoteId,
+				VoteDate: item.VoteDate,
+			}
+		}
+		voters[i] = &Voter{
+			Id:           voter.Id,
+			Name:         voter.Name,
+			Email:        voter.Email,
+			VoterHistory: voterHistory,
+			Address:      addressFromStorage(voter.Address),
+			PrecinctId:   voter.PrecinctId,
+			BirthDate:    birthDateFromStorage(voter.BirthDate),
+			RegisteredAt: voter.RegisteredAt,
+
+			Status:          storage.StatusOf(voter),
+			StatusReason:    voter.StatusReason,
+			StatusChangedAt: voter.StatusChangedAt,
+		}
+	}
+
+	return voters, nil
+}
+
+// Get the ids of all Voters
+
+func (a *adapter) ReadVoterIds(ctx context.Context) ([]int, error) {
+	ids, err := a.r.GetItemIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
@@ -1,5 +1,11 @@
 package read
 
+import (
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
 type HistoryMap map[int]VoterHistory
 
 // This is part of the redis Port
@@ -13,4 +19,41 @@ type Voter struct {
 	Name         string     `json:"name"`
 	Email        string     `json:"email"`
 	VoterHistory HistoryMap `json:"history"`
+
+	Address    *Address `json:"address,omitempty"`
+	PrecinctId string   `json:"precinct_id,omitempty"`
+
+	//BirthDate is a day, 2006-01-02
+	BirthDate    string     `json:"birth_date,omitempty"`
+	RegisteredAt *time.Time `json:"registered_at,omitempty"`
+
+	//Status is the registration status, see the storage.Status
+	//constants. Reason and time are those of the last transition.
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+}
+
+type Address struct {
+	Number int    `json:"number,omitempty"`
+	Street string `json:"street"`
+	Unit   string `json:"unit,omitempty"`
+	City   string `json:"city"`
+	State  string `json:"state"`
+	Zip    string `json:"zip"`
+}
+
+func addressFromStorage(address *storage.Address) *Address {
+	if address == nil {
+		return nil
+	}
+	a := Address(*address)
+	return &a
+}
+
+func birthDateFromStorage(day *time.Time) string {
+	if day == nil {
+		return ""
+	}
+	return day.Format("2006-01-02")
 }
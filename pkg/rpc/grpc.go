@@ -0,0 +1,273 @@
+package rpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=drexel.edu/voter-api --go-grpc_out=../.. --go-grpc_opt=module=drexel.edu/voter-api voter/v1/voter.proto
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"drexel.edu/voter-api/pkg/auth"
+	"drexel.edu/voter-api/pkg/create"
+	"drexel.edu/voter-api/pkg/delete"
+	"drexel.edu/voter-api/pkg/health"
+	"drexel.edu/voter-api/pkg/logging"
+	"drexel.edu/voter-api/pkg/metrics"
+	"drexel.edu/voter-api/pkg/read"
+	"drexel.edu/voter-api/pkg/rpc/voterpb"
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/tenant"
+	"drexel.edu/voter-api/pkg/update"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadata is the metadata key of the request id, the gRPC
+// counterpart of the X-Request-ID header.
+const RequestIDMetadata = "x-request-id"
+
+// readinessInterval is how often the health service re-runs the
+// readiness checks
+const readinessInterval = 10 * time.Second
+
+// Config holds the settings of the gRPC Port that are not adapters.
+type Config struct {
+	//RequestTimeout bounds unary calls that come without a shorter
+	//deadline of their own. Zero means no deadline.
+	RequestTimeout time.Duration
+
+	//Credentials serve TLS, nil serves plaintext
+	Credentials credentials.TransportCredentials
+
+	//Tenants resolves the tenant of every call to the voter service
+	//from the x-tenant-id metadata, the authority and the bearer
+	//token. Nil turns tenancy off.
+	Tenants *tenant.Resolver
+}
+
+// NewServer returns a gRPC server with the voter service, the standard
+// health service and reflection registered. The health service reports
+// SERVING while healthAdapter is ready, it is checked until ctx is done.
+func NewServer(ctx context.Context, createAdapter create.Adapter, updateAdapter update.Adapter, readAdapter read.Adapter, deleteAdapter delete.Adapter, healthAdapter health.Adapter, config Config) *grpc.Server {
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryObserve, unaryTimeout(config.RequestTimeout), unaryTenant(config.Tenants)),
+		grpc.ChainStreamInterceptor(streamObserve, streamTenant(config.Tenants)),
+	}
+	if config.Credentials != nil {
+		options = append(options, grpc.Creds(config.Credentials))
+	}
+	s := grpc.NewServer(options...)
+
+	voterpb.RegisterVoterServiceServer(s, &server{
+		createAdapter: createAdapter,
+		updateAdapter: updateAdapter,
+		readAdapter:   readAdapter,
+		deleteAdapter: deleteAdapter,
+	})
+
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	go trackReadiness(ctx, healthServer, healthAdapter)
+
+	reflection.Register(s)
+	return s
+}
+
+// trackReadiness mirrors /readyz into the health service, for the
+// whole server ("") and the voter service
+func trackReadiness(ctx context.Context, healthServer *grpchealth.Server, healthAdapter health.Adapter) {
+	ticker := time.NewTicker(readinessInterval)
+	defer ticker.Stop()
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, readinessInterval)
+		serving := healthpb.HealthCheckResponse_NOT_SERVING
+		if healthAdapter.Ready(checkCtx).OK() {
+			serving = healthpb.HealthCheckResponse_SERVING
+		}
+		cancel()
+		healthServer.SetServingStatus("", serving)
+		healthServer.SetServingStatus(voterpb.VoterService_ServiceDesc.ServiceName, serving)
+
+		select {
+		case <-ctx.Done():
+			//tell clients to go elsewhere while we drain
+			healthServer.Shutdown()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// toStatus maps the domain errors to gRPC status codes
+func toStatus(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, storage.ErrAlreadyExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, storage.ErrInvalid):
+		code = codes.InvalidArgument
+	case errors.Is(err, storage.ErrConflict):
+		code = codes.Aborted
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	}
+	return status.Error(code, err.Error())
+}
+
+// withRequest puts the request id and the principal of the client in
+// ctx, like the requestID and clientCertificate middleware of the rest
+// Port, and returns the request id
+func withRequest(ctx context.Context) (context.Context, string) {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadata); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+	ctx = logging.WithRequestID(ctx, id)
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			chains := info.State.VerifiedChains
+			if len(chains) > 0 && len(chains[0]) > 0 {
+				ctx = auth.WithPrincipal(ctx, auth.Principal{
+					Name:   chains[0][0].Subject.String(),
+					Source: auth.SourceClientCert,
+				})
+			}
+		}
+	}
+	return ctx, id
+}
+
+// observe logs and counts a finished call
+func observe(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	metrics.ObserveRPC(method, code.String(), time.Since(start))
+
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+	attrs := []any{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		attrs = append(attrs, slog.String("principal", principal.Name))
+	}
+	logging.FromContext(ctx).Log(ctx, level, "rpc", attrs...)
+}
+
+func unaryObserve(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, id := withRequest(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, id))
+	resp, err := handler(ctx, req)
+	observe(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// unaryTimeout puts the configured deadline on calls, a client deadline
+// that is shorter wins
+func unaryTimeout(d time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if d <= 0 {
+			return handler(ctx, req)
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+func streamObserve(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, id := withRequest(ss.Context())
+	ss.SetHeader(metadata.Pairs(RequestIDMetadata, id))
+	err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	observe(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// serverStream hands the handler the context the interceptors built
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// TenantMetadata is the metadata key naming the tenant of a call, the
+// gRPC counterpart of the X-Tenant-ID header
+const TenantMetadata = "x-tenant-id"
+
+// withTenant puts the tenant of a call to the voter service in ctx like
+// the resolveTenant middleware of the rest Port. The health service and
+// reflection belong to the server, not to a tenant.
+func withTenant(ctx context.Context, resolver *tenant.Resolver, method string) (context.Context, error) {
+	if resolver == nil || !strings.HasPrefix(method, "/"+voterpb.VoterService_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	t, err := resolver.Resolve(tenant.Request{
+		Header:        first(TenantMetadata),
+		Host:          first(":authority"),
+		Authorization: first("authorization"),
+	})
+	switch {
+	case errors.Is(err, tenant.ErrUnknown):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, tenant.ErrInvalidToken):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return tenant.WithTenant(ctx, t), nil
+}
+
+func unaryTenant(resolver *tenant.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := withTenant(ctx, resolver, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamTenant(resolver *tenant.Resolver) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := withTenant(ss.Context(), resolver, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
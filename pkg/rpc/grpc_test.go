@@ -0,0 +1,253 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"drexel.edu/voter-api/pkg/logging"
+	"drexel.edu/voter-api/pkg/read"
+	"drexel.edu/voter-api/pkg/rpc/voterpb"
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{storage.NotFoundf("voter %d not found", 1), codes.NotFound},
+		{storage.AlreadyExistsf("voter %d already exists", 1), codes.AlreadyExists},
+		{storage.Invalidf("invalid Voter Id"), codes.InvalidArgument},
+		{storage.Conflictf("voter %d was changed meanwhile", 1), codes.Aborted},
+		{fmt.Errorf("reading voter 1: %w", storage.NotFoundf("voter %d not found", 1)), codes.NotFound},
+		{fmt.Errorf("JSON.GET: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{errors.New("connection refused"), codes.Internal},
+	}
+	for _, test := range tests {
+		t.Run(test.err.Error(), func(t *testing.T) {
+			err := toStatus(test.err)
+			s, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("%v is not a status", err)
+			}
+			if s.Code() != test.code {
+				t.Errorf("got %v, want %v", s.Code(), test.code)
+			}
+			if s.Message() != test.err.Error() {
+				t.Errorf("the message is %q, want %q", s.Message(), test.err.Error())
+			}
+		})
+	}
+}
+
+var voterMethod = &grpc.UnaryServerInfo{FullMethod: "/" + voterpb.VoterService_ServiceDesc.ServiceName + "/GetVoter"}
+
+func TestUnaryObservePropagatesTheRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want string
+	}{
+		{"sent by the client", metadata.Pairs(RequestIDMetadata, "abc-123"), "abc-123"},
+		{"generated", metadata.MD{}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), test.md)
+			var got string
+			_, err := unaryObserve(ctx, nil, voterMethod, func(ctx context.Context, req any) (any, error) {
+				got = logging.RequestID(ctx)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == "" || (test.want != "" && got != test.want) {
+				t.Errorf("the handler saw the request id %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnaryTimeout(t *testing.T) {
+	deadline := func(ctx context.Context, d time.Duration) (time.Duration, bool) {
+		var left time.Duration
+		var ok bool
+		unaryTimeout(d)(ctx, nil, voterMethod, func(ctx context.Context, req any) (any, error) {
+			var at time.Time
+			at, ok = ctx.Deadline()
+			left = time.Until(at)
+			return nil, nil
+		})
+		return left, ok
+	}
+
+	if _, ok := deadline(context.Background(), 0); ok {
+		t.Error("no timeout put a deadline on the call")
+	}
+	if left, ok := deadline(context.Background(), time.Minute); !ok || left > time.Minute || left < 50*time.Second {
+		t.Errorf("the deadline is %v away, want a minute", left)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if left, ok := deadline(ctx, time.Minute); !ok || left > time.Second {
+		t.Errorf("the deadline is %v away, the shorter one of the client should win", left)
+	}
+}
+
+func TestUnaryTenant(t *testing.T) {
+	tenants, err := tenant.New([]tenant.Tenant{{Id: "montgomery"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := tenant.NewResolver(tenants, tenant.Options{Sources: []string{tenant.SourceHeader}})
+	health := &grpc.UnaryServerInfo{FullMethod: "/" + healthpb.Health_ServiceDesc.ServiceName + "/Check"}
+
+	tests := []struct {
+		name     string
+		resolver *tenant.Resolver
+		info     *grpc.UnaryServerInfo
+		tenant   string
+		code     codes.Code
+		want     string
+	}{
+		{"tenancy off", nil, voterMethod, "", codes.OK, ""},
+		{"known tenant", resolver, voterMethod, "montgomery", codes.OK, "montgomery"},
+		{"unknown tenant", resolver, voterMethod, "bucks", codes.NotFound, ""},
+		{"no tenant", resolver, voterMethod, "", codes.InvalidArgument, ""},
+		{"health service", resolver, health, "", codes.OK, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if test.tenant != "" {
+				md.Set(TenantMetadata, test.tenant)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			got := ""
+			_, err := unaryTenant(test.resolver)(ctx, nil, test.info, func(ctx context.Context, req any) (any, error) {
+				if found, ok := tenant.From(ctx); ok {
+					got = found.Id
+				}
+				return nil, nil
+			})
+			if status.Code(err) != test.code {
+				t.Fatalf("got %v, want %v", err, test.code)
+			}
+			if got != test.want {
+				t.Errorf("the handler saw the tenant %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	tenants, err := tenant.New([]tenant.Tenant{{Id: "montgomery"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := tenant.NewResolver(tenants, tenant.Options{Sources: []string{tenant.SourceHeader}})
+	info := &grpc.StreamServerInfo{FullMethod: "/" + voterpb.VoterService_ServiceDesc.ServiceName + "/ListVoters"}
+	chain := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return streamObserve(srv, ss, info, func(srv any, ss grpc.ServerStream) error {
+			return streamTenant(resolver)(srv, ss, info, handler)
+		})
+	}
+
+	md := metadata.Pairs(TenantMetadata, "montgomery", RequestIDMetadata, "abc-123")
+	stream := &listStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+	err = chain(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+		if id := logging.RequestID(ss.Context()); id != "abc-123" {
+			t.Errorf("the handler saw the request id %q", id)
+		}
+		if found, ok := tenant.From(ss.Context()); !ok || found.Id != "montgomery" {
+			t.Errorf("the handler saw the tenant %+v", found)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md = metadata.Pairs(TenantMetadata, "bucks")
+	stream = &listStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+	err = chain(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+		t.Error("the handler ran for an unknown tenant")
+		return nil
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("got %v, want NotFound", err)
+	}
+}
+
+// listStream collects what ListVoters sends
+type listStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*voterpb.Voter
+}
+
+func (s *listStream) Context() context.Context        { return s.ctx }
+func (s *listStream) SetHeader(metadata.MD) error     { return nil }
+func (s *listStream) Send(voter *voterpb.Voter) error { s.sent = append(s.sent, voter); return nil }
+func (s *listStream) SendMsg(m any) error             { return s.Send(m.(*voterpb.Voter)) }
+
+// pagedReader serves the voters 1 to n, without the ids in deleted,
+// and records the size of every page that was read
+type pagedReader struct {
+	read.Adapter
+	n       int
+	deleted map[int]bool
+	pages   []int
+}
+
+func (r *pagedReader) ReadVoterIds(ctx context.Context) ([]int, error) {
+	ids := make([]int, r.n)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	return ids, nil
+}
+
+func (r *pagedReader) ReadVoters(ctx context.Context, ids []int) ([]*read.Voter, error) {
+	r.pages = append(r.pages, len(ids))
+	voters := make([]*read.Voter, len(ids))
+	for i, id := range ids {
+		if !r.deleted[id] {
+			voters[i] = &read.Voter{Id: id}
+		}
+	}
+	return voters, nil
+}
+
+func TestListVotersReadsPages(t *testing.T) {
+	reader := &pagedReader{n: 2*listPageSize + 5, deleted: map[int]bool{7: true}}
+	s := &server{readAdapter: reader}
+	stream := &listStream{ctx: context.Background()}
+
+	if err := s.ListVoters(&voterpb.ListVotersRequest{}, stream); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{listPageSize, listPageSize, 5}; fmt.Sprint(reader.pages) != fmt.Sprint(want) {
+		t.Errorf("read the pages %v, want %v", reader.pages, want)
+	}
+	if len(stream.sent) != reader.n-1 {
+		t.Fatalf("sent %d voters, want %d", len(stream.sent), reader.n-1)
+	}
+	for i := 1; i < len(stream.sent); i++ {
+		if stream.sent[i-1].Id >= stream.sent[i].Id || stream.sent[i].Id == 7 {
+			t.Fatalf("sent voter %d after %d", stream.sent[i].Id, stream.sent[i-1].Id)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package rpc
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"drexel.edu/voter-api/pkg/create"
+	"drexel.edu/voter-api/pkg/delete"
+	"drexel.edu/voter-api/pkg/read"
+	"drexel.edu/voter-api/pkg/rpc/voterpb"
+	"drexel.edu/voter-api/pkg/update"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The gRPC Port. Like the rest Port it only translates between the
+// wire format, here the messages of proto/voter/v1/voter.proto, and
+// the adapters. Writes answer with the stored result, read back
+// through the read adapter, instead of a status string.
+
+type server struct {
+	voterpb.UnimplementedVoterServiceServer
+
+	createAdapter create.Adapter
+	updateAdapter update.Adapter
+	readAdapter   read.Adapter
+	deleteAdapter delete.Adapter
+}
+
+func (s *server) CreateVoter(ctx context.Context, req *voterpb.CreateVoterRequest) (*voterpb.Voter, error) {
+	err := s.createAdapter.CreateVoter(ctx, create.Voter{
+		Id:    int(req.GetId()),
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return s.GetVoter(ctx, &voterpb.GetVoterRequest{Id: req.GetId()})
+}
+
+func (s *server) GetVoter(ctx context.Context, req *voterpb.GetVoterRequest) (*voterpb.Voter, error) {
+	voter, err := s.readAdapter.ReadVoter(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toVoter(voter), nil
+}
+
+func (s *server) UpdateVoter(ctx context.Context, req *voterpb.UpdateVoterRequest) (*voterpb.Voter, error) {
+	err := s.updateAdapter.UpdateVoter(ctx, update.Voter{
+		Id:    int(req.GetId()),
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return s.GetVoter(ctx, &voterpb.GetVoterRequest{Id: req.GetId()})
+}
+
+func (s *server) DeleteVoter(ctx context.Context, req *voterpb.DeleteVoterRequest) (*emptypb.Empty, error) {
+	if err := s.deleteAdapter.DeleteVoter(ctx, int(req.GetId())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// listPageSize is how many voters ListVoters reads from the repository
+// at once
+const listPageSize = 100
+
+// ListVoters streams the voters in the order of their ids. Only the ids
+// are read up front, the voters are read and sent a page at a time, so
+// a large table is never held in memory at once.
+func (s *server) ListVoters(req *voterpb.ListVotersRequest, stream voterpb.VoterService_ListVotersServer) error {
+	ctx := stream.Context()
+	ids, err := s.readAdapter.ReadVoterIds(ctx)
+	if err != nil {
+		return toStatus(err)
+	}
+	for start := 0; start < len(ids); start += listPageSize {
+		voters, err := s.readAdapter.ReadVoters(ctx, ids[start:min(start+listPageSize, len(ids))])
+		if err != nil {
+			return toStatus(err)
+		}
+		for _, voter := range voters {
+			//deleted since the ids were read
+			if voter == nil {
+				continue
+			}
+			if err := stream.Send(toVoter(*voter)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *server) CreateVoterHistory(ctx context.Context, req *voterpb.CreateVoterHistoryRequest) (*voterpb.VoterHistory, error) {
+	err := s.createAdapter.CreateVoterHistory(ctx, int(req.GetVoterId()), create.VoterHistory{
+		PollId:   int(req.GetPollId()),
+		VoteId:   int(req.GetVoteId()),
+		VoteDate: fromTimestamp(req.GetVoteDate()),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return s.GetVoterHistory(ctx, &voterpb.GetVoterHistoryRequest{VoterId: req.GetVoterId(), PollId: req.GetPollId()})
+}
+
+func (s *server) GetVoterHistory(ctx context.Context, req *voterpb.GetVoterHistoryRequest) (*voterpb.VoterHistory, error) {
+	history, err := s.readAdapter.ReadVoterHistory(ctx, int(req.GetVoterId()), int(req.GetPollId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toVoterHistory(history), nil
+}
+
+func (s *server) UpdateVoterHistory(ctx context.Context, req *voterpb.UpdateVoterHistoryRequest) (*voterpb.VoterHistory, error) {
+	err := s.updateAdapter.UpdateVoterHistory(ctx, int(req.GetVoterId()), update.VoterHistory{
+		PollId:   int(req.GetPollId()),
+		VoteId:   int(req.GetVoteId()),
+		VoteDate: fromTimestamp(req.GetVoteDate()),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return s.GetVoterHistory(ctx, &voterpb.GetVoterHistoryRequest{VoterId: req.GetVoterId(), PollId: req.GetPollId()})
+}
+
+func (s *server) DeleteVoterHistory(ctx context.Context, req *voterpb.DeleteVoterHistoryRequest) (*emptypb.Empty, error) {
+	if err := s.deleteAdapter.DeleteVoterHistory(ctx, int(req.GetVoterId()), int(req.GetPollId())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) ListVoterHistory(req *voterpb.ListVoterHistoryRequest, stream voterpb.VoterService_ListVoterHistoryServer) error {
+	histories, err := s.readAdapter.ReadAllVoterHistory(stream.Context(), int(req.GetVoterId()))
+	if err != nil {
+		return toStatus(err)
+	}
+	sort.Slice(histories, func(i, j int) bool { return histories[i].PollId < histories[j].PollId })
+	for _, history := range histories {
+		if err := stream.Send(toVoterHistory(*history)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toVoter(v read.Voter) *voterpb.Voter {
+	voter := &voterpb.Voter{
+		Id:    int64(v.Id),
+		Name:  v.Name,
+		Email: v.Email,
+	}
+	for _, history := range v.VoterHistory {
+		voter.History = append(voter.History, toVoterHistory(history))
+	}
+	sort.Slice(voter.History, func(i, j int) bool {
+		return voter.History[i].PollId < voter.History[j].PollId
+	})
+	return voter
+}
+
+func toVoterHistory(h read.VoterHistory) *voterpb.VoterHistory {
+	history := &voterpb.VoterHistory{
+		PollId: int64(h.PollId),
+		VoteId: int64(h.VoteId),
+	}
+	if !h.VoteDate.IsZero() {
+		history.VoteDate = timestamppb.New(h.VoteDate)
+	}
+	return history
+}
+
+// fromTimestamp maps an unset vote date to the zero time, like a JSON
+// body without vote_date
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,1080 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.33.0-devel
+// 	protoc        (unknown)
+// source: voter/v1/voter.proto
+
+// The gRPC Port of voter-api. It is backed by the same create, read,
+// update and delete adapters as the REST Port. Errors use the standard
+// status codes: NOT_FOUND, ALREADY_EXISTS, INVALID_ARGUMENT, ABORTED
+// for a write that lost a race, DEADLINE_EXCEEDED and INTERNAL.
+//
+// pkg/rpc/voterpb is generated from this file, see go:generate in
+// pkg/rpc/grpc.go.
+
+package voterpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Voter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id    int64  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email string `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	// history is ordered by poll id
+	History []*VoterHistory `protobuf:"bytes,4,rep,name=history,proto3" json:"history,omitempty"`
+}
+
+func (x *Voter) Reset() {
+	*x = Voter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Voter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Voter) ProtoMessage() {}
+
+func (x *Voter) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Voter.ProtoReflect.Descriptor instead.
+func (*Voter) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Voter) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Voter) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Voter) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *Voter) GetHistory() []*VoterHistory {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+type VoterHistory struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PollId   int64                  `protobuf:"varint,1,opt,name=poll_id,json=pollId,proto3" json:"poll_id,omitempty"`
+	VoteId   int64                  `protobuf:"varint,2,opt,name=vote_id,json=voteId,proto3" json:"vote_id,omitempty"`
+	VoteDate *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=vote_date,json=voteDate,proto3" json:"vote_date,omitempty"`
+}
+
+func (x *VoterHistory) Reset() {
+	*x = VoterHistory{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VoterHistory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoterHistory) ProtoMessage() {}
+
+func (x *VoterHistory) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoterHistory.ProtoReflect.Descriptor instead.
+func (*VoterHistory) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *VoterHistory) GetPollId() int64 {
+	if x != nil {
+		return x.PollId
+	}
+	return 0
+}
+
+func (x *VoterHistory) GetVoteId() int64 {
+	if x != nil {
+		return x.VoteId
+	}
+	return 0
+}
+
+func (x *VoterHistory) GetVoteDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.VoteDate
+	}
+	return nil
+}
+
+type CreateVoterRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id    int64  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email string `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+}
+
+func (x *CreateVoterRequest) Reset() {
+	*x = CreateVoterRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateVoterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateVoterRequest) ProtoMessage() {}
+
+func (x *CreateVoterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateVoterRequest.ProtoReflect.Descriptor instead.
+func (*CreateVoterRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateVoterRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *CreateVoterRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateVoterRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+type GetVoterRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id int64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *GetVoterRequest) Reset() {
+	*x = GetVoterRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetVoterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVoterRequest) ProtoMessage() {}
+
+func (x *GetVoterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVoterRequest.ProtoReflect.Descriptor instead.
+func (*GetVoterRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetVoterRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type UpdateVoterRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id    int64  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email string `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+}
+
+func (x *UpdateVoterRequest) Reset() {
+	*x = UpdateVoterRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateVoterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateVoterRequest) ProtoMessage() {}
+
+func (x *UpdateVoterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateVoterRequest.ProtoReflect.Descriptor instead.
+func (*UpdateVoterRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpdateVoterRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *UpdateVoterRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UpdateVoterRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+type DeleteVoterRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id int64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *DeleteVoterRequest) Reset() {
+	*x = DeleteVoterRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteVoterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteVoterRequest) ProtoMessage() {}
+
+func (x *DeleteVoterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteVoterRequest.ProtoReflect.Descriptor instead.
+func (*DeleteVoterRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteVoterRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListVotersRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListVotersRequest) Reset() {
+	*x = ListVotersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListVotersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVotersRequest) ProtoMessage() {}
+
+func (x *ListVotersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVotersRequest.ProtoReflect.Descriptor instead.
+func (*ListVotersRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{6}
+}
+
+type CreateVoterHistoryRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	VoterId  int64                  `protobuf:"varint,1,opt,name=voter_id,json=voterId,proto3" json:"voter_id,omitempty"`
+	PollId   int64                  `protobuf:"varint,2,opt,name=poll_id,json=pollId,proto3" json:"poll_id,omitempty"`
+	VoteId   int64                  `protobuf:"varint,3,opt,name=vote_id,json=voteId,proto3" json:"vote_id,omitempty"`
+	VoteDate *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=vote_date,json=voteDate,proto3" json:"vote_date,omitempty"`
+}
+
+func (x *CreateVoterHistoryRequest) Reset() {
+	*x = CreateVoterHistoryRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateVoterHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateVoterHistoryRequest) ProtoMessage() {}
+
+func (x *CreateVoterHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateVoterHistoryRequest.ProtoReflect.Descriptor instead.
+func (*CreateVoterHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CreateVoterHistoryRequest) GetVoterId() int64 {
+	if x != nil {
+		return x.VoterId
+	}
+	return 0
+}
+
+func (x *CreateVoterHistoryRequest) GetPollId() int64 {
+	if x != nil {
+		return x.PollId
+	}
+	return 0
+}
+
+func (x *CreateVoterHistoryRequest) GetVoteId() int64 {
+	if x != nil {
+		return x.VoteId
+	}
+	return 0
+}
+
+func (x *CreateVoterHistoryRequest) GetVoteDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.VoteDate
+	}
+	return nil
+}
+
+type GetVoterHistoryRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	VoterId int64 `protobuf:"varint,1,opt,name=voter_id,json=voterId,proto3" json:"voter_id,omitempty"`
+	PollId  int64 `protobuf:"varint,2,opt,name=poll_id,json=pollId,proto3" json:"poll_id,omitempty"`
+}
+
+func (x *GetVoterHistoryRequest) Reset() {
+	*x = GetVoterHistoryRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetVoterHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVoterHistoryRequest) ProtoMessage() {}
+
+func (x *GetVoterHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVoterHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetVoterHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetVoterHistoryRequest) GetVoterId() int64 {
+	if x != nil {
+		return x.VoterId
+	}
+	return 0
+}
+
+func (x *GetVoterHistoryRequest) GetPollId() int64 {
+	if x != nil {
+		return x.PollId
+	}
+	return 0
+}
+
+type UpdateVoterHistoryRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	VoterId  int64                  `protobuf:"varint,1,opt,name=voter_id,json=voterId,proto3" json:"voter_id,omitempty"`
+	PollId   int64                  `protobuf:"varint,2,opt,name=poll_id,json=pollId,proto3" json:"poll_id,omitempty"`
+	VoteId   int64                  `protobuf:"varint,3,opt,name=vote_id,json=voteId,proto3" json:"vote_id,omitempty"`
+	VoteDate *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=vote_date,json=voteDate,proto3" json:"vote_date,omitempty"`
+}
+
+func (x *UpdateVoterHistoryRequest) Reset() {
+	*x = UpdateVoterHistoryRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateVoterHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateVoterHistoryRequest) ProtoMessage() {}
+
+func (x *UpdateVoterHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateVoterHistoryRequest.ProtoReflect.Descriptor instead.
+func (*UpdateVoterHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *UpdateVoterHistoryRequest) GetVoterId() int64 {
+	if x != nil {
+		return x.VoterId
+	}
+	return 0
+}
+
+func (x *UpdateVoterHistoryRequest) GetPollId() int64 {
+	if x != nil {
+		return x.PollId
+	}
+	return 0
+}
+
+func (x *UpdateVoterHistoryRequest) GetVoteId() int64 {
+	if x != nil {
+		return x.VoteId
+	}
+	return 0
+}
+
+func (x *UpdateVoterHistoryRequest) GetVoteDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.VoteDate
+	}
+	return nil
+}
+
+type DeleteVoterHistoryRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	VoterId int64 `protobuf:"varint,1,opt,name=voter_id,json=voterId,proto3" json:"voter_id,omitempty"`
+	PollId  int64 `protobuf:"varint,2,opt,name=poll_id,json=pollId,proto3" json:"poll_id,omitempty"`
+}
+
+func (x *DeleteVoterHistoryRequest) Reset() {
+	*x = DeleteVoterHistoryRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteVoterHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteVoterHistoryRequest) ProtoMessage() {}
+
+func (x *DeleteVoterHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteVoterHistoryRequest.ProtoReflect.Descriptor instead.
+func (*DeleteVoterHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteVoterHistoryRequest) GetVoterId() int64 {
+	if x != nil {
+		return x.VoterId
+	}
+	return 0
+}
+
+func (x *DeleteVoterHistoryRequest) GetPollId() int64 {
+	if x != nil {
+		return x.PollId
+	}
+	return 0
+}
+
+type ListVoterHistoryRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	VoterId int64 `protobuf:"varint,1,opt,name=voter_id,json=voterId,proto3" json:"voter_id,omitempty"`
+}
+
+func (x *ListVoterHistoryRequest) Reset() {
+	*x = ListVoterHistoryRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListVoterHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVoterHistoryRequest) ProtoMessage() {}
+
+func (x *ListVoterHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVoterHistoryRequest.ProtoReflect.Descriptor instead.
+func (*ListVoterHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListVoterHistoryRequest) GetVoterId() int64 {
+	if x != nil {
+		return x.VoterId
+	}
+	return 0
+}
+
+var File_voter_v1_voter_proto protoreflect.FileDescriptor
+
+var file_voter_v1_voter_proto_rawDesc = []byte{
+	0x0a, 0x14, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x2f, 0x76, 0x6f, 0x74, 0x65, 0x72,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x08, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31,
+	0x1a, 0x1b, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2f, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x73,
+	0x0a, 0x05, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x65,
+	0x6d, 0x61, 0x69, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x6d, 0x61, 0x69,
+	0x6c, 0x12, 0x30, 0x0a, 0x07, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x16, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f,
+	0x74, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x07, 0x68, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x22, 0x79, 0x0a, 0x0c, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x12, 0x17, 0x0a, 0x07, 0x70, 0x6f, 0x6c, 0x6c, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x70, 0x6f, 0x6c, 0x6c, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07,
+	0x76, 0x6f, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x76,
+	0x6f, 0x74, 0x65, 0x49, 0x64, 0x12, 0x37, 0x0a, 0x09, 0x76, 0x6f, 0x74, 0x65, 0x5f, 0x64, 0x61,
+	0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x76, 0x6f, 0x74, 0x65, 0x44, 0x61, 0x74, 0x65, 0x22, 0x4e,
+	0x0a, 0x12, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6d, 0x61, 0x69,
+	0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x22, 0x21,
+	0x0a, 0x0f, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x02, 0x69,
+	0x64, 0x22, 0x4e, 0x0a, 0x12, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x65,
+	0x6d, 0x61, 0x69, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x6d, 0x61, 0x69,
+	0x6c, 0x22, 0x24, 0x0a, 0x12, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x02, 0x69, 0x64, 0x22, 0x13, 0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74, 0x56,
+	0x6f, 0x74, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xa1, 0x01, 0x0a,
+	0x19, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x76, 0x6f,
+	0x74, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x6f,
+	0x74, 0x65, 0x72, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x70, 0x6f, 0x6c, 0x6c, 0x5f, 0x69, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x70, 0x6f, 0x6c, 0x6c, 0x49, 0x64, 0x12, 0x17,
+	0x0a, 0x07, 0x76, 0x6f, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x06, 0x76, 0x6f, 0x74, 0x65, 0x49, 0x64, 0x12, 0x37, 0x0a, 0x09, 0x76, 0x6f, 0x74, 0x65, 0x5f,
+	0x64, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x76, 0x6f, 0x74, 0x65, 0x44, 0x61, 0x74, 0x65,
+	0x22, 0x4c, 0x0a, 0x16, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x76, 0x6f,
+	0x74, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x6f,
+	0x74, 0x65, 0x72, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x70, 0x6f, 0x6c, 0x6c, 0x5f, 0x69, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x70, 0x6f, 0x6c, 0x6c, 0x49, 0x64, 0x22, 0xa1,
+	0x01, 0x0a, 0x19, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69,
+	0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08,
+	0x76, 0x6f, 0x74, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07,
+	0x76, 0x6f, 0x74, 0x65, 0x72, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x70, 0x6f, 0x6c, 0x6c, 0x5f,
+	0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x70, 0x6f, 0x6c, 0x6c, 0x49, 0x64,
+	0x12, 0x17, 0x0a, 0x07, 0x76, 0x6f, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x06, 0x76, 0x6f, 0x74, 0x65, 0x49, 0x64, 0x12, 0x37, 0x0a, 0x09, 0x76, 0x6f, 0x74,
+	0x65, 0x5f, 0x64, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x76, 0x6f, 0x74, 0x65, 0x44, 0x61,
+	0x74, 0x65, 0x22, 0x4f, 0x0a, 0x19, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65,
+	0x72, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x19, 0x0a, 0x08, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x07, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x70, 0x6f,
+	0x6c, 0x6c, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x70, 0x6f, 0x6c,
+	0x6c, 0x49, 0x64, 0x22, 0x34, 0x0a, 0x17, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19,
+	0x0a, 0x08, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x07, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x49, 0x64, 0x32, 0xdc, 0x05, 0x0a, 0x0c, 0x56, 0x6f,
+	0x74, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3c, 0x0a, 0x0b, 0x43, 0x72,
+	0x65, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x1c, 0x2e, 0x76, 0x6f, 0x74, 0x65,
+	0x72, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0f, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e,
+	0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x36, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x56,
+	0x6f, 0x74, 0x65, 0x72, 0x12, 0x19, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e,
+	0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x0f, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x12, 0x3c, 0x0a, 0x0b, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12,
+	0x1c, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0f, 0x2e,
+	0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x43,
+	0x0a, 0x0b, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x1c, 0x2e,
+	0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x56,
+	0x6f, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d,
+	0x70, 0x74, 0x79, 0x12, 0x3c, 0x0a, 0x0a, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x73, 0x12, 0x1b, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73,
+	0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0f,
+	0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x30,
+	0x01, 0x12, 0x51, 0x0a, 0x12, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x23, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e,
+	0x76, 0x31, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69,
+	0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x76,
+	0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69, 0x73,
+	0x74, 0x6f, 0x72, 0x79, 0x12, 0x4b, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x20, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e,
+	0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74, 0x6f,
+	0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x76, 0x6f, 0x74, 0x65,
+	0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72,
+	0x79, 0x12, 0x51, 0x0a, 0x12, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x23, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e,
+	0x76, 0x31, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69,
+	0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x76,
+	0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48, 0x69, 0x73,
+	0x74, 0x6f, 0x72, 0x79, 0x12, 0x51, 0x0a, 0x12, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x56, 0x6f,
+	0x74, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x23, 0x2e, 0x76, 0x6f, 0x74,
+	0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x56, 0x6f, 0x74, 0x65,
+	0x72, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x4f, 0x0a, 0x10, 0x4c, 0x69, 0x73, 0x74, 0x56,
+	0x6f, 0x74, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x21, 0x2e, 0x76, 0x6f,
+	0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16,
+	0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x48,
+	0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x30, 0x01, 0x42, 0x2e, 0x5a, 0x2c, 0x64, 0x72, 0x65, 0x78,
+	0x65, 0x6c, 0x2e, 0x65, 0x64, 0x75, 0x2f, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2d, 0x61, 0x70, 0x69,
+	0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x70, 0x62,
+	0x3b, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_voter_v1_voter_proto_rawDescOnce sync.Once
+	file_voter_v1_voter_proto_rawDescData = file_voter_v1_voter_proto_rawDesc
+)
+
+func file_voter_v1_voter_proto_rawDescGZIP() []byte {
+	file_voter_v1_voter_proto_rawDescOnce.Do(func() {
+		file_voter_v1_voter_proto_rawDescData = protoimpl.X.CompressGZIP(file_voter_v1_voter_proto_rawDescData)
+	})
+	return file_voter_v1_voter_proto_rawDescData
+}
+
+var file_voter_v1_voter_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_voter_v1_voter_proto_goTypes = []interface{}{
+	(*Voter)(nil),                     // 0: voter.v1.Voter
+	(*VoterHistory)(nil),              // 1: voter.v1.VoterHistory
+	(*CreateVoterRequest)(nil),        // 2: voter.v1.CreateVoterRequest
+	(*GetVoterRequest)(nil),           // 3: voter.v1.GetVoterRequest
+	(*UpdateVoterRequest)(nil),        // 4: voter.v1.UpdateVoterRequest
+	(*DeleteVoterRequest)(nil),        // 5: voter.v1.DeleteVoterRequest
+	(*ListVotersRequest)(nil),         // 6: voter.v1.ListVotersRequest
+	(*CreateVoterHistoryRequest)(nil), // 7: voter.v1.CreateVoterHistoryRequest
+	(*GetVoterHistoryRequest)(nil),    // 8: voter.v1.GetVoterHistoryRequest
+	(*UpdateVoterHistoryRequest)(nil), // 9: voter.v1.UpdateVoterHistoryRequest
+	(*DeleteVoterHistoryRequest)(nil), // 10: voter.v1.DeleteVoterHistoryRequest
+	(*ListVoterHistoryRequest)(nil),   // 11: voter.v1.ListVoterHistoryRequest
+	(*timestamppb.Timestamp)(nil),     // 12: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),             // 13: google.protobuf.Empty
+}
+var file_voter_v1_voter_proto_depIdxs = []int32{
+	1,  // 0: voter.v1.Voter.history:type_name -> voter.v1.VoterHistory
+	12, // 1: voter.v1.VoterHistory.vote_date:type_name -> google.protobuf.Timestamp
+	12, // 2: voter.v1.CreateVoterHistoryRequest.vote_date:type_name -> google.protobuf.Timestamp
+	12, // 3: voter.v1.UpdateVoterHistoryRequest.vote_date:type_name -> google.protobuf.Timestamp
+	2,  // 4: voter.v1.VoterService.CreateVoter:input_type -> voter.v1.CreateVoterRequest
+	3,  // 5: voter.v1.VoterService.GetVoter:input_type -> voter.v1.GetVoterRequest
+	4,  // 6: voter.v1.VoterService.UpdateVoter:input_type -> voter.v1.UpdateVoterRequest
+	5,  // 7: voter.v1.VoterService.DeleteVoter:input_type -> voter.v1.DeleteVoterRequest
+	6,  // 8: voter.v1.VoterService.ListVoters:input_type -> voter.v1.ListVotersRequest
+	7,  // 9: voter.v1.VoterService.CreateVoterHistory:input_type -> voter.v1.CreateVoterHistoryRequest
+	8,  // 10: voter.v1.VoterService.GetVoterHistory:input_type -> voter.v1.GetVoterHistoryRequest
+	9,  // 11: voter.v1.VoterService.UpdateVoterHistory:input_type -> voter.v1.UpdateVoterHistoryRequest
+	10, // 12: voter.v1.VoterService.DeleteVoterHistory:input_type -> voter.v1.DeleteVoterHistoryRequest
+	11, // 13: voter.v1.VoterService.ListVoterHistory:input_type -> voter.v1.ListVoterHistoryRequest
+	0,  // 14: voter.v1.VoterService.CreateVoter:output_type -> voter.v1.Voter
+	0,  // 15: voter.v1.VoterService.GetVoter:output_type -> voter.v1.Voter
+	0,  // 16: voter.v1.VoterService.UpdateVoter:output_type -> voter.v1.Voter
+	13, // 17: voter.v1.VoterService.DeleteVoter:output_type -> google.protobuf.Empty
+	0,  // 18: voter.v1.VoterService.ListVoters:output_type -> voter.v1.Voter
+	1,  // 19: voter.v1.VoterService.CreateVoterHistory:output_type -> voter.v1.VoterHistory
+	1,  // 20: voter.v1.VoterService.GetVoterHistory:output_type -> voter.v1.VoterHistory
+	1,  // 21: voter.v1.VoterService.UpdateVoterHistory:output_type -> voter.v1.VoterHistory
+	13, // 22: voter.v1.VoterService.DeleteVoterHistory:output_type -> google.protobuf.Empty
+	1,  // 23: voter.v1.VoterService.ListVoterHistory:output_type -> voter.v1.VoterHistory
+	14, // [14:24] is the sub-list for method output_type
+	4,  // [4:14] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_voter_v1_voter_proto_init() }
+func file_voter_v1_voter_proto_init() {
+	if File_voter_v1_voter_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_voter_v1_voter_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Voter); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*VoterHistory); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateVoterRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetVoterRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateVoterRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteVoterRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListVotersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateVoterHistoryRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetVoterHistoryRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateVoterHistoryRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteVoterHistoryRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListVoterHistoryRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_voter_v1_voter_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_voter_v1_voter_proto_goTypes,
+		DependencyIndexes: file_voter_v1_voter_proto_depIdxs,
+		MessageInfos:      file_voter_v1_voter_proto_msgTypes,
+	}.Build()
+	File_voter_v1_voter_proto = out.File
+	file_voter_v1_voter_proto_rawDesc = nil
+	file_voter_v1_voter_proto_goTypes = nil
+	file_voter_v1_voter_proto_depIdxs = nil
+}
@@ -0,0 +1,512 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: voter/v1/voter.proto
+
+// The gRPC Port of voter-api. It is backed by the same create, read,
+// update and delete adapters as the REST Port. Errors use the standard
+// status codes: NOT_FOUND, ALREADY_EXISTS, INVALID_ARGUMENT, ABORTED
+// for a write that lost a race, DEADLINE_EXCEEDED and INTERNAL.
+//
+// pkg/rpc/voterpb is generated from this file, see go:generate in
+// pkg/rpc/grpc.go.
+
+package voterpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	VoterService_CreateVoter_FullMethodName        = "/voter.v1.VoterService/CreateVoter"
+	VoterService_GetVoter_FullMethodName           = "/voter.v1.VoterService/GetVoter"
+	VoterService_UpdateVoter_FullMethodName        = "/voter.v1.VoterService/UpdateVoter"
+	VoterService_DeleteVoter_FullMethodName        = "/voter.v1.VoterService/DeleteVoter"
+	VoterService_ListVoters_FullMethodName         = "/voter.v1.VoterService/ListVoters"
+	VoterService_CreateVoterHistory_FullMethodName = "/voter.v1.VoterService/CreateVoterHistory"
+	VoterService_GetVoterHistory_FullMethodName    = "/voter.v1.VoterService/GetVoterHistory"
+	VoterService_UpdateVoterHistory_FullMethodName = "/voter.v1.VoterService/UpdateVoterHistory"
+	VoterService_DeleteVoterHistory_FullMethodName = "/voter.v1.VoterService/DeleteVoterHistory"
+	VoterService_ListVoterHistory_FullMethodName   = "/voter.v1.VoterService/ListVoterHistory"
+)
+
+// VoterServiceClient is the client API for VoterService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VoterServiceClient interface {
+	CreateVoter(ctx context.Context, in *CreateVoterRequest, opts ...grpc.CallOption) (*Voter, error)
+	GetVoter(ctx context.Context, in *GetVoterRequest, opts ...grpc.CallOption) (*Voter, error)
+	UpdateVoter(ctx context.Context, in *UpdateVoterRequest, opts ...grpc.CallOption) (*Voter, error)
+	DeleteVoter(ctx context.Context, in *DeleteVoterRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListVoters streams every voter, one message per voter.
+	ListVoters(ctx context.Context, in *ListVotersRequest, opts ...grpc.CallOption) (VoterService_ListVotersClient, error)
+	CreateVoterHistory(ctx context.Context, in *CreateVoterHistoryRequest, opts ...grpc.CallOption) (*VoterHistory, error)
+	GetVoterHistory(ctx context.Context, in *GetVoterHistoryRequest, opts ...grpc.CallOption) (*VoterHistory, error)
+	UpdateVoterHistory(ctx context.Context, in *UpdateVoterHistoryRequest, opts ...grpc.CallOption) (*VoterHistory, error)
+	DeleteVoterHistory(ctx context.Context, in *DeleteVoterHistoryRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListVoterHistory streams the history of one voter, one message per
+	// poll.
+	ListVoterHistory(ctx context.Context, in *ListVoterHistoryRequest, opts ...grpc.CallOption) (VoterService_ListVoterHistoryClient, error)
+}
+
+type voterServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVoterServiceClient(cc grpc.ClientConnInterface) VoterServiceClient {
+	return &voterServiceClient{cc}
+}
+
+func (c *voterServiceClient) CreateVoter(ctx context.Context, in *CreateVoterRequest, opts ...grpc.CallOption) (*Voter, error) {
+	out := new(Voter)
+	err := c.cc.Invoke(ctx, VoterService_CreateVoter_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) GetVoter(ctx context.Context, in *GetVoterRequest, opts ...grpc.CallOption) (*Voter, error) {
+	out := new(Voter)
+	err := c.cc.Invoke(ctx, VoterService_GetVoter_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) UpdateVoter(ctx context.Context, in *UpdateVoterRequest, opts ...grpc.CallOption) (*Voter, error) {
+	out := new(Voter)
+	err := c.cc.Invoke(ctx, VoterService_UpdateVoter_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) DeleteVoter(ctx context.Context, in *DeleteVoterRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, VoterService_DeleteVoter_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) ListVoters(ctx context.Context, in *ListVotersRequest, opts ...grpc.CallOption) (VoterService_ListVotersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VoterService_ServiceDesc.Streams[0], VoterService_ListVoters_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &voterServiceListVotersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VoterService_ListVotersClient interface {
+	Recv() (*Voter, error)
+	grpc.ClientStream
+}
+
+type voterServiceListVotersClient struct {
+	grpc.ClientStream
+}
+
+func (x *voterServiceListVotersClient) Recv() (*Voter, error) {
+	m := new(Voter)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *voterServiceClient) CreateVoterHistory(ctx context.Context, in *CreateVoterHistoryRequest, opts ...grpc.CallOption) (*VoterHistory, error) {
+	out := new(VoterHistory)
+	err := c.cc.Invoke(ctx, VoterService_CreateVoterHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) GetVoterHistory(ctx context.Context, in *GetVoterHistoryRequest, opts ...grpc.CallOption) (*VoterHistory, error) {
+	out := new(VoterHistory)
+	err := c.cc.Invoke(ctx, VoterService_GetVoterHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) UpdateVoterHistory(ctx context.Context, in *UpdateVoterHistoryRequest, opts ...grpc.CallOption) (*VoterHistory, error) {
+	out := new(VoterHistory)
+	err := c.cc.Invoke(ctx, VoterService_UpdateVoterHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) DeleteVoterHistory(ctx context.Context, in *DeleteVoterHistoryRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, VoterService_DeleteVoterHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) ListVoterHistory(ctx context.Context, in *ListVoterHistoryRequest, opts ...grpc.CallOption) (VoterService_ListVoterHistoryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VoterService_ServiceDesc.Streams[1], VoterService_ListVoterHistory_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &voterServiceListVoterHistoryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VoterService_ListVoterHistoryClient interface {
+	Recv() (*VoterHistory, error)
+	grpc.ClientStream
+}
+
+type voterServiceListVoterHistoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *voterServiceListVoterHistoryClient) Recv() (*VoterHistory, error) {
+	m := new(VoterHistory)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VoterServiceServer is the server API for VoterService service.
+// All implementations must embed UnimplementedVoterServiceServer
+// for forward compatibility
+type VoterServiceServer interface {
+	CreateVoter(context.Context, *CreateVoterRequest) (*Voter, error)
+	GetVoter(context.Context, *GetVoterRequest) (*Voter, error)
+	UpdateVoter(context.Context, *UpdateVoterRequest) (*Voter, error)
+	DeleteVoter(context.Context, *DeleteVoterRequest) (*emptypb.Empty, error)
+	// ListVoters streams every voter, one message per voter.
+	ListVoters(*ListVotersRequest, VoterService_ListVotersServer) error
+	CreateVoterHistory(context.Context, *CreateVoterHistoryRequest) (*VoterHistory, error)
+	GetVoterHistory(context.Context, *GetVoterHistoryRequest) (*VoterHistory, error)
+	UpdateVoterHistory(context.Context, *UpdateVoterHistoryRequest) (*VoterHistory, error)
+	DeleteVoterHistory(context.Context, *DeleteVoterHistoryRequest) (*emptypb.Empty, error)
+	// ListVoterHistory streams the history of one voter, one message per
+	// poll.
+	ListVoterHistory(*ListVoterHistoryRequest, VoterService_ListVoterHistoryServer) error
+	mustEmbedUnimplementedVoterServiceServer()
+}
+
+// UnimplementedVoterServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedVoterServiceServer struct {
+}
+
+func (UnimplementedVoterServiceServer) CreateVoter(context.Context, *CreateVoterRequest) (*Voter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateVoter not implemented")
+}
+func (UnimplementedVoterServiceServer) GetVoter(context.Context, *GetVoterRequest) (*Voter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVoter not implemented")
+}
+func (UnimplementedVoterServiceServer) UpdateVoter(context.Context, *UpdateVoterRequest) (*Voter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateVoter not implemented")
+}
+func (UnimplementedVoterServiceServer) DeleteVoter(context.Context, *DeleteVoterRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteVoter not implemented")
+}
+func (UnimplementedVoterServiceServer) ListVoters(*ListVotersRequest, VoterService_ListVotersServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListVoters not implemented")
+}
+func (UnimplementedVoterServiceServer) CreateVoterHistory(context.Context, *CreateVoterHistoryRequest) (*VoterHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateVoterHistory not implemented")
+}
+func (UnimplementedVoterServiceServer) GetVoterHistory(context.Context, *GetVoterHistoryRequest) (*VoterHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVoterHistory not implemented")
+}
+func (UnimplementedVoterServiceServer) UpdateVoterHistory(context.Context, *UpdateVoterHistoryRequest) (*VoterHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateVoterHistory not implemented")
+}
+func (UnimplementedVoterServiceServer) DeleteVoterHistory(context.Context, *DeleteVoterHistoryRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteVoterHistory not implemented")
+}
+func (UnimplementedVoterServiceServer) ListVoterHistory(*ListVoterHistoryRequest, VoterService_ListVoterHistoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListVoterHistory not implemented")
+}
+func (UnimplementedVoterServiceServer) mustEmbedUnimplementedVoterServiceServer() {}
+
+// UnsafeVoterServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VoterServiceServer will
+// result in compilation errors.
+type UnsafeVoterServiceServer interface {
+	mustEmbedUnimplementedVoterServiceServer()
+}
+
+func RegisterVoterServiceServer(s grpc.ServiceRegistrar, srv VoterServiceServer) {
+	s.RegisterService(&VoterService_ServiceDesc, srv)
+}
+
+func _VoterService_CreateVoter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateVoterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).CreateVoter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_CreateVoter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).CreateVoter(ctx, req.(*CreateVoterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_GetVoter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVoterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).GetVoter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_GetVoter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).GetVoter(ctx, req.(*GetVoterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_UpdateVoter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateVoterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).UpdateVoter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_UpdateVoter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).UpdateVoter(ctx, req.(*UpdateVoterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_DeleteVoter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteVoterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).DeleteVoter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_DeleteVoter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).DeleteVoter(ctx, req.(*DeleteVoterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_ListVoters_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListVotersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VoterServiceServer).ListVoters(m, &voterServiceListVotersServer{stream})
+}
+
+type VoterService_ListVotersServer interface {
+	Send(*Voter) error
+	grpc.ServerStream
+}
+
+type voterServiceListVotersServer struct {
+	grpc.ServerStream
+}
+
+func (x *voterServiceListVotersServer) Send(m *Voter) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _VoterService_CreateVoterHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateVoterHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).CreateVoterHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_CreateVoterHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).CreateVoterHistory(ctx, req.(*CreateVoterHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_GetVoterHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVoterHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).GetVoterHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_GetVoterHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).GetVoterHistory(ctx, req.(*GetVoterHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_UpdateVoterHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateVoterHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).UpdateVoterHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_UpdateVoterHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).UpdateVoterHistory(ctx, req.(*UpdateVoterHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_DeleteVoterHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteVoterHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).DeleteVoterHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_DeleteVoterHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).DeleteVoterHistory(ctx, req.(*DeleteVoterHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_ListVoterHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListVoterHistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VoterServiceServer).ListVoterHistory(m, &voterServiceListVoterHistoryServer{stream})
+}
+
+type VoterService_ListVoterHistoryServer interface {
+	Send(*VoterHistory) error
+	grpc.ServerStream
+}
+
+type voterServiceListVoterHistoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *voterServiceListVoterHistoryServer) Send(m *VoterHistory) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// VoterService_ServiceDesc is the grpc.ServiceDesc for VoterService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VoterService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "voter.v1.VoterService",
+	HandlerType: (*VoterServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateVoter",
+			Handler:    _VoterService_CreateVoter_Handler,
+		},
+		{
+			MethodName: "GetVoter",
+			Handler:    _VoterService_GetVoter_Handler,
+		},
+		{
+			MethodName: "UpdateVoter",
+			Handler:    _VoterService_UpdateVoter_Handler,
+		},
+		{
+			MethodName: "DeleteVoter",
+			Handler:    _VoterService_DeleteVoter_Handler,
+		},
+		{
+			MethodName: "CreateVoterHistory",
+			Handler:    _VoterService_CreateVoterHistory_Handler,
+		},
+		{
+			MethodName: "GetVoterHistory",
+			Handler:    _VoterService_GetVoterHistory_Handler,
+		},
+		{
+			MethodName: "UpdateVoterHistory",
+			Handler:    _VoterService_UpdateVoterHistory_Handler,
+		},
+		{
+			MethodName: "DeleteVoterHistory",
+			Handler:    _VoterService_DeleteVoterHistory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListVoters",
+			Handler:       _VoterService_ListVoters_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListVoterHistory",
+			Handler:       _VoterService_ListVoterHistory_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "voter/v1/voter.proto",
+}
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// The search Port finds voters by their name, email and the polls they
+// took part in. The repository answers from a RediSearch index when the
+// backend has one, Scan is the fallback for those that do not.
+
+const (
+	//DefaultLimit and MaxLimit bound the page size
+	DefaultLimit = 20
+	MaxLimit     = 100
+
+	//maxTerms caps the words of q, every one of them is a clause of
+	//the index query
+	maxTerms = 10
+)
+
+type Adapter interface {
+	Search(context.Context, Query) (Result, error)
+}
+
+type Repository interface {
+	SearchVoters(context.Context, storage.VoterQuery) (storage.VoterSearchResult, error)
+}
+
+type adapter struct {
+	r Repository
+}
+
+func New(r Repository) Adapter {
+	return &adapter{r}
+}
+
+func (a *adapter) Search(ctx context.Context, query Query) (Result, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultLimit
+	}
+	if query.Limit < 1 || query.Limit > MaxLimit {
+		return Result{}, storage.Invalidf("limit must be between 1 and %d", MaxLimit)
+	}
+	if query.Offset < 0 {
+		return Result{}, storage.Invalidf("offset cannot be negative")
+	}
+	for _, pollId := range query.Polls {
+		if pollId < 1 {
+			return Result{}, storage.Invalidf("invalid Poll Id")
+		}
+	}
+
+	//single letters, such as initials, are too short for a prefix
+	//search and are left out
+	var words []string
+	for _, word := range terms(query.Q) {
+		if utf8.RuneCountInString(word) > 1 {
+			words = append(words, word)
+		}
+	}
+	if len(words) > maxTerms {
+		return Result{}, storage.Invalidf("a search cannot have more than %d words", maxTerms)
+	}
+
+	found, err := a.r.SearchVoters(ctx, storage.VoterQuery{
+		Terms:   words,
+		Fuzzy:   query.Fuzzy,
+		Email:   strings.TrimSpace(query.Email),
+		PollIds: query.Polls,
+		Offset:  query.Offset,
+		Limit:   query.Limit,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Total:  found.Total,
+		Voters: make([]Voter, 0, len(found.Voters)),
+		Facets: Facets{Polls: make([]PollFacet, 0, len(found.PollFacets))},
+	}
+	for _, voter := range found.Voters {
+		result.Voters = append(result.Voters, fromStorage(voter))
+	}
+	for pollId, count := range found.PollFacets {
+		result.Facets.Polls = append(result.Facets.Polls, PollFacet{PollId: pollId, Count: count})
+	}
+	sort.Slice(result.Facets.Polls, func(i, j int) bool {
+		return result.Facets.Polls[i].PollId < result.Facets.Polls[j].PollId
+	})
+	return result, nil
+}
+
+// terms splits q into lower case words the way the index tokenizes
+// names, on everything that is not a letter or a digit
+func terms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
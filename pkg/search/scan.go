@@ -0,0 +1,113 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// Lister reads every voter, it is all Scan needs from a backend
+type Lister interface {
+	GetAllItems(context.Context) ([]storage.Voter, error)
+}
+
+// Scan is the Repository for backends without a search index. It reads
+// every voter and matches them in memory, the same way the RediSearch
+// query does, so it costs a full read per search.
+type Scan struct {
+	r Lister
+}
+
+func NewScan(r Lister) *Scan {
+	return &Scan{r}
+}
+
+func (s *Scan) SearchVoters(ctx context.Context, query storage.VoterQuery) (storage.VoterSearchResult, error) {
+	voters, err := s.r.GetAllItems(ctx)
+	if err != nil {
+		return storage.VoterSearchResult{}, err
+	}
+
+	result := storage.VoterSearchResult{PollFacets: make(map[int]int)}
+	var matching []storage.Voter
+	for _, voter := range voters {
+		if !matches(query, voter) {
+			continue
+		}
+		matching = append(matching, voter)
+		for pollId := range voter.VoterHistory {
+			result.PollFacets[pollId]++
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].Id < matching[j].Id })
+
+	result.Total = len(matching)
+	start := min(query.Offset, len(matching))
+	end := min(start+query.Limit, len(matching))
+	result.Voters = matching[start:end]
+	return result, nil
+}
+
+// matches reports whether voter is a hit for query
+func matches(query storage.VoterQuery, voter storage.Voter) bool {
+	if query.Email != "" && !strings.EqualFold(query.Email, voter.Email) {
+		return false
+	}
+	for _, pollId := range query.PollIds {
+		if _, ok := voter.VoterHistory[pollId]; !ok {
+			return false
+		}
+	}
+
+	words := terms(voter.Name)
+	for _, term := range query.Terms {
+		if !matchesAny(term, words, query.Fuzzy) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(term string, words []string, fuzzy bool) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+		if fuzzy && withinOneEdit(term, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinOneEdit reports whether a and b are at most one insertion,
+// deletion or substitution apart
+func withinOneEdit(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(rb)-len(ra) > 1 {
+		return false
+	}
+
+	i, j, edits := 0, 0, 0
+	for i < len(ra) && j < len(rb) {
+		if ra[i] == rb[j] {
+			i++
+			j++
+			continue
+		}
+		edits++
+		if edits > 1 {
+			return false
+		}
+		if len(ra) == len(rb) {
+			i++
+		}
+		j++
+	}
+	return edits+(len(rb)-j)+(len(ra)-i) <= 1
+}
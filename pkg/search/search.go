@@ -0,0 +1,72 @@
+package search
+
+import (
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// Query is a search as it comes in on GET /voters/search
+type Query struct {
+	//Q holds the words to look for in the name
+	Q string `query:"q"`
+
+	//Fuzzy also matches words one typo away
+	Fuzzy bool `query:"fuzzy"`
+
+	Email string `query:"email"`
+
+	//Polls keeps the voters that took part in all of these polls
+	Polls []int `query:"poll"`
+
+	Offset int `query:"offset"`
+	Limit  int `query:"limit"`
+}
+
+type Result struct {
+	Total  int     `json:"total"`
+	Voters []Voter `json:"voters"`
+	Facets Facets  `json:"facets"`
+}
+
+type Facets struct {
+	Polls []PollFacet `json:"polls"`
+}
+
+// PollFacet counts the matching voters that took part in a poll
+type PollFacet struct {
+	PollId int `json:"poll_id"`
+	Count  int `json:"count"`
+}
+
+type HistoryMap map[int]VoterHistory
+
+type Voter struct {
+	Id           int        `json:"id"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	VoterHistory HistoryMap `json:"history"`
+}
+
+type VoterHistory struct {
+	PollId   int       `json:"poll_id"`
+	VoteId   int       `json:"vote_id"`
+	VoteDate time.Time `json:"vote_date"`
+}
+
+func fromStorage(voter storage.Voter) Voter {
+	history := make(HistoryMap, len(voter.VoterHistory))
+	for pollId, h := range voter.VoterHistory {
+		history[pollId] = VoterHistory{
+			PollId:   h.PollId,
+			VoteId:   h.VoteId,
+			VoteDate: h.VoteDate,
+		}
+	}
+	return Voter{
+		Id:           voter.Id,
+		Name:         voter.Name,
+		Email:        voter.Email,
+		VoterHistory: history,
+	}
+}
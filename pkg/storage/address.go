@@ -0,0 +1,59 @@
+package storage
+
+import "strings"
+
+// This is part of the redis Port
+//
+// An Address is where a voter lives. It decides the precinct the voter
+// is assigned to, see precinct.Rules.
+type Address struct {
+	Number int    `json:"number,omitempty"`
+	Street string `json:"street"`
+	Unit   string `json:"unit,omitempty"`
+	City   string `json:"city"`
+	State  string `json:"state"`
+	Zip    string `json:"zip"`
+}
+
+// An Assigner picks the precinct of an address, empty if there is
+// none. See precinct.Rules.
+type Assigner interface {
+	Assign(Address) string
+}
+
+// CheckAddress makes sure an address has what a precinct rule needs
+func CheckAddress(address Address) error {
+	if address.Number < 0 {
+		return Invalidf("house number cannot be negative")
+	}
+	fields := []struct{ name, value string }{
+		{"street", address.Street},
+		{"city", address.City},
+		{"state", address.State},
+		{"zip", address.Zip},
+	}
+	for _, field := range fields {
+		if len(strings.TrimSpace(field.value)) == 0 {
+			return Invalidf("address %s cannot be blank", field.name)
+		}
+	}
+	return nil
+}
+
+// SetAddress stores address on voter along with its precinct, a nil
+// precincts leaves the voter unassigned
+func SetAddress(voter *Voter, address Address, precincts Assigner) {
+	stored := Address{
+		Number: address.Number,
+		Street: strings.TrimSpace(address.Street),
+		Unit:   strings.TrimSpace(address.Unit),
+		City:   strings.TrimSpace(address.City),
+		State:  strings.TrimSpace(address.State),
+		Zip:    strings.TrimSpace(address.Zip),
+	}
+	voter.Address = &stored
+	voter.PrecinctId = ""
+	if precincts != nil {
+		voter.PrecinctId = precincts.Assign(stored)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The kinds of domain errors. The Ports and the repository wrap their
+// errors in one of these so an inbound Port can answer with the right
+// status (404, 409, ...) without parsing messages:
+//
+//	errors.Is(err, storage.ErrNotFound)
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrInvalid       = errors.New("invalid argument")
+	ErrConflict      = errors.New("conflict")
+)
+
+// domainError keeps the message it was created with, the kind is only
+// there for errors.Is
+type domainError struct {
+	kind error
+	msg  string
+}
+
+func (e *domainError) Error() string { return e.msg }
+
+func (e *domainError) Unwrap() error { return e.kind }
+
+func NotFoundf(format string, args ...any) error {
+	return &domainError{ErrNotFound, fmt.Sprintf(format, args...)}
+}
+
+func AlreadyExistsf(format string, args ...any) error {
+	return &domainError{ErrAlreadyExists, fmt.Sprintf(format, args...)}
+}
+
+func Invalidf(format string, args ...any) error {
+	return &domainError{ErrInvalid, fmt.Sprintf(format, args...)}
+}
+
+func Conflictf(format string, args ...any) error {
+	return &domainError{ErrConflict, fmt.Sprintf(format, args...)}
+}
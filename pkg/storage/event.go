@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// This is part of the redis Port
+//
+// An Event records one change to a voter. The repository publishes
+// one for every successful mutation, so consumers can follow the
+// changes instead of polling all voters.
+type Event struct {
+	//ID is assigned by the stream the event is stored in, it is
+	//empty until the event was published
+	ID      string    `json:"-"`
+	Type    string    `json:"type"`
+	VoterId int       `json:"voter_id"`
+	PollId  int       `json:"poll_id,omitempty"`
+	Time    time.Time `json:"time"`
+
+	//Voter is the voter after the change, or before it was deleted
+	Voter *Voter `json:"voter,omitempty"`
+
+	//History is the history entry the event is about, after the
+	//change, or before it was deleted
+	History *VoterHistory `json:"history,omitempty"`
+}
+
+// The types of Event
+const (
+	EventVoterCreated       = "voter.created"
+	EventVoterUpdated       = "voter.updated"
+	EventVoterDeleted       = "voter.deleted"
+	EventVoterStatusChanged = "voter.status_changed"
+	EventHistoryCreated     = "history.created"
+	EventHistoryUpdated     = "history.updated"
+	EventHistoryDeleted     = "history.deleted"
+)
+
+// DiffEvents returns the events that turn before into after. Either
+// may be nil, for a voter that is created or deleted. A voter whose
+// name, email, address or precinct changed gets a voter.updated event,
+// one whose status changed a voter.status_changed event, and every poll
+// in its history that was added, changed or removed gets its own event.
+func DiffEvents(before *Voter, after *Voter, now time.Time) []Event {
+	var events []Event
+	switch {
+	case before == nil && after == nil:
+		return nil
+	case before == nil:
+		events = append(events, Event{Type: EventVoterCreated, VoterId: after.Id, Voter: after})
+	case after == nil:
+		return []Event{{Type: EventVoterDeleted, VoterId: before.Id, Voter: before, Time: now}}
+	default:
+		if before.Name != after.Name || before.Email != after.Email || !sameAddress(before.Address, after.Address) || before.PrecinctId != after.PrecinctId {
+			events = append(events, Event{Type: EventVoterUpdated, VoterId: after.Id, Voter: after})
+		}
+		if StatusOf(before) != StatusOf(after) {
+			events = append(events, Event{Type: EventVoterStatusChanged, VoterId: after.Id, Voter: after})
+		}
+	}
+
+	//history events follow in poll order, map order is random
+	first := len(events)
+	var old HistoryMap
+	if before != nil {
+		old = before.VoterHistory
+	}
+	for pollId, history := range after.VoterHistory {
+		h := history
+		previous, existed := old[pollId]
+		switch {
+		case !existed:
+			events = append(events, Event{Type: EventHistoryCreated, VoterId: after.Id, PollId: pollId, History: &h})
+		case !previous.VoteDate.Equal(history.VoteDate) || previous.VoteId != history.VoteId:
+			events = append(events, Event{Type: EventHistoryUpdated, VoterId: after.Id, PollId: pollId, History: &h})
+		}
+	}
+	for pollId, history := range old {
+		if _, kept := after.VoterHistory[pollId]; !kept {
+			h := history
+			events = append(events, Event{Type: EventHistoryDeleted, VoterId: after.Id, PollId: pollId, History: &h})
+		}
+	}
+
+	history := events[first:]
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].PollId < history[j].PollId
+	})
+
+	for i := range events {
+		events[i].Time = now
+	}
+	return events
+}
+
+func sameAddress(a, b *Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,11 @@
+package storage
+
+// This is part of the redis Port
+//
+// A Precinct is where a voter votes. Precincts are a reference table,
+// each one belongs to a district and voters refer to it by id.
+type Precinct struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	District string `json:"district"`
+}
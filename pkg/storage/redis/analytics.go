@@ -0,0 +1,360 @@
+package rediscache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// The analytics aggregates. They are kept up to date in the MULTI/EXEC
+// of every voter write, so the analytics never have to read voter:*.
+// All scores are unix seconds.
+const (
+	//RedisAnalyticsPrefix is in front of every aggregate
+	RedisAnalyticsPrefix = "analytics:"
+
+	//RedisRegistrationsKey is a sorted set of the voter ids, scored by
+	//when they registered
+	RedisRegistrationsKey = RedisAnalyticsPrefix + "registrations"
+
+	//RedisFirstVotesKey and RedisLastVotesKey are sorted sets of the
+	//voter ids, scored by the date of their first and last vote. A
+	//voter that never voted has a last vote of 0.
+	RedisFirstVotesKey = RedisAnalyticsPrefix + "first-votes"
+	RedisLastVotesKey  = RedisAnalyticsPrefix + "last-votes"
+
+	//RedisPollTotalsKey is a sorted set of the poll ids, scored by
+	//how many voters took part
+	RedisPollTotalsKey = RedisAnalyticsPrefix + "polls"
+)
+
+// pollVotesKey is a sorted set of the voters that took part in a poll,
+// scored by the date of their vote
+func pollVotesKey(pollId int) string {
+	return fmt.Sprintf("%spoll:%d:votes", RedisAnalyticsPrefix, pollId)
+}
+
+// pollVotersKey is a set of the voters that took part in a poll. It
+// holds the same ids as pollVotesKey, as a set ZUNIONSTORE gives every
+// one of them a score of 1, which counts polls.
+func pollVotersKey(pollId int) string {
+	return fmt.Sprintf("%spoll:%d:voters", RedisAnalyticsPrefix, pollId)
+}
+
+func unix(t time.Time) float64 {
+	return float64(t.Unix())
+}
+
+// unknownRegistration is the score of the voters registered before the
+// date was recorded, the unix epoch. They count as registered in every
+// turnout and against the quota of the tenant, but are new voters in no
+// period.
+const unknownRegistration = 0
+
+// indexAnalytics updates the aggregates in the keyspace for a voter
+// going from before to after. Either may be nil, for a voter that is created or deleted.
+func indexAnalytics(ctx context.Context, c redis.Cmdable, ks keyspace, before *storage.Voter, after *storage.Voter) {
+	var id int
+	var old, current storage.HistoryMap
+	if before != nil {
+		id, old = before.Id, before.VoterHistory
+	}
+	if after != nil {
+		id, current = after.Id, after.VoterHistory
+	}
+
+	switch {
+	case after == nil:
+		c.ZRem(ctx, ks.key(RedisRegistrationsKey), id)
+	case after.RegisteredAt != nil:
+		c.ZAdd(ctx, ks.key(RedisRegistrationsKey), redis.Z{Score: unix(*after.RegisteredAt), Member: id})
+	case before == nil:
+		//registered before the date was recorded
+		c.ZAdd(ctx, ks.key(RedisRegistrationsKey), redis.Z{Score: unknownRegistration, Member: id})
+	}
+
+	removed := false
+	for pollId, history := range current {
+		previous, existed := old[pollId]
+		if !existed {
+			c.SAdd(ctx, ks.key(pollVotersKey(pollId)), id)
+			c.ZIncrBy(ctx, ks.key(RedisPollTotalsKey), 1, strconv.Itoa(pollId))
+		}
+		if !existed || !previous.VoteDate.Equal(history.VoteDate) {
+			c.ZAdd(ctx, ks.key(pollVotesKey(pollId)), redis.Z{Score: unix(history.VoteDate), Member: id})
+		}
+	}
+	for pollId := range old {
+		if _, kept := current[pollId]; !kept {
+			c.ZRem(ctx, ks.key(pollVotesKey(pollId)), id)
+			c.SRem(ctx, ks.key(pollVotersKey(pollId)), id)
+			c.ZIncrBy(ctx, ks.key(RedisPollTotalsKey), -1, strconv.Itoa(pollId))
+			removed = true
+		}
+	}
+	if removed {
+		c.ZRemRangeByScore(ctx, ks.key(RedisPollTotalsKey), "-inf", "0")
+	}
+
+	if after == nil {
+		c.ZRem(ctx, ks.key(RedisFirstVotesKey), id)
+		c.ZRem(ctx, ks.key(RedisLastVotesKey), id)
+		return
+	}
+	var first, last time.Time
+	for _, history := range current {
+		if first.IsZero() || history.VoteDate.Before(first) {
+			first = history.VoteDate
+		}
+		if history.VoteDate.After(last) {
+			last = history.VoteDate
+		}
+	}
+	if len(current) == 0 {
+		c.ZRem(ctx, ks.key(RedisFirstVotesKey), id)
+		c.ZAdd(ctx, ks.key(RedisLastVotesKey), redis.Z{Score: 0, Member: id})
+		return
+	}
+	c.ZAdd(ctx, ks.key(RedisFirstVotesKey), redis.Z{Score: unix(first), Member: id})
+	c.ZAdd(ctx, ks.key(RedisLastVotesKey), redis.Z{Score: unix(last), Member: id})
+}
+
+// RebuildAnalytics recomputes the aggregates from every voter, for the
+// voters stored before they were kept. Writes made while it runs may be
+// counted wrong, run it while the api is stopped.
+func (t *VoterCache) RebuildAnalytics(ctx context.Context) (int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pattern := ks.key(RedisAnalyticsPrefix) + "*"
+	var stale []string
+	iter := t.client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		stale = append(stale, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return 0, err
+	}
+
+	voters, err := t.GetAllItems(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	_, err = t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(stale) > 0 {
+			pipe.Del(ctx, stale...)
+		}
+		for i := range voters {
+			indexAnalytics(ctx, pipe, ks, nil, &voters[i])
+		}
+		return nil
+	})
+	logCommand(ctx, "MULTI/EXEC", pattern, start, err)
+	if err != nil {
+		return 0, err
+	}
+	return len(voters), nil
+}
+
+// scoreRange is the ZCOUNT range [from, to), an open end for a zero time
+func scoreRange(from, to time.Time) (string, string) {
+	min, max := "-inf", "+inf"
+	if !from.IsZero() {
+		min = strconv.FormatInt(from.Unix(), 10)
+	}
+	if !to.IsZero() {
+		max = "(" + strconv.FormatInt(to.Unix(), 10)
+	}
+	return min, max
+}
+
+// zcounts counts the members of key scored within each pair of
+// consecutive bounds, in one round trip
+func (t *VoterCache) zcounts(ctx context.Context, key string, bounds []time.Time) ([]int, error) {
+	if len(bounds) < 2 {
+		return nil, nil
+	}
+	cmds := make([]*redis.IntCmd, len(bounds)-1)
+	start := time.Now()
+	_, err := t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range cmds {
+			min, max := scoreRange(bounds[i], bounds[i+1])
+			cmds[i] = pipe.ZCount(ctx, key, min, max)
+		}
+		return nil
+	})
+	logCommand(ctx, "ZCOUNT", key, start, err)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]int, len(cmds))
+	for i, cmd := range cmds {
+		counts[i] = int(cmd.Val())
+	}
+	return counts, nil
+}
+
+// CountRegistrations implements analytics.Repository.
+func (t *VoterCache) CountRegistrations(ctx context.Context, bounds []time.Time) ([]int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.zcounts(ctx, ks.key(RedisRegistrationsKey), bounds)
+}
+
+// CountFirstVotes implements analytics.Repository.
+func (t *VoterCache) CountFirstVotes(ctx context.Context, bounds []time.Time) ([]int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.zcounts(ctx, ks.key(RedisFirstVotesKey), bounds)
+}
+
+// CountVotes implements analytics.Repository.
+func (t *VoterCache) CountVotes(ctx context.Context, pollId int, bounds []time.Time) ([]int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.zcounts(ctx, ks.key(pollVotesKey(pollId)), bounds)
+}
+
+// PollTotals implements analytics.Repository. It returns how many
+// voters took part in each poll.
+func (t *VoterCache) PollTotals(ctx context.Context) (map[int]int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := ks.key(RedisPollTotalsKey)
+	start := time.Now()
+	all, err := t.client.ZRangeWithScores(ctx, key, 0, -1).Result()
+	logCommand(ctx, "ZRANGE", key, start, err)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[int]int, len(all))
+	for _, z := range all {
+		pollId, err := strconv.Atoi(fmt.Sprint(z.Member))
+		if err != nil {
+			return nil, err
+		}
+		totals[pollId] = int(z.Score)
+	}
+	return totals, nil
+}
+
+// VoteSpan implements analytics.Repository. It returns the dates of
+// the first and the last vote in a poll, zero if nobody voted.
+func (t *VoterCache) VoteSpan(ctx context.Context, pollId int) (time.Time, time.Time, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	key := ks.key(pollVotesKey(pollId))
+	var first, last *redis.ZSliceCmd
+	start := time.Now()
+	_, err = t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		first = pipe.ZRangeWithScores(ctx, key, 0, 0)
+		last = pipe.ZRangeWithScores(ctx, key, -1, -1)
+		return nil
+	})
+	logCommand(ctx, "ZRANGE", key, start, err)
+	if err != nil || len(first.Val()) == 0 {
+		return time.Time{}, time.Time{}, err
+	}
+	return time.Unix(int64(first.Val()[0].Score), 0).UTC(), time.Unix(int64(last.Val()[0].Score), 0).UTC(), nil
+}
+
+// Participants implements analytics.Repository. It returns the ids of
+// the voters that took part in at least min of the polls, those in the
+// most polls first, from offset, and how many there are. The polls are
+// added up in a temporary key that is removed in the same MULTI/EXEC.
+func (t *VoterCache) Participants(ctx context.Context, pollIds []int, min int, offset int, limit int) ([]int, int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(pollIds) == 0 {
+		return []int{}, 0, nil
+	}
+	keys := make([]string, len(pollIds))
+	for i, pollId := range pollIds {
+		keys[i] = ks.key(pollVotersKey(pollId))
+	}
+	tmp := fmt.Sprintf("%stmp:participants:%d", ks.key(RedisAnalyticsPrefix), time.Now().UnixNano())
+
+	var members *redis.StringSliceCmd
+	var total *redis.IntCmd
+	start := time.Now()
+	_, err = t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZUnionStore(ctx, tmp, &redis.ZStore{Keys: keys, Aggregate: "SUM"})
+		members = pipe.ZRevRangeByScore(ctx, tmp, &redis.ZRangeBy{
+			Min:    strconv.Itoa(min),
+			Max:    "+inf",
+			Offset: int64(offset),
+			Count:  int64(limit),
+		})
+		total = pipe.ZCount(ctx, tmp, strconv.Itoa(min), "+inf")
+		pipe.Del(ctx, tmp)
+		return nil
+	})
+	logCommand(ctx, "ZUNIONSTORE", tmp, start, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids, err := voterIds(members.Val())
+	return ids, int(total.Val()), err
+}
+
+// Inactive implements analytics.Repository. It returns the ids of the
+// voters whose last vote was before since, or who never voted, those
+// inactive the longest first, from offset, and how many there are.
+func (t *VoterCache) Inactive(ctx context.Context, since time.Time, offset int, limit int) ([]int, int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	key := ks.key(RedisLastVotesKey)
+	max := "(" + strconv.FormatInt(since.Unix(), 10)
+	var members *redis.StringSliceCmd
+	var total *redis.IntCmd
+	start := time.Now()
+	_, err = t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		members = pipe.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min:    "-inf",
+			Max:    max,
+			Offset: int64(offset),
+			Count:  int64(limit),
+		})
+		total = pipe.ZCount(ctx, key, "-inf", max)
+		return nil
+	})
+	logCommand(ctx, "ZRANGEBYSCORE", key, start, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids, err := voterIds(members.Val())
+	return ids, int(total.Val()), err
+}
+
+func voterIds(members []string) ([]int, error) {
+	ids := make([]int, len(members))
+	for i, member := range members {
+		id, err := strconv.Atoi(member)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
@@ -0,0 +1,52 @@
+package rediscache
+
+import (
+	"errors"
+	"testing"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+func TestVotersWithoutARegistrationDate(t *testing.T) {
+	cache, f := newFakeCache(t)
+	cache.RequireTenant()
+	ctx := tenantCtx("a", 2)
+	key := "tenant:a:" + RedisRegistrationsKey
+
+	legacy := testVoter(1)
+	legacy.RegisteredAt = nil
+	if err := cache.AddItem(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddItem(ctx, testVoter(2)); err != nil {
+		t.Fatal(err)
+	}
+	check := func(when string) {
+		t.Helper()
+		if score, ok := f.zsets[key]["1"]; !ok || score != unknownRegistration {
+			t.Errorf("%s the voter without a date has the score %v, %v, want %v", when, score, ok, unknownRegistration)
+		}
+		if score := f.zsets[key]["2"]; score != unix(*testVoter(2).RegisteredAt) {
+			t.Errorf("%s the voter registered on %s has the score %v", when, testVoter(2).RegisteredAt, score)
+		}
+	}
+	check("added,")
+
+	//an update keeps the score, it does not become the time of the update
+	legacy.Name = "Ann Smyth"
+	if err := cache.UpdateItem(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	check("updated,")
+
+	f.zsets[key]["1"] = 1e9
+	if _, err := cache.RebuildAnalytics(ctx); err != nil {
+		t.Fatal(err)
+	}
+	check("rebuilt,")
+
+	//both count against the quota
+	if err := cache.AddItem(ctx, testVoter(3)); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("the voter without a date was not counted in the quota, got %v", err)
+	}
+}
@@ -0,0 +1,441 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"drexel.edu/voter-api/pkg/pii"
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	//RedisDataKeysKey is a hash of the data keys of a keyspace, by id,
+	//each a pii.DataKey wrapped by the master key. RedisActiveDataKey
+	//holds the id of the one that seals, RedisIndexKey the wrapped
+	//key of the blind indexes.
+	RedisDataKeysKey   = "voter-api:data-keys"
+	RedisActiveDataKey = "voter-api:active-data-key"
+	RedisIndexKey      = "voter-api:index-key"
+
+	//keyringTTL is how long a keyring is used before it is read from
+	//redis again, so every instance seals with a new data key at most
+	//this long after rotate-keys made it the active one
+	keyringTTL = 30 * time.Second
+)
+
+// errNoMasterKey is returned when a sealed value is read while
+// encryption is off
+var errNoMasterKey = errors.New("the voter is encrypted, configure the master key to read it")
+
+// encryption holds the master key and the keyrings read so far, by
+// keyspace, with their data keys unwrapped
+type encryption struct {
+	master pii.MasterKey
+
+	mu       sync.Mutex
+	keyrings map[keyspace]*cachedKeyring
+
+	//loading has the keyspaces whose keyrings are being read, the
+	//channel is closed once the read is done
+	loading map[keyspace]chan struct{}
+}
+
+type cachedKeyring struct {
+	keyring  *pii.Keyring
+	keys     map[string][]byte
+	index    []byte
+	loadedAt time.Time
+}
+
+// EncryptWith turns encryption on. From then on the names and emails
+// of voters, in their documents, events and tombstones, and the
+// payloads of the dead letters of the webhooks are sealed with
+// data keys wrapped by master, and searches look emails and names up by
+// their blind indexes. It has to be called before the VoterCache is
+// used. Voters stored before stay readable, rotate-keys seals them.
+func (t *VoterCache) EncryptWith(master pii.MasterKey) {
+	t.encryption = &encryption{
+		master:   master,
+		keyrings: make(map[keyspace]*cachedKeyring),
+		loading:  make(map[keyspace]chan struct{}),
+	}
+}
+
+// document is a voter as it is stored. With encryption on it carries
+// the blind indexes that the search index looks it up by.
+type document struct {
+	storage.Voter
+	EmailIndex string   `json:"email_index,omitempty"`
+	NameIndex  []string `json:"name_index,omitempty"`
+	FuzzyIndex []string `json:"fuzzy_index,omitempty"`
+}
+
+// A sealer seals and opens the personal data of the voters of one
+// keyspace. Its keyring is nil when encryption is off, then voters are
+// stored as they are.
+type sealer struct {
+	t         *VoterCache
+	ks        keyspace
+	kr        *pii.Keyring
+	refreshed bool
+}
+
+// sealer returns the sealer of the keyspace
+func (t *VoterCache) sealer(ctx context.Context, ks keyspace) (*sealer, error) {
+	s := &sealer{t: t, ks: ks}
+	if t.encryption == nil {
+		return s, nil
+	}
+	kr, err := t.loadKeyring(ctx, ks, false)
+	if err != nil {
+		return nil, err
+	}
+	s.kr = kr
+	return s, nil
+}
+
+// fieldContext is what a sealed field is bound to, the same field of
+// the same voter
+func fieldContext(id int, field string) string {
+	return fmt.Sprintf("voter:%d:%s", id, field)
+}
+
+func (s *sealer) seal(id int, field string, value string) (string, error) {
+	return s.sealIn(fieldContext(id, field), value)
+}
+
+// sealIn seals value bound to binding, see pii.Keyring.Seal. It is
+// stored as it is when encryption is off.
+func (s *sealer) sealIn(binding string, value string) (string, error) {
+	if s.kr == nil {
+		return value, nil
+	}
+	return s.kr.Seal(value, binding)
+}
+
+// open opens a sealed field. A field sealed with a data key that is not
+// in the keyring was sealed after it was read, the keyring is read again
+// once.
+func (s *sealer) open(ctx context.Context, id int, field string, value string) (string, error) {
+	return s.openIn(ctx, fieldContext(id, field), value)
+}
+
+// openIn opens a value sealed bound to binding, a value that is not
+// sealed is returned as it is
+func (s *sealer) openIn(ctx context.Context, binding string, value string) (string, error) {
+	if !pii.Sealed(value) {
+		return value, nil
+	}
+	if s.kr == nil {
+		return "", errNoMasterKey
+	}
+	if keyId, _ := pii.KeyId(value); !s.kr.Has(keyId) && !s.refreshed {
+		kr, err := s.t.loadKeyring(ctx, s.ks, true)
+		if err != nil {
+			return "", err
+		}
+		s.kr = kr
+		s.refreshed = true
+	}
+	return s.kr.Open(value, binding)
+}
+
+// sealVoter returns the document to store for voter
+func (s *sealer) sealVoter(voter *storage.Voter) (*document, error) {
+	doc := &document{Voter: *voter}
+	if s.kr == nil {
+		return doc, nil
+	}
+	var err error
+	if doc.Name, err = s.seal(voter.Id, "name", voter.Name); err != nil {
+		return nil, err
+	}
+	if doc.Email, err = s.seal(voter.Id, "email", voter.Email); err != nil {
+		return nil, err
+	}
+	doc.EmailIndex = s.kr.Email(voter.Email)
+	doc.NameIndex, doc.FuzzyIndex = s.kr.Name(voter.Name)
+	return doc, nil
+}
+
+// openVoter opens the name and email of a voter read from redis
+func (s *sealer) openVoter(ctx context.Context, voter *storage.Voter) error {
+	var err error
+	if voter.Name, err = s.open(ctx, voter.Id, "name", voter.Name); err != nil {
+		return err
+	}
+	voter.Email, err = s.open(ctx, voter.Id, "email", voter.Email)
+	return err
+}
+
+// sealEvent returns event with the voter it carries sealed
+func (s *sealer) sealEvent(event storage.Event) (storage.Event, error) {
+	if event.Voter == nil || s.kr == nil {
+		return event, nil
+	}
+	doc, err := s.sealVoter(event.Voter)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	event.Voter = &doc.Voter
+	return event, nil
+}
+
+// openEvent opens the voter an event read from the stream carries
+func (s *sealer) openEvent(ctx context.Context, event *storage.Event) error {
+	if event.Voter == nil {
+		return nil
+	}
+	return s.openVoter(ctx, event.Voter)
+}
+
+func (s *sealer) sealTombstone(tombstone storage.Tombstone) (storage.Tombstone, error) {
+	var err error
+	if tombstone.Name, err = s.seal(tombstone.Id, "name", tombstone.Name); err != nil {
+		return storage.Tombstone{}, err
+	}
+	tombstone.Email, err = s.seal(tombstone.Id, "email", tombstone.Email)
+	return tombstone, err
+}
+
+func (s *sealer) openTombstone(ctx context.Context, tombstone *storage.Tombstone) error {
+	var err error
+	if tombstone.Name, err = s.open(ctx, tombstone.Id, "name", tombstone.Name); err != nil {
+		return err
+	}
+	tombstone.Email, err = s.open(ctx, tombstone.Id, "email", tombstone.Email)
+	return err
+}
+
+// deadLetter is a dead letter as it is stored. With encryption on the
+// payload carries the voter of the event, it is kept sealed in
+// SealedPayload instead of Payload.
+type deadLetter struct {
+	storage.DeadLetter
+	SealedPayload string `json:"sealed_payload,omitempty"`
+}
+
+// deadLetterContext is what the payload of a dead letter is bound to
+func deadLetterContext(id string) string {
+	return "dead-letter:" + id + ":payload"
+}
+
+func (s *sealer) sealDeadLetter(d storage.DeadLetter) (deadLetter, error) {
+	if s.kr == nil {
+		return deadLetter{DeadLetter: d}, nil
+	}
+	sealed, err := s.sealIn(deadLetterContext(d.ID), string(d.Payload))
+	if err != nil {
+		return deadLetter{}, err
+	}
+	d.Payload = nil
+	return deadLetter{DeadLetter: d, SealedPayload: sealed}, nil
+}
+
+func (s *sealer) openDeadLetter(ctx context.Context, d deadLetter) (storage.DeadLetter, error) {
+	if d.SealedPayload == "" {
+		return d.DeadLetter, nil
+	}
+	payload, err := s.openIn(ctx, deadLetterContext(d.ID), d.SealedPayload)
+	if err != nil {
+		return storage.DeadLetter{}, err
+	}
+	d.Payload = json.RawMessage(payload)
+	return d.DeadLetter, nil
+}
+
+//------------------------------------------------------------
+// KEYS
+//------------------------------------------------------------
+
+// loadKeyring returns the keyring of the keyspace, read from redis when
+// it is older than keyringTTL or refresh is set. The data keys and the
+// index key are created the first time a keyspace is used. Only keys
+// that were not unwrapped before go to the master key.
+//
+// The lock is not held during the round trips to redis and the master
+// key. One caller reads the keyring of a keyspace, the others wait for
+// it instead of reading it as well.
+func (t *VoterCache) loadKeyring(ctx context.Context, ks keyspace, refresh bool) (*pii.Keyring, error) {
+	e := t.encryption
+	for {
+		e.mu.Lock()
+		cached := e.keyrings[ks]
+		if cached != nil && !refresh && time.Since(cached.loadedAt) < keyringTTL {
+			e.mu.Unlock()
+			return cached.keyring, nil
+		}
+		wait, busy := e.loading[ks]
+		if !busy {
+			done := make(chan struct{})
+			e.loading[ks] = done
+			e.mu.Unlock()
+
+			next, err := t.readKeyring(ctx, ks, cached)
+
+			e.mu.Lock()
+			if err == nil {
+				e.keyrings[ks] = next
+			}
+			delete(e.loading, ks)
+			close(done)
+			e.mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			return next.keyring, nil
+		}
+		e.mu.Unlock()
+
+		//a refresh reads again after the read in progress, which may
+		//have started before the key it is missing was made
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// readKeyring reads the keys of the keyspace and unwraps those that are
+// not in cached, which may be nil
+func (t *VoterCache) readKeyring(ctx context.Context, ks keyspace, cached *cachedKeyring) (*cachedKeyring, error) {
+	e := t.encryption
+	index, active, dataKeys, err := t.ensureKeys(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &cachedKeyring{keys: make(map[string][]byte, len(dataKeys)), loadedAt: time.Now()}
+	if cached != nil {
+		next.index = cached.index
+	} else if next.index, err = index.Unwrap(ctx, e.master); err != nil {
+		return nil, fmt.Errorf("unwrapping the index key: %w", err)
+	}
+	for id, dataKey := range dataKeys {
+		if cached != nil && cached.keys[id] != nil {
+			next.keys[id] = cached.keys[id]
+			continue
+		}
+		if next.keys[id], err = dataKey.Unwrap(ctx, e.master); err != nil {
+			return nil, fmt.Errorf("unwrapping data key %s: %w", id, err)
+		}
+	}
+	if next.keyring, err = pii.NewKeyring(active, next.keys, next.index); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// ensureKeys reads the keys of the keyspace and creates the index key
+// and the first data key when there are none yet. Two instances that
+// start at once both create them, SETNX keeps the keys of one of them.
+func (t *VoterCache) ensureKeys(ctx context.Context, ks keyspace) (pii.DataKey, string, map[string]pii.DataKey, error) {
+	for attempt := 0; ; attempt++ {
+		index, active, dataKeys, err := t.readKeys(ctx, ks)
+		if err != nil || (index != nil && active != "") {
+			return derefKey(index), active, dataKeys, err
+		}
+		if attempt > 0 {
+			return pii.DataKey{}, "", nil, fmt.Errorf("the keys of %q went missing while they were created", ks)
+		}
+
+		now := time.Now().UTC()
+		if index == nil {
+			dataKey, _, err := pii.NewDataKey(ctx, t.encryption.master, now)
+			if err != nil {
+				return pii.DataKey{}, "", nil, err
+			}
+			data, err := json.Marshal(dataKey)
+			if err != nil {
+				return pii.DataKey{}, "", nil, err
+			}
+			if err := t.client.SetNX(ctx, ks.key(RedisIndexKey), data, 0).Err(); err != nil {
+				return pii.DataKey{}, "", nil, err
+			}
+		}
+		if active == "" {
+			dataKey, _, err := pii.NewDataKey(ctx, t.encryption.master, now)
+			if err != nil {
+				return pii.DataKey{}, "", nil, err
+			}
+			if err := saveDataKey(ctx, t.client, ks, dataKey); err != nil {
+				return pii.DataKey{}, "", nil, err
+			}
+			won, err := t.client.SetNX(ctx, ks.key(RedisActiveDataKey), dataKey.Id, 0).Result()
+			if err != nil {
+				return pii.DataKey{}, "", nil, err
+			}
+			//the other one is active, rotate-keys removes this one
+			if !won {
+				dataKey.RetiredAt = &now
+				if err := saveDataKey(ctx, t.client, ks, dataKey); err != nil {
+					return pii.DataKey{}, "", nil, err
+				}
+			}
+		}
+	}
+}
+
+// readKeys reads the index key, the id of the active data key and the
+// data keys of the keyspace in one round trip. The index key is nil
+// and the id empty when they do not exist yet.
+func (t *VoterCache) readKeys(ctx context.Context, ks keyspace) (*pii.DataKey, string, map[string]pii.DataKey, error) {
+	var indexCmd, activeCmd *redis.StringCmd
+	var dataKeysCmd *redis.MapStringStringCmd
+	start := time.Now()
+	_, err := t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		indexCmd = pipe.Get(ctx, ks.key(RedisIndexKey))
+		activeCmd = pipe.Get(ctx, ks.key(RedisActiveDataKey))
+		dataKeysCmd = pipe.HGetAll(ctx, ks.key(RedisDataKeysKey))
+		return nil
+	})
+	logCommand(ctx, "HGETALL", ks.key(RedisDataKeysKey), start, err)
+	if err != nil && err != redis.Nil {
+		return nil, "", nil, err
+	}
+
+	var index *pii.DataKey
+	if data, err := indexCmd.Bytes(); err == nil {
+		index = &pii.DataKey{}
+		if err := json.Unmarshal(data, index); err != nil {
+			return nil, "", nil, err
+		}
+	} else if err != redis.Nil {
+		return nil, "", nil, err
+	}
+	active, err := activeCmd.Result()
+	if err != nil && err != redis.Nil {
+		return nil, "", nil, err
+	}
+
+	dataKeys := make(map[string]pii.DataKey)
+	for id, data := range dataKeysCmd.Val() {
+		dataKey := pii.DataKey{}
+		if err := json.Unmarshal([]byte(data), &dataKey); err != nil {
+			return nil, "", nil, err
+		}
+		dataKeys[id] = dataKey
+	}
+	return index, active, dataKeys, nil
+}
+
+func saveDataKey(ctx context.Context, c redis.Cmdable, ks keyspace, dataKey pii.DataKey) error {
+	data, err := json.Marshal(dataKey)
+	if err != nil {
+		return err
+	}
+	return c.HSet(ctx, ks.key(RedisDataKeysKey), dataKey.Id, data).Err()
+}
+
+func derefKey(k *pii.DataKey) pii.DataKey {
+	if k == nil {
+		return pii.DataKey{}
+	}
+	return *k
+}
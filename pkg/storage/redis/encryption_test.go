@@ -0,0 +1,372 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"drexel.edu/voter-api/pkg/pii"
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+func testDeadLetter(id string) storage.DeadLetter {
+	return storage.DeadLetter{
+		ID:        id,
+		WebhookID: "w1",
+		EventType: storage.EventVoterCreated,
+		Payload:   json.RawMessage(`{"voter":{"name":"Ann Smith","email":"ann@example.org"}}`),
+		Attempts:  5,
+		FailedAt:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestDeadLettersAreSealed(t *testing.T) {
+	cache, f := newFakeCache(t)
+	ctx := context.Background()
+
+	//one pushed before encryption was turned on
+	if err := cache.PushDeadLetter(ctx, testDeadLetter("d1")); err != nil {
+		t.Fatal(err)
+	}
+	encryptForTest(t, cache)
+	if err := cache.PushDeadLetter(ctx, testDeadLetter("d2")); err != nil {
+		t.Fatal(err)
+	}
+
+	list := f.lists[RedisDeadLetterKey]
+	if len(list) != 2 {
+		t.Fatalf("got %d dead letters", len(list))
+	}
+	if strings.Contains(list[0], "ann@example.org") || !strings.Contains(list[0], `"sealed_payload":"pii:v1:`) {
+		t.Errorf("the payload is stored in clear: %s", list[0])
+	}
+
+	letters, err := cache.ListDeadLetters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range letters {
+		if string(d.Payload) != string(testDeadLetter(d.ID).Payload) {
+			t.Errorf("dead letter %s has the payload %s", d.ID, d.Payload)
+		}
+	}
+
+	//rotate-keys seals the one from before
+	s, err := cache.sealer(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := cache.resealDeadLetters(ctx, "", s); err != nil || n != 2 {
+		t.Fatalf("resealed %d, %v", n, err)
+	}
+	for _, data := range f.lists[RedisDeadLetterKey] {
+		if strings.Contains(data, "ann@example.org") {
+			t.Errorf("a payload is still in clear after the reseal: %s", data)
+		}
+	}
+	if order := f.lists[RedisDeadLetterKey]; !strings.Contains(order[0], `"d2"`) || !strings.Contains(order[1], `"d1"`) {
+		t.Errorf("the reseal changed the order of the list: %v", order)
+	}
+
+	d, err := cache.RemoveDeadLetter(ctx, "d1")
+	if err != nil || d == nil {
+		t.Fatalf("got %v, %v", d, err)
+	}
+	if string(d.Payload) != string(testDeadLetter("d1").Payload) {
+		t.Errorf("the removed dead letter has the payload %s", d.Payload)
+	}
+	if len(f.lists[RedisDeadLetterKey]) != 1 {
+		t.Errorf("%d dead letters are left, want 1", len(f.lists[RedisDeadLetterKey]))
+	}
+}
+
+func TestStoredResponsesAreSealed(t *testing.T) {
+	cache, f := newFakeCache(t)
+	ctx := context.Background()
+	response := []byte(`{"status":200,"body":"{\"name\":\"Ann Smith\"}"}`)
+
+	//one saved before encryption was turned on
+	if err := cache.Save(ctx, "k1", response, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	encryptForTest(t, cache)
+	if err := cache.Save(ctx, "k2", response, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if stored := f.strs[RedisIdempotencyPrefix+"k2"]; strings.Contains(stored, "Ann Smith") {
+		t.Errorf("the response is stored in clear: %s", stored)
+	}
+	for _, key := range []string{"k1", "k2"} {
+		existing, reserved, err := cache.Reserve(ctx, key, []byte(`{"in_progress":true}`), time.Hour)
+		if err != nil || reserved || string(existing) != string(response) {
+			t.Errorf("reserving %s got %s, %v, %v", key, existing, reserved, err)
+		}
+	}
+
+	s, err := cache.sealer(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := cache.resealResponses(ctx, "", s); err != nil || n != 2 {
+		t.Fatalf("resealed %d, %v", n, err)
+	}
+	if stored := f.strs[RedisIdempotencyPrefix+"k1"]; strings.Contains(stored, "Ann Smith") {
+		t.Errorf("the response from before is still in clear after the reseal: %s", stored)
+	}
+	existing, _, err := cache.Reserve(ctx, "k1", nil, time.Hour)
+	if err != nil || string(existing) != string(response) {
+		t.Errorf("after the reseal got %s, %v", existing, err)
+	}
+
+	//a sealed response cannot be moved to another key
+	f.strs[RedisIdempotencyPrefix+"k3"] = f.strs[RedisIdempotencyPrefix+"k1"]
+	if _, _, err := cache.Reserve(ctx, "k3", nil, time.Hour); err == nil {
+		t.Error("a response copied to another key was opened")
+	}
+}
+
+func TestDropClearEvents(t *testing.T) {
+	cache, f := newFakeCache(t)
+	ctx := context.Background()
+
+	//voters 1 to 3 are created before encryption, 4 after, each
+	//without history is one event
+	voter := func(id int) *storage.Voter {
+		v := testVoter(id)
+		v.VoterHistory = nil
+		return v
+	}
+	for id := 1; id <= 3; id++ {
+		if err := cache.AddItem(ctx, voter(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	encryptForTest(t, cache)
+	if err := cache.AddItem(ctx, voter(4)); err != nil {
+		t.Fatal(err)
+	}
+	stream := f.streams[RedisEventStream]
+	if len(stream) != 4 {
+		t.Fatalf("got %d events, want one per voter", len(stream))
+	}
+
+	//one consumer handled the events of voters 1 and 2, another all
+	f.hashes[RedisConsumerOffsetsKey] = map[string]string{"slow": stream[1].ID, "fast": stream[3].ID}
+	dropped, left, err := cache.dropClearEvents(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 2 || left != 1 {
+		t.Errorf("dropped %d and left %d events in clear, want 2 and 1", dropped, left)
+	}
+	if len(f.streams[RedisEventStream]) != 2 {
+		t.Errorf("%d events are left, want the one in clear still needed and the sealed one", len(f.streams[RedisEventStream]))
+	}
+
+	//once the slow consumer caught up
+	f.hashes[RedisConsumerOffsetsKey]["slow"] = stream[3].ID
+	if dropped, left, err = cache.dropClearEvents(ctx, ""); err != nil || dropped != 1 || left != 0 {
+		t.Errorf("dropped %d and left %d, %v", dropped, left, err)
+	}
+	events, err := cache.ReadEvents(ctx, "0", 10, 0)
+	if err != nil || len(events) != 1 || events[0].Voter.Email != testVoter(4).Email {
+		t.Errorf("got the events %+v, %v, want only the sealed one", events, err)
+	}
+}
+
+func TestVotersAreSealed(t *testing.T) {
+	cache, f := newFakeCache(t)
+	ctx := context.Background()
+
+	//one added before encryption was turned on
+	if err := cache.AddItem(ctx, testVoter(1)); err != nil {
+		t.Fatal(err)
+	}
+	encryptForTest(t, cache)
+	if err := cache.AddItem(ctx, testVoter(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := f.strs[RedisKeyPrefix+"2"]
+	if strings.Contains(stored, "Ann Smith") || strings.Contains(stored, "ann@example.org") || !strings.Contains(stored, `"email_index":"`) {
+		t.Errorf("the voter is stored in clear or without its blind indexes: %s", stored)
+	}
+	for _, id := range []int{1, 2} {
+		voter, err := cache.GetItem(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if voter.Name != "Ann Smith" || voter.Email != "ann@example.org" {
+			t.Errorf("voter %d was read as %q %q", id, voter.Name, voter.Email)
+		}
+	}
+
+	//a sealed field only opens as the same field of the same voter
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(stored), &doc); err != nil {
+		t.Fatal(err)
+	}
+	doc["id"] = 3
+	moved, _ := json.Marshal(doc)
+	f.strs[RedisKeyPrefix+"3"] = string(moved)
+	if voter, err := cache.GetItem(ctx, 3); err == nil {
+		t.Errorf("the name of voter 2 was opened as voter 3: %q", voter.Name)
+	}
+	doc["id"] = 2
+	doc["name"], doc["email"] = doc["email"], doc["name"]
+	swapped, _ := json.Marshal(doc)
+	f.strs[RedisKeyPrefix+"2"] = string(swapped)
+	if voter, err := cache.GetItem(ctx, 2); err == nil {
+		t.Errorf("the email was opened as the name: %q", voter.Name)
+	}
+}
+
+func TestKeyRotationSteps(t *testing.T) {
+	cache, f := newFakeCache(t)
+	ctx := context.Background()
+	first := masterKeyLine(t, "first")
+	encryptWithKeyFile(t, cache, first)
+	if err := cache.AddItem(ctx, testVoter(1)); err != nil {
+		t.Fatal(err)
+	}
+	before := document{}
+	if err := json.Unmarshal([]byte(f.strs[RedisKeyPrefix+"1"]), &before); err != nil {
+		t.Fatal(err)
+	}
+	retired, _ := pii.KeyId(before.Name)
+
+	//1. a new data key, the way RotateKeys makes it
+	next, _, err := pii.NewDataKey(ctx, cache.encryption.master, time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveDataKey(ctx, cache.client, "", next); err != nil {
+		t.Fatal(err)
+	}
+	f.strs[RedisActiveDataKey] = next.Id
+
+	//2. a new master key wraps every key again
+	second := masterKeyLine(t, "second")
+	encryptWithKeyFile(t, cache, first, second)
+	if n, err := cache.rewrapKeys(ctx, ""); err != nil || n != 3 {
+		t.Fatalf("rewrapped %d, %v, want the index key and both data keys", n, err)
+	}
+	index, _, dataKeys, err := cache.readKeys(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dataKey := range append([]pii.DataKey{*index}, dataKeys[retired], dataKeys[next.Id]) {
+		if dataKey.MasterKeyId != "second" {
+			t.Errorf("key %s is wrapped by %s", dataKey.Id, dataKey.MasterKeyId)
+		}
+	}
+
+	//3. without the old master key, the voter is sealed again
+	encryptWithKeyFile(t, cache, second)
+	s, err := cache.sealer(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := cache.resealVoters(ctx, "", s); err != nil || n != 1 {
+		t.Fatalf("resealed %d, %v", n, err)
+	}
+	after := document{}
+	if err := json.Unmarshal([]byte(f.strs[RedisKeyPrefix+"1"]), &after); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := pii.KeyId(after.Name); id != next.Id {
+		t.Errorf("the voter is sealed with %s, want %s", id, next.Id)
+	}
+	if after.EmailIndex != before.EmailIndex || strings.Join(after.NameIndex, ",") != strings.Join(before.NameIndex, ",") {
+		t.Error("the blind indexes changed with the data key")
+	}
+	if voter, err := cache.GetItem(ctx, 1); err != nil || voter.Name != "Ann Smith" {
+		t.Errorf("after the reseal got %v, %v", voter, err)
+	}
+
+	//4. the retired data key goes once no instance can still seal with it
+	if removed, kept, err := cache.pruneDataKeys(ctx, "", next.Id); err != nil || len(removed) != 0 || len(kept) != 1 {
+		t.Errorf("a data key not retired was pruned: %v %v %v", removed, kept, err)
+	}
+	retiredAt := time.Now().Add(-2 * keyringTTL)
+	oldKey := dataKeys[retired]
+	oldKey.RetiredAt = &retiredAt
+	if err := saveDataKey(ctx, cache.client, "", oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, kept, err := cache.pruneDataKeys(ctx, "", next.Id); err != nil || strings.Join(kept, ",") != retired {
+		t.Errorf("kept %v, %v, want %s kept for the event sealed with it", kept, err, retired)
+	}
+	//once the stream was trimmed
+	for key := range f.streams {
+		f.streams[key] = nil
+	}
+	removed, kept, err := cache.pruneDataKeys(ctx, "", next.Id)
+	if err != nil || strings.Join(removed, ",") != retired || len(kept) != 0 {
+		t.Errorf("removed %v and kept %v, %v, want %s removed", removed, kept, err, retired)
+	}
+}
+
+// gatedMaster holds every Unwrap until release is closed and counts them
+type gatedMaster struct {
+	pii.MasterKey
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+	unwraps atomic.Int32
+}
+
+func (g *gatedMaster) Unwrap(ctx context.Context, masterKeyId string, wrapped []byte) ([]byte, error) {
+	g.once.Do(func() { close(g.entered) })
+	<-g.release
+	g.unwraps.Add(1)
+	return g.MasterKey.Unwrap(ctx, masterKeyId, wrapped)
+}
+
+func TestKeyringIsReadOnceOutsideTheLock(t *testing.T) {
+	cache, _ := newFakeCache(t)
+	ctx := context.Background()
+	keys := encryptWithKeyFile(t, cache, masterKeyLine(t, "test"))
+	if _, err := cache.sealer(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	//a new instance, the keys are in redis but not unwrapped yet
+	gate := &gatedMaster{MasterKey: keys, entered: make(chan struct{}), release: make(chan struct{})}
+	cache.EncryptWith(gate)
+	keyrings := make([]*pii.Keyring, 10)
+	var wg sync.WaitGroup
+	for i := range keyrings {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			kr, err := cache.loadKeyring(ctx, "", false)
+			if err != nil {
+				t.Error(err)
+			}
+			keyrings[i] = kr
+		}(i)
+	}
+
+	<-gate.entered
+	if !cache.encryption.mu.TryLock() {
+		t.Error("the lock is held while the keys are unwrapped")
+	} else {
+		cache.encryption.mu.Unlock()
+	}
+	close(gate.release)
+	wg.Wait()
+
+	if n := gate.unwraps.Load(); n != 2 {
+		t.Errorf("unwrapped %d keys, want the index key and the data key once", n)
+	}
+	for _, kr := range keyrings {
+		if kr != keyrings[0] {
+			t.Fatal("the callers got different keyrings")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	//RedisEventStream is the stream every change to a voter is
+	//published to, see storage.Event
+	RedisEventStream = "voter-events"
+
+	//maxStreamLength is the length above which the events every
+	//consumer has handled are trimmed, see trimEvents. Events a
+	//consumer still has to handle are kept however long it gets.
+	maxStreamLength = 100000
+
+	//maxTrim bounds how many events one trim drops, so a consumer
+	//catching up after a long outage does not block redis
+	maxTrim = 10000
+
+	//eventField is the field of a stream entry holding the event JSON
+	eventField = "event"
+)
+
+// addEvents queues an XADD per event on c, which may be the client or
+// a pipeline, to the stream of the keyspace. s seals the voters the
+// events carry.
+func addEvents(ctx context.Context, c redis.Cmdable, ks keyspace, s *sealer, events []storage.Event) error {
+	for _, event := range events {
+		event, err := s.sealEvent(event)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		c.XAdd(ctx, &redis.XAddArgs{
+			Stream: ks.key(RedisEventStream),
+			Values: map[string]interface{}{eventField: data},
+		})
+	}
+	return nil
+}
+
+// LastEventID implements events.Repository. It returns the id of the
+// newest event, or "0" when nothing was published yet.
+func (t *VoterCache) LastEventID(ctx context.Context) (string, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return "", err
+	}
+	entries, err := t.client.XRevRangeN(ctx, ks.key(RedisEventStream), "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "0", nil
+	}
+	return entries[0].ID, nil
+}
+
+// ReadEvents implements events.Repository. It returns up to count
+// events published after the event with id after, waiting up to block
+// for the first one. No events within block is not an error.
+func (t *VoterCache) ReadEvents(ctx context.Context, after string, count int, block time.Duration) ([]storage.Event, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+	streams, err := t.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{ks.key(RedisEventStream), after},
+		Count:   int64(count),
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events []storage.Event
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			event, err := eventFromMessage(message)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.openEvent(ctx, &event); err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// trimEvents drops the oldest events once the stream is longer than
+// maxStreamLength, but only those every consumer of the outbox has
+// handled, so a consumer that falls behind never misses an event. A
+// consumer that is no longer run holds the trimming back until its
+// offset is removed from RedisConsumerOffsetsKey.
+func (t *VoterCache) trimEvents(ctx context.Context, ks keyspace) error {
+	stream := ks.key(RedisEventStream)
+	length, err := t.client.XLen(ctx, stream).Result()
+	if err != nil || length <= maxStreamLength {
+		return err
+	}
+	offsets, err := t.client.HGetAll(ctx, ks.key(RedisConsumerOffsetsKey)).Result()
+	if err != nil || len(offsets) == 0 {
+		return err
+	}
+	oldest := ""
+	for _, offset := range offsets {
+		if oldest == "" || streamIDLess(offset, oldest) {
+			oldest = offset
+		}
+	}
+
+	//the last event over the length, or the oldest offset when a
+	//consumer has not got that far yet
+	over, err := t.client.XRangeN(ctx, stream, "-", "+", min(length-maxStreamLength, maxTrim)).Result()
+	if err != nil || len(over) == 0 {
+		return err
+	}
+	minID := over[len(over)-1].ID
+	if streamIDLess(oldest, minID) {
+		minID = oldest
+	}
+
+	//MINID drops the events before minID, approximately trims less
+	//but whole nodes only, which stays cheap
+	start := time.Now()
+	err = t.client.XTrimMinIDApprox(ctx, stream, minID, 0).Err()
+	logCommand(ctx, "XTRIM", stream, start, err)
+	return err
+}
+
+// streamIDLess tells whether the stream entry id a comes before b. Ids
+// are <milliseconds>-<sequence>, a missing sequence counts as 0.
+func streamIDLess(a, b string) bool {
+	aMs, aSeq := splitStreamID(a)
+	bMs, bSeq := splitStreamID(b)
+	if aMs != bMs {
+		return aMs < bMs
+	}
+	return aSeq < bSeq
+}
+
+func splitStreamID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msValue, _ := strconv.ParseUint(ms, 10, 64)
+	seqValue, _ := strconv.ParseUint(seq, 10, 64)
+	return msValue, seqValue
+}
+
+func eventFromMessage(message redis.XMessage) (storage.Event, error) {
+	event := storage.Event{}
+	data, _ := message.Values[eventField].(string)
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return storage.Event{}, err
+	}
+	event.ID = message.ID
+	return event, nil
+}
@@ -0,0 +1,78 @@
+package rediscache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fillStream puts n events with the ids 1-0 to n-0 in the stream
+func fillStream(f *fakeRedis, stream string, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	messages := make([]redis.XMessage, n)
+	for i := range messages {
+		messages[i] = redis.XMessage{ID: fmt.Sprintf("%d-0", i+1), Values: map[string]interface{}{eventField: "{}"}}
+	}
+	f.streams[stream] = messages
+}
+
+func TestTrimKeepsEventsConsumersStillNeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  int
+		offsets []string
+		//first is the id of the oldest event left
+		first string
+	}{
+		{"under the cap", maxStreamLength, []string{"100000-0"}, "1-0"},
+		{"over the cap, consumers caught up", maxStreamLength + 500, []string{"100500-0", "100400-0"}, "500-0"},
+		{"over the cap, a consumer behind", maxStreamLength + 500, []string{"100500-0", "200-0"}, "200-0"},
+		{"a consumer that never handled an event", maxStreamLength + 500, []string{"100500-0", "0"}, "1-0"},
+		{"far over the cap", maxStreamLength + 3*maxTrim, []string{fmt.Sprint(maxStreamLength+3*maxTrim) + "-0"}, fmt.Sprint(maxTrim) + "-0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache, f := newFakeCache(t)
+			ctx := tenantCtx("a", 0)
+			stream := "tenant:a:" + RedisEventStream
+			fillStream(f, stream, test.length)
+
+			//the other consumers saved their offsets before
+			offsets := make(map[string]string)
+			for i, offset := range test.offsets[1:] {
+				offsets[fmt.Sprint("consumer", i+1)] = offset
+			}
+			f.hashes["tenant:a:"+RedisConsumerOffsetsKey] = offsets
+			if err := cache.SaveConsumerOffset(ctx, "consumer0", test.offsets[0]); err != nil {
+				t.Fatal(err)
+			}
+			if got := f.streams[stream][0].ID; got != test.first {
+				t.Errorf("the oldest event left is %s, want %s", got, test.first)
+			}
+			if len(f.streams[stream]) < maxStreamLength {
+				t.Errorf("the stream was trimmed to %d events", len(f.streams[stream]))
+			}
+		})
+	}
+}
+
+func TestStreamIDLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"1-0", "2-0", true},
+		{"2-0", "1-0", false},
+		{"5-1", "5-2", true},
+		{"10-0", "9-5", false},
+		{"0", "1-0", true},
+		{"7", "7-0", false},
+	}
+	for _, test := range tests {
+		if got := streamIDLess(test.a, test.b); got != test.less {
+			t.Errorf("streamIDLess(%q, %q) = %v", test.a, test.b, got)
+		}
+	}
+}
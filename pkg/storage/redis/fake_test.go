@@ -0,0 +1,413 @@
+package rediscache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis is an in-memory redis for the tests, installed as a hook
+// that answers every command itself, so nothing is ever sent to a
+// server. It implements the commands the repository uses well enough to
+// tell which keys a call reads and writes. WATCH never fails.
+type fakeRedis struct {
+	mu      sync.Mutex
+	strs    map[string]string
+	hashes  map[string]map[string]string
+	zsets   map[string]map[string]float64
+	lists   map[string][]string
+	streams map[string][]redis.XMessage
+	indexes map[string]string
+	seq     int
+
+	//touched has every key a command named since takeTouched
+	touched []string
+}
+
+// newFakeCache returns a VoterCache backed by a fakeRedis
+func newFakeCache(t *testing.T) (*VoterCache, *fakeRedis) {
+	t.Helper()
+	f := &fakeRedis{
+		strs:    make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		zsets:   make(map[string]map[string]float64),
+		lists:   make(map[string][]string),
+		streams: make(map[string][]redis.XMessage),
+		indexes: make(map[string]string),
+	}
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	client.AddHook(f)
+	t.Cleanup(func() { client.Close() })
+	return &VoterCache{cache: cache{client: client}}, f
+}
+
+func (f *fakeRedis) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (f *fakeRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		f.process(cmd)
+		return cmd.Err()
+	}
+}
+
+func (f *fakeRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			f.process(cmd)
+		}
+		return nil
+	}
+}
+
+// takeTouched returns the keys named since the last call
+func (f *fakeRedis) takeTouched() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	touched := f.touched
+	f.touched = nil
+	return touched
+}
+
+func arg(a interface{}) string {
+	switch v := a.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(a)
+}
+
+// keysOf returns the arguments of a command that are keys
+func keysOf(args []string) []string {
+	switch args[0] {
+	case "multi", "exec", "unwatch", "ping", "module", "hello", "evalsha", "eval":
+		return nil
+	case "del", "watch":
+		return args[1:]
+	case "json.mget":
+		return args[1 : len(args)-1]
+	case "scan":
+		for i := range args {
+			if strings.EqualFold(args[i], "match") {
+				return []string{args[i+1]}
+			}
+		}
+		return nil
+	case "xread":
+		for i := range args {
+			if strings.EqualFold(args[i], "streams") {
+				rest := args[i+1:]
+				return rest[:len(rest)/2]
+			}
+		}
+		return nil
+	case "zunionstore":
+		n, _ := strconv.Atoi(args[2])
+		return append([]string{args[1]}, args[3:3+n]...)
+	case "ft.create":
+		for i := range args {
+			if strings.EqualFold(args[i], "prefix") {
+				return []string{args[1], args[i+2]}
+			}
+		}
+	}
+	return args[1:2]
+}
+
+func (f *fakeRedis) process(cmd redis.Cmder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	args := make([]string, len(cmd.Args()))
+	for i, a := range cmd.Args() {
+		args[i] = arg(a)
+	}
+	args[0] = strings.ToLower(args[0])
+	f.touched = append(f.touched, keysOf(args)...)
+
+	switch args[0] {
+	case "get":
+		if v, ok := f.strs[args[1]]; ok {
+			cmd.(*redis.StringCmd).SetVal(v)
+		} else {
+			cmd.SetErr(redis.Nil)
+		}
+	case "set", "json.set":
+		value := args[2]
+		if args[0] == "json.set" {
+			value = args[3]
+		}
+		//SET key value EX seconds NX is SetNX with a TTL
+		if b, ok := cmd.(*redis.BoolCmd); ok {
+			_, exists := f.strs[args[1]]
+			b.SetVal(!exists)
+			if exists {
+				break
+			}
+		}
+		f.strs[args[1]] = value
+	case "setnx":
+		_, exists := f.strs[args[1]]
+		if !exists {
+			f.strs[args[1]] = args[2]
+		}
+		cmd.(*redis.BoolCmd).SetVal(!exists)
+	case "del":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := f.strs[key]; ok {
+				n++
+			}
+			delete(f.strs, key)
+			delete(f.zsets, key)
+			delete(f.hashes, key)
+			delete(f.lists, key)
+		}
+		cmd.(*redis.IntCmd).SetVal(int64(n))
+	case "json.get":
+		cmd.(*redis.JSONCmd).SetVal(f.strs[args[1]])
+	case "json.mget":
+		var replies []interface{}
+		for _, key := range args[1 : len(args)-1] {
+			if v, ok := f.strs[key]; ok {
+				replies = append(replies, v)
+			} else {
+				replies = append(replies, nil)
+			}
+		}
+		cmd.(*redis.JSONSliceCmd).SetVal(replies)
+	case "keys":
+		cmd.(*redis.StringSliceCmd).SetVal(f.matching(args[1]))
+	case "scan":
+		cmd.(*redis.ScanCmd).SetVal(f.matching(keysOf(args)[0]), 0)
+	case "hset":
+		h := f.hashes[args[1]]
+		if h == nil {
+			h = make(map[string]string)
+			f.hashes[args[1]] = h
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			h[args[i]] = args[i+1]
+		}
+	case "hsetnx":
+		h := f.hashes[args[1]]
+		if h == nil {
+			h = make(map[string]string)
+			f.hashes[args[1]] = h
+		}
+		_, exists := h[args[2]]
+		if !exists {
+			h[args[2]] = args[3]
+		}
+		cmd.(*redis.BoolCmd).SetVal(!exists)
+	case "hget":
+		if v, ok := f.hashes[args[1]][args[2]]; ok {
+			cmd.(*redis.StringCmd).SetVal(v)
+		} else {
+			cmd.SetErr(redis.Nil)
+		}
+	case "hexists":
+		_, ok := f.hashes[args[1]][args[2]]
+		cmd.(*redis.BoolCmd).SetVal(ok)
+	case "hgetall":
+		all := make(map[string]string)
+		for k, v := range f.hashes[args[1]] {
+			all[k] = v
+		}
+		cmd.(*redis.MapStringStringCmd).SetVal(all)
+	case "hdel":
+		n := 0
+		for _, field := range args[2:] {
+			if _, ok := f.hashes[args[1]][field]; ok {
+				n++
+			}
+			delete(f.hashes[args[1]], field)
+		}
+		cmd.(*redis.IntCmd).SetVal(int64(n))
+	case "zadd":
+		z := f.zsets[args[1]]
+		if z == nil {
+			z = make(map[string]float64)
+			f.zsets[args[1]] = z
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			score, _ := strconv.ParseFloat(args[i], 64)
+			z[args[i+1]] = score
+		}
+	case "zrem":
+		for _, member := range args[2:] {
+			delete(f.zsets[args[1]], member)
+		}
+	case "zcard":
+		cmd.(*redis.IntCmd).SetVal(int64(len(f.zsets[args[1]])))
+	case "zrangebyscore":
+		cmd.(*redis.StringSliceCmd).SetVal(f.rangeByScore(args))
+	case "xadd":
+		for i := range args {
+			if args[i] == "*" {
+				f.seq++
+				values := make(map[string]interface{})
+				for j := i + 1; j+1 < len(args); j += 2 {
+					values[args[j]] = args[j+1]
+				}
+				f.streams[args[1]] = append(f.streams[args[1]], redis.XMessage{ID: fmt.Sprintf("%d-0", f.seq), Values: values})
+				break
+			}
+		}
+	case "xrange", "xrevrange":
+		messages := f.streams[args[1]]
+		if len(args) == 6 && strings.EqualFold(args[4], "count") {
+			count, _ := strconv.Atoi(args[5])
+			messages = messages[:min(count, len(messages))]
+		}
+		cmd.(*redis.XMessageSliceCmd).SetVal(messages)
+	case "xdel":
+		deleted := make(map[string]bool)
+		for _, id := range args[2:] {
+			deleted[id] = true
+		}
+		var kept []redis.XMessage
+		for _, message := range f.streams[args[1]] {
+			if !deleted[message.ID] {
+				kept = append(kept, message)
+			}
+		}
+		cmd.(*redis.IntCmd).SetVal(int64(len(f.streams[args[1]]) - len(kept)))
+		f.streams[args[1]] = kept
+	case "xlen":
+		cmd.(*redis.IntCmd).SetVal(int64(len(f.streams[args[1]])))
+	case "xtrim":
+		//only XTRIM key MINID [=|~] id
+		minID := args[len(args)-1]
+		var kept []redis.XMessage
+		for _, message := range f.streams[args[1]] {
+			if !streamIDLess(message.ID, minID) {
+				kept = append(kept, message)
+			}
+		}
+		f.streams[args[1]] = kept
+	case "xread":
+		var streams []redis.XStream
+		for _, key := range keysOf(args) {
+			if len(f.streams[key]) > 0 {
+				streams = append(streams, redis.XStream{Stream: key, Messages: f.streams[key]})
+			}
+		}
+		if len(streams) == 0 {
+			cmd.SetErr(redis.Nil)
+		}
+		cmd.(*redis.XStreamSliceCmd).SetVal(streams)
+	case "lpush":
+		f.lists[args[1]] = append(args[2:], f.lists[args[1]]...)
+	case "rpush":
+		f.lists[args[1]] = append(f.lists[args[1]], args[2:]...)
+	case "ltrim":
+		start, _ := strconv.Atoi(args[2])
+		stop, _ := strconv.Atoi(args[3])
+		if list := f.lists[args[1]]; stop >= 0 && stop+1 < len(list) && start == 0 {
+			f.lists[args[1]] = list[:stop+1]
+		}
+	case "lrem":
+		list, n := f.lists[args[1]], 0
+		for i := range list {
+			if list[i] == args[3] {
+				f.lists[args[1]] = append(append([]string{}, list[:i]...), list[i+1:]...)
+				n = 1
+				break
+			}
+		}
+		cmd.(*redis.IntCmd).SetVal(int64(n))
+	case "lrange":
+		cmd.(*redis.StringSliceCmd).SetVal(f.lists[args[1]])
+	case "ft.create":
+		f.indexes[args[1]] = keysOf(args)[1]
+	case "ft.info":
+		if _, ok := f.indexes[args[1]]; !ok {
+			cmd.SetErr(fmt.Errorf("Unknown index name"))
+		}
+	case "ft.dropindex":
+		delete(f.indexes, args[1])
+	case "ft.search":
+		//every document under the prefix of the index matches
+		prefix, ok := f.indexes[args[1]]
+		if !ok {
+			cmd.SetErr(fmt.Errorf("Unknown index name"))
+			return
+		}
+		keys := f.matching(prefix + "*")
+		reply := []interface{}{int64(len(keys))}
+		for _, key := range keys {
+			reply = append(reply, key)
+		}
+		cmd.(*redis.Cmd).SetVal(reply)
+	case "ft.aggregate":
+		cmd.(*redis.Cmd).SetVal([]interface{}{int64(0)})
+	}
+}
+
+// matching returns the string keys matching a pattern ending in *
+func (f *fakeRedis) matching(pattern string) []string {
+	prefix := strings.TrimSuffix(pattern, "*")
+	var keys []string
+	for key := range f.strs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range f.zsets {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rangeByScore answers ZRANGEBYSCORE key min max [LIMIT offset count]
+func (f *fakeRedis) rangeByScore(args []string) []string {
+	bound := func(s string) (float64, bool) {
+		exclusive := strings.HasPrefix(s, "(")
+		s = strings.TrimPrefix(s, "(")
+		switch s {
+		case "-inf":
+			return -1e308, exclusive
+		case "+inf":
+			return 1e308, exclusive
+		}
+		v, _ := strconv.ParseFloat(s, 64)
+		return v, exclusive
+	}
+	min, minExclusive := bound(args[2])
+	max, maxExclusive := bound(args[3])
+
+	type member struct {
+		name  string
+		score float64
+	}
+	var members []member
+	for name, score := range f.zsets[args[1]] {
+		if score < min || (minExclusive && score == min) || score > max || (maxExclusive && score == max) {
+			continue
+		}
+		members = append(members, member{name, score})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].score < members[j].score })
+
+	offset, count := 0, len(members)
+	if len(args) == 7 && strings.EqualFold(args[4], "limit") {
+		offset, _ = strconv.Atoi(args[5])
+		count, _ = strconv.Atoi(args[6])
+	}
+	names := []string{}
+	for i := offset; i < len(members) && len(names) < count; i++ {
+		names = append(names, members[i].name)
+	}
+	return names
+}
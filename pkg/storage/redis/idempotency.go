@@ -0,0 +1,84 @@
+package rediscache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisIdempotencyPrefix is the prefix of the stored responses of
+// requests sent with an Idempotency-Key. With encryption on they are
+// sealed, a response can carry the name and email of a voter.
+const RedisIdempotencyPrefix = "idempotency:"
+
+// responseContext is what the stored response under key is bound to
+func responseContext(key string) string {
+	return "response:" + key
+}
+
+// Reserve implements idempotency.Store.
+func (t *VoterCache) Reserve(ctx context.Context, key string, value []byte, ttl time.Duration) ([]byte, bool, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return nil, false, err
+	}
+	key = ks.key(RedisIdempotencyPrefix + key)
+	sealed, err := s.sealIn(responseContext(key), string(value))
+	if err != nil {
+		return nil, false, err
+	}
+	reserved, err := t.client.SetNX(ctx, key, sealed, ttl).Result()
+	if err != nil || reserved {
+		return nil, reserved, err
+	}
+
+	existing, err := t.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		//it expired in between, try once more
+		reserved, err = t.client.SetNX(ctx, key, sealed, ttl).Result()
+		if err == nil && !reserved {
+			err = fmt.Errorf("idempotency key %s changed while reserving it", key)
+		}
+		return nil, reserved, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if existing, err = s.openIn(ctx, responseContext(key), existing); err != nil {
+		return nil, false, err
+	}
+	return []byte(existing), false, nil
+}
+
+// Save implements idempotency.Store.
+func (t *VoterCache) Save(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return err
+	}
+	key = ks.key(RedisIdempotencyPrefix + key)
+	sealed, err := s.sealIn(responseContext(key), string(value))
+	if err != nil {
+		return err
+	}
+	return t.client.Set(ctx, key, sealed, ttl).Err()
+}
+
+// Release implements idempotency.Store.
+func (t *VoterCache) Release(ctx context.Context, key string) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	return t.client.Del(ctx, ks.key(RedisIdempotencyPrefix+key)).Err()
+}
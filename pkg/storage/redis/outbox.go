@@ -0,0 +1,109 @@
+package rediscache
+
+import (
+	"context"
+	"time"
+
+	"drexel.edu/voter-api/pkg/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConsumerOffsetsKey is a hash of the id of the last event every
+// consumer of the event stream has handled, by consumer name.
+const RedisConsumerOffsetsKey = "voter-events:offsets"
+
+// ConsumerOffset implements outbox.Repository. It returns "" for a
+// consumer that never saved an offset.
+func (t *VoterCache) ConsumerOffset(ctx context.Context, consumer string) (string, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return "", err
+	}
+	key := ks.key(RedisConsumerOffsetsKey)
+	start := time.Now()
+	offset, err := t.client.HGet(ctx, key, consumer).Result()
+	logCommand(ctx, "HGET", key, start, err)
+	if err == redis.Nil {
+		return "", nil
+	}
+	return offset, err
+}
+
+// SaveConsumerOffset implements outbox.Repository. The events that
+// every consumer has handled by now may be trimmed from the stream.
+func (t *VoterCache) SaveConsumerOffset(ctx context.Context, consumer string, offset string) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	key := ks.key(RedisConsumerOffsetsKey)
+	start := time.Now()
+	err = t.client.HSet(ctx, key, consumer, offset).Err()
+	logCommand(ctx, "HSET", key, start, err)
+	if err != nil {
+		return err
+	}
+
+	//the offset is saved, a stream that was not trimmed this time is
+	//trimmed with the next offset
+	if err := t.trimEvents(ctx, ks); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "trimming the event stream failed", "error", err)
+	}
+	return nil
+}
+
+// RedisConsumerLockPrefix is the prefix of the lock of every consumer,
+// only the relay holding it forwards events to the consumer.
+const RedisConsumerLockPrefix = "voter-events:locks:"
+
+// lockConsumerScript takes a lock that is free or renews one the owner
+// already holds, in one step.
+//
+// KEYS[1] lock, ARGV[1] owner, ARGV[2] ttl in milliseconds
+// returns 1 when the owner holds the lock, 0 otherwise
+var lockConsumerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// unlockConsumerScript frees a lock unless another owner took it over.
+//
+// KEYS[1] lock, ARGV[1] owner
+var unlockConsumerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// LockConsumer implements outbox.Repository.
+func (t *VoterCache) LockConsumer(ctx context.Context, consumer string, owner string, ttl time.Duration) (bool, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return false, err
+	}
+	key := ks.key(RedisConsumerLockPrefix + consumer)
+	start := time.Now()
+	held, err := lockConsumerScript.Run(ctx, t.client, []string{key}, owner, ttl.Milliseconds()).Int()
+	logCommand(ctx, "EVALSHA", key, start, err)
+	return held == 1, err
+}
+
+// UnlockConsumer implements outbox.Repository.
+func (t *VoterCache) UnlockConsumer(ctx context.Context, consumer string, owner string) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	key := ks.key(RedisConsumerLockPrefix + consumer)
+	start := time.Now()
+	err = unlockConsumerScript.Run(ctx, t.client, []string{key}, owner).Err()
+	logCommand(ctx, "EVALSHA", key, start, err)
+	return err
+}
@@ -0,0 +1,243 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	//RedisPrecinctsKey is a hash of the precincts by id
+	RedisPrecinctsKey = "precincts"
+
+	//RedisPrecinctVotersPrefix is followed by a precinct id. Each key
+	//is a sorted set of the ids of the voters assigned to the
+	//precinct, scored by the id so it pages in id order. It is kept
+	//up to date in the MULTI/EXEC of every voter write.
+	RedisPrecinctVotersPrefix = "precinct-voters:"
+)
+
+func precinctVotersKey(precinctId string) string {
+	return RedisPrecinctVotersPrefix + precinctId
+}
+
+// indexPrecinct moves a voter between the precinct indexes when its
+// precinct changed. Either voter may be nil, for a voter that is
+// created or deleted.
+func indexPrecinct(ctx context.Context, c redis.Cmdable, ks keyspace, before *storage.Voter, after *storage.Voter) {
+	var from, to string
+	var id int
+	if before != nil {
+		from, id = before.PrecinctId, before.Id
+	}
+	if after != nil {
+		to, id = after.PrecinctId, after.Id
+	}
+	if from == to {
+		return
+	}
+	if from != "" {
+		c.ZRem(ctx, ks.key(precinctVotersKey(from)), id)
+	}
+	if to != "" {
+		c.ZAdd(ctx, ks.key(precinctVotersKey(to)), redis.Z{Score: float64(id), Member: id})
+	}
+}
+
+// AddPrecinct implements precinct.Repository.
+func (t *VoterCache) AddPrecinct(ctx context.Context, p storage.Precinct) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	hash := ks.key(RedisPrecinctsKey)
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	added, err := t.client.HSetNX(ctx, hash, p.Id, data).Result()
+	logCommand(ctx, "HSETNX", hash, start, err)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return storage.AlreadyExistsf("precinct %s already exists", p.Id)
+	}
+	return nil
+}
+
+// UpdatePrecinct implements precinct.Repository.
+func (t *VoterCache) UpdatePrecinct(ctx context.Context, p storage.Precinct) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	hash := ks.key(RedisPrecinctsKey)
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	err = t.client.Watch(ctx, func(rtx *redis.Tx) error {
+		exists, err := rtx.HExists(ctx, hash, p.Id).Result()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return storage.NotFoundf("precinct %s does not exist", p.Id)
+		}
+		start := time.Now()
+		_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, hash, p.Id, data)
+			return nil
+		})
+		logCommand(ctx, "HSET", hash, start, err)
+		return err
+	}, hash)
+	if errors.Is(err, redis.TxFailedErr) {
+		return ErrTxConflict
+	}
+	return err
+}
+
+// GetPrecinct implements precinct.Repository.
+func (t *VoterCache) GetPrecinct(ctx context.Context, id string) (*storage.Precinct, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash := ks.key(RedisPrecinctsKey)
+	start := time.Now()
+	data, err := t.client.HGet(ctx, hash, id).Bytes()
+	logCommand(ctx, "HGET", hash, start, err)
+	if err == redis.Nil {
+		return nil, storage.NotFoundf("precinct %s does not exist", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &storage.Precinct{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ListPrecincts implements precinct.Repository, ordered by id.
+func (t *VoterCache) ListPrecincts(ctx context.Context) ([]storage.Precinct, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash := ks.key(RedisPrecinctsKey)
+	start := time.Now()
+	all, err := t.client.HGetAll(ctx, hash).Result()
+	logCommand(ctx, "HGETALL", hash, start, err)
+	if err != nil {
+		return nil, err
+	}
+	precincts := make([]storage.Precinct, 0, len(all))
+	for _, data := range all {
+		p := storage.Precinct{}
+		if err := json.Unmarshal([]byte(data), &p); err != nil {
+			return nil, err
+		}
+		precincts = append(precincts, p)
+	}
+	sort.Slice(precincts, func(i, j int) bool { return precincts[i].Id < precincts[j].Id })
+	return precincts, nil
+}
+
+// DeletePrecinct implements precinct.Repository. A precinct voters are
+// assigned to is not removed, the index is WATCHed so a voter assigned
+// in the meantime is noticed.
+func (t *VoterCache) DeletePrecinct(ctx context.Context, id string) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	hash := ks.key(RedisPrecinctsKey)
+	key := ks.key(precinctVotersKey(id))
+	err = t.client.Watch(ctx, func(rtx *redis.Tx) error {
+		exists, err := rtx.HExists(ctx, hash, id).Result()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return storage.NotFoundf("precinct %s does not exist", id)
+		}
+		assigned, err := rtx.ZCard(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if assigned > 0 {
+			return storage.Conflictf("precinct %s still has %d voters", id, assigned)
+		}
+		start := time.Now()
+		_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, hash, id)
+			return nil
+		})
+		logCommand(ctx, "HDEL", hash, start, err)
+		return err
+	}, hash, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return ErrTxConflict
+	}
+	return err
+}
+
+// PrecinctVoters implements precinct.Repository. It returns up to
+// limit voters of the precinct with an id above after, in id order,
+// and how many voters the precinct has in all.
+func (t *VoterCache) PrecinctVoters(ctx context.Context, precinctId string, after int, limit int) ([]storage.Voter, int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	key := ks.key(precinctVotersKey(precinctId))
+	start := time.Now()
+	var members *redis.StringSliceCmd
+	var total *redis.IntCmd
+	_, err = t.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		members = pipe.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min:   "(" + strconv.Itoa(after),
+			Max:   "+inf",
+			Count: int64(limit),
+		})
+		total = pipe.ZCard(ctx, key)
+		return nil
+	})
+	logCommand(ctx, "ZRANGEBYSCORE", key, start, err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keys := make([]string, 0, len(members.Val()))
+	for _, member := range members.Val() {
+		id, err := strconv.Atoi(member)
+		if err != nil {
+			return nil, 0, err
+		}
+		keys = append(keys, ks.voter(id))
+	}
+	items, err := t.getItemsFromRedis(ctx, ks, keys)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	//a voter deleted between the two reads is left out
+	voters := make([]storage.Voter, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			voters = append(voters, *item)
+		}
+	}
+	return voters, int(total.Val()), nil
+}
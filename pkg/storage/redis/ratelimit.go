@@ -0,0 +1,62 @@
+package rediscache
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRateLimitPrefix is the prefix of the token buckets, it does not
+// match RedisKeyPrefix so buckets never show up as voters.
+const RedisRateLimitPrefix = "ratelimit:"
+
+// tokenBucketScript refills and takes from a bucket in one step so
+// concurrent requests on different replicas cannot both take the last
+// token. The clock of redis is used so replicas with skewed clocks
+// still agree. The bucket expires once it would be full again.
+//
+// KEYS[1] bucket, ARGV[1] tokens per second, ARGV[2] burst
+// returns {allowed (0 or 1), tokens left as a string}
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// TakeToken implements ratelimit.Store. The buckets of every tenant are
+// its own, like its limits.
+func (t *VoterCache) TakeToken(ctx context.Context, key string, ratePerSecond float64, burst int) (bool, float64, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+	res, err := tokenBucketScript.Run(ctx, t.client, []string{ks.key(RedisRateLimitPrefix + key)}, ratePerSecond, burst).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed == 1, tokens, nil
+}
@@ -7,10 +7,15 @@ package rediscache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"drexel.edu/voter-api/pkg/logging"
 	"drexel.edu/voter-api/pkg/storage"
 	"github.com/redis/go-redis/v9"
 )
@@ -19,11 +24,17 @@ const (
 	RedisNilError        = "redis: nil"
 	RedisDefaultLocation = "0.0.0.0:6379"
 	RedisKeyPrefix       = "voter:"
+
+	//SchemaVersion is the version of the storage format this build
+	//reads and writes. It is recorded under RedisSchemaVersionKey the
+	//first time the app connects and checked by the readiness probe.
+	//Bump it whenever storage.Voter changes in an incompatible way.
+	SchemaVersion         = 1
+	RedisSchemaVersionKey = "voter-api:schema-version"
 )
 
 type cache struct {
-	client  *redis.Client
-	context context.Context
+	client *redis.Client
 }
 
 // Voter is the struct that represents the main object of our
@@ -33,29 +44,25 @@ type VoterCache struct {
 
 	//Redis cache connections
 	cache
-}
 
-// DeleteAllVoterHistory implements delete.Repository.
-func (*VoterCache) DeleteAllVoterHistory(int) error {
-	panic("unimplemented")
-}
-
-// DeleteAllVoters implements delete.Repository.
-func (*VoterCache) DeleteAllVoters() error {
-	panic("unimplemented")
-}
+	//tenancy makes every call require a tenant, see RequireTenant
+	tenancy bool
 
-// DeleteVoterHistory implements delete.Repository.
-func (*VoterCache) DeleteVoterHistory(int, int) error {
-	panic("unimplemented")
+	//encryption seals the personal data of voters, nil when it is
+	//off, see EncryptWith
+	encryption *encryption
 }
 
-// GetAllItem implements read.Repository.
-
 // New is a constructor function that returns a pointer to a new
 // VoterCache struct.  If this is called it uses the default Redis URL
 // with the companion constructor NewWithCacheInstance.
 func New() (*VoterCache, error) {
+	return NewWithCacheInstance(DefaultLocation())
+}
+
+// DefaultLocation returns the location of the redis cache to use when
+// none is given explicitly.
+func DefaultLocation() string {
 	//We will use an override if the REDIS_URL is provided as an environment
 	//variable, which is the preferred way to wire up a docker container
 	redisUrl := os.Getenv("REDIS_URL")
@@ -63,7 +70,34 @@ func New() (*VoterCache, error) {
 	if redisUrl == "" {
 		redisUrl = RedisDefaultLocation
 	}
-	return NewWithCacheInstance(redisUrl)
+	return redisUrl
+}
+
+// NewWithRetry keeps calling NewWithCacheInstance with an exponential
+// backoff until redis answers or ctx is done. This lets the app start
+// before redis does, for example when both come up in docker compose.
+func NewWithRetry(ctx context.Context, location string) (*VoterCache, error) {
+	const (
+		initialBackoff = 250 * time.Millisecond
+		maxBackoff     = 8 * time.Second
+	)
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		voterCache, err := NewWithCacheInstance(location)
+		if err == nil {
+			return voterCache, nil
+		}
+
+		slog.Warn("redis is not available, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("giving up connecting to redis at %s after %d attempts: %w", location, attempt, err)
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
 }
 
 // NewWithCacheInstance is a constructor function that returns a pointer to a new
@@ -77,27 +111,75 @@ func NewWithCacheInstance(location string) (*VoterCache, error) {
 		Addr: location,
 	})
 
-	//We use this context to coordinate betwen our go code and
-	//the redis operaitons
-	ctx := context.TODO()
-
 	//This is the reccomended way to ensure that our redis connection
-	//is working
-	err := client.Ping(ctx).Err()
+	//is working. Every other redis call uses the context of the request
+	//that triggered it, so deadlines and cancellation reach redis.
+	err := client.Ping(context.Background()).Err()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	//Record the schema version on a fresh database. An existing value
+	//is left alone so a mismatch shows up in the readiness probe.
+	err = client.SetNX(context.Background(), RedisSchemaVersionKey, SchemaVersion, 0).Err()
 	if err != nil {
-		log.Println("Error connecting to redis" + err.Error())
+		client.Close()
 		return nil, err
 	}
 
 	//Return a pointer to a new VoterCache struct
 	return &VoterCache{
 		cache: cache{
-			client:  client,
-			context: ctx,
+			client: client,
 		},
 	}, nil
 }
 
+// Close releases the connections to redis. It must only be called once
+// nothing uses the VoterCache anymore.
+func (t *VoterCache) Close() error {
+	return t.client.Close()
+}
+
+// AddHook installs a go-redis hook on the client, for example to
+// record command latency. Hooks see every command the repository sends.
+func (t *VoterCache) AddHook(hook redis.Hook) {
+	t.client.AddHook(hook)
+}
+
+// PoolStats returns the connection pool statistics of the client.
+func (t *VoterCache) PoolStats() *redis.PoolStats {
+	return t.client.PoolStats()
+}
+
+// Ping implements health.Repository.
+func (t *VoterCache) Ping(ctx context.Context) error {
+	return t.client.Ping(ctx).Err()
+}
+
+// JSONModuleLoaded implements health.Repository. It looks for the
+// RedisJSON module (registered as "ReJSON") in MODULE LIST.
+func (t *VoterCache) JSONModuleLoaded(ctx context.Context) (bool, error) {
+	modules, err := t.client.Do(ctx, "MODULE", "LIST").Result()
+	if err != nil {
+		return false, err
+	}
+	return containsString(modules, "rejson"), nil
+}
+
+// StoredSchemaVersion implements health.Repository.
+func (t *VoterCache) StoredSchemaVersion(ctx context.Context) (int, error) {
+	version, err := t.client.Get(ctx, RedisSchemaVersionKey).Result()
+	if err == redis.Nil {
+		return 0, fmt.Errorf("no schema version stored under %s", RedisSchemaVersionKey)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(version)
+}
+
 //------------------------------------------------------------
 // REDIS HELPERS
 //------------------------------------------------------------
@@ -110,11 +192,52 @@ func redisKeyFromId(id int) string {
 }
 
 // getAllKeys will return all keys in the database that match the prefix
-// used in this application - RedisKeyPrefix.  It will return a string slice
-// of all keys.  Used by GetAll and DeleteAll
-func (t *VoterCache) getAllKeys() ([]string, error) {
-	key := fmt.Sprintf("%s*", RedisKeyPrefix)
-	return t.client.Keys(t.context, key).Result()
+// used in this application - RedisKeyPrefix - in the keyspace of the
+// tenant.  It will return a string slice of all keys.  Used by GetAll
+// and DeleteAll
+func (t *VoterCache) getAllKeys(ctx context.Context, ks keyspace) ([]string, error) {
+	key := ks.key(RedisKeyPrefix) + "*"
+	start := time.Now()
+	keys, err := t.client.Keys(ctx, key).Result()
+	logCommand(ctx, "KEYS", key, start, err)
+	return keys, err
+}
+
+// logCommand writes a debug line for a redis call so that slow or
+// failing commands can be traced back to the request that issued them
+func logCommand(ctx context.Context, command string, key string, start time.Time, err error) {
+	attrs := []any{
+		slog.String("command", command),
+		slog.String("key", key),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logging.FromContext(ctx).DebugContext(ctx, "redis", attrs...)
+}
+
+// containsString walks a reply of MODULE LIST and reports whether one
+// of the strings in it matches s. The reply is a list of maps in RESP3
+// and a list of flat lists in RESP2, so both shapes are handled.
+func containsString(reply interface{}, s string) bool {
+	switch v := reply.(type) {
+	case string:
+		return strings.EqualFold(v, s)
+	case []interface{}:
+		for _, item := range v {
+			if containsString(item, s) {
+				return true
+			}
+		}
+	case map[interface{}]interface{}:
+		for _, item := range v {
+			if containsString(item, s) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func fromJsonString(s string, item *storage.Voter) error {
@@ -125,31 +248,67 @@ func fromJsonString(s string, item *storage.Voter) error {
 	return nil
 }
 
-// upsertVoter will be used by insert and update, Redis only supports upserts
-// so we will check if an item exists before update, and if it does not exist
-// before insert
-func (t *VoterCache) upsertVoter(item *storage.Voter) error {
-	log.Println("Adding new Id:", redisKeyFromId(item.Id))
-	return t.client.JSONSet(t.context, redisKeyFromId(item.Id), ".", item).Err()
-}
-
-// Helper to return a Voter from redis provided a key
-func (t *VoterCache) getItemFromRedis(key string, item *storage.Voter) error {
+// Helper to return a Voter from redis provided a key in the keyspace
+func (t *VoterCache) getItemFromRedis(ctx context.Context, ks keyspace, key string, item *storage.Voter) error {
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return err
+	}
 
 	//Lets query redis for the item, note we can return parts of the
 	//json structure, the second parameter "." means return the entire
 	//json structure
-	itemJson, err := t.client.JSONGet(t.context, key, ".").Result()
+	start := time.Now()
+	itemJson, err := t.client.JSONGet(ctx, key, ".").Result()
+	logCommand(ctx, "JSON.GET", key, start, err)
+	//JSON.GET answers an empty string rather than nil for a missing key
+	if err == redis.Nil || (err == nil && itemJson == "") {
+		return storage.NotFoundf("voter item with key %s does not exist", key)
+	}
 	if err != nil {
 		return err
 	}
 
-	return fromJsonString(itemJson, item)
+	if err := fromJsonString(itemJson, item); err != nil {
+		return err
+	}
+	return s.openVoter(ctx, item)
 }
 
-func (t *VoterCache) doesKeyExist(id int) bool {
-	kc, _ := t.client.Exists(t.context, redisKeyFromId(id)).Result()
-	return kc > 0
+// getItemsFromRedis fetches the voters under keys, in the keyspace,
+// with a single JSON.MGET. The result follows the order of keys, a key
+// without a voter gives nil.
+func (t *VoterCache) getItemsFromRedis(ctx context.Context, ks keyspace, keys []string) ([]*storage.Voter, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	replies, err := t.client.JSONMGet(ctx, ".", keys...).Result()
+	logCommand(ctx, "JSON.MGET", fmt.Sprintf("%d keys", len(keys)), start, err)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	items := make([]*storage.Voter, len(keys))
+	for i, reply := range replies {
+		itemJson, _ := reply.(string)
+		if itemJson == "" {
+			continue
+		}
+		items[i] = &storage.Voter{}
+		if err := fromJsonString(itemJson, items[i]); err != nil {
+			return nil, err
+		}
+		if err := s.openVoter(ctx, items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
 }
 
 //------------------------------------------------------------
@@ -169,12 +328,10 @@ func (t *VoterCache) doesKeyExist(id int) bool {
 //	 (1) The item will be added to the DB
 //		(2) The DB file will be saved with the item added
 //		(3) If there is an error, it will be returned
-func (t *VoterCache) AddItem(item *storage.Voter) error {
-
-	if t.doesKeyExist(item.Id) {
-		return fmt.Errorf("voter item with id %d already exists", item.Id)
-	}
-	return t.upsertVoter(item)
+func (t *VoterCache) AddItem(ctx context.Context, item *storage.Voter) error {
+	return t.RunInTransaction(ctx, func(tx *Tx) error {
+		return tx.AddItem(ctx, item)
+	})
 }
 
 // DeleteItem accepts an item id and removes it from the DB.
@@ -190,25 +347,119 @@ func (t *VoterCache) AddItem(item *storage.Voter) error {
 //	 (1) The item will be removed from the DB
 //		(2) The DB file will be saved with the item removed
 //		(3) If there is an error, it will be returned
-func (t *VoterCache) DeleteItem(id int) error {
-	if !t.doesKeyExist(id) {
-		return fmt.Errorf("voter item with id %d does not exist", id)
-	}
-	return t.client.Del(t.context, redisKeyFromId(id)).Err()
+func (t *VoterCache) DeleteItem(ctx context.Context, id int) error {
+	return t.RunInTransaction(ctx, func(tx *Tx) error {
+		return tx.DeleteItem(ctx, id)
+	})
 }
 
 // DeleteAll removes all items from the DB.
 // It will be exposed via a DELETE /voter endpoint
-func (t *VoterCache) DeleteAll() (int, error) {
-	keyList, err := t.getAllKeys()
+func (t *VoterCache) DeleteAll(ctx context.Context) (int, error) {
+	ks, err := t.keyspace(ctx)
 	if err != nil {
 		return 0, err
 	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return 0, err
+	}
+	keyList, err := t.getAllKeys(ctx, ks)
+	if err != nil {
+		return 0, err
+	}
+
+	//DEL needs at least one key, an empty database means there
+	//is nothing to remove
+	if len(keyList) == 0 {
+		return 0, nil
+	}
 
-	//Notice how we can deconstruct the slice into a variadic argument
-	//for the Del function by using the ... operator
-	numDeleted, err := t.client.Del(t.context, keyList...).Result()
-	return int(numDeleted), err
+	//The voters are WATCHed and read first, every one of them gets a
+	//voter.deleted event carrying what was removed. The DEL and the
+	//events go out in one MULTI/EXEC, like every other write.
+	var numDeleted int64
+	err = t.client.Watch(ctx, func(rtx *redis.Tx) error {
+		voters := make([]storage.Voter, 0, len(keyList))
+		for _, k := range keyList {
+			itemJson, err := rtx.JSONGet(ctx, k, ".").Result()
+			if err != nil {
+				return err
+			}
+			//removed since KEYS, DEL will not count it either
+			if itemJson == "" {
+				continue
+			}
+			voter := storage.Voter{}
+			if err := fromJsonString(itemJson, &voter); err != nil {
+				return err
+			}
+			if err := s.openVoter(ctx, &voter); err != nil {
+				return err
+			}
+			voters = append(voters, voter)
+		}
+
+		now := time.Now().UTC()
+		start := time.Now()
+		cmds, err := rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			//Notice how we can deconstruct the slice into a variadic argument
+			//for the Del function by using the ... operator
+			pipe.Del(ctx, keyList...)
+			for idx := range voters {
+				indexPrecinct(ctx, pipe, ks, &voters[idx], nil)
+				indexAnalytics(ctx, pipe, ks, &voters[idx], nil)
+				if err := addEvents(ctx, pipe, ks, s, storage.DiffEvents(&voters[idx], nil, now)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		logCommand(ctx, "DEL", ks.key(RedisKeyPrefix)+"*", start, err)
+		if err != nil {
+			return err
+		}
+		numDeleted = cmds[0].(*redis.IntCmd).Val()
+		return nil
+	}, keyList...)
+	if errors.Is(err, redis.TxFailedErr) {
+		return 0, ErrTxConflict
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(numDeleted), nil
+}
+
+// DeleteAllVoters implements delete.Repository. It removes every voter
+// (and therefore all of their history) and returns how many were removed.
+func (t *VoterCache) DeleteAllVoters(ctx context.Context) (int, error) {
+	return t.DeleteAll(ctx)
+}
+
+// DeleteVoterHistory implements delete.Repository. It removes a single
+// poll from the history of a voter. Both the voter and the poll must
+// exist, otherwise an error is returned.
+func (t *VoterCache) DeleteVoterHistory(ctx context.Context, voterId int, pollId int) error {
+	return t.RunInTransaction(ctx, func(tx *Tx) error {
+		return tx.DeleteVoterHistory(ctx, voterId, pollId)
+	})
+}
+
+// DeleteAllVoterHistory implements delete.Repository. It clears the
+// history of a voter while keeping the voter itself, and returns how
+// many history entries were removed.
+func (t *VoterCache) DeleteAllVoterHistory(ctx context.Context, voterId int) (int, error) {
+	var numDeleted int
+	err := t.RunInTransaction(ctx, func(tx *Tx) error {
+		var err error
+		numDeleted, err = tx.DeleteAllVoterHistory(ctx, voterId)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return numDeleted, nil
 }
 
 // UpdateItem accepts a Voter and updates it in the DB.
@@ -224,11 +475,10 @@ func (t *VoterCache) DeleteAll() (int, error) {
 //	 (1) The item will be updated in the DB
 //		(2) The DB file will be saved with the item updated
 //		(3) If there is an error, it will be returned
-func (t *VoterCache) UpdateItem(item *storage.Voter) error {
-	if !t.doesKeyExist(item.Id) {
-		return fmt.Errorf("voter item with id %d does not exist", item.Id)
-	}
-	return t.upsertVoter(item)
+func (t *VoterCache) UpdateItem(ctx context.Context, item *storage.Voter) error {
+	return t.RunInTransaction(ctx, func(tx *Tx) error {
+		return tx.UpdateItem(ctx, item)
+	})
 }
 
 // GetItem accepts an item id and returns the item from the DB.
@@ -245,9 +495,13 @@ func (t *VoterCache) UpdateItem(item *storage.Voter) error {
 //		(2) If there is an error, it will be returned
 //			along with an empty Voter
 //		(3) The database file will not be modified
-func (t *VoterCache) GetItem(id int) (*storage.Voter, error) {
+func (t *VoterCache) GetItem(ctx context.Context, id int) (*storage.Voter, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
 	newVoter := &storage.Voter{}
-	err := t.getItemFromRedis(redisKeyFromId(id), newVoter)
+	err = t.getItemFromRedis(ctx, ks, ks.voter(id), newVoter)
 	if err != nil {
 		return nil, err
 	}
@@ -264,25 +518,71 @@ func (t *VoterCache) GetItem(id int) (*storage.Voter, error) {
 //		(2) If there is an error, it will be returned
 //			along with an empty slice
 //		(3) The database file will not be modified
-func (t *VoterCache) GetAllItems() ([]storage.Voter, error) {
-	keyList, err := t.getAllKeys()
+func (t *VoterCache) GetAllItems(ctx context.Context) ([]storage.Voter, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keyList, err := t.getAllKeys(ctx, ks)
 	if err != nil {
 		return nil, err
 	}
 
-	//preallocate the slice, will make things faster
-	resList := make([]storage.Voter, len(keyList))
+	items, err := t.getItemsFromRedis(ctx, ks, keyList)
+	if err != nil {
+		return nil, err
+	}
 
-	for idx, k := range keyList {
-		err := t.getItemFromRedis(k, &resList[idx])
-		if err != nil {
-			return nil, err
+	//preallocate the slice, will make things faster. A voter deleted
+	//between KEYS and JSON.MGET is left out.
+	resList := make([]storage.Voter, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			resList = append(resList, *item)
 		}
 	}
 
 	return resList, nil
 }
 
+// GetItems returns the voters with the given ids in one round trip to
+// redis, in the order of ids. An id without a voter gives nil, it is
+// not an error.
+func (t *VoterCache) GetItems(ctx context.Context, ids []int) ([]*storage.Voter, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = ks.voter(id)
+	}
+	return t.getItemsFromRedis(ctx, ks, keys)
+}
+
+// GetItemIds returns the ids of every voter. Only the keys are read, so
+// a caller can go through the voters with GetItems a few at a time.
+func (t *VoterCache) GetItemIds(ctx context.Context) ([]int, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := t.getAllKeys(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ks.key(RedisKeyPrefix)
+	ids := make([]int, 0, len(keys))
+	for _, key := range keys {
+		id, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if err != nil {
+			return nil, fmt.Errorf("the voter key %q does not end in an id: %w", key, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // PrintItem accepts a Voter and prints it to the console
 // in a JSON pretty format. As some help, look at the
 // json.MarshalIndent() function from our in class go tutorial.
@@ -0,0 +1,555 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"drexel.edu/voter-api/pkg/pii"
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// rotationBatch is how many voters or events are read at once while
+// looking for the data keys still in use
+const rotationBatch = 1000
+
+// A KeyRotation tells what RotateKeys did. Kept lists the retired data
+// keys that events in the stream were still sealed with, they are
+// removed by a later rotation once the stream has been trimmed.
+// ClearEventsLeft counts the events in clear that a consumer has still
+// to handle, a later rotation deletes them.
+type KeyRotation struct {
+	DataKey         string
+	Rewrapped       int
+	Voters          int
+	Tombstones      int
+	DeadLetters     int
+	Responses       int
+	ClearEvents     int
+	ClearEventsLeft int
+	Removed         []string
+	Kept            []string
+}
+
+// RotateKeys seals every voter, tombstone, dead letter and stored
+// response of the keyspace in ctx again with a new data key and wraps every key again with the current
+// master key. It goes in four steps:
+//
+//  1. a new data key becomes the active one, the old one is retired
+//  2. the data keys and the index key are wrapped again, afterwards the
+//     master keys that wrapped them before are no longer needed
+//  3. once every instance had keyringTTL to pick the new data key up,
+//     every voter, tombstone, dead letter and stored response is
+//     sealed again, those that were never sealed included, and the
+//     events stored in clear are deleted
+//  4. the retired data keys that nothing is sealed with anymore are
+//     removed
+//
+// The api can keep running, voters are WATCHed while they are sealed
+// again. Events are never rewritten, their data keys stay until the
+// stream no longer holds events sealed with them. Events stored before
+// encryption was turned on carry voters in clear, they are deleted once
+// every consumer of the outbox has handled them.
+func (t *VoterCache) RotateKeys(ctx context.Context) (KeyRotation, error) {
+	if t.encryption == nil {
+		return KeyRotation{}, errors.New("encryption is off, configure a keyfile or a KMS to rotate keys")
+	}
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return KeyRotation{}, err
+	}
+	master := t.encryption.master
+	rotation := KeyRotation{}
+
+	//1. the new data key
+	if _, err := t.loadKeyring(ctx, ks, true); err != nil {
+		return KeyRotation{}, err
+	}
+	_, active, dataKeys, err := t.readKeys(ctx, ks)
+	if err != nil {
+		return KeyRotation{}, err
+	}
+	now := time.Now().UTC()
+	next, _, err := pii.NewDataKey(ctx, master, now)
+	if err != nil {
+		return KeyRotation{}, err
+	}
+	previous := dataKeys[active]
+	previous.RetiredAt = &now
+	_, err = t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if err := saveDataKey(ctx, pipe, ks, next); err != nil {
+			return err
+		}
+		if err := saveDataKey(ctx, pipe, ks, previous); err != nil {
+			return err
+		}
+		return pipe.Set(ctx, ks.key(RedisActiveDataKey), next.Id, 0).Err()
+	})
+	if err != nil {
+		return KeyRotation{}, err
+	}
+	rotation.DataKey = next.Id
+	slog.InfoContext(ctx, "rotating keys", "keyspace", string(ks), "data_key", next.Id, "retired", previous.Id)
+
+	//2. wrap every key again
+	if rotation.Rewrapped, err = t.rewrapKeys(ctx, ks); err != nil {
+		return rotation, err
+	}
+
+	//3. seal everything again
+	slog.InfoContext(ctx, "waiting for every instance to pick up the new data key", "wait", keyringTTL)
+	select {
+	case <-ctx.Done():
+		return rotation, ctx.Err()
+	case <-time.After(time.Until(now.Add(keyringTTL))):
+	}
+	if _, err := t.loadKeyring(ctx, ks, true); err != nil {
+		return rotation, err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return rotation, err
+	}
+	if rotation.Voters, err = t.resealVoters(ctx, ks, s); err != nil {
+		return rotation, err
+	}
+	if rotation.Tombstones, err = t.resealTombstones(ctx, ks, s); err != nil {
+		return rotation, err
+	}
+	if rotation.DeadLetters, err = t.resealDeadLetters(ctx, ks, s); err != nil {
+		return rotation, err
+	}
+	if rotation.Responses, err = t.resealResponses(ctx, ks, s); err != nil {
+		return rotation, err
+	}
+	if rotation.ClearEvents, rotation.ClearEventsLeft, err = t.dropClearEvents(ctx, ks); err != nil {
+		return rotation, err
+	}
+
+	//4. remove the data keys nothing needs anymore
+	rotation.Removed, rotation.Kept, err = t.pruneDataKeys(ctx, ks, next.Id)
+	return rotation, err
+}
+
+// rewrapKeys wraps the data keys and the index key with the current
+// master key and returns how many it wrapped
+func (t *VoterCache) rewrapKeys(ctx context.Context, ks keyspace) (int, error) {
+	master := t.encryption.master
+	index, _, dataKeys, err := t.readKeys(ctx, ks)
+	if err != nil {
+		return 0, err
+	}
+	rewrap := func(dataKey *pii.DataKey) error {
+		key, err := dataKey.Unwrap(ctx, master)
+		if err != nil {
+			return err
+		}
+		return dataKey.Wrap(ctx, master, key)
+	}
+
+	rewrapped := 0
+	if index != nil {
+		if err := rewrap(index); err != nil {
+			return 0, err
+		}
+		data, err := json.Marshal(index)
+		if err != nil {
+			return 0, err
+		}
+		if err := t.client.Set(ctx, ks.key(RedisIndexKey), data, 0).Err(); err != nil {
+			return 0, err
+		}
+		rewrapped++
+	}
+	for _, dataKey := range dataKeys {
+		if err := rewrap(&dataKey); err != nil {
+			return 0, err
+		}
+		if err := saveDataKey(ctx, t.client, ks, dataKey); err != nil {
+			return 0, err
+		}
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
+// resealVoters seals every voter of the keyspace again with the active
+// data key, each in a transaction of its own
+func (t *VoterCache) resealVoters(ctx context.Context, ks keyspace, s *sealer) (int, error) {
+	keys, err := t.getAllKeys(ctx, ks)
+	if err != nil {
+		return 0, err
+	}
+	resealed := 0
+	for _, key := range keys {
+		var found bool
+		reseal := func(rtx *redis.Tx) error {
+			itemJson, err := rtx.JSONGet(ctx, key, ".").Result()
+			if err == redis.Nil || (err == nil && itemJson == "") {
+				found = false
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			voter := storage.Voter{}
+			if err := fromJsonString(itemJson, &voter); err != nil {
+				return err
+			}
+			if err := s.openVoter(ctx, &voter); err != nil {
+				return err
+			}
+			doc, err := s.sealVoter(&voter)
+			if err != nil {
+				return err
+			}
+			_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.JSONSet(ctx, key, ".", doc)
+				return nil
+			})
+			found = true
+			return err
+		}
+
+		for attempt := 0; ; attempt++ {
+			err = t.client.Watch(ctx, reseal, key)
+			if !errors.Is(err, redis.TxFailedErr) {
+				break
+			}
+			if attempt+1 == maxTxAttempts {
+				return resealed, ErrTxConflict
+			}
+		}
+		if err != nil {
+			return resealed, err
+		}
+		if found {
+			resealed++
+		}
+	}
+	return resealed, nil
+}
+
+// resealTombstones seals every tombstone of the keyspace again with the
+// active data key
+func (t *VoterCache) resealTombstones(ctx context.Context, ks keyspace, s *sealer) (int, error) {
+	key := ks.key(RedisTombstonesKey)
+	all, err := t.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	_, err = t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, data := range all {
+			tombstone := storage.Tombstone{}
+			if err := json.Unmarshal([]byte(data), &tombstone); err != nil {
+				return err
+			}
+			if err := s.openTombstone(ctx, &tombstone); err != nil {
+				return err
+			}
+			if err := setTombstone(ctx, pipe, ks, s, tombstone); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(all), nil
+}
+
+// resealDeadLetters seals the payload of every dead letter of the
+// keyspace again with the active data key, those pushed before
+// encryption was turned on included. The list is WATCHed and written
+// back whole, a delivery failing or a replay meanwhile starts it over.
+func (t *VoterCache) resealDeadLetters(ctx context.Context, ks keyspace, s *sealer) (int, error) {
+	list := ks.key(RedisDeadLetterKey)
+	resealed := 0
+	reseal := func(rtx *redis.Tx) error {
+		all, err := rtx.LRange(ctx, list, 0, -1).Result()
+		if err != nil || len(all) == 0 {
+			resealed = 0
+			return err
+		}
+		sealed := make([]interface{}, len(all))
+		for i, data := range all {
+			stored := deadLetter{}
+			if err := json.Unmarshal([]byte(data), &stored); err != nil {
+				return err
+			}
+			d, err := s.openDeadLetter(ctx, stored)
+			if err != nil {
+				return err
+			}
+			if stored, err = s.sealDeadLetter(d); err != nil {
+				return err
+			}
+			if sealed[i], err = json.Marshal(stored); err != nil {
+				return err
+			}
+		}
+		_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, list)
+			pipe.RPush(ctx, list, sealed...)
+			return nil
+		})
+		resealed = len(all)
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := t.client.Watch(ctx, reseal, list)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return resealed, err
+		}
+		if attempt+1 == maxTxAttempts {
+			return 0, ErrTxConflict
+		}
+	}
+}
+
+// resealResponses seals every stored response of the keyspace again
+// with the active data key, keeping its TTL. Each is WATCHed, a request
+// finishing meanwhile starts it over.
+func (t *VoterCache) resealResponses(ctx context.Context, ks keyspace, s *sealer) (int, error) {
+	var keys []string
+	iter := t.client.Scan(ctx, 0, ks.key(RedisIdempotencyPrefix)+"*", rotationBatch).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return 0, err
+	}
+
+	resealed := 0
+	for _, key := range keys {
+		var found bool
+		reseal := func(rtx *redis.Tx) error {
+			value, err := rtx.Get(ctx, key).Result()
+			if err == redis.Nil {
+				found = false
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if value, err = s.openIn(ctx, responseContext(key), value); err != nil {
+				return err
+			}
+			if value, err = s.sealIn(responseContext(key), value); err != nil {
+				return err
+			}
+			_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, value, redis.KeepTTL)
+				return nil
+			})
+			found = true
+			return err
+		}
+
+		var err error
+		for attempt := 0; ; attempt++ {
+			err = t.client.Watch(ctx, reseal, key)
+			if !errors.Is(err, redis.TxFailedErr) {
+				break
+			}
+			if attempt+1 == maxTxAttempts {
+				return resealed, ErrTxConflict
+			}
+		}
+		if err != nil {
+			return resealed, err
+		}
+		if found {
+			resealed++
+		}
+	}
+	return resealed, nil
+}
+
+// dropClearEvents deletes the events of the keyspace that carry a voter
+// in clear and that every consumer of the outbox has handled. It
+// returns how many it deleted and how many in clear it left for a
+// consumer that has not got that far yet.
+func (t *VoterCache) dropClearEvents(ctx context.Context, ks keyspace) (int, int, error) {
+	offsets, err := t.client.HGetAll(ctx, ks.key(RedisConsumerOffsetsKey)).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	oldest := ""
+	for _, offset := range offsets {
+		if oldest == "" || streamIDLess(offset, oldest) {
+			oldest = offset
+		}
+	}
+
+	stream := ks.key(RedisEventStream)
+	dropped, left := 0, 0
+	for after := "-"; ; {
+		messages, err := t.client.XRangeN(ctx, stream, after, "+", rotationBatch).Result()
+		if err != nil {
+			return dropped, left, err
+		}
+		var clear []string
+		for _, message := range messages {
+			event, err := eventFromMessage(message)
+			if err != nil {
+				return dropped, left, err
+			}
+			if event.Voter == nil || !inClear(event.Voter.Name, event.Voter.Email) {
+				continue
+			}
+			if oldest != "" && streamIDLess(oldest, message.ID) {
+				left++
+				continue
+			}
+			clear = append(clear, message.ID)
+		}
+		if len(clear) > 0 {
+			start := time.Now()
+			err := t.client.XDel(ctx, stream, clear...).Err()
+			logCommand(ctx, "XDEL", stream, start, err)
+			if err != nil {
+				return dropped, left, err
+			}
+			dropped += len(clear)
+		}
+		if len(messages) < rotationBatch {
+			return dropped, left, nil
+		}
+		after = "(" + messages[len(messages)-1].ID
+	}
+}
+
+// inClear tells whether one of values is personal data that is not
+// sealed
+func inClear(values ...string) bool {
+	for _, value := range values {
+		if value != "" && !pii.Sealed(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneDataKeys removes the retired data keys that no voter, tombstone,
+// dead letter or event is sealed with and that were retired long enough ago that
+// no instance seals with them anymore. It returns the ids it removed
+// and those it kept.
+func (t *VoterCache) pruneDataKeys(ctx context.Context, ks keyspace, active string) ([]string, []string, error) {
+	_, _, dataKeys, err := t.readKeys(ctx, ks)
+	if err != nil {
+		return nil, nil, err
+	}
+	inUse, err := t.dataKeysInUse(ctx, ks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var removed, kept []string
+	cutoff := time.Now().Add(-keyringTTL)
+	for id, dataKey := range dataKeys {
+		switch {
+		case id == active:
+		case inUse[id] || dataKey.RetiredAt == nil || dataKey.RetiredAt.After(cutoff):
+			kept = append(kept, id)
+		default:
+			removed = append(removed, id)
+		}
+	}
+	if len(removed) > 0 {
+		if err := t.client.HDel(ctx, ks.key(RedisDataKeysKey), removed...).Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(kept)
+	return removed, kept, nil
+}
+
+// dataKeysInUse returns the ids of the data keys that a voter, a
+// tombstone, a dead letter or an event of the keyspace is sealed with
+func (t *VoterCache) dataKeysInUse(ctx context.Context, ks keyspace) (map[string]bool, error) {
+	inUse := make(map[string]bool)
+	note := func(values ...string) {
+		for _, value := range values {
+			if id, sealed := pii.KeyId(value); sealed {
+				inUse[id] = true
+			}
+		}
+	}
+
+	//the voters are not opened, only the ids of their data keys are read
+	keys, err := t.getAllKeys(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+	for from := 0; from < len(keys); from += rotationBatch {
+		batch := keys[from:min(from+rotationBatch, len(keys))]
+		replies, err := t.client.JSONMGet(ctx, ".", batch...).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for _, reply := range replies {
+			itemJson, _ := reply.(string)
+			if itemJson == "" {
+				continue
+			}
+			voter := storage.Voter{}
+			if err := fromJsonString(itemJson, &voter); err != nil {
+				return nil, err
+			}
+			note(voter.Name, voter.Email)
+		}
+	}
+
+	tombstones, err := t.client.HGetAll(ctx, ks.key(RedisTombstonesKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range tombstones {
+		tombstone := storage.Tombstone{}
+		if err := json.Unmarshal([]byte(data), &tombstone); err != nil {
+			return nil, err
+		}
+		note(tombstone.Name, tombstone.Email)
+	}
+
+	letters, err := t.client.LRange(ctx, ks.key(RedisDeadLetterKey), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range letters {
+		d := deadLetter{}
+		if err := json.Unmarshal([]byte(data), &d); err != nil {
+			return nil, err
+		}
+		note(d.SealedPayload)
+	}
+
+	stream := ks.key(RedisEventStream)
+	for after := "-"; ; {
+		messages, err := t.client.XRangeN(ctx, stream, after, "+", rotationBatch).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range messages {
+			event, err := eventFromMessage(message)
+			if err != nil {
+				return nil, err
+			}
+			if event.Voter != nil {
+				note(event.Voter.Name, event.Voter.Email)
+			}
+		}
+		if len(messages) < rotationBatch {
+			return inUse, nil
+		}
+		after = "(" + messages[len(messages)-1].ID
+	}
+}
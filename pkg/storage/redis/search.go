@@ -0,0 +1,333 @@
+package rediscache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/voter-api/pkg/pii"
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	//RedisSearchIndex is the RediSearch index over the voter:* JSON
+	//documents. RediSearch keeps it up to date on every write, the
+	//repository only creates it.
+	RedisSearchIndex = "voters-idx"
+
+	//searchIndexVersion is recorded under RedisSearchIndexVersionKey,
+	//with a suffix when the index is over blind indexes. Bump it
+	//whenever searchSchema or blindSearchSchema changes, the next start
+	//drops the index and builds it again.
+	searchIndexVersion         = 1
+	RedisSearchIndexVersionKey = "voter-api:search-index-version"
+)
+
+// The errors of RediSearch that EnsureSearchIndex expects
+const (
+	errUnknownIndex       = "unknown index name"
+	errIndexAlreadyExists = "index already exists"
+)
+
+// searchSchema indexes the name word by word without stemming or stop
+// words, so "Ann" is not dropped and "Smiths" does not match "Smith".
+// The history is a map, $.history.*.poll_id collects the poll ids into
+// a multi-value numeric field.
+var searchSchema = []interface{}{
+	"$.id", "AS", "id", "NUMERIC", "SORTABLE",
+	"$.name", "AS", "name", "TEXT", "NOSTEM",
+	"$.email", "AS", "email", "TAG",
+	"$.history.*.poll_id", "AS", "poll_id", "NUMERIC",
+}
+
+// blindSearchSchema is searchSchema when encryption is on. The name and
+// email are sealed, the index has their blind indexes instead: one per
+// prefix of a word of the name, the fuzzy variants of the words and the
+// email. See pii.Keyring.
+var blindSearchSchema = []interface{}{
+	"$.id", "AS", "id", "NUMERIC", "SORTABLE",
+	"$.name_index[*]", "AS", "name", "TAG",
+	"$.fuzzy_index[*]", "AS", "fuzzy", "TAG",
+	"$.email_index", "AS", "email", "TAG",
+	"$.history.*.poll_id", "AS", "poll_id", "NUMERIC",
+}
+
+// schema returns the schema and version of the search index
+func (t *VoterCache) schema() ([]interface{}, string) {
+	if t.encryption != nil {
+		return blindSearchSchema, strconv.Itoa(searchIndexVersion) + "-blind"
+	}
+	return searchSchema, strconv.Itoa(searchIndexVersion)
+}
+
+// SearchModuleLoaded looks for the RediSearch module (registered as
+// "search") in MODULE LIST.
+func (t *VoterCache) SearchModuleLoaded(ctx context.Context) (bool, error) {
+	modules, err := t.client.Do(ctx, "MODULE", "LIST").Result()
+	if err != nil {
+		return false, err
+	}
+	return containsString(modules, "search"), nil
+}
+
+// EnsureSearchIndex creates the search index of the tenant in ctx, or
+// builds it again when it was created by a build with another
+// searchIndexVersion or with encryption turned the other way. Every
+// tenant has an index of its own, over its own voters. Existing voters
+// are indexed in the background by RediSearch.
+func (t *VoterCache) EnsureSearchIndex(ctx context.Context) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	index := ks.key(RedisSearchIndex)
+	versionKey := ks.key(RedisSearchIndexVersionKey)
+	schema, version := t.schema()
+	stored, err := t.client.Get(ctx, versionKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	if stored == version {
+		start := time.Now()
+		err := t.client.Do(ctx, "FT.INFO", index).Err()
+		logCommand(ctx, "FT.INFO", index, start, err)
+		if err == nil {
+			return nil
+		}
+		if !isSearchError(err, errUnknownIndex) {
+			return err
+		}
+	} else {
+		//the documents stay, only the index goes
+		start := time.Now()
+		err := t.client.Do(ctx, "FT.DROPINDEX", index).Err()
+		logCommand(ctx, "FT.DROPINDEX", index, start, err)
+		if err != nil && !isSearchError(err, errUnknownIndex) {
+			return err
+		}
+	}
+
+	args := []interface{}{"FT.CREATE", index, "ON", "JSON", "PREFIX", "1", ks.key(RedisKeyPrefix), "STOPWORDS", "0", "SCHEMA"}
+	start := time.Now()
+	err = t.client.Do(ctx, append(args, schema...)...).Err()
+	logCommand(ctx, "FT.CREATE", index, start, err)
+	//another instance may have been quicker
+	if err != nil && !isSearchError(err, errIndexAlreadyExists) {
+		return err
+	}
+	return t.client.Set(ctx, versionKey, version, 0).Err()
+}
+
+func isSearchError(err error, msg string) bool {
+	return strings.Contains(strings.ToLower(err.Error()), msg)
+}
+
+// SearchVoters implements search.Repository with FT.SEARCH for the page
+// of ids, JSON.MGET for the voters and FT.AGGREGATE for the facets.
+func (t *VoterCache) SearchVoters(ctx context.Context, query storage.VoterQuery) (storage.VoterSearchResult, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return storage.VoterSearchResult{}, err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return storage.VoterSearchResult{}, err
+	}
+	index := ks.key(RedisSearchIndex)
+	q := searchQuery(query, s.kr)
+
+	start := time.Now()
+	reply, err := t.client.Do(ctx, "FT.SEARCH", index, q,
+		"NOCONTENT", "SORTBY", "id", "ASC",
+		"LIMIT", query.Offset, query.Limit,
+		"DIALECT", "2").Result()
+	logCommand(ctx, "FT.SEARCH", q, start, err)
+	if err != nil {
+		return storage.VoterSearchResult{}, err
+	}
+	total, keys, err := searchKeys(reply)
+	if err != nil {
+		return storage.VoterSearchResult{}, err
+	}
+
+	items, err := t.getItemsFromRedis(ctx, ks, keys)
+	if err != nil {
+		return storage.VoterSearchResult{}, err
+	}
+	result := storage.VoterSearchResult{Total: total}
+	for _, item := range items {
+		//deleted since the search
+		if item != nil {
+			result.Voters = append(result.Voters, *item)
+		}
+	}
+
+	result.PollFacets, err = t.pollFacets(ctx, index, q)
+	if err != nil {
+		return storage.VoterSearchResult{}, err
+	}
+	return result, nil
+}
+
+// pollFacets counts the voters matching q per poll. LOAD hands the
+// poll ids over as a JSON array, split turns it into one row per poll.
+func (t *VoterCache) pollFacets(ctx context.Context, index string, q string) (map[int]int, error) {
+	start := time.Now()
+	reply, err := t.client.Do(ctx, "FT.AGGREGATE", index, q,
+		"LOAD", "3", "$.history.*.poll_id", "AS", "polls",
+		"APPLY", `split(@polls, ",", "[] ")`, "AS", "poll",
+		"GROUPBY", "1", "@poll",
+		"REDUCE", "COUNT", "0", "AS", "count",
+		"DIALECT", "2").Result()
+	logCommand(ctx, "FT.AGGREGATE", q, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := make(map[int]int)
+	for _, row := range aggregateRows(reply) {
+		//voters without history give an empty poll
+		pollId, err := strconv.Atoi(fmt.Sprint(row["poll"]))
+		if err != nil {
+			continue
+		}
+		count, err := strconv.Atoi(fmt.Sprint(row["count"]))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected facet count %v", row["count"])
+		}
+		facets[pollId] = count
+	}
+	return facets, nil
+}
+
+// searchQuery translates query to the RediSearch query syntax. Terms
+// only hold letters and digits, they need no escaping. With a keyring
+// the terms and the email are looked up by their blind indexes.
+func searchQuery(query storage.VoterQuery, kr *pii.Keyring) string {
+	if kr != nil {
+		return blindSearchQuery(query, kr)
+	}
+	var clauses []string
+	for _, term := range query.Terms {
+		if query.Fuzzy {
+			clauses = append(clauses, fmt.Sprintf("@name:(%s*|%%%s%%)", term, term))
+		} else {
+			clauses = append(clauses, fmt.Sprintf("@name:%s*", term))
+		}
+	}
+	if query.Email != "" {
+		clauses = append(clauses, fmt.Sprintf("@email:{%s}", escapeTag(query.Email)))
+	}
+	for _, pollId := range query.PollIds {
+		clauses = append(clauses, fmt.Sprintf("@poll_id:[%d %d]", pollId, pollId))
+	}
+	if len(clauses) == 0 {
+		return "*"
+	}
+	return strings.Join(clauses, " ")
+}
+
+// blindSearchQuery is searchQuery over blindSearchSchema. The hashes
+// are hex, they need no escaping either.
+func blindSearchQuery(query storage.VoterQuery, kr *pii.Keyring) string {
+	var clauses []string
+	for _, term := range query.Terms {
+		prefix, fuzzy := kr.Term(term)
+		if query.Fuzzy {
+			clauses = append(clauses, fmt.Sprintf("(@name:{%s}|@fuzzy:{%s})", prefix, strings.Join(fuzzy, "|")))
+		} else {
+			clauses = append(clauses, fmt.Sprintf("@name:{%s}", prefix))
+		}
+	}
+	if query.Email != "" {
+		clauses = append(clauses, fmt.Sprintf("@email:{%s}", kr.Email(query.Email)))
+	}
+	for _, pollId := range query.PollIds {
+		clauses = append(clauses, fmt.Sprintf("@poll_id:[%d %d]", pollId, pollId))
+	}
+	if len(clauses) == 0 {
+		return "*"
+	}
+	return strings.Join(clauses, " ")
+}
+
+// escapeTag escapes the punctuation of an email, such as @ and ., which
+// would otherwise end the tag
+func escapeTag(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// searchKeys reads the total and the keys of an FT.SEARCH ... NOCONTENT
+// reply, a flat list in RESP2 and a map in RESP3
+func searchKeys(reply interface{}) (int, []string, error) {
+	switch v := reply.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			break
+		}
+		total, ok := v[0].(int64)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(v)-1)
+		for _, key := range v[1:] {
+			keys = append(keys, fmt.Sprint(key))
+		}
+		return int(total), keys, nil
+	case map[interface{}]interface{}:
+		total, ok := v["total_results"].(int64)
+		if !ok {
+			break
+		}
+		results, _ := v["results"].([]interface{})
+		keys := make([]string, 0, len(results))
+		for _, result := range results {
+			if doc, ok := result.(map[interface{}]interface{}); ok {
+				keys = append(keys, fmt.Sprint(doc["id"]))
+			}
+		}
+		return int(total), keys, nil
+	}
+	return 0, nil, fmt.Errorf("unexpected FT.SEARCH reply %T", reply)
+}
+
+// aggregateRows reads the rows of an FT.AGGREGATE reply, each a list
+// of field, value pairs in RESP2 and a map in RESP3
+func aggregateRows(reply interface{}) []map[string]interface{} {
+	var rows []map[string]interface{}
+	switch v := reply.(type) {
+	case []interface{}:
+		//the first element is the number of rows
+		for _, row := range v[min(1, len(v)):] {
+			pairs, _ := row.([]interface{})
+			fields := make(map[string]interface{}, len(pairs)/2)
+			for i := 0; i+1 < len(pairs); i += 2 {
+				fields[fmt.Sprint(pairs[i])] = pairs[i+1]
+			}
+			rows = append(rows, fields)
+		}
+	case map[interface{}]interface{}:
+		results, _ := v["results"].([]interface{})
+		for _, result := range results {
+			doc, _ := result.(map[interface{}]interface{})
+			attributes, _ := doc["extra_attributes"].(map[interface{}]interface{})
+			fields := make(map[string]interface{}, len(attributes))
+			for key, value := range attributes {
+				fields[fmt.Sprint(key)] = value
+			}
+			rows = append(rows, fields)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,74 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/tenant"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisTenantPrefix is followed by the id of a tenant and a colon in
+// front of every key of that tenant, tenant:montgomery:voter:5 for
+// example. Without tenancy the keys have no prefix, as before.
+const RedisTenantPrefix = "tenant:"
+
+// ErrNoTenant is returned by every call without a tenant in its context
+// once tenancy is on. The keys without a prefix belong to no tenant, so
+// such a call would see the data of none or of all of them.
+var ErrNoTenant = errors.New("the call has no tenant, every call needs one when tenancy is on")
+
+// keyspace is put in front of every key a call reads or writes. It is
+// taken from the tenant in the context of the call and from nowhere
+// else, which is what keeps the tenants apart: no method of the
+// repository takes a tenant or a raw key.
+type keyspace string
+
+func (k keyspace) key(name string) string {
+	return string(k) + name
+}
+
+func (k keyspace) voter(id int) string {
+	return k.key(redisKeyFromId(id))
+}
+
+// RequireTenant turns tenancy on. It has to be called before the
+// VoterCache is used.
+func (t *VoterCache) RequireTenant() {
+	t.tenancy = true
+}
+
+// keyspace returns the keyspace of the tenant in ctx
+func (t *VoterCache) keyspace(ctx context.Context) (keyspace, error) {
+	tn, ok := tenant.From(ctx)
+	if !ok {
+		if t.tenancy {
+			return "", ErrNoTenant
+		}
+		return "", nil
+	}
+	return keyspace(RedisTenantPrefix + tn.Id + ":"), nil
+}
+
+// checkQuota refuses a commit that would register more voters than the
+// tenant in ctx may have. The voters are counted in the registrations
+// aggregate, which is not WATCHed, so creates running at the same time
+// can overshoot the cap by a few voters.
+func checkQuota(ctx context.Context, c redis.Cmdable, ks keyspace, added int) error {
+	tn, ok := tenant.From(ctx)
+	if !ok || tn.MaxVoters == 0 || added == 0 {
+		return nil
+	}
+	start := time.Now()
+	registered, err := c.ZCard(ctx, ks.key(RedisRegistrationsKey)).Result()
+	logCommand(ctx, "ZCARD", ks.key(RedisRegistrationsKey), start, err)
+	if err != nil {
+		return err
+	}
+	if int(registered)+added > tn.MaxVoters {
+		return storage.Conflictf("tenant %s has reached its quota of %d voters", tn.Id, tn.MaxVoters)
+	}
+	return nil
+}
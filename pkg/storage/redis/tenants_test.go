@@ -0,0 +1,271 @@
+package rediscache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"drexel.edu/voter-api/pkg/pii"
+	"drexel.edu/voter-api/pkg/storage"
+	"drexel.edu/voter-api/pkg/tenant"
+)
+
+func tenantCtx(id string, maxVoters int) context.Context {
+	return tenant.WithTenant(context.Background(), tenant.Tenant{Id: id, MaxVoters: maxVoters})
+}
+
+func testVoter(id int) *storage.Voter {
+	registered := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	return &storage.Voter{
+		Id:         id,
+		Name:       "Ann Smith",
+		Email:      "ann@example.org",
+		PrecinctId: "p1",
+		VoterHistory: storage.HistoryMap{
+			7: {PollId: 7, VoteId: 1, VoteDate: registered.AddDate(0, 1, 0)},
+		},
+		RegisteredAt: &registered,
+	}
+}
+
+// encryptForTest turns encryption on with a keyfile of one master key
+func encryptForTest(t *testing.T, cache *VoterCache) {
+	t.Helper()
+	encryptWithKeyFile(t, cache, masterKeyLine(t, "test"))
+}
+
+// masterKeyLine returns a line of a keyfile with a new master key
+func masterKeyLine(t *testing.T, id string) string {
+	t.Helper()
+	key := make([]byte, pii.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return id + " " + base64.StdEncoding.EncodeToString(key) + "\n"
+}
+
+// encryptWithKeyFile turns encryption on with a keyfile of the lines
+// and returns the keyfile
+func encryptWithKeyFile(t *testing.T, cache *VoterCache, lines ...string) *pii.KeyFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "master.keys")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := pii.LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.EncryptWith(keys)
+	return keys
+}
+
+func TestKeyspacePrefixesEveryKey(t *testing.T) {
+	cache, fake := newFakeCache(t)
+	cache.RequireTenant()
+	encryptForTest(t, cache)
+	ctx := tenantCtx("a", 0)
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"search index", func() error { return cache.EnsureSearchIndex(ctx) }},
+		{"precinct", func() error { return cache.AddPrecinct(ctx, storage.Precinct{Id: "p1"}) }},
+		{"voter", func() error { return cache.AddItem(ctx, testVoter(1)) }},
+		{"get", func() error { _, err := cache.GetItem(ctx, 1); return err }},
+		{"list", func() error { _, err := cache.GetAllItems(ctx); return err }},
+		{"search", func() error {
+			_, err := cache.SearchVoters(ctx, storage.VoterQuery{Terms: []string{"ann"}, Limit: 10})
+			return err
+		}},
+		{"precinct voters", func() error { _, _, err := cache.PrecinctVoters(ctx, "p1", 0, 10); return err }},
+		{"events", func() error { _, err := cache.ReadEvents(ctx, "0", 10, 0); return err }},
+		{"analytics", func() error {
+			_, err := cache.CountRegistrations(ctx, []time.Time{time.Unix(0, 0), time.Now()})
+			return err
+		}},
+		{"merge", func() error {
+			return cache.RunInTransaction(ctx, func(tx *Tx) error {
+				if err := tx.DeleteItem(ctx, 1); err != nil {
+					return err
+				}
+				return tx.AddTombstone(ctx, storage.Tombstone{Id: 1, Name: "Ann Smith", MergedInto: 2, MergedAt: time.Now()})
+			})
+		}},
+		{"tombstone", func() error { _, err := cache.GetTombstone(ctx, 1); return err }},
+		{"delete all", func() error { _, err := cache.DeleteAll(ctx); return err }},
+	}
+
+	var touched []string
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		for _, key := range fake.takeTouched() {
+			if !strings.HasPrefix(key, "tenant:a:") {
+				t.Errorf("%s: the key %q is outside the keyspace of tenant a", step.name, key)
+			}
+			touched = append(touched, key)
+		}
+	}
+
+	families := []string{
+		ks("a", redisKeyFromId(1)),
+		ks("a", RedisEventStream),
+		ks("a", RedisPrecinctsKey),
+		ks("a", precinctVotersKey("p1")),
+		ks("a", RedisRegistrationsKey),
+		ks("a", pollVotesKey(7)),
+		ks("a", RedisSearchIndex),
+		ks("a", RedisSearchIndexVersionKey),
+		ks("a", RedisDataKeysKey),
+		ks("a", RedisActiveDataKey),
+		ks("a", RedisIndexKey),
+		ks("a", RedisTombstonesKey),
+	}
+	for _, family := range families {
+		if !contains(touched, family) {
+			t.Errorf("no command used %q", family)
+		}
+	}
+}
+
+func ks(tenantId string, name string) string {
+	return RedisTenantPrefix + tenantId + ":" + name
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRequireTenant(t *testing.T) {
+	cache, fake := newFakeCache(t)
+	cache.RequireTenant()
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"add":    func() error { return cache.AddItem(ctx, testVoter(1)) },
+		"get":    func() error { _, err := cache.GetItem(ctx, 1); return err },
+		"list":   func() error { _, err := cache.GetAllItems(ctx); return err },
+		"delete": func() error { return cache.DeleteItem(ctx, 1) },
+		"delete all": func() error {
+			_, err := cache.DeleteAll(ctx)
+			return err
+		},
+		"search": func() error {
+			_, err := cache.SearchVoters(ctx, storage.VoterQuery{Limit: 10})
+			return err
+		},
+		"search index": func() error { return cache.EnsureSearchIndex(ctx) },
+		"precincts":    func() error { _, err := cache.ListPrecincts(ctx); return err },
+		"events":       func() error { _, err := cache.ReadEvents(ctx, "0", 10, 0); return err },
+		"analytics":    func() error { _, err := cache.PollTotals(ctx); return err },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrNoTenant) {
+			t.Errorf("%s: got %v, want ErrNoTenant", name, err)
+		}
+	}
+	if touched := fake.takeTouched(); len(touched) > 0 {
+		t.Errorf("calls without a tenant used the keys %v", touched)
+	}
+}
+
+func TestTenantsAreIsolated(t *testing.T) {
+	cache, _ := newFakeCache(t)
+	cache.RequireTenant()
+	a, b := tenantCtx("a", 0), tenantCtx("b", 0)
+	for _, ctx := range []context.Context{a, b} {
+		if err := cache.EnsureSearchIndex(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.AddItem(a, testVoter(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.GetItem(b, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("tenant b read the voter of tenant a, got %v", err)
+	}
+	if voters, err := cache.GetAllItems(b); err != nil || len(voters) != 0 {
+		t.Errorf("tenant b listed %v, %v", voters, err)
+	}
+	if voters, err := cache.GetItems(b, []int{1}); err != nil || voters[0] != nil {
+		t.Errorf("tenant b read %v, %v", voters, err)
+	}
+	result, err := cache.SearchVoters(b, storage.VoterQuery{Limit: 10})
+	if err != nil || result.Total != 0 || len(result.Voters) != 0 {
+		t.Errorf("tenant b found %+v, %v", result, err)
+	}
+	if err := cache.DeleteItem(b, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("tenant b deleted the voter of tenant a, got %v", err)
+	}
+
+	//a voter with the same id in tenant b is another voter
+	other := testVoter(1)
+	other.Name = "Bob Jones"
+	if err := cache.AddItem(b, other); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := cache.DeleteAll(b); err != nil || deleted != 1 {
+		t.Fatalf("DeleteAll of tenant b removed %d voters, %v", deleted, err)
+	}
+
+	voter, err := cache.GetItem(a, 1)
+	if err != nil {
+		t.Fatalf("the voter of tenant a is gone: %v", err)
+	}
+	if voter.Name != "Ann Smith" {
+		t.Errorf("tenant a reads %q", voter.Name)
+	}
+	result, err = cache.SearchVoters(a, storage.VoterQuery{Limit: 10})
+	if err != nil || result.Total != 1 {
+		t.Errorf("tenant a found %+v, %v", result, err)
+	}
+}
+
+func TestQuotaIsPerTenant(t *testing.T) {
+	cache, _ := newFakeCache(t)
+	cache.RequireTenant()
+	a, b := tenantCtx("a", 2), tenantCtx("b", 1)
+
+	for id := 1; id <= 2; id++ {
+		if err := cache.AddItem(a, testVoter(id)); err != nil {
+			t.Fatalf("voter %d of tenant a: %v", id, err)
+		}
+	}
+	if err := cache.AddItem(a, testVoter(3)); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("tenant a went over its quota, got %v", err)
+	}
+
+	//the voters of tenant a do not count against tenant b
+	if err := cache.AddItem(b, testVoter(1)); err != nil {
+		t.Fatalf("tenant b: %v", err)
+	}
+	if err := cache.AddItem(b, testVoter(2)); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("tenant b went over its quota, got %v", err)
+	}
+
+	//deleting frees the quota of the tenant only
+	if err := cache.DeleteItem(b, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddItem(a, testVoter(3)); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("a delete in tenant b freed the quota of tenant a, got %v", err)
+	}
+	if err := cache.AddItem(b, testVoter(2)); err != nil {
+		t.Errorf("tenant b: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisTombstonesKey is a hash of the tombstones of merged voters, by
+// voter id. They live outside of voter:* so that neither the reads nor
+// the search index come across them.
+const RedisTombstonesKey = "voter-tombstones"
+
+func setTombstone(ctx context.Context, c redis.Cmdable, ks keyspace, s *sealer, tombstone storage.Tombstone) error {
+	tombstone, err := s.sealTombstone(tombstone)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(tombstone)
+	if err != nil {
+		return err
+	}
+	return c.HSet(ctx, ks.key(RedisTombstonesKey), strconv.Itoa(tombstone.Id), data).Err()
+}
+
+// GetTombstone returns the tombstone of a merged voter, nil if the
+// voter was never merged away.
+func (t *VoterCache) GetTombstone(ctx context.Context, id int) (*storage.Tombstone, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := ks.key(RedisTombstonesKey)
+	start := time.Now()
+	data, err := t.client.HGet(ctx, key, strconv.Itoa(id)).Result()
+	logCommand(ctx, "HGET", key, start, err)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tombstone := &storage.Tombstone{}
+	if err := json.Unmarshal([]byte(data), tombstone); err != nil {
+		return nil, err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.openTombstone(ctx, tombstone); err != nil {
+		return nil, err
+	}
+	return tombstone, nil
+}
@@ -0,0 +1,311 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxTxAttempts is how often a transaction is retried when one of the
+// voters it read was changed by someone else before it committed.
+const maxTxAttempts = 3
+
+// ErrTxConflict is returned when a transaction kept losing the race
+// against concurrent writes.
+var ErrTxConflict = storage.Conflictf("the transaction conflicted with concurrent writes, try again")
+
+// Tx is a repository that stages its writes in memory and commits them
+// all at once in a MULTI/EXEC. Every voter it reads from redis is
+// WATCHed, so the commit fails if one of them changed in the meantime.
+// Reads see the writes staged earlier in the same transaction, so the
+// regular adapters can run on top of a Tx unchanged.
+type Tx struct {
+	tx *redis.Tx
+
+	//ks is the keyspace of the tenant the transaction runs for, s
+	//seals and opens the voters in it
+	ks keyspace
+	s  *sealer
+
+	//staged holds the voters written so far, a nil voter is a delete
+	staged  map[int]*storage.Voter
+	watched map[string]bool
+
+	//original holds the voters as they were read from redis, nil
+	//for a voter that did not exist. The commit publishes the events
+	//between original and staged.
+	original map[int]*storage.Voter
+
+	//tombstones holds the tombstones to write, by voter id
+	tombstones map[int]storage.Tombstone
+}
+
+// RunInTransaction calls fn with a fresh Tx and commits what it staged
+// if fn returns nil. Returning an error from fn discards every write.
+// fn may be called again if the commit lost a race, so it must not have
+// side effects outside of the Tx.
+func (t *VoterCache) RunInTransaction(ctx context.Context, fn func(*Tx) error) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		err := t.client.Watch(ctx, func(rtx *redis.Tx) error {
+			tx := &Tx{
+				tx:         rtx,
+				ks:         ks,
+				s:          s,
+				staged:     make(map[int]*storage.Voter),
+				watched:    make(map[string]bool),
+				original:   make(map[int]*storage.Voter),
+				tombstones: make(map[int]storage.Tombstone),
+			}
+			if err := fn(tx); err != nil {
+				return err
+			}
+			return tx.commit(ctx)
+		})
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return ErrTxConflict
+}
+
+// Savepoint returns a function that rolls the staged writes back to
+// what they are now, used to undo a single failed operation.
+func (tx *Tx) Savepoint() (rollback func()) {
+	snapshot := make(map[int]*storage.Voter, len(tx.staged))
+	for id, voter := range tx.staged {
+		snapshot[id] = voter
+	}
+	tombstones := make(map[int]storage.Tombstone, len(tx.tombstones))
+	for id, tombstone := range tx.tombstones {
+		tombstones[id] = tombstone
+	}
+	return func() {
+		tx.staged = snapshot
+		tx.tombstones = tombstones
+	}
+}
+
+func (tx *Tx) commit(ctx context.Context) error {
+	if len(tx.staged) == 0 && len(tx.tombstones) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(tx.staged))
+	for id := range tx.staged {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	added := 0
+	for _, id := range ids {
+		if tx.original[id] == nil && tx.staged[id] != nil {
+			added++
+		}
+	}
+	if err := checkQuota(ctx, tx.tx, tx.ks, added); err != nil {
+		return err
+	}
+
+	//The events go into the same MULTI/EXEC as the writes. The
+	//stream is the outbox, a write and its events are stored together
+	//or not at all, and the relay forwards them from there.
+	now := time.Now().UTC()
+	start := time.Now()
+	_, err := tx.tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			voter := tx.staged[id]
+			if voter == nil {
+				pipe.Del(ctx, tx.ks.voter(id))
+			} else {
+				doc, err := tx.s.sealVoter(voter)
+				if err != nil {
+					return err
+				}
+				pipe.JSONSet(ctx, tx.ks.voter(id), ".", doc)
+			}
+			indexPrecinct(ctx, pipe, tx.ks, tx.original[id], voter)
+			indexAnalytics(ctx, pipe, tx.ks, tx.original[id], voter)
+			if err := addEvents(ctx, pipe, tx.ks, tx.s, storage.DiffEvents(tx.original[id], voter, now)); err != nil {
+				return err
+			}
+		}
+		for _, tombstone := range tx.tombstones {
+			if err := setTombstone(ctx, pipe, tx.ks, tx.s, tombstone); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	logCommand(ctx, "MULTI/EXEC", fmt.Sprintf("%d voters", len(ids)), start, err)
+	return err
+}
+
+func (tx *Tx) watch(ctx context.Context, key string) error {
+	if tx.watched[key] {
+		return nil
+	}
+	if err := tx.tx.Watch(ctx, key).Err(); err != nil {
+		return err
+	}
+	tx.watched[key] = true
+	return nil
+}
+
+func (tx *Tx) exists(ctx context.Context, id int) (bool, error) {
+	voter, err := tx.current(ctx, id)
+	return voter != nil, err
+}
+
+// current returns the voter as the transaction sees it, the staged
+// one or else the one in redis, nil if there is none. The caller must
+// copy it before changing it.
+func (tx *Tx) current(ctx context.Context, id int) (*storage.Voter, error) {
+	if voter, staged := tx.staged[id]; staged {
+		return voter, nil
+	}
+	if voter, read := tx.original[id]; read {
+		return voter, nil
+	}
+
+	key := tx.ks.voter(id)
+	if err := tx.watch(ctx, key); err != nil {
+		return nil, err
+	}
+	itemJson, err := tx.tx.JSONGet(ctx, key, ".").Result()
+	//JSON.GET answers an empty string rather than nil for a missing key
+	if err == redis.Nil || (err == nil && itemJson == "") {
+		tx.original[id] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	voter := &storage.Voter{}
+	if err := fromJsonString(itemJson, voter); err != nil {
+		return nil, err
+	}
+	if err := tx.s.openVoter(ctx, voter); err != nil {
+		return nil, err
+	}
+	tx.original[id] = voter
+	return voter, nil
+}
+
+// GetItem returns the staged voter or reads it from redis.
+func (tx *Tx) GetItem(ctx context.Context, id int) (*storage.Voter, error) {
+	voter, err := tx.current(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if voter == nil {
+		return nil, storage.NotFoundf("voter item with id %d does not exist", id)
+	}
+	return copyVoter(voter), nil
+}
+
+// AddItem stages a new voter.
+func (tx *Tx) AddItem(ctx context.Context, item *storage.Voter) error {
+	exists, err := tx.exists(ctx, item.Id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return storage.AlreadyExistsf("voter item with id %d already exists", item.Id)
+	}
+	tx.staged[item.Id] = copyVoter(item)
+	return nil
+}
+
+// UpdateItem stages a changed voter.
+func (tx *Tx) UpdateItem(ctx context.Context, item *storage.Voter) error {
+	exists, err := tx.exists(ctx, item.Id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.NotFoundf("voter item with id %d does not exist", item.Id)
+	}
+	tx.staged[item.Id] = copyVoter(item)
+	return nil
+}
+
+// DeleteItem stages the removal of a voter.
+func (tx *Tx) DeleteItem(ctx context.Context, id int) error {
+	exists, err := tx.exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.NotFoundf("voter item with id %d does not exist", id)
+	}
+	tx.staged[id] = nil
+	return nil
+}
+
+// DeleteVoterHistory stages the removal of a poll from a voter.
+func (tx *Tx) DeleteVoterHistory(ctx context.Context, voterId int, pollId int) error {
+	voter, err := tx.GetItem(ctx, voterId)
+	if err != nil {
+		return err
+	}
+	if _, exists := voter.VoterHistory[pollId]; !exists {
+		return storage.NotFoundf("poll %d does not exist in the history of voter %d", pollId, voterId)
+	}
+	delete(voter.VoterHistory, pollId)
+	tx.staged[voterId] = voter
+	return nil
+}
+
+// DeleteAllVoterHistory stages clearing the history of a voter.
+func (tx *Tx) DeleteAllVoterHistory(ctx context.Context, voterId int) (int, error) {
+	voter, err := tx.GetItem(ctx, voterId)
+	if err != nil {
+		return 0, err
+	}
+	numDeleted := len(voter.VoterHistory)
+	voter.VoterHistory = make(storage.HistoryMap)
+	tx.staged[voterId] = voter
+	return numDeleted, nil
+}
+
+// AddTombstone stages the tombstone of a voter, usually along with
+// deleting it.
+func (tx *Tx) AddTombstone(ctx context.Context, tombstone storage.Tombstone) error {
+	tx.tombstones[tombstone.Id] = tombstone
+	return nil
+}
+
+// DeleteAllVoters is not available inside a transaction, it would have
+// to WATCH every voter.
+func (tx *Tx) DeleteAllVoters(context.Context) (int, error) {
+	return 0, errors.New("deleting all voters is not supported inside a transaction")
+}
+
+// copyVoter makes sure staged voters are not shared with the caller,
+// the adapters edit the history map of the voters they get in place.
+func copyVoter(v *storage.Voter) *storage.Voter {
+	c := *v
+	if v.Address != nil {
+		address := *v.Address
+		c.Address = &address
+	}
+	if v.VoterHistory != nil {
+		c.VoterHistory = make(storage.HistoryMap, len(v.VoterHistory))
+		for pollId, history := range v.VoterHistory {
+			c.VoterHistory[pollId] = history
+		}
+	}
+	return &c
+}
@@ -0,0 +1,206 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	//RedisWebhooksKey is a hash of the webhook subscriptions by id
+	RedisWebhooksKey = "webhooks"
+
+	//RedisDeadLetterKey is a list of the deliveries that failed,
+	//newest first
+	RedisDeadLetterKey = "webhooks:dead-letters"
+
+	//maxDeadLetters caps the dead-letter list, the oldest fall off
+	maxDeadLetters = 1000
+)
+
+// SaveWebhook implements webhooks.Repository. It adds or replaces the
+// subscription with the id of w.
+func (t *VoterCache) SaveWebhook(ctx context.Context, w storage.Webhook) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	hash := ks.key(RedisWebhooksKey)
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = t.client.HSet(ctx, hash, w.ID, data).Err()
+	logCommand(ctx, "HSET", hash, start, err)
+	return err
+}
+
+// GetWebhook implements webhooks.Repository. It returns nil when there
+// is no subscription with the id.
+func (t *VoterCache) GetWebhook(ctx context.Context, id string) (*storage.Webhook, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash := ks.key(RedisWebhooksKey)
+	start := time.Now()
+	data, err := t.client.HGet(ctx, hash, id).Bytes()
+	logCommand(ctx, "HGET", hash, start, err)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	w := &storage.Webhook{}
+	if err := json.Unmarshal(data, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ListWebhooks implements webhooks.Repository.
+func (t *VoterCache) ListWebhooks(ctx context.Context) ([]storage.Webhook, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash := ks.key(RedisWebhooksKey)
+	start := time.Now()
+	all, err := t.client.HGetAll(ctx, hash).Result()
+	logCommand(ctx, "HGETALL", hash, start, err)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]storage.Webhook, 0, len(all))
+	for _, data := range all {
+		w := storage.Webhook{}
+		if err := json.Unmarshal([]byte(data), &w); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook implements webhooks.Repository. It reports whether
+// there was a subscription to remove.
+func (t *VoterCache) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return false, err
+	}
+	hash := ks.key(RedisWebhooksKey)
+	start := time.Now()
+	n, err := t.client.HDel(ctx, hash, id).Result()
+	logCommand(ctx, "HDEL", hash, start, err)
+	return n > 0, err
+}
+
+// PushDeadLetter implements webhooks.Repository.
+func (t *VoterCache) PushDeadLetter(ctx context.Context, d storage.DeadLetter) error {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return err
+	}
+	sealed, err := s.sealDeadLetter(d)
+	if err != nil {
+		return err
+	}
+	list := ks.key(RedisDeadLetterKey)
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	_, err = t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, list, data)
+		pipe.LTrim(ctx, list, 0, maxDeadLetters-1)
+		return nil
+	})
+	logCommand(ctx, "LPUSH", list, start, err)
+	return err
+}
+
+// ListDeadLetters implements webhooks.Repository, newest first.
+func (t *VoterCache) ListDeadLetters(ctx context.Context) ([]storage.DeadLetter, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := ks.key(RedisDeadLetterKey)
+	start := time.Now()
+	all, err := t.client.LRange(ctx, list, 0, -1).Result()
+	logCommand(ctx, "LRANGE", list, start, err)
+	if err != nil {
+		return nil, err
+	}
+	s, err := t.sealer(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]storage.DeadLetter, 0, len(all))
+	for _, data := range all {
+		stored := deadLetter{}
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			return nil, err
+		}
+		d, err := s.openDeadLetter(ctx, stored)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, d)
+	}
+	return letters, nil
+}
+
+// RemoveDeadLetter implements webhooks.Repository. It takes the dead
+// letter with the id off the list and returns it, or nil when there is
+// none. Two replays of the same letter cannot both get it, LREM only
+// removes it once.
+func (t *VoterCache) RemoveDeadLetter(ctx context.Context, id string) (*storage.DeadLetter, error) {
+	ks, err := t.keyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := ks.key(RedisDeadLetterKey)
+	start := time.Now()
+	all, err := t.client.LRange(ctx, list, 0, -1).Result()
+	logCommand(ctx, "LRANGE", list, start, err)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range all {
+		stored := deadLetter{}
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			return nil, err
+		}
+		if stored.ID != id {
+			continue
+		}
+		s, err := t.sealer(ctx, ks)
+		if err != nil {
+			return nil, err
+		}
+		d, err := s.openDeadLetter(ctx, stored)
+		if err != nil {
+			return nil, err
+		}
+		start = time.Now()
+		n, err := t.client.LRem(ctx, list, 1, data).Result()
+		logCommand(ctx, "LREM", list, start, err)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		return &d, nil
+	}
+	return nil, nil
+}
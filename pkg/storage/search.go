@@ -0,0 +1,26 @@
+package storage
+
+// This is part of the redis Port
+//
+// A VoterQuery searches voters. Every term has to match a word of the
+// name, as a prefix or, with Fuzzy, within one edit of the whole word.
+// Terms are at least two lower case letters or digits. Email matches
+// the whole address regardless of case, and the voter has to have a
+// history in every poll of PollIds. Results are ordered by voter id.
+type VoterQuery struct {
+	Terms   []string
+	Fuzzy   bool
+	Email   string
+	PollIds []int
+	Offset  int
+	Limit   int
+}
+
+// VoterSearchResult holds one page of the voters matching a query.
+// Total and PollFacets count every match, not only the page.
+// PollFacets maps a poll id to how many matching voters took part.
+type VoterSearchResult struct {
+	Total      int
+	Voters     []Voter
+	PollFacets map[int]int
+}
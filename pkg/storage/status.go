@@ -0,0 +1,23 @@
+package storage
+
+// The registration statuses of a voter. A new registration is pending
+// until it was verified, only active voters may vote. Inactive voters
+// have moved or not voted for a while, suspended ones are under
+// review. A purged voter was removed from the roll for good and is only
+// kept for the record.
+const (
+	StatusPending   = "pending"
+	StatusActive    = "active"
+	StatusInactive  = "inactive"
+	StatusSuspended = "suspended"
+	StatusPurged    = "purged"
+)
+
+// StatusOf returns the status of voter. Voters stored before there were
+// statuses count as active, they were all allowed to vote.
+func StatusOf(voter *Voter) string {
+	if voter.Status == "" {
+		return StatusActive
+	}
+	return voter.Status
+}
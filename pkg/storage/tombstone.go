@@ -0,0 +1,16 @@
+package storage
+
+import "time"
+
+// This is part of the redis Port
+//
+// A Tombstone is what is left of a voter that was merged into another
+// one as a duplicate. The voter itself is gone, the tombstone tells
+// where its history went.
+type Tombstone struct {
+	Id         int       `json:"id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	MergedInto int       `json:"merged_into"`
+	MergedAt   time.Time `json:"merged_at"`
+}
@@ -1,5 +1,10 @@
 package storage
 
+import (
+	"strings"
+	"time"
+)
+
 type HistoryMap map[int]VoterHistory
 
 // This is part of the redis Port
@@ -13,4 +18,35 @@ type Voter struct {
 	Name         string     `json:"name"`
 	Email        string     `json:"email"`
 	VoterHistory HistoryMap `json:"history"`
+
+	//PrecinctId is derived from Address, empty when no rule matched
+	Address    *Address `json:"address,omitempty"`
+	PrecinctId string   `json:"precinct_id,omitempty"`
+
+	//BirthDate is a day, midnight UTC. RegisteredAt is when the voter
+	//registered, both are missing on voters stored before they were
+	//recorded.
+	BirthDate    *time.Time `json:"birth_date,omitempty"`
+	RegisteredAt *time.Time `json:"registered_at,omitempty"`
+
+	//Status is one of the Status constants. Voters stored before
+	//there were statuses have none, read it with StatusOf.
+	Status          string     `json:"status,omitempty"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+}
+
+// DateLayout is how a birth date is written
+const DateLayout = "2006-01-02"
+
+// ParseBirthDate reads a birth date, which cannot be after now
+func ParseBirthDate(date string, now time.Time) (*time.Time, error) {
+	day, err := time.Parse(DateLayout, strings.TrimSpace(date))
+	if err != nil {
+		return nil, Invalidf("birth date must look like %s", DateLayout)
+	}
+	if day.After(now) {
+		return nil, Invalidf("birth date cannot be in the future")
+	}
+	return &day, nil
 }
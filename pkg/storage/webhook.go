@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// This is part of the redis Port
+//
+// A Webhook is a subscription of a partner system to the events of
+// the given types, an empty list means all of them. Secret signs the
+// deliveries.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// A DeadLetter is a delivery that failed every attempt. Payload is the
+// body that was sent, so a replay delivers exactly the same event.
+type DeadLetter struct {
+	ID        string          `json:"id"`
+	WebhookID string          `json:"webhook_id"`
+	EventID   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
@@ -0,0 +1,74 @@
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("a secret for the tests")
+
+// sign returns a JWT with the header and claims, signed with HS256 and
+// secret
+func sign(t *testing.T, header map[string]any, claims map[string]any, secret []byte) string {
+	t.Helper()
+	segment := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := segment(header) + "." + segment(claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyHS256(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	valid := map[string]any{"tenant": "bucks", "exp": now.Add(time.Hour).Unix()}
+
+	claims, err := verifyHS256(sign(t, hs256, valid, testSecret), testSecret, now)
+	if err != nil {
+		t.Fatalf("a valid token was refused: %v", err)
+	}
+	if claims["tenant"] != "bucks" {
+		t.Errorf("got the claims %v", claims)
+	}
+
+	//alg none with the signature left off
+	unsigned := sign(t, map[string]any{"alg": "none"}, valid, testSecret)
+	unsigned = unsigned[:strings.LastIndexByte(unsigned, '.')+1]
+
+	//the claims of one token with the signature of another
+	swapped := strings.Split(sign(t, hs256, valid, testSecret), ".")
+	other := strings.Split(sign(t, hs256, map[string]any{"tenant": "montgomery"}, testSecret), ".")
+	swapped[1] = other[1]
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"alg none", unsigned},
+		{"alg none signed", sign(t, map[string]any{"alg": "none"}, valid, testSecret)},
+		{"other algorithm", sign(t, map[string]any{"alg": "HS512"}, valid, testSecret)},
+		{"expired", sign(t, hs256, map[string]any{"tenant": "bucks", "exp": now.Unix()}, testSecret)},
+		{"not valid yet", sign(t, hs256, map[string]any{"tenant": "bucks", "nbf": now.Add(time.Minute).Unix()}, testSecret)},
+		{"other secret", sign(t, hs256, valid, []byte("another secret"))},
+		{"claims swapped", strings.Join(swapped, ".")},
+		{"two parts", "a.b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if claims, err := verifyHS256(test.token, testSecret, now); err == nil {
+				t.Errorf("the token was accepted with the claims %v", claims)
+			}
+		})
+	}
+}
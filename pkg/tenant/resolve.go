@@ -0,0 +1,153 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// The places a tenant can be named in
+const (
+	//SourceHeader is the X-Tenant-ID header, or the x-tenant-id
+	//metadata of a gRPC call
+	SourceHeader = "header"
+
+	//SourceSubdomain is the label in front of the domain of the
+	//deployment, montgomery.voters.example.org for montgomery
+	SourceSubdomain = "subdomain"
+
+	//SourceJWT is a claim of the bearer token, which has to be
+	//signed with HS256 and the secret of the deployment. With it on
+	//every request needs a valid token, the other sources alone are
+	//not trusted.
+	SourceJWT = "jwt"
+)
+
+// Header names the tenant of a request when SourceHeader is on
+const Header = "X-Tenant-ID"
+
+var (
+	// ErrUnresolved is returned when none of the sources names a tenant
+	ErrUnresolved = errors.New("the request does not name a tenant")
+
+	// ErrUnknown is returned for a tenant that is not configured
+	ErrUnknown = errors.New("unknown tenant")
+
+	// ErrMismatch is returned when two sources name different tenants,
+	// a header cannot pick another tenant than the token grants
+	ErrMismatch = errors.New("the request names more than one tenant")
+
+	// ErrInvalidToken is returned for a bearer token that is missing,
+	// malformed, badly signed or expired
+	ErrInvalidToken = errors.New("invalid bearer token")
+)
+
+// Options tell the Resolver where to look
+type Options struct {
+	//Sources are the Source constants to look at. Every source that
+	//names a tenant has to name the same one.
+	Sources []string
+
+	//Domain is the domain of the deployment, for SourceSubdomain
+	Domain string
+
+	//JWTSecret verifies the bearer tokens and JWTClaim is the claim
+	//holding the tenant id, for SourceJWT
+	JWTSecret []byte
+	JWTClaim  string
+}
+
+// Request is what the Resolver looks at, the inbound Port fills it in
+type Request struct {
+	//Header is the value of the X-Tenant-ID header
+	Header string
+
+	//Host is the host the request was sent to, with or without port
+	Host string
+
+	//Authorization is the value of the Authorization header
+	Authorization string
+}
+
+// Resolver finds the tenant of a request
+type Resolver struct {
+	tenants *Tenants
+	options Options
+}
+
+func NewResolver(tenants *Tenants, options Options) *Resolver {
+	options.Domain = strings.ToLower(strings.Trim(options.Domain, "."))
+	return &Resolver{tenants, options}
+}
+
+// Tenants returns the tenants the resolver knows
+func (r *Resolver) Tenants() *Tenants {
+	return r.tenants
+}
+
+// Resolve returns the tenant named by req
+func (r *Resolver) Resolve(req Request) (Tenant, error) {
+	id := ""
+	for _, source := range r.options.Sources {
+		found, err := r.lookup(source, req)
+		if err != nil {
+			return Tenant{}, err
+		}
+		if found == "" {
+			continue
+		}
+		if id != "" && found != id {
+			return Tenant{}, ErrMismatch
+		}
+		id = found
+	}
+	if id == "" {
+		return Tenant{}, ErrUnresolved
+	}
+	t, ok := r.tenants.Get(id)
+	if !ok {
+		return Tenant{}, fmt.Errorf("%w %q", ErrUnknown, id)
+	}
+	return t, nil
+}
+
+// lookup returns the tenant id named in one source, "" if there is none
+func (r *Resolver) lookup(source string, req Request) (string, error) {
+	switch source {
+	case SourceHeader:
+		return strings.TrimSpace(req.Header), nil
+	case SourceSubdomain:
+		return r.subdomain(req.Host), nil
+	case SourceJWT:
+		token, ok := strings.CutPrefix(req.Authorization, "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			return "", fmt.Errorf("%w: the request has none", ErrInvalidToken)
+		}
+		claims, err := verifyHS256(strings.TrimSpace(token), r.options.JWTSecret, time.Now())
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+		}
+		id, _ := claims[r.options.JWTClaim].(string)
+		return id, nil
+	}
+	return "", fmt.Errorf("unknown tenant source %q", source)
+}
+
+// subdomain returns the label right in front of the domain, "" for the
+// domain itself or another host
+func (r *Resolver) subdomain(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	prefix, ok := strings.CutSuffix(host, "."+r.options.Domain)
+	if !ok || r.options.Domain == "" {
+		return ""
+	}
+	if i := strings.LastIndexByte(prefix, '.'); i >= 0 {
+		prefix = prefix[i+1:]
+	}
+	return prefix
+}
@@ -0,0 +1,99 @@
+package tenant
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testResolver(t *testing.T, sources ...string) *Resolver {
+	t.Helper()
+	tenants, err := New([]Tenant{{Id: "montgomery"}, {Id: "bucks"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewResolver(tenants, Options{
+		Sources:   sources,
+		Domain:    "voters.example.org",
+		JWTSecret: testSecret,
+		JWTClaim:  "tenant",
+	})
+}
+
+func bearer(t *testing.T, id string) string {
+	t.Helper()
+	claims := map[string]any{"tenant": id, "exp": time.Now().Add(time.Hour).Unix()}
+	return "Bearer " + sign(t, map[string]any{"alg": "HS256"}, claims, testSecret)
+}
+
+func TestResolve(t *testing.T) {
+	r := testResolver(t, SourceHeader, SourceSubdomain, SourceJWT)
+	untrusted := testResolver(t, SourceHeader, SourceSubdomain)
+	tests := []struct {
+		name string
+		r    *Resolver
+		req  Request
+		want string
+	}{
+		{"header", untrusted, Request{Header: "bucks"}, "bucks"},
+		{"subdomain with port", untrusted, Request{Host: "Montgomery.voters.example.org:8080"}, "montgomery"},
+		{"the domain itself", untrusted, Request{Header: "bucks", Host: "voters.example.org"}, "bucks"},
+		{"token", r, Request{Authorization: bearer(t, "bucks")}, "bucks"},
+		{"all agree", r, Request{Header: "bucks", Host: "bucks.voters.example.org", Authorization: bearer(t, "bucks")}, "bucks"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.r.Resolve(test.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Id != test.want {
+				t.Errorf("got tenant %q, want %q", got.Id, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveRefuses(t *testing.T) {
+	r := testResolver(t, SourceHeader, SourceSubdomain, SourceJWT)
+	untrusted := testResolver(t, SourceHeader, SourceSubdomain)
+	tests := []struct {
+		name string
+		r    *Resolver
+		req  Request
+		want error
+	}{
+		{"header and token disagree", r, Request{Header: "montgomery", Authorization: bearer(t, "bucks")}, ErrMismatch},
+		{"header and subdomain disagree", untrusted, Request{Header: "montgomery", Host: "bucks.voters.example.org"}, ErrMismatch},
+		{"subdomain and token disagree", r, Request{Host: "bucks.voters.example.org", Authorization: bearer(t, "montgomery")}, ErrMismatch},
+		{"unknown in the header", untrusted, Request{Header: "chester"}, ErrUnknown},
+		{"unknown in the subdomain", untrusted, Request{Host: "chester.voters.example.org"}, ErrUnknown},
+		{"unknown in the token", r, Request{Authorization: bearer(t, "chester")}, ErrUnknown},
+		{"no tenant", untrusted, Request{Host: "example.org"}, ErrUnresolved},
+		{"bad token", r, Request{Header: "bucks", Authorization: "Bearer a.b.c"}, ErrInvalidToken},
+		{"header without token", r, Request{Header: "bucks"}, ErrInvalidToken},
+		{"subdomain without token", r, Request{Host: "bucks.voters.example.org"}, ErrInvalidToken},
+		{"empty bearer", r, Request{Header: "bucks", Authorization: "Bearer "}, ErrInvalidToken},
+		{"another scheme", r, Request{Header: "bucks", Authorization: "Basic YnVja3M6"}, ErrInvalidToken},
+		{"nothing with jwt", r, Request{}, ErrInvalidToken},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.r.Resolve(test.req)
+			if !errors.Is(err, test.want) {
+				t.Errorf("got tenant %q and error %v, want %v", got.Id, err, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveOnlyLooksAtItsSources(t *testing.T) {
+	r := testResolver(t, SourceJWT)
+	got, err := r.Resolve(Request{Header: "montgomery", Authorization: bearer(t, "bucks")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != "bucks" {
+		t.Errorf("got tenant %q, want the one of the token", got.Id)
+	}
+}
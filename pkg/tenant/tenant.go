@@ -0,0 +1,165 @@
+package tenant
+
+//The tenant package describes the jurisdictions, counties for example,
+//that one deployment serves. Like the principal of the auth package,
+//the tenant of a request is resolved once by the inbound Port and
+//stored in the request context. The repository reads it from there and
+//keeps the data of every tenant under keys of its own, nothing further
+//down takes a tenant as an argument.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tenant is a jurisdiction served by this deployment, with the settings
+// that differ between jurisdictions
+type Tenant struct {
+	//Id names the tenant in the X-Tenant-ID header, the subdomain,
+	//the claim of a token and the keys of its data
+	Id   string `yaml:"id"`
+	Name string `yaml:"name"`
+
+	//MaxVoters caps how many voters the tenant may register, 0 means
+	//no cap
+	MaxVoters int `yaml:"max_voters"`
+
+	//RateLimit replaces the limits of the server for the clients of
+	//the tenant, a group left out keeps the limit of the server
+	RateLimit RateLimit `yaml:"rate_limit"`
+
+	//TimeZone is the IANA time zone of the jurisdiction, the days and
+	//open hours of its polls are in it. Empty keeps the time zone of
+	//the deployment, eligibility.time_zone.
+	TimeZone string `yaml:"time_zone"`
+	loc      *time.Location
+}
+
+type RateLimit struct {
+	Read  *Rate `yaml:"read"`
+	Write *Rate `yaml:"write"`
+}
+
+// Rate is a token bucket like config.RateRule
+type Rate struct {
+	PerMinute int `yaml:"per_minute"`
+	Burst     int `yaml:"burst"`
+}
+
+// validId keeps ids usable as a DNS label and inside a redis key,
+// without the glob characters of KEYS and SCAN
+var validId = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Tenants are the tenants of a deployment, loaded from a YAML file:
+//
+//	tenants:
+//	  - id: montgomery
+//	    name: Montgomery County
+//	    max_voters: 600000
+//	    time_zone: America/New_York
+//	  - id: bucks
+//	    name: Bucks County
+//	    rate_limit:
+//	      write:
+//	        per_minute: 60
+//	        burst: 10
+type Tenants struct {
+	byId map[string]Tenant
+}
+
+// Load reads the tenants in path
+func Load(path string) (*Tenants, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Tenants []Tenant `yaml:"tenants"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	tenants, err := New(file.Tenants)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tenants, nil
+}
+
+// New checks the tenants, there has to be at least one
+func New(list []Tenant) (*Tenants, error) {
+	if len(list) == 0 {
+		return nil, fmt.Errorf("there are no tenants")
+	}
+	tenants := &Tenants{byId: make(map[string]Tenant, len(list))}
+	for i, t := range list {
+		if !validId.MatchString(t.Id) {
+			return nil, fmt.Errorf("tenant %d: the id %q must be lower case letters, digits and dashes", i+1, t.Id)
+		}
+		if _, exists := tenants.byId[t.Id]; exists {
+			return nil, fmt.Errorf("tenant %s: the id is used twice", t.Id)
+		}
+		if t.MaxVoters < 0 {
+			return nil, fmt.Errorf("tenant %s: max_voters cannot be negative", t.Id)
+		}
+		for _, rate := range []*Rate{t.RateLimit.Read, t.RateLimit.Write} {
+			if rate != nil && (rate.PerMinute < 1 || rate.Burst < 1) {
+				return nil, fmt.Errorf("tenant %s: rate_limit per_minute and burst must be at least 1", t.Id)
+			}
+		}
+		if t.TimeZone != "" {
+			loc, err := time.LoadLocation(t.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: time_zone must be an IANA time zone such as America/New_York, got %q", t.Id, t.TimeZone)
+			}
+			t.loc = loc
+		}
+		tenants.byId[t.Id] = t
+	}
+	return tenants, nil
+}
+
+// Get returns the tenant with the id
+func (t *Tenants) Get(id string) (Tenant, bool) {
+	tenant, ok := t.byId[id]
+	return tenant, ok
+}
+
+// All returns the tenants ordered by id
+func (t *Tenants) All() []Tenant {
+	all := make([]Tenant, 0, len(t.byId))
+	for _, tenant := range t.byId {
+		all = append(all, tenant)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Id < all[j].Id })
+	return all
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying t.
+func WithTenant(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// From returns the tenant stored in ctx. ok is false when tenancy is
+// off or the tenant was not resolved.
+func From(ctx context.Context) (t Tenant, ok bool) {
+	t, ok = ctx.Value(tenantKey{}).(Tenant)
+	return t, ok
+}
+
+// Location returns the time zone of the tenant in ctx, fallback when
+// tenancy is off or the tenant has no time zone of its own
+func Location(ctx context.Context, fallback *time.Location) *time.Location {
+	if t, ok := From(ctx); ok && t.loc != nil {
+		return t.loc
+	}
+	return fallback
+}
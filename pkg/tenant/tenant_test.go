@@ -0,0 +1,40 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocation(t *testing.T) {
+	tenants, err := New([]Tenant{{Id: "montgomery", TimeZone: "America/New_York"}, {Id: "bucks"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback := time.FixedZone("deployment", 3600)
+
+	montgomery, _ := tenants.Get("montgomery")
+	bucks, _ := tenants.Get("bucks")
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"own time zone", WithTenant(context.Background(), montgomery), "America/New_York"},
+		{"no time zone", WithTenant(context.Background(), bucks), "deployment"},
+		{"no tenant", context.Background(), "deployment"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Location(test.ctx, fallback).String(); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewRefusesUnknownTimeZones(t *testing.T) {
+	if _, err := New([]Tenant{{Id: "bucks", TimeZone: "America/Bucks"}}); err == nil {
+		t.Error("an unknown time zone was accepted")
+	}
+}
@@ -1,9 +1,12 @@
 package update
 
 import (
-	"errors"
+	"context"
 	"strings"
+	"time"
 
+	"drexel.edu/voter-api/pkg/logging"
+	"drexel.edu/voter-api/pkg/metrics"
 	"drexel.edu/voter-api/pkg/storage"
 )
 
@@ -80,8 +83,12 @@ we want to create them. So lets create an interface for that.
 type Adapter interface {
 	//Make sure you capitalize these to make them public or you
 	//won't be able to use them!
-	UpdateVoter(Voter) error
-	UpdateVoterHistory(int, VoterHistory) error
+	UpdateVoter(context.Context, Voter) error
+	UpdateVoterHistory(context.Context, int, VoterHistory) error
+
+	//Transition moves a voter to another registration status, if the
+	//state machine allows it
+	Transition(context.Context, int, Transition) error
 }
 
 /**
@@ -108,7 +115,7 @@ type Repository interface {
 
 	//We will need a get function to check if the
 	//voter exists for the history we are about to add.
-	GetItem(int) (*storage.Voter, error)
+	GetItem(context.Context, int) (*storage.Voter, error)
 
 	//We will need the update function to add new Voter
 	//History. remember that there is only one Voter storage
@@ -116,12 +123,21 @@ type Repository interface {
 	//object. so when we add new history we are actually fetching
 	//an existing voter, editing the voterHistory map, and saving
 	//voter which counts as an update.
-	UpdateItem(*storage.Voter) error
+	UpdateItem(context.Context, *storage.Voter) error
+}
+
+// Polls knows the open hours of the polls, see eligibility.Adapter. It
+// returns the date of a vote in UTC or an error if the poll was not
+// open at the time.
+type Polls interface {
+	VoteDate(context.Context, int, time.Time) (time.Time, error)
 }
 
 // Now we create a struct to implement the Adapter interface
 type adapter struct {
-	r Repository
+	r         Repository
+	precincts storage.Assigner
+	polls     Polls
 }
 
 //Our struct will need a New function so that things
@@ -143,8 +159,8 @@ the correctness of the program. In other words, if a piece of code
 works with a particular type, it should also work correctly when
 the code is replaced with a subtype of that type.
 **/
-func New(r Repository) Adapter {
-	return &adapter{r}
+func New(r Repository, precincts storage.Assigner, polls Polls) Adapter {
+	return &adapter{r, precincts, polls}
 }
 
 /**
@@ -168,12 +184,15 @@ and createVoterHistory functions.
 // do this the compiler wouldn't make the association and throw
 // the same error we talked about above. Make sure you remeber this
 // as it will pop up in all of your adapter! its very important!!!!
-func (a *adapter) UpdateVoter(voter Voter) error {
+func (a *adapter) UpdateVoter(ctx context.Context, voter Voter) (err error) {
+	defer logging.Mutation(ctx, "update voter", time.Now(), &err, voter.Id, 0)
+	defer metrics.Operation("update voter", &err)
+
 	//before we do anything, lets handle some validation
 	//lets make sure that the id exists (i.e., != 0) and
 	//that the Voter's name and email isn't blank.
 	if voter.Id == 0 {
-		return errors.New("invalid Voter Id")
+		return storage.Invalidf("invalid Voter Id")
 	}
 
 	//Note: there are two functions being used in tandem.
@@ -187,7 +206,7 @@ func (a *adapter) UpdateVoter(voter Voter) error {
 	//Unicode. If the string is empty or only has whitespace,
 	//we will get a 0 as a result and end up throwing an error
 	if len(strings.TrimSpace(voter.Name)) == 0 {
-		return errors.New("voter name cannot be blank")
+		return storage.Invalidf("voter name cannot be blank")
 	}
 
 	//Do the same for Email... Extra Credit. find a way to
@@ -197,18 +216,37 @@ func (a *adapter) UpdateVoter(voter Voter) error {
 	//
 	//hint: google "Regex"
 	if len(strings.TrimSpace(voter.Email)) == 0 {
-		return errors.New("voter email cannot be blank")
+		return storage.Invalidf("voter email cannot be blank")
+	}
+
+	if voter.Address != nil {
+		if err := storage.CheckAddress(storage.Address(*voter.Address)); err != nil {
+			return err
+		}
 	}
 
 	//Now that we have done some basic data validation and
 	//we are confident that we have a valid Voter object, we
-	//are in the clear to add it to a repository. So let's
-	//convert it into a storage object
-
-	storageObject := storage.Voter{
-		Id:    voter.Id,
-		Name:  voter.Name,
-		Email: voter.Email,
+	//are in the clear to add it to a repository. An update only
+	//changes the name, the email and, if one is given, the
+	//address, so we start from the stored voter to keep its
+	//history and its status.
+	storageObject, err := a.r.GetItem(ctx, voter.Id)
+	if err != nil {
+		return err
+	}
+	if storage.StatusOf(storageObject) == storage.StatusPurged {
+		return storage.Conflictf("voter %d was purged and cannot be changed", voter.Id)
+	}
+	storageObject.Name = voter.Name
+	storageObject.Email = voter.Email
+	if voter.BirthDate != "" {
+		if storageObject.BirthDate, err = storage.ParseBirthDate(voter.BirthDate, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+	if voter.Address != nil {
+		storage.SetAddress(storageObject, storage.Address(*voter.Address), a.precincts)
 	}
 
 	//that now. Notice that we did this in the method signature
@@ -217,7 +255,7 @@ func (a *adapter) UpdateVoter(voter Voter) error {
 	//but it is also so this function, as a member of adapter can
 	//access its private methods and variables... In this case
 	//we want to use a to access r, the repsoitory. lets try it out.
-	err := a.r.UpdateItem(&storageObject)
+	err = a.r.UpdateItem(ctx, storageObject)
 	if err != nil {
 		return err
 	}
@@ -235,12 +273,14 @@ func (a *adapter) UpdateVoter(voter Voter) error {
 // Note: Please make sure you understand createVoter (above) before
 // you read this. this function is going to be a tad lighter on
 // explanations
-func (a *adapter) UpdateVoterHistory(voterId int, voterHistory VoterHistory) error {
+func (a *adapter) UpdateVoterHistory(ctx context.Context, voterId int, voterHistory VoterHistory) (err error) {
+	defer logging.Mutation(ctx, "update voter history", time.Now(), &err, voterId, voterHistory.PollId)
+	defer metrics.Operation("update voter history", &err)
 
 	//first off, lets check if the Voter exists. If not, no need to
 	//proceed with validation. we can use the repository to retrieve
 	//the voter.
-	targetVoter, err := a.r.GetItem(voterId)
+	targetVoter, err := a.r.GetItem(ctx, voterId)
 	if err != nil {
 		return err
 	}
@@ -250,24 +290,38 @@ func (a *adapter) UpdateVoterHistory(voterId int, voterHistory VoterHistory) err
 	//throw an error. If it was an update port, we would just update
 	//it.
 	if _, exists := targetVoter.VoterHistory[voterHistory.PollId]; !exists {
-		return errors.New("the specified pollId does not exists inside the voter")
+		return storage.NotFoundf("the specified pollId does not exists inside the voter")
+	}
+
+	//A vote without a date happens now. Dates are stored in UTC and
+	//have to fall within the open hours of the poll.
+	voteDate := voterHistory.VoteDate
+	if a.polls == nil {
+		if voteDate.IsZero() {
+			voteDate = time.Now()
+		}
+		voteDate = voteDate.UTC()
+	} else if voteDate, err = a.polls.VoteDate(ctx, voterHistory.PollId, voteDate); err != nil {
+		return err
 	}
 
-	//Now that we know the pollId doesn't already exist within voter,
-	//we just have to convert the history to the storage format, add
-	//and add it to the voter.
+	//Now that we know the pollId already exists within voter, we
+	//just have to convert the history to the storage format and
+	//put it in the voter.
 
 	storageObject := storage.VoterHistory{
 		PollId:   voterHistory.PollId,
 		VoteId:   voterHistory.VoteId,
-		VoteDate: voterHistory.VoteDate,
+		VoteDate: voteDate,
 	}
 
 	targetVoter.VoterHistory[voterHistory.PollId] = storageObject
 
 	//now we just need to add it back into redis
 
-	a.r.UpdateItem(targetVoter)
+	if err = a.r.UpdateItem(ctx, targetVoter); err != nil {
+		return err
+	}
 
 	//Now that we have defined our adapter on the create port,
 	//we have to define an adapter on the redis port. lets jump
@@ -275,3 +329,38 @@ func (a *adapter) UpdateVoterHistory(voterId int, voterHistory VoterHistory) err
 
 	return nil
 }
+
+// Transition changes the status of a voter. Moving to the status the
+// voter already has is refused too, the reason on record would no
+// longer match how the voter got there.
+func (a *adapter) Transition(ctx context.Context, voterId int, transition Transition) (err error) {
+	defer logging.Mutation(ctx, "transition voter", time.Now(), &err, voterId, 0)
+	defer metrics.Operation("transition voter", &err)
+
+	if voterId < 1 {
+		return storage.Invalidf("invalid Voter Id")
+	}
+	if _, known := transitions[transition.Status]; !known {
+		return storage.Invalidf("unknown status %q", transition.Status)
+	}
+	reason := strings.TrimSpace(transition.Reason)
+	if len(reason) == 0 {
+		return storage.Invalidf("a reason is required to change the status of a voter")
+	}
+
+	targetVoter, err := a.r.GetItem(ctx, voterId)
+	if err != nil {
+		return err
+	}
+
+	from := storage.StatusOf(targetVoter)
+	if !canTransition(from, transition.Status) {
+		return storage.Conflictf("voter %d is %s and cannot become %s", voterId, from, transition.Status)
+	}
+
+	targetVoter.Status = transition.Status
+	targetVoter.StatusReason = reason
+	now := time.Now().UTC()
+	targetVoter.StatusChangedAt = &now
+	return a.r.UpdateItem(ctx, targetVoter)
+}
@@ -0,0 +1,41 @@
+package update
+
+import "drexel.edu/voter-api/pkg/storage"
+
+// This is part of the update Port!!!
+//
+// A Transition moves a voter from one registration status to another.
+// The reason is kept with the voter, it is what a clerk reads when
+// asking why someone may not vote.
+type Transition struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// transitions is the state machine of the registration status, the
+// statuses a voter may move to from each status. Purged is final.
+var transitions = map[string][]string{
+	storage.StatusPending:   {storage.StatusActive, storage.StatusPurged},
+	storage.StatusActive:    {storage.StatusInactive, storage.StatusSuspended, storage.StatusPurged},
+	storage.StatusInactive:  {storage.StatusActive, storage.StatusSuspended, storage.StatusPurged},
+	storage.StatusSuspended: {storage.StatusActive, storage.StatusInactive, storage.StatusPurged},
+	storage.StatusPurged:    {},
+}
+
+// Actions are the verbs of the transition endpoints, each names the
+// status it moves a voter to
+var Actions = map[string]string{
+	"activate":   storage.StatusActive,
+	"deactivate": storage.StatusInactive,
+	"suspend":    storage.StatusSuspended,
+	"purge":      storage.StatusPurged,
+}
+
+func canTransition(from, to string) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
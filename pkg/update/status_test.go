@@ -0,0 +1,47 @@
+package update
+
+import (
+	"testing"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+func TestTransitions(t *testing.T) {
+	statuses := []string{storage.StatusPending, storage.StatusActive, storage.StatusInactive, storage.StatusSuspended, storage.StatusPurged}
+	allowed := map[[2]string]bool{
+		{storage.StatusPending, storage.StatusActive}:     true,
+		{storage.StatusPending, storage.StatusPurged}:     true,
+		{storage.StatusActive, storage.StatusInactive}:    true,
+		{storage.StatusActive, storage.StatusSuspended}:   true,
+		{storage.StatusActive, storage.StatusPurged}:      true,
+		{storage.StatusInactive, storage.StatusActive}:    true,
+		{storage.StatusInactive, storage.StatusSuspended}: true,
+		{storage.StatusInactive, storage.StatusPurged}:    true,
+		{storage.StatusSuspended, storage.StatusActive}:   true,
+		{storage.StatusSuspended, storage.StatusInactive}: true,
+		{storage.StatusSuspended, storage.StatusPurged}:   true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			if got := canTransition(from, to); got != want {
+				t.Errorf("canTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	//nothing leaves purged and an unknown status goes nowhere
+	for _, to := range append(statuses, "") {
+		if canTransition(storage.StatusPurged, to) {
+			t.Errorf("a purged voter may become %q", to)
+		}
+		if canTransition("", to) || canTransition("deleted", to) {
+			t.Errorf("an unknown status may become %q", to)
+		}
+	}
+	for _, to := range Actions {
+		if _, ok := transitions[to]; !ok || to == storage.StatusPending {
+			t.Errorf("the action to %s has no state", to)
+		}
+	}
+}
@@ -15,4 +15,19 @@ type Voter struct {
 	Name         string `json:"name"`
 	Email        string `json:"email"`
 	VoterHistory HistoryMap
+
+	//Address assigns the voter to a precinct
+	Address *Address `json:"address"`
+
+	//BirthDate is a day, 2006-01-02
+	BirthDate string `json:"birth_date"`
+}
+
+type Address struct {
+	Number int    `json:"number"`
+	Street string `json:"street"`
+	Unit   string `json:"unit"`
+	City   string `json:"city"`
+	State  string `json:"state"`
+	Zip    string `json:"zip"`
 }
@@ -0,0 +1,252 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+// The webhooks Port lets partner systems subscribe to the changes to
+// voters. The subscriptions are managed through the Adapter, the
+// Dispatcher delivers the events to them.
+
+var (
+	ErrNotFound = errors.New("webhook not found")
+
+	// ErrInvalid wraps every problem with a subscription in a request
+	ErrInvalid = errors.New("invalid webhook")
+)
+
+// minSecretLength keeps secrets chosen by the client guessable only in
+// theory
+const minSecretLength = 16
+
+// eventTypes are the types a subscription may ask for
+var eventTypes = []string{
+	storage.EventVoterCreated,
+	storage.EventVoterUpdated,
+	storage.EventVoterDeleted,
+	storage.EventVoterStatusChanged,
+	storage.EventHistoryCreated,
+	storage.EventHistoryUpdated,
+	storage.EventHistoryDeleted,
+}
+
+type Adapter interface {
+	//Create stores a new subscription. A secret is generated when
+	//none is given, the result is the only time it is returned.
+	Create(context.Context, Subscription) (Subscription, error)
+	Get(context.Context, string) (Subscription, error)
+	List(context.Context) ([]Subscription, error)
+
+	//Update replaces the url and the events of a subscription. The
+	//secret is rotated when a new one is given.
+	Update(context.Context, string, Subscription) (Subscription, error)
+	Delete(context.Context, string) error
+
+	//DeadLetters lists the deliveries that failed, newest first
+	DeadLetters(context.Context) ([]DeadLetter, error)
+
+	//Replay takes a dead letter off the list and delivers it again,
+	//with a fresh set of attempts
+	Replay(context.Context, string) error
+}
+
+type Repository interface {
+	SaveWebhook(context.Context, storage.Webhook) error
+	GetWebhook(context.Context, string) (*storage.Webhook, error)
+	ListWebhooks(context.Context) ([]storage.Webhook, error)
+	DeleteWebhook(context.Context, string) (bool, error)
+
+	PushDeadLetter(context.Context, storage.DeadLetter) error
+	ListDeadLetters(context.Context) ([]storage.DeadLetter, error)
+	RemoveDeadLetter(context.Context, string) (*storage.DeadLetter, error)
+}
+
+type adapter struct {
+	r Repository
+	d *Dispatcher
+}
+
+// New returns a webhooks Adapter. Replays are handed to d.
+func New(r Repository, d *Dispatcher) Adapter {
+	return &adapter{r, d}
+}
+
+func (a *adapter) Create(ctx context.Context, s Subscription) (Subscription, error) {
+	if err := validate(s, a.d.options.AllowPrivate); err != nil {
+		return Subscription{}, err
+	}
+	if s.Secret == "" {
+		secret, err := randomID(32)
+		if err != nil {
+			return Subscription{}, err
+		}
+		s.Secret = secret
+	}
+	id, err := randomID(16)
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	w := storage.Webhook{
+		ID:        id,
+		URL:       s.URL,
+		Events:    s.Events,
+		Secret:    s.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := a.r.SaveWebhook(ctx, w); err != nil {
+		return Subscription{}, err
+	}
+	result := fromStorage(w)
+	result.Secret = w.Secret
+	return result, nil
+}
+
+func (a *adapter) Get(ctx context.Context, id string) (Subscription, error) {
+	w, err := a.r.GetWebhook(ctx, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if w == nil {
+		return Subscription{}, ErrNotFound
+	}
+	return fromStorage(*w), nil
+}
+
+func (a *adapter) List(ctx context.Context) ([]Subscription, error) {
+	stored, err := a.r.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := make([]Subscription, len(stored))
+	for i, w := range stored {
+		subscriptions[i] = fromStorage(w)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions, nil
+}
+
+func (a *adapter) Update(ctx context.Context, id string, s Subscription) (Subscription, error) {
+	if err := validate(s, a.d.options.AllowPrivate); err != nil {
+		return Subscription{}, err
+	}
+	w, err := a.r.GetWebhook(ctx, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if w == nil {
+		return Subscription{}, ErrNotFound
+	}
+
+	w.URL = s.URL
+	w.Events = s.Events
+	if s.Secret != "" {
+		w.Secret = s.Secret
+	}
+	if err := a.r.SaveWebhook(ctx, *w); err != nil {
+		return Subscription{}, err
+	}
+	result := fromStorage(*w)
+	result.Secret = s.Secret
+	return result, nil
+}
+
+func (a *adapter) Delete(ctx context.Context, id string) error {
+	deleted, err := a.r.DeleteWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (a *adapter) DeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	stored, err := a.r.ListDeadLetters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]DeadLetter, len(stored))
+	for i, d := range stored {
+		letters[i] = DeadLetter(d)
+	}
+	return letters, nil
+}
+
+func (a *adapter) Replay(ctx context.Context, id string) error {
+	letter, err := a.r.RemoveDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+	if letter == nil {
+		return ErrNotFound
+	}
+
+	w, err := a.r.GetWebhook(ctx, letter.WebhookID)
+	if err == nil && w == nil {
+		err = fmt.Errorf("%w: the subscription %s was deleted", ErrNotFound, letter.WebhookID)
+	}
+	if err == nil {
+		err = a.d.enqueue(delivery{
+			webhook:   *w,
+			eventID:   letter.EventID,
+			eventType: letter.EventType,
+			payload:   letter.Payload,
+			origin:    context.WithoutCancel(ctx),
+		})
+	}
+	if err != nil {
+		//put it back so it can be replayed later
+		if pushErr := a.r.PushDeadLetter(ctx, *letter); pushErr != nil {
+			return errors.Join(err, pushErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func validate(s Subscription, allowPrivate bool) error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https url", ErrInvalid)
+	}
+	//names are checked again when they are dialed, they may resolve
+	//to something else by then
+	if !allowPrivate && !publicHost(u.Hostname()) {
+		return fmt.Errorf("%w: url must not point at a private, loopback or link-local address", ErrInvalid)
+	}
+	for _, event := range s.Events {
+		if !slices.Contains(eventTypes, event) {
+			return fmt.Errorf("%w: unknown event %q, expected one of %v", ErrInvalid, event, eventTypes)
+		}
+	}
+	if s.Secret != "" && len(s.Secret) < minSecretLength {
+		return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalid, minSecretLength)
+	}
+	return nil
+}
+
+// fromStorage leaves the secret out, it is only returned when it is set
+func fromStorage(w storage.Webhook) Subscription {
+	events := w.Events
+	if events == nil {
+		events = []string{}
+	}
+	return Subscription{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    events,
+		CreatedAt: w.CreatedAt,
+	}
+}
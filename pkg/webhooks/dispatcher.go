@@ -0,0 +1,309 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"drexel.edu/voter-api/pkg/events"
+	"drexel.edu/voter-api/pkg/metrics"
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+const (
+	//maxBackoff caps the wait between two attempts of a delivery
+	maxBackoff = time.Minute
+
+	//parkTimeout bounds parking a delivery on shutdown, when the
+	//context of the dispatcher is already cancelled
+	parkTimeout = 5 * time.Second
+)
+
+// ErrBusy is returned by a replay when the delivery queue is full or
+// the dispatcher is not running.
+var ErrBusy = errors.New("the webhook dispatcher is busy or stopped, try again later")
+
+// Options tune the delivery of webhooks.
+type Options struct {
+	//MaxAttempts is how often a delivery is tried before it is
+	//parked in the dead-letter list
+	MaxAttempts int
+
+	//InitialBackoff is the wait after the first failed attempt, it
+	//doubles with every further attempt
+	InitialBackoff time.Duration
+
+	//Timeout bounds a single attempt
+	Timeout time.Duration
+
+	//Workers is how many deliveries run at once
+	Workers int
+
+	//AllowPrivate lets subscriptions reach private, loopback and
+	//link-local addresses, which are refused otherwise
+	AllowPrivate bool
+}
+
+type delivery struct {
+	webhook   storage.Webhook
+	eventID   string
+	eventType string
+	payload   []byte
+
+	//attempts is how often the delivery was tried so far, backoff the
+	//wait before the next try
+	attempts int
+	backoff  time.Duration
+
+	//done is told after the first attempt, once the delivery
+	//succeeded, was parked or waits for its retry. Nil for replays
+	//and retries.
+	done *sync.WaitGroup
+
+	//origin is the context the delivery was queued from, without its
+	//cancellation. The workers are shared, parking the delivery uses
+	//the values of origin, such as the tenant, to reach the right
+	//dead-letter list.
+	origin context.Context
+}
+
+// Dispatcher is the outbox.Sink that POSTs every event to the
+// subscriptions that asked for it. A delivery that still fails after
+// MaxAttempts ends up in the dead-letter list.
+type Dispatcher struct {
+	r       Repository
+	client  *http.Client
+	options Options
+	queue   chan delivery
+
+	//retries counts the deliveries waiting for their backoff
+	retries sync.WaitGroup
+
+	//stopped is set once Run drains the queue, replays are refused
+	//from then on
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func NewDispatcher(r Repository, options Options) *Dispatcher {
+	return &Dispatcher{
+		r:       r,
+		client:  newClient(options),
+		options: options,
+		queue:   make(chan delivery, options.Workers*10),
+	}
+}
+
+// Run works off the queued deliveries until ctx is cancelled. It
+// returns once every delivery in flight finished or was parked in the
+// dead-letter list, so the repository must stay open until then.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job := <-d.queue:
+					d.attempt(ctx, job)
+					if job.done != nil {
+						job.done.Done()
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	//the retries waiting for their backoff park themselves now
+	d.retries.Wait()
+
+	//nothing takes from the queue anymore, park what is left
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+	for {
+		select {
+		case job := <-d.queue:
+			d.park(ctx, job, job.attempts, errors.New("the dispatcher stopped before the delivery"))
+			if job.done != nil {
+				job.done.Done()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Handle implements outbox.Sink. It queues a delivery of every event
+// to each subscription that asked for it and returns once each of them
+// was tried once. A failed delivery is retried in the background, so a
+// slow or broken receiver does not hold up the relay for its whole
+// backoff. Retries still waiting when the dispatcher stops are parked
+// in the dead-letter list.
+func (d *Dispatcher) Handle(ctx context.Context, found []events.Event) error {
+	webhooks, err := d.r.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	payloads := make([][]byte, len(found))
+	for i, event := range found {
+		if payloads[i], err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, event := range found {
+		payload := payloads[i]
+		for _, w := range webhooks {
+			if len(w.Events) > 0 && !slices.Contains(w.Events, event.Type) {
+				continue
+			}
+			wg.Add(1)
+			d.queueOrPark(ctx, delivery{webhook: w, eventID: event.ID, eventType: event.Type, payload: payload, done: &wg, origin: context.WithoutCancel(ctx)})
+		}
+	}
+	wg.Wait()
+	return nil
+}
+
+// queueOrPark waits for room in the queue, the delivery is parked right
+// away once the dispatcher is stopping
+func (d *Dispatcher) queueOrPark(ctx context.Context, job delivery) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if !d.stopped {
+		select {
+		case d.queue <- job:
+			return
+		case <-ctx.Done():
+		}
+	}
+	d.park(ctx, job, job.attempts, errors.New("the dispatcher stopped before the delivery"))
+	job.done.Done()
+}
+
+// enqueue hands a replayed delivery to the workers without waiting
+func (d *Dispatcher) enqueue(job delivery) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.stopped {
+		return ErrBusy
+	}
+	select {
+	case d.queue <- job:
+		return nil
+	default:
+		return ErrBusy
+	}
+}
+
+// attempt tries a delivery once. A failed attempt is queued again
+// after an exponential backoff, without holding up the worker, and the
+// delivery is parked once MaxAttempts failed.
+func (d *Dispatcher) attempt(ctx context.Context, job delivery) {
+	job.attempts++
+	err := d.send(ctx, job)
+	if err == nil {
+		metrics.WebhookDelivery("delivered")
+		return
+	}
+	metrics.WebhookDelivery("failed")
+	slog.WarnContext(ctx, "webhook delivery failed",
+		"webhook", job.webhook.ID, "event", job.eventID, "attempt", job.attempts, "error", err)
+	if job.attempts >= d.options.MaxAttempts || ctx.Err() != nil {
+		d.park(ctx, job, job.attempts, err)
+		return
+	}
+
+	if job.backoff == 0 {
+		job.backoff = d.options.InitialBackoff
+	} else {
+		job.backoff = min(job.backoff*2, maxBackoff)
+	}
+	job.done = nil
+	d.retries.Add(1)
+	go func() {
+		defer d.retries.Done()
+		select {
+		case <-time.After(job.backoff):
+			select {
+			case d.queue <- job:
+				return
+			case <-ctx.Done():
+			}
+		case <-ctx.Done():
+		}
+		d.park(ctx, job, job.attempts, err)
+	}()
+}
+
+func (d *Dispatcher) send(ctx context.Context, job delivery) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.webhook.URL, bytes.NewReader(job.payload))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, job.eventType)
+	req.Header.Set(DeliveryHeader, job.eventID)
+	req.Header.Set(TimestampHeader, fmt.Sprint(timestamp))
+	req.Header.Set(SignatureHeader, Sign(job.webhook.Secret, timestamp, job.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the receiver answered %s", resp.Status)
+	}
+	return nil
+}
+
+// park moves a delivery to the dead-letter list. It also runs during
+// shutdown, so it does not use ctx for redis but the origin of the job.
+func (d *Dispatcher) park(ctx context.Context, job delivery, attempts int, cause error) {
+	parkCtx, cancel := context.WithTimeout(job.origin, parkTimeout)
+	defer cancel()
+
+	id, err := randomID(16)
+	if err != nil {
+		slog.ErrorContext(ctx, "parking a webhook delivery failed, it is lost",
+			"webhook", job.webhook.ID, "event", job.eventID, "error", err)
+		return
+	}
+	letter := storage.DeadLetter{
+		ID:        id,
+		WebhookID: job.webhook.ID,
+		EventID:   job.eventID,
+		EventType: job.eventType,
+		Payload:   job.payload,
+		Attempts:  attempts,
+		LastError: cause.Error(),
+		FailedAt:  time.Now().UTC(),
+	}
+	if err := d.r.PushDeadLetter(parkCtx, letter); err != nil {
+		slog.ErrorContext(ctx, "parking a webhook delivery failed, it is lost",
+			"webhook", job.webhook.ID, "event", job.eventID, "error", err)
+		return
+	}
+	metrics.WebhookDelivery("dead_lettered")
+}
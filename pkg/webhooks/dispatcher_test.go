@@ -0,0 +1,357 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"drexel.edu/voter-api/pkg/events"
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+const testSecret = "a secret of sixteen or more"
+
+// memRepository keeps the subscriptions and dead letters in memory
+type memRepository struct {
+	mu          sync.Mutex
+	webhooks    map[string]storage.Webhook
+	deadLetters []storage.DeadLetter
+}
+
+func (m *memRepository) SaveWebhook(ctx context.Context, w storage.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks[w.ID] = w
+	return nil
+}
+
+func (m *memRepository) GetWebhook(ctx context.Context, id string) (*storage.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+	return &w, nil
+}
+
+func (m *memRepository) ListWebhooks(ctx context.Context) ([]storage.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var all []storage.Webhook
+	for _, w := range m.webhooks {
+		all = append(all, w)
+	}
+	return all, nil
+}
+
+func (m *memRepository) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.webhooks[id]
+	delete(m.webhooks, id)
+	return ok, nil
+}
+
+func (m *memRepository) PushDeadLetter(ctx context.Context, d storage.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deadLetters = append([]storage.DeadLetter{d}, m.deadLetters...)
+	return nil
+}
+
+func (m *memRepository) ListDeadLetters(ctx context.Context) ([]storage.DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]storage.DeadLetter(nil), m.deadLetters...), nil
+}
+
+func (m *memRepository) RemoveDeadLetter(ctx context.Context, id string) (*storage.DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, d := range m.deadLetters {
+		if d.ID == id {
+			m.deadLetters = append(m.deadLetters[:i], m.deadLetters[i+1:]...)
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+// receiver is a webhook endpoint that answers with the statuses in
+// order, the last one from then on
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	times    []time.Time
+	received chan struct{}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	status := r.statuses[min(len(r.requests), len(r.statuses)-1)]
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	r.times = append(r.times, time.Now())
+	r.mu.Unlock()
+	w.WriteHeader(status)
+	r.received <- struct{}{}
+}
+
+// got returns the requests received so far with their bodies and times
+func (r *receiver) got() ([]*http.Request, [][]byte, []time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests, r.bodies, r.times
+}
+
+// setup starts a dispatcher delivering to a receiver with the statuses
+// and returns the adapter and repository along with them
+func setup(t *testing.T, options Options, statuses ...int) (*Dispatcher, Adapter, *memRepository, *receiver) {
+	t.Helper()
+	rcv := &receiver{statuses: statuses, received: make(chan struct{}, 100)}
+	server := httptest.NewServer(rcv)
+	t.Cleanup(server.Close)
+
+	//the receiver listens on loopback
+	options.AllowPrivate = true
+	repo := &memRepository{webhooks: make(map[string]storage.Webhook)}
+	d := NewDispatcher(repo, options)
+	run(t, d)
+
+	adapter := New(repo, d)
+	if _, err := adapter.Create(context.Background(), Subscription{URL: server.URL + "/hook", Secret: testSecret}); err != nil {
+		t.Fatal(err)
+	}
+	return d, adapter, repo, rcv
+}
+
+// run starts d and returns a function that stops it and waits for Run
+// to return. The test stops it at the latest when it ends.
+func run(t *testing.T, d *Dispatcher) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(stopped)
+	}()
+	stop = sync.OnceFunc(func() {
+		cancel()
+		<-stopped
+	})
+	t.Cleanup(stop)
+	return stop
+}
+
+// waitForRequests waits until the receiver got n requests
+func waitForRequests(t *testing.T, rcv *receiver, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-rcv.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the receiver got %d requests, want %d", i, n)
+		}
+	}
+}
+
+// waitForDeadLetters waits until the repository holds n dead letters
+// and returns them
+func waitForDeadLetters(t *testing.T, repo *memRepository, n int) []storage.DeadLetter {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		letters, _ := repo.ListDeadLetters(context.Background())
+		if len(letters) >= n {
+			return letters
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d dead letters, want %d", len(letters), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testEvent() []events.Event {
+	return []events.Event{{ID: "1700000000000-0", Type: storage.EventVoterCreated, VoterId: 5, Time: time.Now().UTC()}}
+}
+
+// verify checks a delivery the way a receiver would
+func verify(t *testing.T, req *http.Request, body []byte) {
+	t.Helper()
+	timestamp, err := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("the timestamp header is %q", req.Header.Get(TimestampHeader))
+	}
+	if got, want := req.Header.Get(SignatureHeader), Sign(testSecret, timestamp, body); got != want {
+		t.Errorf("the signature is %q, the secret gives %q", got, want)
+	}
+	if Sign("another secret of sixteen", timestamp, body) == req.Header.Get(SignatureHeader) {
+		t.Error("another secret gives the same signature")
+	}
+	if got := req.Header.Get(EventHeader); got != storage.EventVoterCreated {
+		t.Errorf("the event header is %q", got)
+	}
+	if got := req.Header.Get(DeliveryHeader); got != "1700000000000-0" {
+		t.Errorf("the delivery header is %q", got)
+	}
+}
+
+func TestDeliveryIsSigned(t *testing.T) {
+	d, _, repo, rcv := setup(t, Options{MaxAttempts: 1, Timeout: time.Second, Workers: 1}, http.StatusNoContent)
+
+	if err := d.Handle(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	requests, bodies, _ := rcv.got()
+	if len(requests) != 1 {
+		t.Fatalf("the receiver got %d requests", len(requests))
+	}
+	verify(t, requests[0], bodies[0])
+	if !strings.Contains(string(bodies[0]), `"voter_id":5`) {
+		t.Errorf("the body is %s", bodies[0])
+	}
+	if letters, _ := repo.ListDeadLetters(context.Background()); len(letters) != 0 {
+		t.Errorf("a delivered event was parked: %+v", letters)
+	}
+}
+
+func TestServerErrorsAreRetriedWithGrowingBackoff(t *testing.T) {
+	backoff := 20 * time.Millisecond
+	d, _, repo, rcv := setup(t, Options{MaxAttempts: 4, InitialBackoff: backoff, Timeout: time.Second, Workers: 1},
+		http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusInternalServerError, http.StatusOK)
+
+	if err := d.Handle(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	waitForRequests(t, rcv, 4)
+	requests, bodies, times := rcv.got()
+	if len(requests) != 4 {
+		t.Fatalf("the receiver got %d requests, want 4", len(requests))
+	}
+	for i := 1; i < len(times); i++ {
+		gap := times[i].Sub(times[i-1])
+		if want := backoff << (i - 1); gap < want {
+			t.Errorf("attempt %d came %v after the one before, want at least %v", i+1, gap, want)
+		}
+	}
+	for i := range requests {
+		verify(t, requests[i], bodies[i])
+	}
+	if letters, _ := repo.ListDeadLetters(context.Background()); len(letters) != 0 {
+		t.Errorf("a delivered event was parked: %+v", letters)
+	}
+}
+
+func TestPersistentFailureIsDeadLettered(t *testing.T) {
+	d, adapter, repo, rcv := setup(t, Options{MaxAttempts: 3, InitialBackoff: time.Millisecond, Timeout: time.Second, Workers: 1},
+		http.StatusInternalServerError)
+
+	if err := d.Handle(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	waitForRequests(t, rcv, 3)
+	waitForDeadLetters(t, repo, 1)
+	requests, bodies, _ := rcv.got()
+	if len(requests) != 3 {
+		t.Fatalf("the receiver got %d requests, want 3", len(requests))
+	}
+	letters, err := adapter.DeadLetters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 {
+		t.Fatalf("got %d dead letters, want 1", len(letters))
+	}
+	letter := letters[0]
+	if letter.Attempts != 3 || letter.EventID != "1700000000000-0" || letter.EventType != storage.EventVoterCreated {
+		t.Errorf("got the dead letter %+v", letter)
+	}
+	if !strings.Contains(letter.LastError, "500") {
+		t.Errorf("the last error is %q", letter.LastError)
+	}
+	if string(letter.Payload) != string(bodies[0]) {
+		t.Errorf("the dead letter holds %s, %s was sent", letter.Payload, bodies[0])
+	}
+}
+
+func TestHandleDoesNotWaitForRetries(t *testing.T) {
+	rcv := &receiver{statuses: []int{http.StatusInternalServerError}, received: make(chan struct{}, 10)}
+	server := httptest.NewServer(rcv)
+	t.Cleanup(server.Close)
+	repo := &memRepository{webhooks: map[string]storage.Webhook{
+		"w": {ID: "w", URL: server.URL + "/hook", Secret: testSecret},
+	}}
+	d := NewDispatcher(repo, Options{MaxAttempts: 3, InitialBackoff: time.Hour, Timeout: time.Second, Workers: 1, AllowPrivate: true})
+	stop := run(t, d)
+
+	handled := make(chan error, 1)
+	go func() { handled <- d.Handle(context.Background(), testEvent()) }()
+	select {
+	case err := <-handled:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handle waited for the retry")
+	}
+	if requests, _, _ := rcv.got(); len(requests) != 1 {
+		t.Fatalf("the receiver got %d requests, want 1", len(requests))
+	}
+	if letters, _ := repo.ListDeadLetters(context.Background()); len(letters) != 0 {
+		t.Fatalf("a delivery waiting for its retry was parked: %+v", letters)
+	}
+
+	//the retry that is still waiting is parked on shutdown
+	stop()
+	letters, _ := repo.ListDeadLetters(context.Background())
+	if len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Errorf("got the dead letters %+v, want the delivery after 1 attempt", letters)
+	}
+}
+
+func TestReplayDeliversAgain(t *testing.T) {
+	d, adapter, _, rcv := setup(t, Options{MaxAttempts: 1, Timeout: time.Second, Workers: 1},
+		http.StatusInternalServerError, http.StatusOK)
+
+	ctx := context.Background()
+	if err := d.Handle(ctx, testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	<-rcv.received
+	letters, err := adapter.DeadLetters(ctx)
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("got the dead letters %+v, %v", letters, err)
+	}
+
+	if err := adapter.Replay(ctx, letters[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rcv.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the replay was not delivered")
+	}
+	requests, bodies, _ := rcv.got()
+	verify(t, requests[1], bodies[1])
+	if string(bodies[1]) != string(bodies[0]) {
+		t.Errorf("the replay sent %s, the first delivery %s", bodies[1], bodies[0])
+	}
+
+	if letters, _ := adapter.DeadLetters(ctx); len(letters) != 0 {
+		t.Errorf("the replayed dead letter is still listed: %+v", letters)
+	}
+	if err := adapter.Replay(ctx, letters[0].ID); err != ErrNotFound {
+		t.Errorf("replaying it twice gave %v, want ErrNotFound", err)
+	}
+}
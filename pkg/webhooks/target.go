@@ -0,0 +1,60 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errPrivateTarget is returned when a delivery would reach an address
+// inside the network of the server
+var errPrivateTarget = errors.New("webhooks may not be delivered to private, loopback or link-local addresses")
+
+// newClient returns the client deliveries are sent with. Unless the
+// options allow it, it refuses to connect to anything but public
+// addresses. The check runs on the address that is actually dialed, so
+// it also holds for redirects and for names that resolve differently
+// from when the subscription was made.
+func newClient(options Options) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !options.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %s", errPrivateTarget, address)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	//a proxy would be dialed instead of the receiver and defeat the
+	//check above
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: options.Timeout, Transport: transport}
+}
+
+// publicIP tells whether ip is an address a subscription may reach
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// publicHost tells whether the host of a subscription url may be
+// public. Names are only refused when they are obviously local, they
+// are checked for real when they are dialed.
+func publicHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return publicIP(ip)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
@@ -0,0 +1,74 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"drexel.edu/voter-api/pkg/storage"
+)
+
+func TestValidateRefusesPrivateTargets(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://hooks.example.com/voters", true},
+		{"http://93.184.216.34/hook", true},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://localhost/hook", false},
+		{"http://api.localhost./hook", false},
+		{"http://[::1]/hook", false},
+		{"http://10.1.2.3/hook", false},
+		{"http://192.168.0.10/hook", false},
+		{"http://172.16.5.4/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			err := validate(Subscription{URL: test.url}, false)
+			if test.allowed && err != nil {
+				t.Errorf("got %v, want it allowed", err)
+			}
+			if !test.allowed && !errors.Is(err, ErrInvalid) {
+				t.Errorf("got %v, want ErrInvalid", err)
+			}
+			if err := validate(Subscription{URL: test.url}, true); err != nil {
+				t.Errorf("with private targets allowed got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeliveryRefusesToDialPrivateTargets(t *testing.T) {
+	rcv := &receiver{statuses: []int{http.StatusOK}, received: make(chan struct{}, 1)}
+	server := httptest.NewServer(rcv)
+	t.Cleanup(server.Close)
+
+	//the subscription was stored before, or its name resolved to a
+	//public address when it was made
+	repo := &memRepository{webhooks: map[string]storage.Webhook{
+		"w": {ID: "w", URL: server.URL + "/hook", Secret: testSecret},
+	}}
+	d := NewDispatcher(repo, Options{MaxAttempts: 1, Timeout: time.Second, Workers: 1})
+	run(t, d)
+
+	if err := d.Handle(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	letters := waitForDeadLetters(t, repo, 1)
+	if requests, _, _ := rcv.got(); len(requests) != 0 {
+		t.Errorf("the loopback receiver got %d requests", len(requests))
+	}
+	if !strings.Contains(letters[0].LastError, errPrivateTarget.Error()) {
+		t.Errorf("the last error is %q", letters[0].LastError)
+	}
+}